@@ -0,0 +1,187 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// msgType is the type of a message of the cluster bus.
+type msgType uint16
+
+const (
+	msgPing msgType = 0
+	msgPong msgType = 1
+	msgMeet msgType = 2
+	msgFail msgType = 3
+	// msgAuthRequest is sent by a replica asking the masters for their
+	// vote to replace its failed master, and msgAuthAck grants it.
+	msgAuthRequest msgType = 5
+	msgAuthAck     msgType = 6
+)
+
+// nodeFlags are the flags of a node, as sent in gossip and shown by
+// CLUSTER NODES.
+type nodeFlags uint16
+
+const (
+	flagMyself nodeFlags = 1 << iota
+	flagMaster
+	flagReplica
+	// flagPFail marks a node this node cannot reach, and flagFail one that
+	// enough masters could not reach to agree it failed.
+	flagPFail
+	flagFail
+	// flagHandshake marks a node met but that has not replied yet, under
+	// a random ID until it tells its own. flagMeet is set until it is sent
+	// a MEET.
+	flagHandshake
+	flagNoAddr
+	flagMeet
+)
+
+const (
+	// busSignature starts every message.
+	busSignature = "RCmb"
+	busVersion   = 1
+	// nameLen is the length of node IDs.
+	nameLen = 40
+	ipLen   = 46
+	// maxMessageLen bounds the messages read, against corrupt lengths.
+	maxMessageLen = 1 << 20
+)
+
+// slotBitmap is a set of slots, one bit each.
+type slotBitmap [Slots / 8]byte
+
+func (b *slotBitmap) set(slot int) {
+	b[slot/8] |= 1 << (slot % 8)
+}
+
+func (b *slotBitmap) has(slot int) bool {
+	return b[slot/8]&(1<<(slot%8)) != 0
+}
+
+// header starts every message, describing its sender. A replica sends the
+// slots and config epoch of its master. Integers are big endian.
+type header struct {
+	Signature    [4]byte
+	Len          uint32
+	Version      uint16
+	Port         uint16
+	Type         msgType
+	Count        uint16
+	CurrentEpoch uint64
+	ConfigEpoch  uint64
+	Offset       int64
+	Sender       [nameLen]byte
+	Slots        slotBitmap
+	ReplicaOf    [nameLen]byte
+	IP           [ipLen]byte
+	BusPort      uint16
+	Flags        nodeFlags
+}
+
+// gossip describes a node the sender knows, Count of which follow the
+// header of pings, pongs and meets. Times are in seconds.
+type gossip struct {
+	Name         [nameLen]byte
+	PingSent     uint32
+	PongReceived uint32
+	IP           [ipLen]byte
+	Port         uint16
+	BusPort      uint16
+	Flags        nodeFlags
+	_            [2]byte
+}
+
+// message is a message of the cluster bus: a header followed by gossip,
+// or for msgFail the ID of the node that failed.
+type message struct {
+	header
+	gossip  []gossip
+	failing [nameLen]byte
+}
+
+var (
+	headerLen = binary.Size(header{})
+	gossipLen = binary.Size(gossip{})
+)
+
+// encode returns m as sent on the bus.
+func (m *message) encode() []byte {
+	var buf bytes.Buffer
+	m.Signature = [4]byte([]byte(busSignature))
+	m.Version = busVersion
+	m.Count = uint16(len(m.gossip))
+	m.Len = uint32(headerLen + len(m.gossip)*gossipLen)
+	if m.Type == msgFail {
+		m.Len += nameLen
+	}
+	binary.Write(&buf, binary.BigEndian, &m.header)
+	if m.Type == msgFail {
+		buf.Write(m.failing[:])
+	}
+	for i := range m.gossip {
+		binary.Write(&buf, binary.BigEndian, &m.gossip[i])
+	}
+	return buf.Bytes()
+}
+
+// readMessage reads a message from r.
+func readMessage(r io.Reader) (*message, error) {
+	var m message
+	if err := binary.Read(r, binary.BigEndian, &m.header); err != nil {
+		return nil, err
+	}
+	if string(m.Signature[:]) != busSignature {
+		return nil, errors.New("bad signature")
+	}
+	if int(m.Len) < headerLen || m.Len > maxMessageLen {
+		return nil, fmt.Errorf("bad message length: %d bytes", m.Len)
+	}
+	body := make([]byte, int(m.Len)-headerLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	switch m.Type {
+	case msgPing, msgPong, msgMeet:
+		if len(body) != int(m.Count)*gossipLen {
+			return nil, errors.New("bad gossip length")
+		}
+		m.gossip = make([]gossip, m.Count)
+		if err := binary.Read(bytes.NewReader(body), binary.BigEndian, m.gossip); err != nil {
+			return nil, err
+		}
+	case msgFail:
+		if len(body) != nameLen {
+			return nil, errors.New("bad fail length")
+		}
+		copy(m.failing[:], body)
+	}
+	return &m, nil
+}
+
+// name returns a node ID as sent on the bus.
+func name(id string) [nameLen]byte {
+	var b [nameLen]byte
+	copy(b[:], id)
+	return b
+}
+
+// ipBytes returns an IP as sent on the bus, NUL padded.
+func ipBytes(ip string) [ipLen]byte {
+	var b [ipLen]byte
+	copy(b[:], ip)
+	return b
+}
+
+// cstring returns the string in b up to the first NUL.
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return string(b[:i])
+	}
+	return string(b)
+}
@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+	m := &message{header: header{
+		Type:         msgPing,
+		Port:         7000,
+		CurrentEpoch: 3,
+		ConfigEpoch:  2,
+		Offset:       1234,
+		Sender:       name(newNodeID()),
+		IP:           ipBytes("127.0.0.1"),
+		BusPort:      17000,
+		Flags:        flagMaster,
+	}}
+	m.Slots.set(0)
+	m.Slots.set(16383)
+	m.gossip = []gossip{{Name: name(newNodeID()), IP: ipBytes("10.0.0.1"), Port: 7001, BusPort: 17001, Flags: flagPFail}}
+	fail := &message{header: header{Type: msgFail, Sender: m.Sender}, failing: m.gossip[0].Name}
+
+	var buf bytes.Buffer
+	buf.Write(m.encode())
+	buf.Write(fail.encode())
+	got, err := readMessage(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, m, got)
+	assert.True(t, got.Slots.has(16383))
+	assert.False(t, got.Slots.has(1))
+	assert.Equal(t, "127.0.0.1", cstring(got.IP[:]))
+
+	got, err = readMessage(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, fail.failing, got.failing)
+
+	_, err = readMessage(bytes.NewReader(append([]byte("XXXX"), m.encode()[4:]...)))
+	assert.Error(t, err)
+}
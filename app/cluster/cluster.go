@@ -0,0 +1,630 @@
+// Package cluster shards the keyspace across the nodes of a Redis Cluster.
+// Each key belongs to one of the 16384 hash slots, and each slot is served
+// by one master, which the other nodes redirect clients to. Nodes talk
+// over a cluster bus to agree on which serves each slot, detect failed
+// masters and promote their replicas.
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BusPortOffset is the offset of the port of the cluster bus of a node
+// from the port it serves clients on.
+const BusPortOffset = 10000
+
+// DefaultNodeTimeout is the default cluster-node-timeout.
+const DefaultNodeTimeout = 15 * time.Second
+
+var (
+	// ErrClusterDown is returned for keys while some slot is not served.
+	ErrClusterDown = errors.New("The cluster is down")
+	// ErrSlotNotServed is returned for the keys of a slot no node serves.
+	ErrSlotNotServed = errors.New("Hash slot not served")
+	// ErrCrossSlot is returned for requests with keys in different slots.
+	ErrCrossSlot = errors.New("Keys in request don't hash to the same slot")
+)
+
+// MovedError is returned for the keys of a slot another node serves, at
+// Addr.
+type MovedError struct {
+	Slot int
+	Addr string
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("%d %s", e.Slot, e.Addr)
+}
+
+// Replication is the replication of the data of this node, which follows
+// the master the cluster makes it a replica of.
+type Replication interface {
+	ReplicaOf(host string, port string) bool
+	ReplicaOfNoOne()
+	Offset() int64
+}
+
+// Options are the settings of the cluster. NodeTimeout, in nanoseconds, is
+// cluster-node-timeout: how long a node may not reply before it is
+// considered failing.
+type Options struct {
+	NodeTimeout atomic.Int64
+}
+
+// node is a node of the cluster. A replica has a master.
+type node struct {
+	id          string
+	ip          string
+	port        int
+	busPort     int
+	flags       nodeFlags
+	master      *node
+	configEpoch uint64
+	// offset is the replication offset the node last reported.
+	offset int64
+	link   *link
+	// connecting is set while a link to the node is being opened.
+	connecting bool
+	created    time.Time
+	// pingSent is when the node was sent a ping it has not replied to
+	// yet, and pongReceived when it last replied.
+	pingSent     time.Time
+	pongReceived time.Time
+	// dataReceived is when the link to the node last received anything.
+	dataReceived time.Time
+	failTime     time.Time
+	// votedTime is when this node last voted for a replica of the node.
+	votedTime time.Time
+	// failReports are the masters that reported the node failing, and
+	// when they last did.
+	failReports map[*node]time.Time
+}
+
+func (n *node) addr() string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(n.port))
+}
+
+func (n *node) busAddr() string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(n.busPort))
+}
+
+// Cluster is the view of the cluster from one of its nodes, myself: the
+// nodes it knows and which serves each slot.
+type Cluster struct {
+	mu      sync.Mutex
+	repl    Replication
+	options Options
+	myself  *node
+	nodes   map[string]*node
+	slots   [Slots]*node
+	// migrating are the nodes slots this node serves are migrated to, and
+	// importing the nodes slots are imported from.
+	migrating [Slots]*node
+	importing [Slots]*node
+	// currentEpoch is the greatest epoch known in the cluster, and
+	// lastVoteEpoch the epoch this node last voted in.
+	currentEpoch  uint64
+	lastVoteEpoch uint64
+	election      election
+	// configFile is the path of nodes.conf, and dirty is set while it is
+	// out of date.
+	configFile string
+	dirty      bool
+
+	listener net.Listener
+	inbound  map[net.Conn]struct{}
+	done     chan struct{}
+	closed   bool
+}
+
+// New returns a cluster of a single node, serving clients at ip and port,
+// that serves no slot. repl is the replication of its data.
+func New(ip string, port int, repl Replication) *Cluster {
+	myself := &node{
+		id:      newNodeID(),
+		ip:      ip,
+		port:    port,
+		busPort: port + BusPortOffset,
+		flags:   flagMyself,
+		created: time.Now(),
+	}
+	c := &Cluster{
+		repl:    repl,
+		myself:  myself,
+		nodes:   map[string]*node{myself.id: myself},
+		inbound: make(map[net.Conn]struct{}),
+		done:    make(chan struct{}),
+	}
+	c.options.NodeTimeout.Store(int64(DefaultNodeTimeout))
+	return c
+}
+
+// newNodeID returns a random node ID of 40 hex digits.
+func newNodeID() string {
+	var b [nameLen / 2]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Options returns the settings of the cluster.
+func (c *Cluster) Options() *Options {
+	return &c.options
+}
+
+func (c *Cluster) nodeTimeout() time.Duration {
+	return time.Duration(c.options.NodeTimeout.Load())
+}
+
+// MyID returns the ID of this node.
+func (c *Cluster) MyID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.myself.id
+}
+
+// stateOK reports whether every slot is served by a master that did not
+// fail. The caller must hold c.mu.
+func (c *Cluster) stateOK() bool {
+	for _, owner := range c.slots {
+		if owner == nil || owner.flags&flagFail != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AddSlots makes this node serve slots, which must be unassigned.
+func (c *Cluster) AddSlots(slots []int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := checkSlots(slots); err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if c.slots[slot] != nil {
+			return fmt.Errorf("Slot %d is already busy", slot)
+		}
+	}
+	for _, slot := range slots {
+		c.slots[slot] = c.myself
+	}
+	c.changed()
+	c.saveConfig()
+	return nil
+}
+
+// DelSlots makes slots unassigned. The node that served them no longer
+// does, as far as this node knows.
+func (c *Cluster) DelSlots(slots []int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := checkSlots(slots); err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if c.slots[slot] == nil {
+			return fmt.Errorf("Slot %d is already unassigned", slot)
+		}
+	}
+	for _, slot := range slots {
+		c.slots[slot] = nil
+	}
+	c.changed()
+	c.saveConfig()
+	return nil
+}
+
+// checkSlots fails if slots holds a slot twice.
+func checkSlots(slots []int) error {
+	seen := make(map[int]bool, len(slots))
+	for _, slot := range slots {
+		if seen[slot] {
+			return fmt.Errorf("Slot %d specified multiple times", slot)
+		}
+		seen[slot] = true
+	}
+	return nil
+}
+
+// slotCount returns the number of slots n serves. The caller must hold
+// c.mu.
+func (c *Cluster) slotCount(n *node) int {
+	count := 0
+	for _, owner := range c.slots {
+		if owner == n {
+			count++
+		}
+	}
+	return count
+}
+
+// Meet adds the node with its cluster bus at ip and busPort, serving
+// clients at port, to the cluster: this node introduces itself with a
+// MEET, and the others learn about it through gossip.
+func (c *Cluster) Meet(ip string, port int, busPort int) error {
+	if net.ParseIP(ip) == nil || port <= 0 || port > 65535 || busPort <= 0 || busPort > 65535 {
+		return fmt.Errorf("Invalid node address specified: %s:%d", ip, port)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startHandshake(ip, port, busPort, true)
+	return nil
+}
+
+// startHandshake adds a node at ip under a random ID until it replies,
+// unless a handshake with it is already in progress. The caller must hold
+// c.mu.
+func (c *Cluster) startHandshake(ip string, port int, busPort int, meet bool) {
+	for _, n := range c.nodes {
+		if n.flags&flagHandshake != 0 && n.ip == ip && n.port == port && n.busPort == busPort {
+			return
+		}
+	}
+	flags := flagHandshake
+	if meet {
+		flags |= flagMeet
+	}
+	n := &node{id: newNodeID(), ip: ip, port: port, busPort: busPort, flags: flags, created: time.Now()}
+	c.nodes[n.id] = n
+}
+
+// Replicate makes this node a replica of the master with ID id. It must
+// serve no slots.
+func (c *Cluster) Replicate(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[id]
+	if !ok || n.flags&flagHandshake != 0 {
+		return fmt.Errorf("Unknown node %s", id)
+	}
+	if n == c.myself {
+		return errors.New("Can't replicate myself")
+	}
+	if n.master != nil {
+		return errors.New("I can only replicate a master, not a replica.")
+	}
+	if c.myself.master == nil && c.slotCount(c.myself) != 0 {
+		return errors.New("To set a master the node must be empty and without assigned slots.")
+	}
+	c.setMaster(n)
+	c.saveConfig()
+	return nil
+}
+
+// setMaster makes this node a replica of n, replicating its data and
+// dropping its slots and their migrations. The caller must hold c.mu.
+func (c *Cluster) setMaster(n *node) {
+	for slot, owner := range c.slots {
+		if owner == c.myself {
+			c.slots[slot] = nil
+		}
+	}
+	c.migrating, c.importing = [Slots]*node{}, [Slots]*node{}
+	c.myself.master = n
+	c.election = election{}
+	c.repl.ReplicaOf(n.ip, strconv.Itoa(n.port))
+	c.changed()
+}
+
+// CountFailureReports returns the number of masters that currently report
+// the node with ID id failing.
+func (c *Cluster) CountFailureReports(id string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[id]
+	if !ok {
+		return 0, fmt.Errorf("Unknown node %s", id)
+	}
+	return c.failureReports(n), nil
+}
+
+// ParseSlot parses a slot number.
+func ParseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(s)
+	if err != nil || slot < 0 || slot >= Slots {
+		return 0, errors.New("Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+// ParseSlots parses a list of slots and ranges of slots separated by
+// spaces, such as "0-5460 7000".
+func ParseSlots(s string) ([]int, error) {
+	var slots []int
+	for _, field := range strings.Fields(s) {
+		from, to, isRange := strings.Cut(field, "-")
+		start, err := ParseSlot(from)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = ParseSlot(to); err != nil {
+				return nil, err
+			}
+		}
+		if start > end {
+			return nil, fmt.Errorf("start slot number %d is greater than end slot number %d", start, end)
+		}
+		for slot := start; slot <= end; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// SlotRange is the slots from Start to End included.
+type SlotRange struct {
+	Start int
+	End   int
+}
+
+func (r SlotRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// NodeInfo describes a node of the cluster. MasterID is empty for masters.
+// Failed is set when this node considers it failing, or knows it failed.
+// Migrating and Importing are only set for this node.
+type NodeInfo struct {
+	ID           string
+	IP           string
+	Port         int
+	BusPort      int
+	Myself       bool
+	MasterID     string
+	Flags        []string
+	PingSent     time.Time
+	PongReceived time.Time
+	ConfigEpoch  uint64
+	Connected    bool
+	Failed       bool
+	Offset       int64
+	Slots        []SlotRange
+	Migrating    []SlotTransfer
+	Importing    []SlotTransfer
+}
+
+// Addr returns the address the node serves clients at.
+func (n NodeInfo) Addr() string {
+	return net.JoinHostPort(n.IP, strconv.Itoa(n.Port))
+}
+
+// String describes the node as a line of CLUSTER NODES and nodes.conf.
+func (n NodeInfo) String() string {
+	master, link := "-", "disconnected"
+	if n.MasterID != "" {
+		master = n.MasterID
+	}
+	if n.Connected {
+		link = "connected"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s@%d %s %s %d %d %d %s",
+		n.ID, n.Addr(), n.BusPort, strings.Join(n.Flags, ","), master,
+		unixMilli(n.PingSent), unixMilli(n.PongReceived), n.ConfigEpoch, link)
+	for _, r := range n.Slots {
+		b.WriteString(" " + r.String())
+	}
+	for _, t := range n.Migrating {
+		fmt.Fprintf(&b, " [%d->-%s]", t.Slot, t.NodeID)
+	}
+	for _, t := range n.Importing {
+		fmt.Fprintf(&b, " [%d-<-%s]", t.Slot, t.NodeID)
+	}
+	return b.String()
+}
+
+// unixMilli returns t in milliseconds since the epoch, or 0 if it is zero.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// Nodes returns the nodes known, this node first and then by ID.
+func (c *Cluster) Nodes() []NodeInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodeInfos()
+}
+
+// Replicas returns the replicas of the master with ID id.
+func (c *Cluster) Replicas(id string) ([]NodeInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown node %s", id)
+	}
+	if n.master != nil {
+		return nil, errors.New("The specified node is not a master")
+	}
+	var replicas []NodeInfo
+	for _, info := range c.nodeInfos() {
+		if info.MasterID == id {
+			replicas = append(replicas, info)
+		}
+	}
+	return replicas, nil
+}
+
+// nodeInfos returns the nodes known, this node first and then by ID. The
+// caller must hold c.mu.
+func (c *Cluster) nodeInfos() []NodeInfo {
+	ranges := c.slotRanges()
+	nodes := make([]NodeInfo, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		info := NodeInfo{
+			ID:           n.id,
+			IP:           n.ip,
+			Port:         n.port,
+			BusPort:      n.busPort,
+			Myself:       n == c.myself,
+			Flags:        c.flagNames(n),
+			PingSent:     n.pingSent,
+			PongReceived: n.pongReceived,
+			ConfigEpoch:  n.configEpoch,
+			Connected:    n == c.myself || n.link != nil,
+			Failed:       n.flags&(flagPFail|flagFail) != 0,
+			Offset:       n.offset,
+		}
+		if n == c.myself {
+			info.Offset = c.repl.Offset()
+			info.Migrating = slotTransfers(&c.migrating)
+			info.Importing = slotTransfers(&c.importing)
+		}
+		if n.master != nil {
+			info.MasterID = n.master.id
+			info.ConfigEpoch = n.master.configEpoch
+		}
+		for _, r := range ranges {
+			if c.slots[r.Start] == n {
+				info.Slots = append(info.Slots, r)
+			}
+		}
+		nodes = append(nodes, info)
+	}
+	slices.SortFunc(nodes, func(a, b NodeInfo) int {
+		if a.Myself != b.Myself {
+			if a.Myself {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return nodes
+}
+
+// flagsOf returns the flags of n as sent in gossip. The caller must hold
+// c.mu.
+func (c *Cluster) flagsOf(n *node) nodeFlags {
+	flags := n.flags &^ flagMeet
+	if n.master != nil {
+		flags |= flagReplica
+	} else if n.flags&flagHandshake == 0 {
+		flags |= flagMaster
+	}
+	return flags
+}
+
+// flagNames returns the flags of n as shown by CLUSTER NODES. The caller
+// must hold c.mu.
+func (c *Cluster) flagNames(n *node) []string {
+	flags := c.flagsOf(n)
+	var names []string
+	for _, flag := range []struct {
+		flag nodeFlags
+		name string
+	}{
+		{flagMyself, "myself"},
+		{flagMaster, "master"},
+		{flagReplica, "slave"},
+		{flagPFail, "fail?"},
+		{flagFail, "fail"},
+		{flagHandshake, "handshake"},
+		{flagNoAddr, "noaddr"},
+	} {
+		if flags&flag.flag != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{"noflags"}
+	}
+	return names
+}
+
+// slotRanges returns the ranges of consecutive slots served by the same
+// node, in order. The caller must hold c.mu.
+func (c *Cluster) slotRanges() []SlotRange {
+	var ranges []SlotRange
+	for slot := 0; slot < Slots; {
+		owner := c.slots[slot]
+		end := slot
+		for end+1 < Slots && c.slots[end+1] == owner {
+			end++
+		}
+		if owner != nil {
+			ranges = append(ranges, SlotRange{Start: slot, End: end})
+		}
+		slot = end + 1
+	}
+	return ranges
+}
+
+// Info is the state of the cluster reported by CLUSTER INFO. SlotsPFail and
+// SlotsFail count the slots served by masters failing and failed.
+type Info struct {
+	OK            bool
+	SlotsAssigned int
+	SlotsPFail    int
+	SlotsFail     int
+	KnownNodes    int
+	// Size is the number of masters serving slots.
+	Size         int
+	CurrentEpoch uint64
+	MyEpoch      uint64
+}
+
+// Info returns the state of the cluster.
+func (c *Cluster) Info() Info {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info := Info{
+		OK:           c.stateOK(),
+		KnownNodes:   len(c.nodes),
+		Size:         c.size(),
+		CurrentEpoch: c.currentEpoch,
+		MyEpoch:      c.myself.configEpoch,
+	}
+	if c.myself.master != nil {
+		info.MyEpoch = c.myself.master.configEpoch
+	}
+	for _, owner := range c.slots {
+		if owner == nil {
+			continue
+		}
+		info.SlotsAssigned++
+		switch {
+		case owner.flags&flagFail != 0:
+			info.SlotsFail++
+		case owner.flags&flagPFail != 0:
+			info.SlotsPFail++
+		}
+	}
+	return info
+}
+
+// size returns the number of masters serving slots. The caller must hold
+// c.mu.
+func (c *Cluster) size() int {
+	serving := make(map[*node]bool)
+	for _, owner := range c.slots {
+		if owner != nil {
+			serving[owner] = true
+		}
+	}
+	return len(serving)
+}
+
+// quorum returns the number of masters serving slots that must agree to
+// mark a node failed or to elect a replica. The caller must hold c.mu.
+func (c *Cluster) quorum() int {
+	return c.size()/2 + 1
+}
@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addNode adds a master known to c, as if met.
+func addNode(c *Cluster, id string, port int) *node {
+	n := &node{id: id, ip: "127.0.0.1", port: port, busPort: port + BusPortOffset}
+	c.nodes[id] = n
+	return n
+}
+
+func TestRoute(t *testing.T) {
+	t.Parallel()
+	c := New("127.0.0.1", 7000, &fakeReplication{})
+	assert.ErrorIs(t, c.Route(0, nil, false, nil), ErrClusterDown)
+
+	require.NoError(t, c.AddSlots([]int{0, 1, 2}))
+	assert.EqualError(t, c.AddSlots([]int{3, 2}), "Slot 2 is already busy")
+	assert.EqualError(t, c.AddSlots([]int{4, 4}), "Slot 4 specified multiple times")
+	assert.ErrorIs(t, c.Route(0, nil, false, nil), ErrClusterDown, "not all slots are served")
+
+	other := addNode(c, "other", 7001)
+	for slot := 3; slot < Slots; slot++ {
+		c.slots[slot] = other
+	}
+	assert.NoError(t, c.Route(1, nil, false, nil))
+	var moved *MovedError
+	require.True(t, errors.As(c.Route(3, nil, false, nil), &moved))
+	assert.Equal(t, "3 127.0.0.1:7001", moved.Error())
+
+	require.NoError(t, c.DelSlots([]int{1}))
+	assert.EqualError(t, c.DelSlots([]int{1}), "Slot 1 is already unassigned")
+	assert.ErrorIs(t, c.Route(0, nil, false, nil), ErrClusterDown)
+}
+
+func TestParseSlots(t *testing.T) {
+	t.Parallel()
+	slots, err := ParseSlots("0-2 7 16383")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 7, 16383}, slots)
+
+	_, err = ParseSlots("16384")
+	assert.Error(t, err)
+	_, err = ParseSlots("5-2")
+	assert.Error(t, err)
+}
+
+func TestNodes(t *testing.T) {
+	t.Parallel()
+	c := New("127.0.0.1", 7000, &fakeReplication{})
+	require.NoError(t, c.AddSlots([]int{0, 1, 2, 5}))
+	c.slots[3] = addNode(c, "other", 7001)
+
+	nodes := c.Nodes()
+	require.Len(t, nodes, 2)
+	assert.True(t, nodes[0].Myself)
+	assert.Equal(t, 17000, nodes[0].BusPort)
+	assert.Equal(t, []SlotRange{{0, 2}, {5, 5}}, nodes[0].Slots)
+	assert.Equal(t, "other", nodes[1].ID)
+	assert.Equal(t, []SlotRange{{3, 3}}, nodes[1].Slots)
+
+	info := c.Info()
+	assert.False(t, info.OK)
+	assert.Equal(t, 5, info.SlotsAssigned)
+	assert.Equal(t, 2, info.KnownNodes)
+	assert.Equal(t, 2, info.Size)
+}
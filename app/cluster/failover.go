@@ -0,0 +1,150 @@
+package cluster
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// electionDelay, plus up to as much again at random, is how long a
+	// replica waits after its master failed before asking for votes, so
+	// that the failure spreads first. Replicas wait electionRankDelay more
+	// for each replica of the same master further along the replication
+	// stream, so that the most up to date is likely to win.
+	electionDelay     = 500 * time.Millisecond
+	electionRankDelay = time.Second
+	// minAuthTimeout is the least a replica waits for votes.
+	minAuthTimeout = 2 * time.Second
+)
+
+// election is the state of the election of this node to replace its
+// failed master.
+type election struct {
+	// authTime is when the replica asks for votes, or zero if no election
+	// is scheduled.
+	authTime time.Time
+	authSent bool
+	// authEpoch is the epoch the votes were asked in, and authCount the
+	// number of votes received.
+	authEpoch uint64
+	authCount int
+}
+
+// authTimeout returns how long a replica waits for votes before giving up,
+// retrying after twice as long. The caller must hold c.mu.
+func (c *Cluster) authTimeout() time.Duration {
+	return max(2*c.nodeTimeout(), minAuthTimeout)
+}
+
+// handleFailover runs the election of this node, a replica, if its master
+// failed: after a delay it asks the masters for votes in a new epoch, and
+// once a quorum of them voted for it, it takes over the slots of its
+// master. The caller must hold c.mu.
+func (c *Cluster) handleFailover(now time.Time) {
+	master := c.myself.master
+	if master.flags&flagFail == 0 || c.slotCount(master) == 0 {
+		return
+	}
+	e := &c.election
+	timeout := c.authTimeout()
+	if e.authTime.IsZero() || now.Sub(e.authTime) > 2*timeout {
+		delay := electionDelay + rand.N(electionDelay) + time.Duration(c.rank())*electionRankDelay
+		*e = election{authTime: now.Add(delay)}
+		c.logf("start of election delayed for %v", delay)
+		return
+	}
+	if now.Before(e.authTime) || now.Sub(e.authTime) > timeout {
+		return
+	}
+	if !e.authSent {
+		c.currentEpoch++
+		e.authEpoch = c.currentEpoch
+		e.authSent = true
+		c.broadcast(c.message(msgAuthRequest).encode())
+		c.changed()
+		c.logf("asking for votes in epoch %d", e.authEpoch)
+		return
+	}
+	if e.authCount >= c.quorum() {
+		c.promote(master)
+	}
+}
+
+// rank returns the number of other replicas of this node's master that
+// are further along its replication stream. The caller must hold c.mu.
+func (c *Cluster) rank() int {
+	offset := c.repl.Offset()
+	rank := 0
+	for _, n := range c.nodes {
+		if n != c.myself && n.master == c.myself.master && n.offset > offset {
+			rank++
+		}
+	}
+	return rank
+}
+
+// vote returns the vote of this node for sender, a replica asking to
+// replace its master in a new epoch, or nil if it does not vote for it. A
+// master votes once per epoch, only for the replicas of a failed master,
+// and once per two node timeouts for the replicas of the same master. It
+// refuses replicas claiming slots it knows a newer master of. The caller
+// must hold c.mu.
+func (c *Cluster) vote(sender *node, m *message, now time.Time) []byte {
+	if c.myself.master != nil || c.slotCount(c.myself) == 0 {
+		return nil
+	}
+	if m.CurrentEpoch < c.currentEpoch || c.lastVoteEpoch == c.currentEpoch {
+		return nil
+	}
+	master := sender.master
+	if master == nil || master.flags&flagFail == 0 {
+		return nil
+	}
+	if now.Sub(master.votedTime) < 2*c.nodeTimeout() {
+		return nil
+	}
+	for slot := range Slots {
+		if owner := c.slots[slot]; m.Slots.has(slot) && owner != nil && owner.configEpoch > m.ConfigEpoch {
+			return nil
+		}
+	}
+	c.lastVoteEpoch = c.currentEpoch
+	master.votedTime = now
+	c.changed()
+	c.logf("voted for %s in epoch %d", sender.id, c.currentEpoch)
+	return c.message(msgAuthAck).encode()
+}
+
+// receiveVote counts the vote of sender for this node in the current
+// election. The caller must hold c.mu.
+func (c *Cluster) receiveVote(sender *node, m *message) {
+	e := &c.election
+	if !e.authSent || sender.master != nil || c.slotCount(sender) == 0 || m.CurrentEpoch < e.authEpoch {
+		return
+	}
+	e.authCount++
+}
+
+// promote makes this node the master serving the slots of its failed
+// master, with the epoch it was elected in, and tells the other nodes.
+// The caller must hold c.mu.
+func (c *Cluster) promote(master *node) {
+	for slot, owner := range c.slots {
+		if owner == master {
+			c.slots[slot] = c.myself
+		}
+	}
+	c.myself.master = nil
+	if c.myself.configEpoch < c.election.authEpoch {
+		c.myself.configEpoch = c.election.authEpoch
+	}
+	c.election = election{}
+	c.repl.ReplicaOfNoOne()
+	for _, n := range c.nodes {
+		if n != c.myself && n.flags&flagHandshake == 0 {
+			c.ping(n, msgPong)
+		}
+	}
+	c.changed()
+	c.logf("failover of %s: serving its slots in epoch %d", master.id, c.myself.configEpoch)
+}
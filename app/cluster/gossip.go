@@ -0,0 +1,676 @@
+package cluster
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+const (
+	// cronPeriod is how often a node pings and checks the others.
+	cronPeriod = 100 * time.Millisecond
+	// randomPingPeriod is how often a node pings one of the others it
+	// has not heard from for the longest, whatever the timeout.
+	randomPingPeriod = time.Second
+	// failReportValidity is how long a report of a failing node counts
+	// toward marking it failed, in node timeouts.
+	failReportValidity = 2
+	// failUndoTime is how long after a master failed it is considered
+	// back when it replies while still serving its slots, in node
+	// timeouts: no replica took over.
+	failUndoTime = 2
+	// linkQueueLen is the number of messages queued on a link before
+	// more are dropped.
+	linkQueueLen = 64
+)
+
+// link is the connection on which this node pings another and reads its
+// replies. Messages are queued on out and written in order.
+type link struct {
+	conn net.Conn
+	out  chan []byte
+}
+
+// Serve runs the cluster bus on l until Close: it accepts the connections
+// of the other nodes, and every cronPeriod connects to the nodes it knows,
+// pings them and detects failures. The bus port of this node becomes that
+// of l.
+func (c *Cluster) Serve(l net.Listener) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	c.listener = l
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		c.myself.busPort = addr.Port
+	}
+	c.mu.Unlock()
+
+	go c.cron()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-c.done:
+				return nil
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go c.serveConn(conn)
+	}
+}
+
+// Close stops the cluster bus, closing its connections.
+func (c *Cluster) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	for _, n := range c.nodes {
+		c.freeLink(n)
+	}
+	for conn := range c.inbound {
+		conn.Close()
+	}
+	if c.listener != nil {
+		return c.listener.Close()
+	}
+	return nil
+}
+
+// serveConn reads the messages of another node on a connection it opened,
+// replying to those that want a reply.
+func (c *Cluster) serveConn(conn net.Conn) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c.inbound[conn] = struct{}{}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.inbound, conn)
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		m, err := readMessage(reader)
+		if err != nil {
+			return
+		}
+		reply := c.process(m, nil)
+		if reply == nil {
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(c.nodeTimeout()))
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// connect opens a link to n and starts pinging it.
+func (c *Cluster) connect(n *node) {
+	conn, err := net.DialTimeout("tcp", n.busAddr(), c.nodeTimeout())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n.connecting = false
+	if err != nil {
+		// A node that cannot be reached is as good as one that does not
+		// reply.
+		if n.pingSent.IsZero() {
+			n.pingSent = time.Now()
+		}
+		return
+	}
+	if c.closed || c.nodes[n.id] != n {
+		conn.Close()
+		return
+	}
+	l := &link{conn: conn, out: make(chan []byte, linkQueueLen)}
+	n.link = l
+	n.dataReceived = time.Now()
+	go c.writeLink(l)
+	go c.readLink(n, l)
+	if n.flags&flagMeet != 0 {
+		n.flags &^= flagMeet
+		c.ping(n, msgMeet)
+	} else {
+		c.ping(n, msgPing)
+	}
+}
+
+// writeLink writes the messages queued on l until it is freed.
+func (c *Cluster) writeLink(l *link) {
+	for b := range l.out {
+		l.conn.SetWriteDeadline(time.Now().Add(c.nodeTimeout()))
+		if _, err := l.conn.Write(b); err != nil {
+			l.conn.Close()
+		}
+	}
+}
+
+// readLink reads the replies of n on l until it breaks, then frees it so
+// that the next cron reconnects.
+func (c *Cluster) readLink(n *node, l *link) {
+	reader := bufio.NewReader(l.conn)
+	for {
+		m, err := readMessage(reader)
+		if err != nil {
+			break
+		}
+		c.process(m, n)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n.link == l {
+		c.freeLink(n)
+	}
+}
+
+// freeLink closes the link to n, if any. The caller must hold c.mu.
+func (c *Cluster) freeLink(n *node) {
+	if n.link == nil {
+		return
+	}
+	n.link.conn.Close()
+	close(n.link.out)
+	n.link = nil
+}
+
+// send queues b on the link to n, dropping it if the link is down or
+// backed up. The caller must hold c.mu.
+func (c *Cluster) send(n *node, b []byte) {
+	if n.link == nil {
+		return
+	}
+	select {
+	case n.link.out <- b:
+	default:
+	}
+}
+
+// broadcast sends b to every node linked to. The caller must hold c.mu.
+func (c *Cluster) broadcast(b []byte) {
+	for _, n := range c.nodes {
+		if n != c.myself && n.flags&flagHandshake == 0 {
+			c.send(n, b)
+		}
+	}
+}
+
+// message returns a message of type typ from this node. The caller must
+// hold c.mu.
+func (c *Cluster) message(typ msgType) *message {
+	m := &message{header: header{
+		Type:         typ,
+		Port:         uint16(c.myself.port),
+		CurrentEpoch: c.currentEpoch,
+		ConfigEpoch:  c.myself.configEpoch,
+		Offset:       c.repl.Offset(),
+		Sender:       name(c.myself.id),
+		IP:           ipBytes(c.myself.ip),
+		BusPort:      uint16(c.myself.busPort),
+		Flags:        c.flagsOf(c.myself),
+	}}
+	owner := c.myself
+	if master := c.myself.master; master != nil {
+		owner = master
+		m.ReplicaOf = name(master.id)
+		m.ConfigEpoch = master.configEpoch
+	}
+	for slot, n := range c.slots {
+		if n == owner {
+			m.Slots.set(slot)
+		}
+	}
+	return m
+}
+
+// ping sends n a ping, a pong or a meet, with gossip about some of the
+// other nodes and all of those failing. The caller must hold c.mu.
+func (c *Cluster) ping(n *node, typ msgType) {
+	m := c.message(typ)
+	m.gossip = c.gossipFor(n)
+	c.send(n, m.encode())
+	if typ != msgPong && n.pingSent.IsZero() {
+		n.pingSent = time.Now()
+	}
+}
+
+// gossipFor returns the gossip sent to n: about a tenth of the nodes, at
+// least 3, picked at random, and all of those failing. The caller must
+// hold c.mu.
+func (c *Cluster) gossipFor(n *node) []gossip {
+	var candidates, failing []*node
+	for _, other := range c.nodes {
+		if other == c.myself || other == n || other.flags&(flagHandshake|flagNoAddr) != 0 {
+			continue
+		}
+		if other.flags&flagPFail != 0 {
+			failing = append(failing, other)
+			continue
+		}
+		if other.link == nil && c.slotCount(other) == 0 {
+			continue
+		}
+		candidates = append(candidates, other)
+	}
+	wanted := max(3, len(c.nodes)/10)
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > wanted {
+		candidates = candidates[:wanted]
+	}
+	var entries []gossip
+	for _, other := range append(candidates, failing...) {
+		entries = append(entries, gossip{
+			Name:         name(other.id),
+			PingSent:     uint32(unixMilli(other.pingSent) / 1000),
+			PongReceived: uint32(unixMilli(other.pongReceived) / 1000),
+			IP:           ipBytes(other.ip),
+			Port:         uint16(other.port),
+			BusPort:      uint16(other.busPort),
+			Flags:        c.flagsOf(other),
+		})
+	}
+	return entries
+}
+
+// process handles a message received on the link to from, or from a node
+// that connected to this one if from is nil, and returns the reply to
+// send back, if any.
+func (c *Cluster) process(m *message, from *node) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.saveConfig()
+	if c.closed {
+		return nil
+	}
+	now := time.Now()
+	if from != nil {
+		from.dataReceived = now
+	}
+
+	senderID := cstring(m.Sender[:])
+	sender := c.nodes[senderID]
+	if sender != nil && sender.flags&flagHandshake != 0 {
+		sender = nil
+	}
+	if sender != nil {
+		if m.CurrentEpoch > c.currentEpoch {
+			c.currentEpoch = m.CurrentEpoch
+			c.changed()
+		}
+		if m.ReplicaOf == [nameLen]byte{} && m.ConfigEpoch > sender.configEpoch {
+			sender.configEpoch = m.ConfigEpoch
+			c.changed()
+		}
+		sender.offset = m.Offset
+	}
+
+	var reply []byte
+	switch m.Type {
+	case msgMeet:
+		if sender == nil {
+			// Nodes join the cluster by being met; others are only
+			// learned about through gossip from nodes already in it.
+			sender = &node{
+				id:      senderID,
+				ip:      cstring(m.IP[:]),
+				port:    int(m.Port),
+				busPort: int(m.BusPort),
+				created: now,
+			}
+			c.nodes[senderID] = sender
+			c.changed()
+		}
+		fallthrough
+	case msgPing:
+		pong := c.message(msgPong)
+		pong.gossip = c.gossipFor(sender)
+		reply = pong.encode()
+	case msgPong:
+		if from == nil {
+			break
+		}
+		if from.flags&flagHandshake != 0 {
+			if sender != nil || senderID == c.myself.id {
+				// The node was already known under its ID.
+				c.deleteNode(from)
+				return nil
+			}
+			delete(c.nodes, from.id)
+			from.id = senderID
+			from.flags &^= flagHandshake
+			c.nodes[from.id] = from
+			sender = from
+			c.changed()
+		}
+		from.pingSent = time.Time{}
+		from.pongReceived = now
+		from.flags &^= flagPFail
+		c.clearFailure(from, now)
+	case msgFail:
+		failing := c.nodes[cstring(m.failing[:])]
+		if sender != nil && failing != nil && failing != c.myself && failing.flags&flagFail == 0 {
+			failing.flags = failing.flags&^flagPFail | flagFail
+			failing.failTime = now
+			c.changed()
+		}
+	case msgAuthRequest:
+		if sender != nil {
+			reply = c.vote(sender, m, now)
+		}
+	case msgAuthAck:
+		if sender != nil {
+			c.receiveVote(sender, m)
+		}
+	}
+
+	if sender != nil && (m.Type == msgPing || m.Type == msgPong || m.Type == msgMeet) {
+		c.updateRole(sender, m)
+		if sender.master == nil {
+			c.updateSlots(sender, m.ConfigEpoch, &m.Slots)
+			c.handleEpochCollision(sender)
+		}
+		c.processGossip(sender, m.gossip, now)
+	}
+	return reply
+}
+
+// updateRole records sender as the master or replica it says it is. The
+// caller must hold c.mu.
+func (c *Cluster) updateRole(sender *node, m *message) {
+	if m.ReplicaOf == [nameLen]byte{} {
+		if sender.master != nil {
+			// The replica was promoted.
+			sender.master = nil
+			c.changed()
+		}
+		return
+	}
+	master := c.nodes[cstring(m.ReplicaOf[:])]
+	if master == nil || master == sender.master || master.flags&flagHandshake != 0 {
+		return
+	}
+	if sender.master == nil {
+		// A master turned replica serves no slots anymore.
+		for slot, owner := range c.slots {
+			if owner == sender {
+				c.slots[slot] = nil
+			}
+		}
+	}
+	sender.master = master
+	c.changed()
+}
+
+// updateSlots gives sender, a master with config epoch epoch, the slots it
+// claims that are unassigned or served by a master with an older config
+// epoch, except those this node is importing, which it is told about. If that leaves this node's master, or this node itself, without
+// slots, this node becomes a replica of sender: it was failed over. The
+// caller must hold c.mu.
+func (c *Cluster) updateSlots(sender *node, epoch uint64, slots *slotBitmap) {
+	current := c.myself
+	if c.myself.master != nil {
+		current = c.myself.master
+	}
+	if sender == current {
+		return
+	}
+	lost := false
+	for slot := range Slots {
+		if !slots.has(slot) || c.importing[slot] != nil {
+			continue
+		}
+		owner := c.slots[slot]
+		if owner == sender || (owner != nil && owner.configEpoch >= epoch) {
+			continue
+		}
+		if owner == current {
+			lost = true
+		}
+		c.slots[slot] = sender
+		c.changed()
+	}
+	if lost && c.slotCount(current) == 0 {
+		c.setMaster(sender)
+	}
+}
+
+// handleEpochCollision gives this node a new config epoch if sender, a
+// master, has the same one, so that masters keep distinct epochs. Of the
+// two, the node with the smaller ID moves. The caller must hold c.mu.
+func (c *Cluster) handleEpochCollision(sender *node) {
+	if c.myself.master != nil || sender.configEpoch != c.myself.configEpoch || sender.id <= c.myself.id {
+		return
+	}
+	c.currentEpoch++
+	c.myself.configEpoch = c.currentEpoch
+	c.changed()
+}
+
+// processGossip handles what sender, a node of the cluster, says about
+// others: failure reports of masters, and nodes this node does not know
+// yet, which it starts a handshake with. The caller must hold c.mu.
+func (c *Cluster) processGossip(sender *node, entries []gossip, now time.Time) {
+	for _, g := range entries {
+		n := c.nodes[cstring(g.Name[:])]
+		if n == nil {
+			if g.Flags&(flagNoAddr|flagHandshake) == 0 {
+				c.startHandshake(cstring(g.IP[:]), int(g.Port), int(g.BusPort), false)
+			}
+			continue
+		}
+		if n == c.myself || n.flags&flagHandshake != 0 || sender.master != nil {
+			continue
+		}
+		if g.Flags&(flagPFail|flagFail) != 0 {
+			if n.failReports == nil {
+				n.failReports = make(map[*node]time.Time)
+			}
+			n.failReports[sender] = now
+			c.markFailed(n, now)
+		} else {
+			delete(n.failReports, sender)
+		}
+	}
+}
+
+// failureReports returns the number of masters that reported n failing
+// recently. The caller must hold c.mu.
+func (c *Cluster) failureReports(n *node) int {
+	validity := failReportValidity * c.nodeTimeout()
+	for reporter, t := range n.failReports {
+		if time.Since(t) > validity || c.nodes[reporter.id] != reporter {
+			delete(n.failReports, reporter)
+		}
+	}
+	return len(n.failReports)
+}
+
+// markFailed marks n failed, telling the other nodes, if this node
+// considers it failing and enough masters agree. The caller must hold
+// c.mu.
+func (c *Cluster) markFailed(n *node, now time.Time) {
+	if n.flags&flagPFail == 0 {
+		return
+	}
+	failures := c.failureReports(n)
+	if c.myself.master == nil {
+		failures++
+	}
+	if failures < c.quorum() {
+		return
+	}
+	n.flags = n.flags&^flagPFail | flagFail
+	n.failTime = now
+	fail := c.message(msgFail)
+	fail.failing = name(n.id)
+	c.broadcast(fail.encode())
+	c.changed()
+}
+
+// clearFailure clears the failed flag of n, which replied: a replica, or a
+// master serving no slots, is back right away, and a master serving slots
+// once it is clear none of its replicas took over. The caller must hold
+// c.mu.
+func (c *Cluster) clearFailure(n *node, now time.Time) {
+	if n.flags&flagFail == 0 {
+		return
+	}
+	if n.master != nil || c.slotCount(n) == 0 || now.Sub(n.failTime) > failUndoTime*c.nodeTimeout() {
+		n.flags &^= flagFail
+		c.changed()
+	}
+}
+
+// deleteNode forgets n. The caller must hold c.mu.
+func (c *Cluster) deleteNode(n *node) {
+	c.freeLink(n)
+	delete(c.nodes, n.id)
+	for slot := range Slots {
+		if c.slots[slot] == n {
+			c.slots[slot] = nil
+		}
+		if c.migrating[slot] == n {
+			c.migrating[slot] = nil
+		}
+		if c.importing[slot] == n {
+			c.importing[slot] = nil
+		}
+	}
+	for _, other := range c.nodes {
+		delete(other.failReports, n)
+		if other.master == n {
+			other.master = nil
+		}
+	}
+	c.changed()
+}
+
+// cron runs every cronPeriod until Close.
+func (c *Cluster) cron() {
+	ticker := time.NewTicker(cronPeriod)
+	defer ticker.Stop()
+	for iteration := 1; ; iteration++ {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		c.tick(iteration%int(randomPingPeriod/cronPeriod) == 0)
+	}
+}
+
+// tick connects to the nodes not linked to and pings those due, marks
+// those that do not reply failing, and runs the election of this node if
+// its master failed. randomPing is set once per randomPingPeriod, to ping
+// one of the nodes heard from the longest time ago.
+func (c *Cluster) tick(randomPing bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.saveConfig()
+	if c.closed {
+		return
+	}
+	now := time.Now()
+	timeout := c.nodeTimeout()
+
+	for _, n := range c.nodes {
+		if n == c.myself {
+			continue
+		}
+		if n.flags&flagHandshake != 0 && now.Sub(n.created) > max(timeout, time.Second) {
+			c.deleteNode(n)
+			continue
+		}
+		if n.link == nil && !n.connecting {
+			n.connecting = true
+			go c.connect(n)
+		}
+	}
+
+	if randomPing {
+		var oldest *node
+		for range 5 {
+			n := c.randomNode()
+			if n == nil || n.link == nil || !n.pingSent.IsZero() || n.flags&flagHandshake != 0 {
+				continue
+			}
+			if oldest == nil || n.pongReceived.Before(oldest.pongReceived) {
+				oldest = n
+			}
+		}
+		if oldest != nil {
+			c.ping(oldest, msgPing)
+		}
+	}
+
+	for _, n := range c.nodes {
+		if n == c.myself || n.flags&flagHandshake != 0 {
+			continue
+		}
+		waiting := !n.pingSent.IsZero()
+		// A link that got nothing for long while a ping is due is
+		// reconnected, in case only the connection broke.
+		if n.link != nil && waiting && now.Sub(n.pingSent) > timeout/2 && now.Sub(n.dataReceived) > timeout/2 {
+			c.freeLink(n)
+		}
+		if n.link != nil && !waiting && now.Sub(n.pongReceived) > timeout/2 {
+			c.ping(n, msgPing)
+		}
+		if waiting && now.Sub(n.pingSent) > timeout && n.flags&(flagPFail|flagFail) == 0 {
+			n.flags |= flagPFail
+		}
+		c.markFailed(n, now)
+	}
+
+	if c.myself.master != nil {
+		c.handleFailover(now)
+	}
+}
+
+// randomNode returns one of the other nodes at random, if any. The caller
+// must hold c.mu.
+func (c *Cluster) randomNode() *node {
+	if len(c.nodes) < 2 {
+		return nil
+	}
+	i := rand.IntN(len(c.nodes) - 1)
+	for _, n := range c.nodes {
+		if n == c.myself {
+			continue
+		}
+		if i == 0 {
+			return n
+		}
+		i--
+	}
+	return nil
+}
+
+// logf logs about the cluster bus of this node.
+func (c *Cluster) logf(format string, args ...any) {
+	log.Printf("cluster: "+format, args...)
+}
@@ -0,0 +1,155 @@
+package cluster
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReplication records the master a node replicates.
+type fakeReplication struct {
+	mu     sync.Mutex
+	master string
+}
+
+func (r *fakeReplication) ReplicaOf(host string, port string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.master = net.JoinHostPort(host, port)
+	return true
+}
+
+func (r *fakeReplication) ReplicaOfNoOne() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.master = ""
+}
+
+func (r *fakeReplication) Offset() int64 {
+	return 0
+}
+
+func (r *fakeReplication) Master() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.master
+}
+
+// testNode is a node running its cluster bus on localhost.
+type testNode struct {
+	*Cluster
+	repl    *fakeReplication
+	port    int
+	busPort int
+}
+
+// startNode starts a node serving slots, pretending to serve clients on
+// port, with a short node timeout.
+func startNode(t *testing.T, port int, slots string) *testNode {
+	t.Helper()
+	repl := &fakeReplication{}
+	c := New("127.0.0.1", port, repl)
+	c.Options().NodeTimeout.Store(int64(300 * time.Millisecond))
+	parsed, err := ParseSlots(slots)
+	require.NoError(t, err)
+	require.NoError(t, c.AddSlots(parsed))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go c.Serve(l)
+	t.Cleanup(func() { c.Close() })
+	return &testNode{Cluster: c, repl: repl, port: port, busPort: l.Addr().(*net.TCPAddr).Port}
+}
+
+// startCluster starts three masters sharing the slots, met through the
+// first, and waits until they all know each other.
+func startCluster(t *testing.T, basePort int) []*testNode {
+	t.Helper()
+	nodes := []*testNode{
+		startNode(t, basePort, "0-5460"),
+		startNode(t, basePort+1, "5461-10922"),
+		startNode(t, basePort+2, "10923-16383"),
+	}
+	for _, n := range nodes[1:] {
+		require.NoError(t, nodes[0].Meet("127.0.0.1", n.port, n.busPort))
+	}
+	require.Eventually(t, func() bool {
+		for _, n := range nodes {
+			if info := n.Info(); !info.OK || info.KnownNodes != 3 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+	return nodes
+}
+
+func TestGossip(t *testing.T) {
+	t.Parallel()
+	nodes := startCluster(t, 7100)
+
+	var moved *MovedError
+	require.True(t, errors.As(nodes[1].Route(0, nil, false, nil), &moved))
+	assert.Equal(t, "127.0.0.1:7100", moved.Addr)
+	assert.NoError(t, nodes[1].Route(6000, nil, false, nil))
+
+	// Masters that collided on config epoch 0 move to distinct ones.
+	require.Eventually(t, func() bool {
+		epochs := make(map[uint64]bool)
+		for _, n := range nodes {
+			epochs[n.Info().MyEpoch] = true
+		}
+		return len(epochs) == 3
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestFailover(t *testing.T) {
+	t.Parallel()
+	nodes := startCluster(t, 7200)
+	replica := startNode(t, 7203, "")
+	require.NoError(t, replica.Meet("127.0.0.1", nodes[0].port, nodes[0].busPort))
+	require.Eventually(t, func() bool { return replica.Info().KnownNodes == 4 }, 5*time.Second, 50*time.Millisecond)
+	require.NoError(t, replica.Replicate(nodes[0].MyID()))
+	assert.Equal(t, "127.0.0.1:7200", replica.repl.Master())
+	require.Eventually(t, func() bool {
+		replicas, err := nodes[1].Replicas(nodes[0].MyID())
+		return err == nil && len(replicas) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	nodes[0].Close()
+	require.Eventually(t, func() bool { return replica.Route(0, nil, false, nil) == nil }, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, "", replica.repl.Master(), "the promoted replica is a master")
+
+	var moved *MovedError
+	require.Eventually(t, func() bool {
+		return errors.As(nodes[1].Route(0, nil, false, nil), &moved) && moved.Addr == "127.0.0.1:7203"
+	}, 5*time.Second, 50*time.Millisecond)
+	info := nodes[2].Info()
+	assert.True(t, info.OK)
+	assert.Equal(t, Slots, info.SlotsAssigned)
+}
+
+func TestFailureDetection(t *testing.T) {
+	t.Parallel()
+	nodes := startCluster(t, 7300)
+	id := nodes[2].MyID()
+	nodes[2].Close()
+
+	require.Eventually(t, func() bool {
+		for _, n := range nodes[:2] {
+			info := n.Info()
+			if info.OK || info.SlotsFail != 16384-10923 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.ErrorIs(t, nodes[0].Route(0, nil, false, nil), ErrClusterDown)
+	reports, err := nodes[0].CountFailureReports(id)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, reports, 1)
+}
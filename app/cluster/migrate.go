@@ -0,0 +1,232 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrTryAgain is returned for requests with several keys of a slot
+	// being migrated, some of which already moved.
+	ErrTryAgain = errors.New("Multiple keys request during rehashing of slot")
+	// errNotMaster is returned when a replica is asked to migrate slots.
+	errNotMaster = errors.New("Please use SETSLOT only with masters.")
+)
+
+// AskError is returned for the keys of a slot being migrated to the node
+// at Addr that are not on this node anymore. Unlike a MovedError, it only
+// redirects the request, after ASKING.
+type AskError struct {
+	Slot int
+	Addr string
+}
+
+func (e *AskError) Error() string {
+	return fmt.Sprintf("%d %s", e.Slot, e.Addr)
+}
+
+// SlotTransfer is a slot this node is migrating to, or importing from, the
+// node with ID NodeID.
+type SlotTransfer struct {
+	Slot   int
+	NodeID string
+}
+
+// Route returns nil if this node serves keys, the keys of a request in
+// slot, and otherwise the error redirecting them: a MovedError to the node
+// that serves the slot, or ErrSlotNotServed. While some slot is not
+// served, or served by a failed master, the cluster is down and no key is
+// served.
+//
+// While the slot migrates from this node, keys that are not here anymore,
+// as reported by exists, are redirected with an AskError to the node
+// importing them, which serves them to clients that sent ASKING, asking.
+// Requests with several keys, only some of which moved, get ErrTryAgain.
+func (c *Cluster) Route(slot int, keys []string, asking bool, exists func(key string) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.stateOK() {
+		return ErrClusterDown
+	}
+	owner := c.slots[slot]
+	if owner == nil {
+		return ErrSlotNotServed
+	}
+	migrating := owner == c.myself && c.migrating[slot] != nil
+	importing := c.importing[slot] != nil
+	if migrating || importing {
+		missing := 0
+		for _, key := range keys {
+			if !exists(key) {
+				missing++
+			}
+		}
+		if migrating && missing > 0 {
+			if missing < len(keys) {
+				return ErrTryAgain
+			}
+			return &AskError{Slot: slot, Addr: c.migrating[slot].addr()}
+		}
+		if importing && asking {
+			if len(keys) > 1 && missing > 0 {
+				return ErrTryAgain
+			}
+			return nil
+		}
+	}
+	if owner != c.myself {
+		return &MovedError{Slot: slot, Addr: owner.addr()}
+	}
+	return nil
+}
+
+// masterNode returns the master with ID id, for slots to be moved to or
+// from. The caller must hold c.mu.
+func (c *Cluster) masterNode(id string) (*node, error) {
+	n, ok := c.nodes[id]
+	if !ok || n.flags&flagHandshake != 0 {
+		return nil, fmt.Errorf("I don't know about node %s", id)
+	}
+	if n.master != nil {
+		return nil, errors.New("Target node is not a master")
+	}
+	return n, nil
+}
+
+// SetSlotMigrating marks slot, which this node serves, as migrating to the
+// node with ID id.
+func (c *Cluster) SetSlotMigrating(slot int, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.myself.master != nil {
+		return errNotMaster
+	}
+	if c.slots[slot] != c.myself {
+		return fmt.Errorf("I'm not the owner of hash slot %d", slot)
+	}
+	n, err := c.masterNode(id)
+	if err != nil {
+		return err
+	}
+	c.migrating[slot] = n
+	c.changed()
+	c.saveConfig()
+	return nil
+}
+
+// SetSlotImporting marks slot, which this node does not serve, as imported
+// from the node with ID id.
+func (c *Cluster) SetSlotImporting(slot int, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.myself.master != nil {
+		return errNotMaster
+	}
+	if c.slots[slot] == c.myself {
+		return fmt.Errorf("I'm already the owner of hash slot %d", slot)
+	}
+	n, err := c.masterNode(id)
+	if err != nil {
+		return err
+	}
+	c.importing[slot] = n
+	c.changed()
+	c.saveConfig()
+	return nil
+}
+
+// SetSlotStable clears the migrating and importing states of slot.
+func (c *Cluster) SetSlotStable(slot int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.myself.master != nil {
+		return errNotMaster
+	}
+	c.migrating[slot], c.importing[slot] = nil, nil
+	c.changed()
+	c.saveConfig()
+	return nil
+}
+
+// SetSlotNode assigns slot to the node with ID id, ending its migration:
+// the node that migrated it must not hold keys of it anymore, hasKeys, and
+// the node that imported it takes a new config epoch so that the others
+// accept that it serves it now.
+func (c *Cluster) SetSlotNode(slot int, id string, hasKeys bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.myself.master != nil {
+		return errNotMaster
+	}
+	n, ok := c.nodes[id]
+	if !ok || n.flags&flagHandshake != 0 {
+		return fmt.Errorf("Unknown node %s", id)
+	}
+	if n.master != nil {
+		return errors.New("Target node is not a master")
+	}
+	if c.slots[slot] == c.myself && n != c.myself && hasKeys {
+		return fmt.Errorf("Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot)
+	}
+	if !hasKeys {
+		c.migrating[slot] = nil
+	}
+	if n == c.myself && c.importing[slot] != nil {
+		c.importing[slot] = nil
+		c.bumpConfigEpoch()
+	}
+	c.slots[slot] = n
+	for _, other := range c.nodes {
+		if other != c.myself && other.flags&flagHandshake == 0 {
+			c.ping(other, msgPong)
+		}
+	}
+	c.changed()
+	c.saveConfig()
+	return nil
+}
+
+// bumpConfigEpoch gives this node the greatest config epoch, without the
+// agreement of the others, unless it already has it alone. The caller must
+// hold c.mu.
+func (c *Cluster) bumpConfigEpoch() {
+	greatest := c.currentEpoch
+	for _, n := range c.nodes {
+		greatest = max(greatest, n.configEpoch)
+	}
+	if c.myself.configEpoch == 0 || c.myself.configEpoch != greatest {
+		c.currentEpoch++
+		c.myself.configEpoch = c.currentEpoch
+	}
+}
+
+// slotTransfers returns the slots of transfers, migrating or importing,
+// in order. The caller must hold c.mu.
+func slotTransfers(transfers *[Slots]*node) []SlotTransfer {
+	var slots []SlotTransfer
+	for slot, n := range transfers {
+		if n != nil {
+			slots = append(slots, SlotTransfer{Slot: slot, NodeID: n.id})
+		}
+	}
+	return slots
+}
+
+// parseSlotTransfer parses a migrating slot, "[slot->-id]", or an
+// importing one, "[slot-<-id]", as shown by CLUSTER NODES.
+func parseSlotTransfer(s string) (SlotTransfer, bool, error) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	slot, id, migrating := strings.Cut(inner, "->-")
+	if !migrating {
+		var ok bool
+		if slot, id, ok = strings.Cut(inner, "-<-"); !ok {
+			return SlotTransfer{}, false, fmt.Errorf("invalid slot %s", s)
+		}
+	}
+	n, err := ParseSlot(slot)
+	if err != nil {
+		return SlotTransfer{}, false, fmt.Errorf("invalid slot %s", s)
+	}
+	return SlotTransfer{Slot: n, NodeID: id}, migrating, nil
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+	source := New("127.0.0.1", 7000, &fakeReplication{})
+	target := New("127.0.0.1", 7001, &fakeReplication{})
+	require.NoError(t, source.AddSlots([]int{0}))
+	otherSource := addNode(target, source.MyID(), 7000)
+	otherTarget := addNode(source, target.MyID(), 7001)
+	for slot := 1; slot < Slots; slot++ {
+		source.slots[slot] = otherTarget
+		target.slots[slot] = target.myself
+	}
+	target.slots[0] = otherSource
+
+	assert.EqualError(t, source.SetSlotImporting(0, target.MyID()), "I'm already the owner of hash slot 0")
+	assert.EqualError(t, target.SetSlotMigrating(0, source.MyID()), "I'm not the owner of hash slot 0")
+	assert.EqualError(t, source.SetSlotMigrating(0, "unknown"), "I don't know about node unknown")
+	require.NoError(t, source.SetSlotMigrating(0, target.MyID()))
+	require.NoError(t, target.SetSlotImporting(0, source.MyID()))
+	assert.Equal(t, []SlotTransfer{{Slot: 0, NodeID: target.MyID()}}, source.Nodes()[0].Migrating)
+
+	moved := map[string]bool{"a": true, "b": true}
+	onSource := func(key string) bool { return !moved[key] }
+	onTarget := func(key string) bool { return moved[key] }
+	assert.NoError(t, source.Route(0, []string{"c"}, false, onSource))
+	var ask *AskError
+	require.True(t, errors.As(source.Route(0, []string{"a", "b"}, false, onSource), &ask))
+	assert.Equal(t, "0 127.0.0.1:7001", ask.Error())
+	assert.ErrorIs(t, source.Route(0, []string{"a", "c"}, false, onSource), ErrTryAgain)
+
+	var redirect *MovedError
+	assert.True(t, errors.As(target.Route(0, []string{"a"}, false, onTarget), &redirect), "only after ASKING")
+	assert.NoError(t, target.Route(0, []string{"a"}, true, onTarget))
+	assert.NoError(t, target.Route(0, []string{"c"}, true, onTarget))
+	assert.ErrorIs(t, target.Route(0, []string{"a", "c"}, true, onTarget), ErrTryAgain)
+
+	require.NoError(t, target.SetSlotNode(0, target.MyID(), true))
+	assert.NoError(t, target.Route(0, []string{"a"}, false, onTarget))
+	assert.Nil(t, target.Nodes()[0].Importing)
+	assert.Equal(t, uint64(1), target.myself.configEpoch)
+
+	assert.EqualError(t, source.SetSlotNode(0, target.MyID(), true),
+		"Can't assign hashslot 0 to a different node while I still hold keys for this hash slot.")
+	require.NoError(t, source.SetSlotNode(0, target.MyID(), false))
+	require.True(t, errors.As(source.Route(0, []string{"a"}, false, onSource), &redirect))
+	assert.Nil(t, source.Nodes()[0].Migrating)
+}
+
+func TestParseSlotTransfer(t *testing.T) {
+	t.Parallel()
+	transfer, migrating, err := parseSlotTransfer("[5->-abc]")
+	require.NoError(t, err)
+	assert.True(t, migrating)
+	assert.Equal(t, SlotTransfer{Slot: 5, NodeID: "abc"}, transfer)
+
+	transfer, migrating, err = parseSlotTransfer("[16383-<-abc]")
+	require.NoError(t, err)
+	assert.False(t, migrating)
+	assert.Equal(t, SlotTransfer{Slot: 16383, NodeID: "abc"}, transfer)
+
+	_, _, err = parseSlotTransfer("[5-abc]")
+	assert.Error(t, err)
+}
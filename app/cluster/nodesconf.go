@@ -0,0 +1,246 @@
+package cluster
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// changed marks the configuration of the cluster as out of date in
+// nodes.conf. The caller must hold c.mu.
+func (c *Cluster) changed() {
+	c.dirty = true
+}
+
+// saveConfig writes nodes.conf if the configuration changed. The caller
+// must hold c.mu.
+func (c *Cluster) saveConfig() {
+	if !c.dirty || c.configFile == "" {
+		return
+	}
+	if err := c.writeConfig(); err != nil {
+		c.logf("saving %s: %v", c.configFile, err)
+		return
+	}
+	c.dirty = false
+}
+
+// writeConfig writes nodes.conf: a line per node, as in CLUSTER NODES, and
+// the epochs of this node. It replaces the file at once, so that a crash
+// leaves the old one. The caller must hold c.mu.
+func (c *Cluster) writeConfig() error {
+	var b strings.Builder
+	for _, n := range c.nodeInfos() {
+		if !slices.Contains(n.Flags, "handshake") {
+			b.WriteString(n.String() + "\n")
+		}
+	}
+	fmt.Fprintf(&b, "vars currentEpoch %d lastVoteEpoch %d\n", c.currentEpoch, c.lastVoteEpoch)
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.configFile), "temp-nodes-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.configFile)
+}
+
+// SaveConfig writes nodes.conf.
+func (c *Cluster) SaveConfig() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.configFile == "" {
+		return nil
+	}
+	if err := c.writeConfig(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// LoadConfig makes path the nodes.conf of this node, and restores the
+// configuration saved there, if any: the ID and epochs of this node, the
+// nodes it knew and the slots they served. It reports whether there was
+// one. A replica starts replicating its master again.
+func (c *Cluster) LoadConfig(path string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configFile = path
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		c.changed()
+		c.saveConfig()
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	masters := make(map[*node]string)
+	var transfers []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "vars" {
+			if err := c.loadVars(fields[1:]); err != nil {
+				return false, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			continue
+		}
+		n, master, err := c.loadNode(fields)
+		if err != nil {
+			return false, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if n == c.myself {
+			transfers = slices.DeleteFunc(fields[8:], func(field string) bool { return !strings.HasPrefix(field, "[") })
+		}
+		if master != "-" {
+			masters[n] = master
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	for _, field := range transfers {
+		t, migrating, err := parseSlotTransfer(field)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+		n, ok := c.nodes[t.NodeID]
+		if !ok {
+			return false, fmt.Errorf("%s: unknown node %s of slot %d", path, t.NodeID, t.Slot)
+		}
+		if migrating {
+			c.migrating[t.Slot] = n
+		} else {
+			c.importing[t.Slot] = n
+		}
+	}
+	for n, id := range masters {
+		master, ok := c.nodes[id]
+		if !ok {
+			return false, fmt.Errorf("%s: unknown master %s of %s", path, id, n.id)
+		}
+		if n == c.myself {
+			c.setMaster(master)
+		} else {
+			n.master = master
+		}
+	}
+	c.dirty = false
+	return true, nil
+}
+
+// loadVars restores the epochs of this node from the vars line of
+// nodes.conf. The caller must hold c.mu.
+func (c *Cluster) loadVars(fields []string) error {
+	if len(fields)%2 != 0 {
+		return errors.New("invalid vars")
+	}
+	for i := 0; i < len(fields); i += 2 {
+		value, err := strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", fields[i], err)
+		}
+		switch fields[i] {
+		case "currentEpoch":
+			c.currentEpoch = value
+		case "lastVoteEpoch":
+			c.lastVoteEpoch = value
+		}
+	}
+	return nil
+}
+
+// loadNode restores a node from a line of nodes.conf, split into fields,
+// and returns it with the ID of its master, or "-". The line of this node
+// gives it its ID, keeping its address. The caller must hold c.mu.
+func (c *Cluster) loadNode(fields []string) (*node, string, error) {
+	if len(fields) < 8 {
+		return nil, "", errors.New("invalid node line")
+	}
+	id, addr, flags := fields[0], fields[1], strings.Split(fields[2], ",")
+	configEpoch, err := strconv.ParseUint(fields[6], 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid config epoch: %w", err)
+	}
+
+	var n *node
+	if slices.Contains(flags, "myself") {
+		delete(c.nodes, c.myself.id)
+		n = c.myself
+		n.id = id
+	} else {
+		hostPort, busPort, ok := strings.Cut(addr, "@")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid address %s", addr)
+		}
+		busPort, _, _ = strings.Cut(busPort, ",")
+		ip, port, err := splitAddr(hostPort)
+		if err != nil {
+			return nil, "", err
+		}
+		bus, err := strconv.Atoi(busPort)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid address %s", addr)
+		}
+		n = &node{id: id, ip: ip, port: port, busPort: bus}
+		if slices.Contains(flags, "fail") {
+			n.flags |= flagFail
+		}
+	}
+	if slices.Contains(flags, "master") {
+		n.configEpoch = configEpoch
+	}
+	c.nodes[id] = n
+
+	for _, field := range fields[8:] {
+		if strings.HasPrefix(field, "[") {
+			continue
+		}
+		slots, err := ParseSlots(field)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, slot := range slots {
+			c.slots[slot] = n
+		}
+	}
+	return n, fields[3], nil
+}
+
+// splitAddr splits an address of the form ip:port.
+func splitAddr(addr string) (string, int, error) {
+	ip, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid address %s", addr)
+	}
+	return ip, port, nil
+}
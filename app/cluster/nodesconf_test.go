@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "nodes.conf")
+	c := New("127.0.0.1", 7000, &fakeReplication{})
+	loaded, err := c.LoadConfig(path)
+	require.NoError(t, err)
+	assert.False(t, loaded)
+	require.NoError(t, c.AddSlots([]int{0, 1, 2, 7}))
+	other := addNode(c, "0123456789012345678901234567890123456789", 7001)
+	other.configEpoch = 4
+	c.slots[5] = other
+	c.migrating[7], c.importing[5] = other, other
+	c.currentEpoch, c.lastVoteEpoch = 5, 3
+	require.NoError(t, c.SaveConfig())
+
+	restored := New("127.0.0.1", 7000, &fakeReplication{})
+	loaded, err = restored.LoadConfig(path)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, c.MyID(), restored.MyID())
+	assert.Equal(t, c.Nodes(), restored.Nodes())
+	assert.Equal(t, uint64(5), restored.currentEpoch)
+	assert.Equal(t, uint64(3), restored.lastVoteEpoch)
+}
+
+func TestConfigReplica(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "nodes.conf")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join([]string{
+		"0123456789012345678901234567890123456789 127.0.0.1:7001@17001 master - 0 0 2 connected 0-16383",
+		"9876543210987654321098765432109876543210 127.0.0.1:7000@17000 myself,slave 0123456789012345678901234567890123456789 0 0 2 connected",
+		"vars currentEpoch 2 lastVoteEpoch 0",
+	}, "\n")), 0o644))
+
+	repl := &fakeReplication{}
+	c := New("127.0.0.1", 7000, repl)
+	loaded, err := c.LoadConfig(path)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, "9876543210987654321098765432109876543210", c.MyID())
+	assert.Equal(t, "127.0.0.1:7001", repl.Master(), "a replica replicates its master again")
+	assert.Equal(t, uint64(2), c.Info().MyEpoch)
+}
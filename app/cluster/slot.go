@@ -0,0 +1,44 @@
+package cluster
+
+import "strings"
+
+// Slots is the number of hash slots keys are sharded into.
+const Slots = 16384
+
+// crc16Table is the table of CRC16-CCITT (XMODEM), the checksum Redis
+// Cluster hashes keys with.
+var crc16Table = func() [256]uint16 {
+	var table [256]uint16
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// KeySlot returns the hash slot of key. When key holds a non-empty hash
+// tag, the part between the first { and the first } after it, only the tag
+// is hashed, so that keys sharing it share a slot.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) & (Slots - 1)
+}
@@ -0,0 +1,29 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16(t *testing.T) {
+	t.Parallel()
+	// The check value of CRC16-CCITT (XMODEM), from the Redis Cluster
+	// specification.
+	assert.Equal(t, uint16(0x31c3), crc16("123456789"))
+}
+
+func TestKeySlot(t *testing.T) {
+	t.Parallel()
+	// Examples from the documentation of CLUSTER KEYSLOT.
+	assert.Equal(t, 11058, KeySlot("somekey"))
+	assert.Equal(t, 2515, KeySlot("foo{hash_tag}"))
+	assert.Equal(t, 12182, KeySlot("foo"))
+	assert.Equal(t, 5061, KeySlot("bar"))
+
+	assert.Equal(t, KeySlot("user1000"), KeySlot("{user1000}.following"))
+	assert.Equal(t, KeySlot("user1000"), KeySlot("foo{user1000}{x}"), "only the first tag counts")
+	assert.Equal(t, KeySlot("x"), KeySlot("{x}}"))
+	assert.Equal(t, int(crc16("{}a"))&(Slots-1), KeySlot("{}a"), "an empty tag hashes the whole key")
+	assert.Equal(t, int(crc16("a{b"))&(Slots-1), KeySlot("a{b"), "an unclosed tag hashes the whole key")
+}
@@ -0,0 +1,327 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+type SetBitCommand struct {
+	Key    string
+	Offset uint64
+	Bit    int
+}
+
+func (c SetBitCommand) Execute(store *store.Store) protocol.Frame {
+	old, err := store.SetBit(c.Key, c.Offset, c.Bit)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: old}
+}
+
+type GetBitCommand struct {
+	Key    string
+	Offset uint64
+}
+
+func (c GetBitCommand) Execute(store *store.Store) protocol.Frame {
+	bit, err := store.GetBit(c.Key, c.Offset)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: bit}
+}
+
+// BitCountCommand implements BITCOUNT. Range is nil when no range was given.
+type BitCountCommand struct {
+	Key   string
+	Range *store.BitRange
+}
+
+func (c BitCountCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.BitCount(c.Key, c.Range)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+// BitPosCommand implements BITPOS. Range is nil when no start was given.
+type BitPosCommand struct {
+	Key   string
+	Bit   int
+	Range *store.BitRange
+}
+
+func (c BitPosCommand) Execute(store *store.Store) protocol.Frame {
+	pos, err := store.BitPos(c.Key, c.Bit, c.Range)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: int(pos)}
+}
+
+type BitOpCommand struct {
+	Op          store.BitOpKind
+	Destination string
+	Keys        []string
+}
+
+func (c BitOpCommand) Execute(store *store.Store) protocol.Frame {
+	length, err := store.BitOp(c.Op, c.Destination, c.Keys)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: length}
+}
+
+// BitFieldCommand implements BITFIELD and BITFIELD_RO.
+type BitFieldCommand struct {
+	Key string
+	Ops []store.BitFieldOp
+}
+
+func (c BitFieldCommand) Execute(store *store.Store) protocol.Frame {
+	values, oks, err := store.BitField(c.Key, c.Ops)
+	if err != nil {
+		return errorFrame(err)
+	}
+	elems := make([]protocol.Frame, len(values))
+	for i, value := range values {
+		if !oks[i] {
+			elems[i] = protocol.BulkNullString{}
+			continue
+		}
+		elems[i] = protocol.Integer{Value: int(value)}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+// parseBitOffset parses a bit offset bounded by the maximum string size.
+func parseBitOffset(arg string) (uint64, error) {
+	offset, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil || offset > store.MaxBitOffset {
+		return 0, fmt.Errorf("bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+func parseSetBit(args []string) (any, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("setbit command requires 3 arguments")
+	}
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if args[2] != "0" && args[2] != "1" {
+		return nil, fmt.Errorf("bit is not an integer or out of range")
+	}
+	return SetBitCommand{Key: args[0], Offset: offset, Bit: int(args[2][0] - '0')}, nil
+}
+
+func parseGetBit(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("getbit command requires 2 arguments")
+	}
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return GetBitCommand{Key: args[0], Offset: offset}, nil
+}
+
+// parseBitRange parses "start [end [BYTE|BIT]]" for BITCOUNT and BITPOS.
+// BITCOUNT requires an end, which requireEnd enforces.
+func parseBitRange(args []string, requireEnd bool) (*store.BitRange, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if len(args) > 3 || (requireEnd && len(args) == 1) {
+		return nil, fmt.Errorf("syntax error")
+	}
+
+	start, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	r := &store.BitRange{Start: start}
+	if len(args) >= 2 {
+		end, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		r.End = &end
+	}
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BIT":
+			r.Bit = true
+		case "BYTE":
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+	return r, nil
+}
+
+func parseBitCount(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("bitcount command requires at least 1 argument")
+	}
+	r, err := parseBitRange(args[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	return BitCountCommand{Key: args[0], Range: r}, nil
+}
+
+func parseBitPos(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("bitpos command requires at least 2 arguments")
+	}
+	if args[1] != "0" && args[1] != "1" {
+		return nil, fmt.Errorf("The bit argument must be 1 or 0.")
+	}
+	r, err := parseBitRange(args[2:], false)
+	if err != nil {
+		return nil, err
+	}
+	return BitPosCommand{Key: args[0], Bit: int(args[1][0] - '0'), Range: r}, nil
+}
+
+func parseBitOp(args []string) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("bitop command requires at least 3 arguments")
+	}
+	cmd := BitOpCommand{Destination: args[1], Keys: args[2:]}
+	switch strings.ToUpper(args[0]) {
+	case "AND":
+		cmd.Op = store.BitOpAnd
+	case "OR":
+		cmd.Op = store.BitOpOr
+	case "XOR":
+		cmd.Op = store.BitOpXor
+	case "NOT":
+		if len(cmd.Keys) != 1 {
+			return nil, fmt.Errorf("BITOP NOT must be called with a single source key.")
+		}
+		cmd.Op = store.BitOpNot
+	case "DIFF":
+		if len(cmd.Keys) < 2 {
+			return nil, fmt.Errorf("BITOP DIFF must be called with at least two source keys.")
+		}
+		cmd.Op = store.BitOpDiff
+	default:
+		return nil, fmt.Errorf("syntax error")
+	}
+	return cmd, nil
+}
+
+// parseBitFieldType parses i1..i64 and u1..u63.
+func parseBitFieldType(arg string) (store.BitFieldType, error) {
+	invalid := fmt.Errorf("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(arg) < 2 {
+		return store.BitFieldType{}, invalid
+	}
+	t := store.BitFieldType{}
+	switch arg[0] {
+	case 'i', 'I':
+		t.Signed = true
+	case 'u', 'U':
+	default:
+		return store.BitFieldType{}, invalid
+	}
+	width, err := strconv.ParseUint(arg[1:], 10, 8)
+	if err != nil || width < 1 || (t.Signed && width > 64) || (!t.Signed && width > 63) {
+		return store.BitFieldType{}, invalid
+	}
+	t.Bits = uint(width)
+	return t, nil
+}
+
+// parseBitFieldOffset parses a BITFIELD offset, where "#N" means N fields of type t.
+func parseBitFieldOffset(arg string, t store.BitFieldType) (uint64, error) {
+	if rest, ok := strings.CutPrefix(arg, "#"); ok {
+		n, err := strconv.ParseUint(rest, 10, 64)
+		if err != nil || n > store.MaxBitOffset/uint64(t.Bits) {
+			return 0, fmt.Errorf("bit offset is not an integer or out of range")
+		}
+		return n * uint64(t.Bits), nil
+	}
+	return parseBitOffset(arg)
+}
+
+func parseBitField(readOnly bool) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("bitfield command requires at least 1 argument")
+		}
+
+		cmd := BitFieldCommand{Key: args[0]}
+		overflow := store.BitFieldWrap
+		for i := 1; i < len(args); {
+			subcommand := strings.ToUpper(args[i])
+			if readOnly && subcommand != "GET" {
+				return nil, fmt.Errorf("BITFIELD_RO only supports the GET subcommand")
+			}
+
+			switch subcommand {
+			case "OVERFLOW":
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("syntax error")
+				}
+				switch strings.ToUpper(args[i+1]) {
+				case "WRAP":
+					overflow = store.BitFieldWrap
+				case "SAT":
+					overflow = store.BitFieldSat
+				case "FAIL":
+					overflow = store.BitFieldFail
+				default:
+					return nil, fmt.Errorf("Invalid OVERFLOW type specified")
+				}
+				i += 2
+			case "GET", "SET", "INCRBY":
+				arity := 3
+				if subcommand != "GET" {
+					arity = 4
+				}
+				if i+arity > len(args) {
+					return nil, fmt.Errorf("syntax error")
+				}
+				t, err := parseBitFieldType(args[i+1])
+				if err != nil {
+					return nil, err
+				}
+				offset, err := parseBitFieldOffset(args[i+2], t)
+				if err != nil {
+					return nil, err
+				}
+				op := store.BitFieldOp{Type: t, Offset: offset, Overflow: overflow}
+				switch subcommand {
+				case "SET":
+					op.Kind = store.BitFieldSet
+				case "INCRBY":
+					op.Kind = store.BitFieldIncrBy
+				}
+				if op.Kind != store.BitFieldGet {
+					value, err := strconv.ParseInt(args[i+3], 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("value is not an integer or out of range")
+					}
+					op.Value = value
+				}
+				cmd.Ops = append(cmd.Ops, op)
+				i += arity
+			default:
+				return nil, fmt.Errorf("syntax error")
+			}
+		}
+		return cmd, nil
+	}
+}
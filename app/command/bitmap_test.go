@@ -0,0 +1,96 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromArrayBitmap(t *testing.T) {
+	t.Parallel()
+	end := int64(-1)
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "setbit", in: request("SETBIT", "k", "4294967295", "1"), want: SetBitCommand{Key: "k", Offset: 4294967295, Bit: 1}},
+		{name: "setbit offset too large", in: request("SETBIT", "k", "4294967296", "1"), wantErr: true},
+		{name: "setbit bad bit", in: request("SETBIT", "k", "1", "2"), wantErr: true},
+		{name: "bitcount", in: request("BITCOUNT", "k"), want: BitCountCommand{Key: "k"}},
+		{
+			name: "bitcount bits",
+			in:   request("BITCOUNT", "k", "1", "-1", "bit"),
+			want: BitCountCommand{Key: "k", Range: &store.BitRange{Start: 1, End: &end, Bit: true}},
+		},
+		{name: "bitcount missing end", in: request("BITCOUNT", "k", "1"), wantErr: true},
+		{name: "bitpos start only", in: request("BITPOS", "k", "0", "2"), want: BitPosCommand{Key: "k", Range: &store.BitRange{Start: 2}}},
+		{name: "bitpos bad bit", in: request("BITPOS", "k", "2"), wantErr: true},
+		{name: "bitop diff", in: request("BITOP", "DIFF", "d", "a", "b"), want: BitOpCommand{Op: store.BitOpDiff, Destination: "d", Keys: []string{"a", "b"}}},
+		{name: "bitop diff one key", in: request("BITOP", "DIFF", "d", "a"), wantErr: true},
+		{name: "bitop not two keys", in: request("BITOP", "NOT", "d", "a", "b"), wantErr: true},
+		{
+			name: "bitfield",
+			in:   request("BITFIELD", "k", "GET", "u4", "#2", "OVERFLOW", "FAIL", "INCRBY", "i5", "3", "-1"),
+			want: BitFieldCommand{Key: "k", Ops: []store.BitFieldOp{
+				{Kind: store.BitFieldGet, Type: store.BitFieldType{Bits: 4}, Offset: 8},
+				{Kind: store.BitFieldIncrBy, Type: store.BitFieldType{Signed: true, Bits: 5}, Offset: 3, Value: -1, Overflow: store.BitFieldFail},
+			}},
+		},
+		{name: "bitfield u64", in: request("BITFIELD", "k", "GET", "u64", "0"), wantErr: true},
+		{name: "bitfield bad overflow", in: request("BITFIELD", "k", "OVERFLOW", "NOPE"), wantErr: true},
+		{name: "bitfield_ro set", in: request("BITFIELD_RO", "k", "SET", "i8", "0", "1"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBitFieldCommandFailReply(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	got := BitFieldCommand{Key: "k", Ops: []store.BitFieldOp{
+		{Kind: store.BitFieldSet, Type: store.BitFieldType{Signed: true, Bits: 8}, Value: 1000, Overflow: store.BitFieldFail},
+	}}.Execute(s)
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{protocol.BulkNullString{}}}, got)
+}
+
+func TestGetBinaryBitmap(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	// Bits are numbered from the most significant of each byte: 0x0d, CR,
+	// then 0x0a, LF.
+	for _, offset := range []uint64{4, 5, 7, 12, 14} {
+		SetBitCommand{Key: "k", Offset: offset, Bit: 1}.Execute(s)
+	}
+	got := GetCommand{Key: "k"}.Execute(s)
+	assert.Equal(t, protocol.BulkString{Bytes: []byte("\r\n")}, got)
+
+	// The reply must not desync the stream for the next one.
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	require.NoError(t, got.Write(w))
+	require.NoError(t, protocol.SimpleString{Value: "PONG"}.Write(w))
+	r := bufio.NewReader(&buf)
+	frame, err := protocol.ReadFrame(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\r\n"), frame.(protocol.BulkString).Bytes)
+	frame, err = protocol.ReadFrame(r)
+	require.NoError(t, err)
+	assert.Equal(t, protocol.SimpleString{Value: "PONG"}, frame)
+}
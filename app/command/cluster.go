@@ -0,0 +1,613 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+var clusterHelp = []string{
+	"CLUSTER <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ADDSLOTS <slot> [<slot> ...]",
+	"    Assign slots to current node.",
+	"ADDSLOTSRANGE <start slot> <end slot> [<start slot> <end slot> ...]",
+	"    Assign slots which are between <start-slot> and <end-slot> to current node.",
+	"DELSLOTS <slot> [<slot> ...]",
+	"    Delete slots information from current node.",
+	"DELSLOTSRANGE <start slot> <end slot> [<start slot> <end slot> ...]",
+	"    Delete slots information which are between <start-slot> and <end-slot> from current node.",
+	"COUNT-FAILURE-REPORTS <node-id>",
+	"    Return number of failure reports for <node-id>.",
+	"COUNTKEYSINSLOT <slot>",
+	"    Return the number of keys in <slot>.",
+	"GETKEYSINSLOT <slot> <count>",
+	"    Return key names stored by current node in a slot.",
+	"INFO",
+	"    Return information about the cluster.",
+	"KEYSLOT <key>",
+	"    Return the hash slot for <key>.",
+	"MEET <ip> <port> [<bus-port>]",
+	"    Connect nodes into a working cluster.",
+	"MYID",
+	"    Return the node id.",
+	"NODES",
+	"    Return cluster configuration seen by node. Output format:",
+	"    <id> <ip:port@bus-port> <flags> <master> <pings> <pongs> <epoch> <link> <slot> ...",
+	"REPLICATE <node-id>",
+	"    Configure current node as replica to <node-id>.",
+	"REPLICAS <node-id>",
+	"    Return <node-id> replicas.",
+	"SAVECONFIG",
+	"    Force saving cluster configuration on disk.",
+	"SETSLOT <slot> (IMPORTING <node-id>|MIGRATING <node-id>|STABLE|NODE <node-id>)",
+	"    Set slot state.",
+	"SHARDS",
+	"    Return information about slot range mappings and the nodes associated with them.",
+	"SLOTS",
+	"    Return information about slots range mappings. Each range is made of:",
+	"    start, end, master and replicas IP addresses, ports and ids",
+	"HELP",
+	"    Print this help.",
+}
+
+// errClusterDisabled is the reply to CLUSTER on a server not in cluster mode.
+var errClusterDisabled = protocol.Error{Message: "This instance has cluster support disabled"}
+
+// ClusterCommand implements CLUSTER. Subcommand is upper case; Key is the
+// argument of KEYSLOT, Slots those of the slot assignment subcommands,
+// NodeID the node of REPLICATE, REPLICAS, COUNT-FAILURE-REPORTS and
+// SETSLOT, and IP, Port and BusPort the node of MEET. Slot is the slot of
+// SETSLOT, GETKEYSINSLOT and COUNTKEYSINSLOT, Action the upper case action
+// of SETSLOT and Count the number of keys of GETKEYSINSLOT.
+type ClusterCommand struct {
+	Subcommand string
+	Key        string
+	Slots      []int
+	NodeID     string
+	IP         string
+	Port       int
+	BusPort    int
+	Slot       int
+	Action     string
+	Count      int
+}
+
+// Execute runs the subcommand against cl, which is nil when the server is
+// not in cluster mode, and db, the keyspace of the node.
+func (c ClusterCommand) Execute(cl *cluster.Cluster, db *store.Store) protocol.Frame {
+	if c.Subcommand == "HELP" {
+		elems := make([]protocol.Frame, len(clusterHelp))
+		for i, line := range clusterHelp {
+			elems[i] = protocol.SimpleString{Value: line}
+		}
+		return protocol.Array{Elems: elems}
+	}
+	if cl == nil {
+		return errClusterDisabled
+	}
+	switch c.Subcommand {
+	case "KEYSLOT":
+		return protocol.Integer{Value: cluster.KeySlot(c.Key)}
+	case "MYID":
+		return bulk(cl.MyID())
+	case "ADDSLOTS", "ADDSLOTSRANGE":
+		if err := cl.AddSlots(c.Slots); err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		return protocol.SimpleString{Value: "OK"}
+	case "DELSLOTS", "DELSLOTSRANGE":
+		if err := cl.DelSlots(c.Slots); err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		return protocol.SimpleString{Value: "OK"}
+	case "MEET":
+		if err := cl.Meet(c.IP, c.Port, c.BusPort); err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		return protocol.SimpleString{Value: "OK"}
+	case "REPLICATE":
+		if err := cl.Replicate(c.NodeID); err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		return protocol.SimpleString{Value: "OK"}
+	case "REPLICAS", "SLAVES":
+		replicas, err := cl.Replicas(c.NodeID)
+		if err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		elems := make([]protocol.Frame, len(replicas))
+		for i, replica := range replicas {
+			elems[i] = bulk(replica.String())
+		}
+		return protocol.Array{Elems: elems}
+	case "COUNT-FAILURE-REPORTS":
+		n, err := cl.CountFailureReports(c.NodeID)
+		if err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		return protocol.Integer{Value: n}
+	case "SAVECONFIG":
+		if err := cl.SaveConfig(); err != nil {
+			return protocol.Error{Message: "error saving the cluster node config: " + err.Error()}
+		}
+		return protocol.SimpleString{Value: "OK"}
+	case "SETSLOT":
+		var err error
+		switch c.Action {
+		case "MIGRATING":
+			err = cl.SetSlotMigrating(c.Slot, c.NodeID)
+		case "IMPORTING":
+			err = cl.SetSlotImporting(c.Slot, c.NodeID)
+		case "STABLE":
+			err = cl.SetSlotStable(c.Slot)
+		case "NODE":
+			err = cl.SetSlotNode(c.Slot, c.NodeID, len(keysInSlot(db, c.Slot)) > 0)
+		}
+		if err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+		return protocol.SimpleString{Value: "OK"}
+	case "GETKEYSINSLOT":
+		keys := keysInSlot(db, c.Slot)
+		elems := make([]protocol.Frame, min(c.Count, len(keys)))
+		for i := range elems {
+			elems[i] = bulk(keys[i])
+		}
+		return protocol.Array{Elems: elems}
+	case "COUNTKEYSINSLOT":
+		return protocol.Integer{Value: len(keysInSlot(db, c.Slot))}
+	case "NODES":
+		return bulk(clusterNodes(cl.Nodes()))
+	case "SLOTS":
+		return clusterSlots(cl.Nodes())
+	case "SHARDS":
+		return clusterShards(cl.Nodes())
+	default:
+		return bulk(clusterInfo(cl.Info()))
+	}
+}
+
+// AskingCommand implements ASKING, after which the next command of the
+// client is served by a node importing the slot of its keys.
+type AskingCommand struct{}
+
+// Execute replies to ASKING, which the server remembers for the client.
+// cl is nil when the server is not in cluster mode.
+func (c AskingCommand) Execute(cl *cluster.Cluster) protocol.Frame {
+	if cl == nil {
+		return errClusterDisabled
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+// keysInSlot returns the keys of db in slot, in order.
+func keysInSlot(db *store.Store, slot int) []string {
+	keys := slices.DeleteFunc(db.Keys("*"), func(key string) bool { return cluster.KeySlot(key) != slot })
+	slices.Sort(keys)
+	return keys
+}
+
+// clusterNodes formats nodes as CLUSTER NODES does, one line each.
+func clusterNodes(nodes []cluster.NodeInfo) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(n.String() + "\n")
+	}
+	return b.String()
+}
+
+// clusterSlots builds the reply of CLUSTER SLOTS: for each range of slots,
+// in order, its bounds, the master serving it and its replicas that did
+// not fail.
+func clusterSlots(nodes []cluster.NodeInfo) protocol.Frame {
+	type slotRange struct {
+		cluster.SlotRange
+		elem []protocol.Frame
+	}
+	var ranges []slotRange
+	for _, n := range nodes {
+		for _, r := range n.Slots {
+			elem := []protocol.Frame{
+				protocol.Integer{Value: r.Start},
+				protocol.Integer{Value: r.End},
+				clusterSlotsNode(n),
+			}
+			for _, replica := range nodes {
+				if replica.MasterID == n.ID && !replica.Failed {
+					elem = append(elem, clusterSlotsNode(replica))
+				}
+			}
+			ranges = append(ranges, slotRange{r, elem})
+		}
+	}
+	slices.SortFunc(ranges, func(a, b slotRange) int { return a.Start - b.Start })
+	elems := make([]protocol.Frame, len(ranges))
+	for i, r := range ranges {
+		elems[i] = protocol.Array{Elems: r.elem}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+func clusterSlotsNode(n cluster.NodeInfo) protocol.Frame {
+	return protocol.Array{Elems: []protocol.Frame{
+		bulk(n.IP),
+		protocol.Integer{Value: n.Port},
+		bulk(n.ID),
+		protocol.Array{Elems: []protocol.Frame{}},
+	}}
+}
+
+// clusterShards builds the reply of CLUSTER SHARDS: for each master, the
+// ranges of slots it serves and a description of it and its replicas.
+func clusterShards(nodes []cluster.NodeInfo) protocol.Frame {
+	elems := []protocol.Frame{}
+	for _, n := range nodes {
+		if n.MasterID != "" {
+			continue
+		}
+		slots := make([]protocol.Frame, 0, 2*len(n.Slots))
+		for _, r := range n.Slots {
+			slots = append(slots, protocol.Integer{Value: r.Start}, protocol.Integer{Value: r.End})
+		}
+		shard := []protocol.Frame{clusterShardsNode(n)}
+		for _, replica := range nodes {
+			if replica.MasterID == n.ID {
+				shard = append(shard, clusterShardsNode(replica))
+			}
+		}
+		elems = append(elems, protocol.Array{Elems: []protocol.Frame{
+			bulk("slots"), protocol.Array{Elems: slots},
+			bulk("nodes"), protocol.Array{Elems: shard},
+		}})
+	}
+	return protocol.Array{Elems: elems}
+}
+
+func clusterShardsNode(n cluster.NodeInfo) protocol.Frame {
+	role, health := "master", "online"
+	if n.MasterID != "" {
+		role = "replica"
+	}
+	if n.Failed {
+		health = "fail"
+	}
+	return protocol.Array{Elems: []protocol.Frame{
+		bulk("id"), bulk(n.ID),
+		bulk("port"), protocol.Integer{Value: n.Port},
+		bulk("ip"), bulk(n.IP),
+		bulk("endpoint"), bulk(n.IP),
+		bulk("role"), bulk(role),
+		bulk("replication-offset"), protocol.Integer{Value: int(n.Offset)},
+		bulk("health"), bulk(health),
+	}}
+}
+
+// clusterInfo formats info as CLUSTER INFO does.
+func clusterInfo(info cluster.Info) string {
+	state := "fail"
+	if info.OK {
+		state = "ok"
+	}
+	fields := [][2]string{
+		{"cluster_state", state},
+		{"cluster_slots_assigned", strconv.Itoa(info.SlotsAssigned)},
+		{"cluster_slots_ok", strconv.Itoa(info.SlotsAssigned - info.SlotsPFail - info.SlotsFail)},
+		{"cluster_slots_pfail", strconv.Itoa(info.SlotsPFail)},
+		{"cluster_slots_fail", strconv.Itoa(info.SlotsFail)},
+		{"cluster_known_nodes", strconv.Itoa(info.KnownNodes)},
+		{"cluster_size", strconv.Itoa(info.Size)},
+		{"cluster_current_epoch", strconv.FormatUint(info.CurrentEpoch, 10)},
+		{"cluster_my_epoch", strconv.FormatUint(info.MyEpoch, 10)},
+	}
+	var b strings.Builder
+	for _, field := range fields {
+		fmt.Fprintf(&b, "%s:%s\r\n", field[0], field[1])
+	}
+	return b.String()
+}
+
+func parseCluster(args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for 'cluster' command")
+	}
+	subcommand := strings.ToUpper(args[0])
+	cmd := ClusterCommand{Subcommand: subcommand}
+	switch {
+	case subcommand == "KEYSLOT" && len(args) == 2:
+		cmd.Key = args[1]
+	case (subcommand == "ADDSLOTS" || subcommand == "DELSLOTS") && len(args) >= 2:
+		for _, arg := range args[1:] {
+			slot, err := cluster.ParseSlot(arg)
+			if err != nil {
+				return nil, err
+			}
+			cmd.Slots = append(cmd.Slots, slot)
+		}
+	case (subcommand == "ADDSLOTSRANGE" || subcommand == "DELSLOTSRANGE") && len(args) >= 3 && len(args)%2 == 1:
+		for i := 1; i < len(args); i += 2 {
+			start, err := cluster.ParseSlot(args[i])
+			if err != nil {
+				return nil, err
+			}
+			end, err := cluster.ParseSlot(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("start slot number %d is greater than end slot number %d", start, end)
+			}
+			for slot := start; slot <= end; slot++ {
+				cmd.Slots = append(cmd.Slots, slot)
+			}
+		}
+	case subcommand == "MEET" && (len(args) == 3 || len(args) == 4):
+		cmd.IP = args[1]
+		port, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid base port specified: %s", args[2])
+		}
+		cmd.Port, cmd.BusPort = port, port+cluster.BusPortOffset
+		if len(args) == 4 {
+			if cmd.BusPort, err = strconv.Atoi(args[3]); err != nil {
+				return nil, fmt.Errorf("Invalid bus port specified: %s", args[3])
+			}
+		}
+	case subcommand == "SETSLOT" && len(args) >= 3:
+		slot, err := cluster.ParseSlot(args[1])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Slot, cmd.Action = slot, strings.ToUpper(args[2])
+		switch {
+		case (cmd.Action == "MIGRATING" || cmd.Action == "IMPORTING" || cmd.Action == "NODE") && len(args) == 4:
+			cmd.NodeID = args[3]
+		case cmd.Action == "STABLE" && len(args) == 3:
+		default:
+			return nil, fmt.Errorf("Invalid CLUSTER SETSLOT action or number of arguments. Try CLUSTER HELP")
+		}
+	case subcommand == "GETKEYSINSLOT" && len(args) == 3:
+		slot, err := cluster.ParseSlot(args[1])
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		if count < 0 {
+			return nil, fmt.Errorf("Invalid number of keys")
+		}
+		cmd.Slot, cmd.Count = slot, count
+	case subcommand == "COUNTKEYSINSLOT" && len(args) == 2:
+		slot, err := cluster.ParseSlot(args[1])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Slot = slot
+	case (subcommand == "REPLICATE" || subcommand == "REPLICAS" || subcommand == "SLAVES" ||
+		subcommand == "COUNT-FAILURE-REPORTS") && len(args) == 2:
+		cmd.NodeID = args[1]
+	case (subcommand == "MYID" || subcommand == "NODES" || subcommand == "SAVECONFIG" || subcommand == "SLOTS" || subcommand == "SHARDS" ||
+		subcommand == "INFO" || subcommand == "HELP") && len(args) == 1:
+	default:
+		return nil, fmt.Errorf("unknown subcommand or wrong number of arguments for '%s'. Try CLUSTER HELP.", args[0])
+	}
+	return cmd, nil
+}
+
+// keySpec locates the keys among the arguments of a command, the name
+// being argument 0: from first to last, negative counting from the end,
+// every step arguments. A zero step marks the commands whose keys are
+// found by keysOf instead.
+type keySpec struct {
+	first, last, step int
+}
+
+// keySpecs are the keys of the commands that take any, which a node of a
+// cluster must serve. Subcommands are listed as "NAME|SUBCOMMAND".
+var keySpecs = map[string]keySpec{
+	"SET": {1, 1, 1}, "GET": {1, 1, 1}, "INCR": {1, 1, 1},
+
+	"SADD": {1, 1, 1}, "SREM": {1, 1, 1}, "SMEMBERS": {1, 1, 1}, "SISMEMBER": {1, 1, 1},
+	"SMISMEMBER": {1, 1, 1}, "SCARD": {1, 1, 1}, "SPOP": {1, 1, 1}, "SRANDMEMBER": {1, 1, 1},
+	"SMOVE": {1, 2, 1}, "SINTER": {1, -1, 1}, "SUNION": {1, -1, 1}, "SDIFF": {1, -1, 1},
+	"SINTERSTORE": {1, -1, 1}, "SUNIONSTORE": {1, -1, 1}, "SDIFFSTORE": {1, -1, 1},
+	"SINTERCARD": {},
+
+	"ZADD": {1, 1, 1}, "ZINCRBY": {1, 1, 1}, "ZREM": {1, 1, 1}, "ZSCORE": {1, 1, 1},
+	"ZMSCORE": {1, 1, 1}, "ZCARD": {1, 1, 1}, "ZCOUNT": {1, 1, 1}, "ZRANK": {1, 1, 1},
+	"ZREVRANK": {1, 1, 1}, "ZRANGE": {1, 1, 1}, "ZRANGESTORE": {1, 2, 1},
+	"ZPOPMIN": {1, 1, 1}, "ZPOPMAX": {1, 1, 1}, "BZPOPMIN": {1, -2, 1}, "BZPOPMAX": {1, -2, 1},
+	"ZREMRANGEBYRANK": {1, 1, 1}, "ZREMRANGEBYSCORE": {1, 1, 1}, "ZREMRANGEBYLEX": {1, 1, 1},
+	"ZUNION": {}, "ZINTER": {}, "ZDIFF": {}, "ZUNIONSTORE": {}, "ZINTERSTORE": {}, "ZDIFFSTORE": {},
+	"ZMPOP": {}, "BZMPOP": {},
+
+	"XADD": {1, 1, 1}, "XRANGE": {1, 1, 1}, "XREVRANGE": {1, 1, 1}, "XLEN": {1, 1, 1},
+	"XDEL": {1, 1, 1}, "XTRIM": {1, 1, 1}, "XACK": {1, 1, 1}, "XPENDING": {1, 1, 1},
+	"XCLAIM": {1, 1, 1}, "XAUTOCLAIM": {1, 1, 1}, "XREAD": {}, "XREADGROUP": {},
+	"XGROUP|CREATE": {2, 2, 1}, "XGROUP|SETID": {2, 2, 1}, "XGROUP|DESTROY": {2, 2, 1},
+	"XGROUP|CREATECONSUMER": {2, 2, 1}, "XGROUP|DELCONSUMER": {2, 2, 1},
+	"XINFO|STREAM": {2, 2, 1}, "XINFO|GROUPS": {2, 2, 1}, "XINFO|CONSUMERS": {2, 2, 1},
+
+	"SETBIT": {1, 1, 1}, "GETBIT": {1, 1, 1}, "BITCOUNT": {1, 1, 1}, "BITPOS": {1, 1, 1},
+	"BITOP": {2, -1, 1}, "BITFIELD": {1, 1, 1}, "BITFIELD_RO": {1, 1, 1},
+
+	"PFADD": {1, 1, 1}, "PFCOUNT": {1, -1, 1}, "PFMERGE": {1, -1, 1},
+
+	"GEOADD": {1, 1, 1}, "GEOPOS": {1, 1, 1}, "GEOHASH": {1, 1, 1}, "GEODIST": {1, 1, 1},
+	"GEOSEARCH": {1, 1, 1}, "GEOSEARCHSTORE": {1, 2, 1},
+
+	"DEL": {1, -1, 1}, "UNLINK": {1, -1, 1}, "SSCAN": {1, 1, 1}, "ZSCAN": {1, 1, 1},
+	"HSCAN": {1, 1, 1}, "MOVE": {1, 1, 1}, "DUMP": {1, 1, 1}, "RESTORE": {1, 1, 1},
+	"OBJECT|ENCODING": {2, 2, 1}, "OBJECT|FREQ": {2, 2, 1}, "OBJECT|IDLETIME": {2, 2, 1},
+	"OBJECT|REFCOUNT": {2, 2, 1}, "MEMORY|USAGE": {2, 2, 1},
+	"RESTORE-ASKING": {1, 1, 1}, "MIGRATE": {},
+}
+
+// requestKeys returns the keys of request.
+func requestKeys(request protocol.Array) []string {
+	args, err := bulkStrings("command", request.Elems)
+	if err != nil || len(args) == 0 {
+		return nil
+	}
+	name := strings.ToUpper(args[0])
+	spec, ok := keySpec{}, false
+	if len(args) > 1 {
+		spec, ok = keySpecs[name+"|"+strings.ToUpper(args[1])]
+	}
+	if !ok {
+		if spec, ok = keySpecs[name]; !ok {
+			return nil
+		}
+	}
+	if spec.step == 0 {
+		return keysOf(name, args)
+	}
+	last := spec.last
+	if last < 0 {
+		last += len(args)
+	}
+	var keys []string
+	for i := spec.first; i <= last && i < len(args); i += spec.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+// keysOf returns the keys of the commands that give their number, or
+// follow a STREAMS or KEYS keyword.
+func keysOf(name string, args []string) []string {
+	switch name {
+	case "MIGRATE":
+		if len(args) < 6 {
+			return nil
+		}
+		if args[3] != "" {
+			return args[3:4]
+		}
+		for i := 6; i < len(args); i++ {
+			if strings.EqualFold(args[i], "KEYS") {
+				return args[i+1:]
+			}
+		}
+		return nil
+	case "XREAD", "XREADGROUP":
+		for i, arg := range args {
+			if strings.EqualFold(arg, "STREAMS") {
+				streams := args[i+1:]
+				return streams[:len(streams)/2]
+			}
+		}
+		return nil
+	case "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		if len(args) < 3 {
+			return nil
+		}
+		return append([]string{args[1]}, numKeys(args[2:])...)
+	case "BZMPOP":
+		if len(args) < 3 {
+			return nil
+		}
+		return numKeys(args[2:])
+	default:
+		if len(args) < 2 {
+			return nil
+		}
+		return numKeys(args[1:])
+	}
+}
+
+// numKeys returns the keys of args, a number of keys followed by them.
+func numKeys(args []string) []string {
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return nil
+	}
+	return args[1 : 1+min(n, len(args)-1)]
+}
+
+// clusterDisallowed are the commands a node of a cluster refuses, as it
+// has a single database and the cluster decides what it replicates.
+// SELECT is refused for any other database.
+var clusterDisallowed = map[string]bool{
+	"MOVE":      true,
+	"SWAPDB":    true,
+	"REPLICAOF": true,
+	"SLAVEOF":   true,
+}
+
+// ClusterRefusal returns the error with which a node of cl refuses request,
+// if it does: redirections for the keys of slots it does not serve, and
+// refusals of keys in different slots. The keys of a transaction must all
+// be in one slot too: slot is the slot of the keys of the commands queued
+// so far, or -1, and is updated with those of request. cl is nil when the
+// server is not in cluster mode.
+//
+// While a slot migrates, the keys of db decide where a request goes: asking
+// is set for the clients that sent ASKING, which a node importing the slot
+// serves, as it does RESTORE-ASKING.
+func ClusterRefusal(request protocol.Array, cl *cluster.Cluster, db *store.Store, asking bool, slot *int) (protocol.Error, bool) {
+	if cl == nil {
+		return protocol.Error{}, false
+	}
+	args, err := bulkStrings("command", request.Elems)
+	if err != nil {
+		return protocol.Error{}, false
+	}
+	name := strings.ToUpper(args[0])
+	if clusterDisallowed[name] || (name == "SELECT" && len(args) == 2 && args[1] != "0") {
+		return protocol.Error{Message: name + " is not allowed in cluster mode"}, true
+	}
+	keys := requestKeys(request)
+	if len(keys) == 0 {
+		return protocol.Error{}, false
+	}
+	current := -1
+	if slot != nil {
+		current = *slot
+	}
+	for _, key := range keys {
+		s := cluster.KeySlot(key)
+		if current >= 0 && s != current {
+			return errorFrame(cluster.ErrCrossSlot).(protocol.Error), true
+		}
+		current = s
+	}
+	exists := func(key string) bool {
+		_, ok := db.Type(key)
+		return ok
+	}
+	if err := cl.Route(current, keys, asking || name == "RESTORE-ASKING", exists); err != nil {
+		return errorFrame(err).(protocol.Error), true
+	}
+	if slot != nil {
+		*slot = current
+	}
+	return protocol.Error{}, false
+}
+
+// clusterErrorFrame converts a cluster error into its RESP error reply, or
+// returns false.
+func clusterErrorFrame(err error) (protocol.Error, bool) {
+	var moved *cluster.MovedError
+	var ask *cluster.AskError
+	switch {
+	case errors.As(err, &moved):
+		return protocol.Error{Prefix: "MOVED", Message: moved.Error()}, true
+	case errors.As(err, &ask):
+		return protocol.Error{Prefix: "ASK", Message: ask.Error()}, true
+	case errors.Is(err, cluster.ErrTryAgain):
+		return protocol.Error{Prefix: "TRYAGAIN", Message: err.Error()}, true
+	case errors.Is(err, cluster.ErrCrossSlot):
+		return protocol.Error{Prefix: "CROSSSLOT", Message: err.Error()}, true
+	case errors.Is(err, cluster.ErrClusterDown), errors.Is(err, cluster.ErrSlotNotServed):
+		return protocol.Error{Prefix: "CLUSTERDOWN", Message: err.Error()}, true
+	}
+	return protocol.Error{}, false
+}
@@ -0,0 +1,169 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestKeys(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		request protocol.Array
+		keys    []string
+	}{
+		{request("PING"), nil},
+		{request("GET", "a"), []string{"a"}},
+		{request("DEL", "a", "b"), []string{"a", "b"}},
+		{request("SMOVE", "a", "b", "m"), []string{"a", "b"}},
+		{request("BZPOPMIN", "a", "b", "0"), []string{"a", "b"}},
+		{request("BITOP", "AND", "d", "a", "b"), []string{"d", "a", "b"}},
+		{request("ZUNIONSTORE", "d", "2", "a", "b", "WEIGHTS", "1", "2"), []string{"d", "a", "b"}},
+		{request("ZINTER", "2", "a", "b"), []string{"a", "b"}},
+		{request("BZMPOP", "0", "1", "a", "MIN"), []string{"a"}},
+		{request("XREAD", "COUNT", "1", "STREAMS", "a", "b", "0", "0"), []string{"a", "b"}},
+		{request("XGROUP", "CREATE", "a", "g", "$"), []string{"a"}},
+		{request("OBJECT", "ENCODING", "a"), []string{"a"}},
+		{request("MIGRATE", "h", "1", "a", "0", "0", "COPY"), []string{"a"}},
+		{request("MIGRATE", "h", "1", "", "0", "0", "REPLACE", "KEYS", "a", "b"), []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.keys, requestKeys(tt.request), "%v", tt.request)
+	}
+}
+
+func TestClusterRefusal(t *testing.T) {
+	t.Parallel()
+	db := store.NewStore()
+	_, refused := ClusterRefusal(request("GET", "a"), nil, db, false, nil)
+	assert.False(t, refused, "servers not in cluster mode refuse nothing")
+
+	cl := newCluster(t)
+	msg, refused := ClusterRefusal(request("GET", "a"), cl, db, false, nil)
+	assert.True(t, refused)
+	assert.Equal(t, protocol.Error{Prefix: "CLUSTERDOWN", Message: "The cluster is down"}, msg)
+
+	slots, err := cluster.ParseSlots("0-16383")
+	require.NoError(t, err)
+	require.NoError(t, cl.AddSlots(slots))
+	_, refused = ClusterRefusal(request("SINTER", "{u}a", "{u}b"), cl, db, false, nil)
+	assert.False(t, refused)
+	msg, refused = ClusterRefusal(request("DEL", "a", "b"), cl, db, false, nil)
+	assert.True(t, refused)
+	assert.Equal(t, "CROSSSLOT", msg.Prefix)
+	msg, _ = ClusterRefusal(request("SELECT", "1"), cl, db, false, nil)
+	assert.Equal(t, "SELECT is not allowed in cluster mode", msg.Message)
+	_, refused = ClusterRefusal(request("SELECT", "0"), cl, db, false, nil)
+	assert.False(t, refused)
+
+	slot := -1
+	_, refused = ClusterRefusal(request("SET", "a", "1"), cl, db, false, &slot)
+	assert.False(t, refused)
+	assert.Equal(t, cluster.KeySlot("a"), slot)
+	_, refused = ClusterRefusal(request("GET", "b"), cl, db, false, &slot)
+	assert.True(t, refused, "the keys of a transaction must be in one slot")
+
+	ask, _ := clusterErrorFrame(&cluster.AskError{Slot: 3, Addr: "127.0.0.1:7001"})
+	assert.Equal(t, protocol.Error{Prefix: "ASK", Message: "3 127.0.0.1:7001"}, ask)
+	tryAgain, _ := clusterErrorFrame(cluster.ErrTryAgain)
+	assert.Equal(t, "TRYAGAIN", tryAgain.Prefix)
+}
+
+func TestCluster(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, errClusterDisabled, ClusterCommand{Subcommand: "INFO"}.Execute(nil, store.NewStore()))
+
+	cl := newCluster(t)
+	assert.Equal(t, protocol.Integer{Value: 12182}, ClusterCommand{Subcommand: "KEYSLOT", Key: "foo"}.Execute(cl, store.NewStore()))
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, ClusterCommand{Subcommand: "ADDSLOTS", Slots: []int{0, 1, 2}}.Execute(cl, store.NewStore()))
+
+	id := cl.MyID()
+	assert.Equal(t, bulk(id+" 127.0.0.1:7000@17000 myself,master - 0 0 0 connected 0-2\n"), ClusterCommand{Subcommand: "NODES"}.Execute(cl, store.NewStore()))
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{
+		protocol.Array{Elems: []protocol.Frame{
+			protocol.Integer{Value: 0},
+			protocol.Integer{Value: 2},
+			protocol.Array{Elems: []protocol.Frame{bulk("127.0.0.1"), protocol.Integer{Value: 7000}, bulk(id), protocol.Array{Elems: []protocol.Frame{}}}},
+		}},
+	}}, ClusterCommand{Subcommand: "SLOTS"}.Execute(cl, store.NewStore()))
+
+	assert.Equal(t, protocol.Error{Message: "Unknown node abc"}, ClusterCommand{Subcommand: "REPLICATE", NodeID: "abc"}.Execute(cl, store.NewStore()))
+	assert.Equal(t, protocol.Error{Message: "Can't replicate myself"}, ClusterCommand{Subcommand: "REPLICATE", NodeID: id}.Execute(cl, store.NewStore()))
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{}}, ClusterCommand{Subcommand: "REPLICAS", NodeID: id}.Execute(cl, store.NewStore()))
+
+	info := ClusterCommand{Subcommand: "INFO"}.Execute(cl, store.NewStore()).(protocol.BulkString)
+	assert.Contains(t, string(info.Bytes), "cluster_state:fail\r\ncluster_slots_assigned:3\r\n")
+}
+
+func TestClusterSlotKeys(t *testing.T) {
+	t.Parallel()
+	cl := newCluster(t)
+	db := store.NewStore()
+	for _, key := range []string{"{u}c", "{u}a", "{u}b", "other"} {
+		db.Set(key, "v", nil)
+	}
+	slot := cluster.KeySlot("u")
+	assert.Equal(t, protocol.Integer{Value: 3}, ClusterCommand{Subcommand: "COUNTKEYSINSLOT", Slot: slot}.Execute(cl, db))
+	assert.Equal(t, bulkArray([]string{"{u}a", "{u}b"}), ClusterCommand{Subcommand: "GETKEYSINSLOT", Slot: slot, Count: 2}.Execute(cl, db))
+
+	assert.Equal(t, protocol.Error{Message: "I'm not the owner of hash slot 0"},
+		ClusterCommand{Subcommand: "SETSLOT", Slot: 0, Action: "MIGRATING", NodeID: "abc"}.Execute(cl, db))
+	assert.Equal(t, protocol.Error{Message: "I don't know about node abc"},
+		ClusterCommand{Subcommand: "SETSLOT", Slot: 0, Action: "IMPORTING", NodeID: "abc"}.Execute(cl, db))
+	assert.Equal(t, protocol.SimpleString{Value: "OK"},
+		ClusterCommand{Subcommand: "SETSLOT", Slot: slot, Action: "NODE", NodeID: cl.MyID()}.Execute(cl, db))
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, ClusterCommand{Subcommand: "SETSLOT", Slot: slot, Action: "STABLE"}.Execute(cl, db))
+	assert.Equal(t, []cluster.SlotRange{{Start: slot, End: slot}}, cl.Nodes()[0].Slots)
+}
+
+func TestParseCluster(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		request protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{request("CLUSTER", "keyslot", "foo"), ClusterCommand{Subcommand: "KEYSLOT", Key: "foo"}, false},
+		{request("CLUSTER", "ADDSLOTS", "1", "5"), ClusterCommand{Subcommand: "ADDSLOTS", Slots: []int{1, 5}}, false},
+		{request("CLUSTER", "ADDSLOTSRANGE", "1", "3"), ClusterCommand{Subcommand: "ADDSLOTSRANGE", Slots: []int{1, 2, 3}}, false},
+		{request("CLUSTER", "NODES"), ClusterCommand{Subcommand: "NODES"}, false},
+		{request("CLUSTER", "MEET", "127.0.0.1", "7001"), ClusterCommand{Subcommand: "MEET", IP: "127.0.0.1", Port: 7001, BusPort: 17001}, false},
+		{request("CLUSTER", "MEET", "127.0.0.1", "7001", "7101"), ClusterCommand{Subcommand: "MEET", IP: "127.0.0.1", Port: 7001, BusPort: 7101}, false},
+		{request("CLUSTER", "REPLICATE", "abc"), ClusterCommand{Subcommand: "REPLICATE", NodeID: "abc"}, false},
+		{request("CLUSTER", "SETSLOT", "5", "migrating", "abc"), ClusterCommand{Subcommand: "SETSLOT", Slot: 5, Action: "MIGRATING", NodeID: "abc"}, false},
+		{request("CLUSTER", "SETSLOT", "5", "STABLE"), ClusterCommand{Subcommand: "SETSLOT", Slot: 5, Action: "STABLE"}, false},
+		{request("CLUSTER", "GETKEYSINSLOT", "5", "10"), ClusterCommand{Subcommand: "GETKEYSINSLOT", Slot: 5, Count: 10}, false},
+		{request("CLUSTER", "COUNTKEYSINSLOT", "5"), ClusterCommand{Subcommand: "COUNTKEYSINSLOT", Slot: 5}, false},
+		{request("CLUSTER", "MEET", "127.0.0.1", "x"), nil, true},
+		{request("CLUSTER", "ADDSLOTS", "16384"), nil, true},
+		{request("CLUSTER", "ADDSLOTSRANGE", "3", "1"), nil, true},
+		{request("CLUSTER", "KEYSLOT"), nil, true},
+		{request("CLUSTER", "SETSLOT", "5", "NODE"), nil, true},
+		{request("CLUSTER", "SETSLOT", "5", "STABLE", "abc"), nil, true},
+		{request("CLUSTER", "GETKEYSINSLOT", "5", "-1"), nil, true},
+		{request("CLUSTER", "COUNTKEYSINSLOT", "16384"), nil, true},
+		{request("CLUSTER", "NOPE"), nil, true},
+		{request("CLUSTER"), nil, true},
+	}
+	for _, tt := range tests {
+		got, err := FromArray(tt.request)
+		if tt.wantErr {
+			assert.Error(t, err, "%v", tt.request)
+			continue
+		}
+		require.NoError(t, err, "%v", tt.request)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+// newCluster returns a node at 127.0.0.1:7000 that serves no slot.
+func newCluster(t *testing.T) *cluster.Cluster {
+	repl := replication.New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(repl.Close)
+	return cluster.New("127.0.0.1", 7000, repl)
+}
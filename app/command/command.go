@@ -2,19 +2,41 @@ package command
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
 	"github.com/codecrafters-io/redis-starter-go/app/store"
 )
 
+// StoreCommand is a command that only needs the keyspace to execute.
+type StoreCommand interface {
+	Execute(store *store.Store) protocol.Frame
+}
+
+// BlockingCommand is a StoreCommand that may wait for another client to
+// write to its keys. Execute never blocks, which is how it runs inside MULTI.
+// ExecuteBlocking makes each of its attempts through attempt.
+type BlockingCommand interface {
+	StoreCommand
+	ExecuteBlocking(store *store.Store, attempt Attempt) protocol.Frame
+}
+
+// Attempt runs try, one attempt of a blocking command, and returns its
+// reply and whether there was one. The server runs it as a write, so that
+// what a command pops is sent to replicas before any other write runs.
+type Attempt func(try func() (protocol.Frame, bool)) (protocol.Frame, bool)
+
 type PingCommand struct{}
 
-func (c *PingCommand) Execute() protocol.SimpleString {
+func (c PingCommand) Execute() protocol.SimpleString {
 	return protocol.SimpleString{Value: "PONG"}
 }
 
@@ -22,7 +44,7 @@ type EchoCommand struct {
 	Message string
 }
 
-func (c *EchoCommand) Execute() protocol.SimpleString {
+func (c EchoCommand) Execute() protocol.SimpleString {
 	return protocol.SimpleString{Value: c.Message}
 }
 
@@ -31,7 +53,7 @@ type SetCommand struct {
 	Value string
 }
 
-func (c *SetCommand) Execute(store *store.Store) protocol.SimpleString {
+func (c SetCommand) Execute(store *store.Store) protocol.Frame {
 	store.Set(c.Key, c.Value, nil)
 	return protocol.SimpleString{Value: "OK"}
 }
@@ -42,32 +64,47 @@ type SetTTLCommand struct {
 	TTL   time.Duration
 }
 
-func (c *SetTTLCommand) Execute(store *store.Store) protocol.SimpleString {
+func (c SetTTLCommand) Execute(store *store.Store) protocol.Frame {
 	store.Set(c.Key, c.Value, &c.TTL)
 	return protocol.SimpleString{Value: "OK"}
 }
 
+// SetAtCommand implements SET with EXAT or PXAT, which expire the key at At.
+type SetAtCommand struct {
+	Key   string
+	Value string
+	At    time.Time
+}
+
+func (c SetAtCommand) Execute(store *store.Store) protocol.Frame {
+	store.SetAt(c.Key, c.Value, c.At)
+	return protocol.SimpleString{Value: "OK"}
+}
+
 type GetCommand struct {
 	Key string
 }
 
-func (c *GetCommand) Execute(store *store.Store) protocol.Frame {
-	value, ok := store.Get(c.Key)
+func (c GetCommand) Execute(store *store.Store) protocol.Frame {
+	value, ok, err := store.GetString(c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
 	if !ok {
 		return protocol.BulkNullString{}
 	}
 
-	return protocol.SimpleString{Value: value}
+	return protocol.BulkString{Bytes: []byte(value)}
 }
 
 type IncrCommand struct {
 	Key string
 }
 
-func (c *IncrCommand) Execute(store *store.Store) protocol.Frame {
+func (c IncrCommand) Execute(store *store.Store) protocol.Frame {
 	value, err := store.Incr(c.Key)
 	if err != nil {
-		return protocol.Error{Message: err.Error()}
+		return errorFrame(err)
 	}
 
 	return protocol.Integer{Value: value}
@@ -77,13 +114,27 @@ type ExecCommand struct{}
 
 type DiscardCommand struct{}
 
+// MultiCommand implements MULTI. Asking is set when the client sent ASKING
+// before it, for the keys of the transaction.
 type MultiCommand struct {
 	Commands []any
+	Asking   bool
 }
 
-func (c *MultiCommand) Execute(reader *bufio.Reader, writer *bufio.Writer, store *store.Store, file *rdb.File) protocol.Frame {
+// Execute queues commands until EXEC and runs them. db is the client's
+// selected database, which a queued SELECT changes. Commands refused for
+// lack of memory or by a replica while queueing abort the transaction, as
+// does running out of memory by EXEC when any queued command may use more.
+// The writes of the transaction reach replicas together. info is what
+// INFO reports on, whose counters include the queued commands.
+func (c MultiCommand) Execute(reader *bufio.Reader, writer *bufio.Writer, dbs *store.Databases, db *int, file *rdb.File, cfg *config.Config, repl *replication.Replication, info InfoSources) protocol.Frame {
 	protocol.SimpleString{Value: "OK"}.Write(writer)
 
+	var requests []protocol.Array
+	aborted, denyOOM := false, false
+	// slot is the hash slot of the keys queued, which in cluster mode
+	// must all be in one.
+	slot := -1
 read:
 	for {
 		frame, err := protocol.ReadFrame(reader)
@@ -91,7 +142,9 @@ read:
 			return protocol.Error{Message: err.Error()}
 		}
 
-		cmd, err := FromArray(frame.(protocol.Array))
+		request := frame.(protocol.Array)
+		info.Stats.Command()
+		cmd, err := FromArray(request)
 		if err != nil {
 			return protocol.Error{Message: err.Error()}
 		}
@@ -104,30 +157,61 @@ read:
 		case DiscardCommand:
 			return protocol.SimpleString{Value: "OK"}
 		default:
+			if msg, refused := ClusterRefusal(request, info.Cluster, dbs.DB(*db), c.Asking, &slot); refused {
+				aborted = true
+				msg.Write(writer)
+				continue
+			}
+			if msg, refused := ReplicaRefusal(request, repl); refused {
+				aborted = true
+				msg.Write(writer)
+				continue
+			}
+			oom := DenyOOM(request)
+			if err := Evict(dbs, repl); err != nil && oom {
+				aborted = true
+				errorFrame(err).Write(writer)
+				continue
+			}
+			denyOOM = denyOOM || oom
+			if _, ok := cmd.(AskingCommand); ok {
+				c.Asking = true
+			}
 			c.Commands = append(c.Commands, cmd)
+			requests = append(requests, request)
 			protocol.SimpleString{Value: "QUEUED"}.Write(writer)
 		}
 	}
 
+	if aborted {
+		return protocol.Error{Prefix: "EXECABORT", Message: "Transaction discarded because of previous errors."}
+	}
+	if err := Evict(dbs, repl); err != nil && denyOOM {
+		return protocol.Error{Prefix: "EXECABORT", Message: "Transaction discarded because of: OOM " + err.Error()}
+	}
 	if len(c.Commands) == 0 {
 		return protocol.Array{Elems: []protocol.Frame{}}
 	}
 
+	repl.Lock()
+	defer repl.Unlock()
+	var writes []replication.Command
 	results := make([]protocol.Frame, len(c.Commands))
 	for i, cmd := range c.Commands {
+		selected := *db
 		switch c := cmd.(type) {
 		case PingCommand:
 			results[i] = c.Execute()
 		case EchoCommand:
 			results[i] = c.Execute()
-		case SetCommand:
-			results[i] = c.Execute(store)
-		case SetTTLCommand:
-			results[i] = c.Execute(store)
-		case GetCommand:
-			results[i] = c.Execute(store)
-		case IncrCommand:
-			results[i] = c.Execute(store)
+		case SelectCommand:
+			results[i] = c.Execute(dbs, db)
+		case DatabasesCommand:
+			results[i] = c.Execute(dbs, *db)
+		case PersistenceCommand:
+			results[i] = c.Execute(dbs, file)
+		case StoreCommand:
+			results[i] = c.Execute(dbs.DB(*db))
 		case ExecCommand:
 			msg := protocol.Error{Message: "EXEC without MULTI"}
 			results[i] = msg
@@ -135,30 +219,23 @@ read:
 			msg := protocol.Error{Message: "nested multi commands are not allowed"}
 			results[i] = msg
 		case ConfigCommand:
-			results[i] = c.Execute(file)
+			results[i] = c.Execute(cfg)
+		case InfoCommand:
+			results[i] = c.Execute(info)
+		case ClusterCommand:
+			results[i] = c.Execute(info.Cluster, dbs.DB(*db))
+		case AskingCommand:
+			results[i] = c.Execute(info.Cluster)
 		default:
 			results[i] = protocol.Error{Message: "unknown command"}
 		}
+		writes = append(writes, Writes(selected, dbs.DB(selected), requests[i], cmd, results[i])...)
 	}
+	repl.Propagate(writes)
 
 	return protocol.Array{Elems: results}
 }
 
-type ConfigCommand struct {
-	Config string
-}
-
-func (c *ConfigCommand) Execute(file *rdb.File) protocol.Frame {
-	switch c.Config {
-	case "dbfilename":
-		return protocol.Array{Elems: []protocol.Frame{protocol.BulkString{Bytes: []byte("dbfilename")}, protocol.BulkString{Bytes: []byte(file.DBFilename)}}}
-	case "dir":
-		return protocol.Array{Elems: []protocol.Frame{protocol.BulkString{Bytes: []byte("dir")}, protocol.BulkString{Bytes: []byte(file.Dir)}}}
-	default:
-		return protocol.Error{Message: fmt.Sprintf("unknown config: %s", c.Config)}
-	}
-}
-
 // FromArray converts a protocol.Array to a command
 func FromArray(arr protocol.Array) (any, error) {
 	if arr.Null || len(arr.Elems) == 0 {
@@ -212,8 +289,8 @@ func FromArray(arr protocol.Array) (any, error) {
 				return nil, fmt.Errorf("set expiration value must be a bulk string")
 			}
 
-			ttlValue, err := strconv.ParseUint(string(ttlStr.Bytes), 10, 32)
-			if err != nil {
+			ttlValue, err := strconv.ParseUint(string(ttlStr.Bytes), 10, 64)
+			if err != nil || (ttlValue > math.MaxUint32 && !strings.HasSuffix(string(unit.Bytes), "AT")) {
 				return nil, fmt.Errorf("invalid expiration value: %s", string(ttlStr.Bytes))
 			}
 
@@ -227,6 +304,10 @@ func FromArray(arr protocol.Array) (any, error) {
 				ttl = time.Duration(ttlValue) * time.Second
 			case "PX":
 				ttl = time.Duration(ttlValue) * time.Millisecond
+			case "EXAT":
+				return SetAtCommand{Key: string(key.Bytes), Value: string(value.Bytes), At: time.Unix(int64(ttlValue), 0)}, nil
+			case "PXAT":
+				return SetAtCommand{Key: string(key.Bytes), Value: string(value.Bytes), At: time.UnixMilli(int64(ttlValue))}, nil
 			default:
 				return nil, fmt.Errorf("invalid expiration unit: %s", string(unit.Bytes))
 			}
@@ -259,31 +340,235 @@ func FromArray(arr protocol.Array) (any, error) {
 		return ExecCommand{}, nil
 	case "DISCARD":
 		return DiscardCommand{}, nil
-	case "CONFIG":
-		if len(arr.Elems) < 3 {
-			return nil, fmt.Errorf("config command requires 2 argument")
-		}
-		isGet, ok := arr.Elems[1].(protocol.BulkString)
+	default:
+		parse, ok := parsers[cmd]
 		if !ok {
-			return nil, fmt.Errorf("config isGet argument must be a bulk string")
+			return nil, fmt.Errorf("unknown command: %s", cmd)
 		}
-		if string(isGet.Bytes) != "GET" {
-			return nil, fmt.Errorf("config isGet command requires GET argument")
+		args, err := bulkStrings(cmd, arr.Elems[1:])
+		if err != nil {
+			return nil, err
 		}
+		return parse(args)
+	}
+}
+
+// parsers builds the commands whose arguments are all plain bulk strings.
+var parsers = map[string]func(args []string) (any, error){
+	"SADD":        parseSAdd,
+	"SREM":        parseSRem,
+	"SMEMBERS":    parseSMembers,
+	"SISMEMBER":   parseSIsMember,
+	"SMISMEMBER":  parseSMIsMember,
+	"SCARD":       parseSCard,
+	"SPOP":        parseSPop,
+	"SRANDMEMBER": parseSRandMember,
+	"SMOVE":       parseSMove,
+	"SINTER":      parseSetOp(store.SetOpInter),
+	"SUNION":      parseSetOp(store.SetOpUnion),
+	"SDIFF":       parseSetOp(store.SetOpDiff),
+	"SINTERSTORE": parseSetOpStore(store.SetOpInter),
+	"SUNIONSTORE": parseSetOpStore(store.SetOpUnion),
+	"SDIFFSTORE":  parseSetOpStore(store.SetOpDiff),
+	"SINTERCARD":  parseSInterCard,
+	"ZADD":        parseZAdd,
+	"ZINCRBY":     parseZIncrBy,
+	"ZREM":        parseZRem,
+	"ZSCORE":      parseZScore,
+	"ZMSCORE":     parseZMScore,
+	"ZCARD":       parseZCard,
+	"ZCOUNT":      parseZCount,
+	"ZRANK":       parseZRank(false),
+	"ZREVRANK":    parseZRank(true),
+	"ZRANGE":      parseZRange,
+
+	"ZUNION":           parseZCombineCommand("zunion", store.SetOpUnion),
+	"ZINTER":           parseZCombineCommand("zinter", store.SetOpInter),
+	"ZDIFF":            parseZCombineCommand("zdiff", store.SetOpDiff),
+	"ZUNIONSTORE":      parseZCombineStoreCommand("zunionstore", store.SetOpUnion),
+	"ZINTERSTORE":      parseZCombineStoreCommand("zinterstore", store.SetOpInter),
+	"ZDIFFSTORE":       parseZCombineStoreCommand("zdiffstore", store.SetOpDiff),
+	"ZPOPMIN":          parseZPop(false),
+	"ZPOPMAX":          parseZPop(true),
+	"BZPOPMIN":         parseBZPop(false),
+	"BZPOPMAX":         parseBZPop(true),
+	"ZMPOP":            parseZMPop,
+	"BZMPOP":           parseBZMPop,
+	"ZRANGESTORE":      parseZRangeStore,
+	"ZREMRANGEBYRANK":  parseZRemRange(store.ZRangeByRank),
+	"ZREMRANGEBYSCORE": parseZRemRange(store.ZRangeByScore),
+	"ZREMRANGEBYLEX":   parseZRemRange(store.ZRangeByLex),
+
+	"XADD":      parseXAdd,
+	"XRANGE":    parseXRange(false),
+	"XREVRANGE": parseXRange(true),
+	"XLEN":      parseXLen,
+	"XDEL":      parseXDel,
+	"XTRIM":     parseXTrim,
+	"XREAD":     parseXRead,
+
+	"XGROUP":     parseXGroup,
+	"XREADGROUP": parseXReadGroup,
+	"XACK":       parseXAck,
+	"XPENDING":   parseXPending,
+	"XCLAIM":     parseXClaim,
+	"XAUTOCLAIM": parseXAutoClaim,
+	"XINFO":      parseXInfo,
+
+	"SETBIT":      parseSetBit,
+	"GETBIT":      parseGetBit,
+	"BITCOUNT":    parseBitCount,
+	"BITPOS":      parseBitPos,
+	"BITOP":       parseBitOp,
+	"BITFIELD":    parseBitField(false),
+	"BITFIELD_RO": parseBitField(true),
+
+	"PFADD":   parsePFAdd,
+	"PFCOUNT": parsePFCount,
+	"PFMERGE": parsePFMerge,
+
+	"GEOADD":         parseGeoAdd,
+	"GEOPOS":         parseGeoPos,
+	"GEOHASH":        parseGeoHash,
+	"GEODIST":        parseGeoDist,
+	"GEOSEARCH":      parseGeoSearch,
+	"GEOSEARCHSTORE": parseGeoSearchStore,
+
+	"KEYS":   parseKeys,
+	"DEL":    parseDel,
+	"UNLINK": parseUnlink,
+	"SCAN":   parseScan,
+	"SSCAN":  parseSScan,
+	"ZSCAN":  parseZScan,
+	"HSCAN":  parseHScan,
+
+	"SELECT":   parseSelect,
+	"MOVE":     parseMove,
+	"SWAPDB":   parseSwapDB,
+	"DBSIZE":   parseNoArgs("dbsize", DBSizeCommand{}),
+	"FLUSHDB":  parseFlushDB,
+	"FLUSHALL": parseFlushAll,
+	"SAVE":     parseNoArgs("save", SaveCommand{}),
+	"BGSAVE":   parseNoArgs("bgsave", BgSaveCommand{}),
+	"LASTSAVE": parseNoArgs("lastsave", LastSaveCommand{}),
+
+	"DUMP":           parseDump,
+	"RESTORE":        parseRestore,
+	"RESTORE-ASKING": parseRestore,
+	"MIGRATE":        parseMigrate,
+	"OBJECT":         parseObject,
+	"MEMORY":         parseMemory,
+
+	"CONFIG":  parseConfig,
+	"CLUSTER": parseCluster,
+	"ASKING":  parseNoArgs("asking", AskingCommand{}),
+	"INFO":    parseInfo,
+
+	"REPLICAOF": parseReplicaOf,
+	"SLAVEOF":   parseReplicaOf,
+	"REPLCONF":  parseReplConf,
+	"PSYNC":     parsePSync,
+	"WAIT":      parseWait,
+	"WAITAOF":   parseWaitAOF,
+}
+
+// denyOOMCommands are the commands that may use more memory, which are
+// refused while the keyspace is over maxmemory and no key can be evicted.
+// Subcommands are listed as "NAME|SUBCOMMAND".
+var denyOOMCommands = map[string]bool{
+	"SET":                   true,
+	"INCR":                  true,
+	"SADD":                  true,
+	"SMOVE":                 true,
+	"SINTERSTORE":           true,
+	"SUNIONSTORE":           true,
+	"SDIFFSTORE":            true,
+	"ZADD":                  true,
+	"ZINCRBY":               true,
+	"ZUNIONSTORE":           true,
+	"ZINTERSTORE":           true,
+	"ZDIFFSTORE":            true,
+	"ZRANGESTORE":           true,
+	"XADD":                  true,
+	"XGROUP|CREATE":         true,
+	"XGROUP|CREATECONSUMER": true,
+	"SETBIT":                true,
+	"BITOP":                 true,
+	"BITFIELD":              true,
+	"PFADD":                 true,
+	"PFMERGE":               true,
+	"GEOADD":                true,
+	"GEOSEARCHSTORE":        true,
+	"RESTORE":               true,
+	"RESTORE-ASKING":        true,
+}
 
-		config, ok := arr.Elems[2].(protocol.BulkString)
+// DenyOOM reports whether request is a command refused when over maxmemory.
+func DenyOOM(request protocol.Array) bool {
+	return commandIn(denyOOMCommands, request)
+}
+
+// commandIn reports whether the command of request, or its subcommand, is
+// in set.
+func commandIn(set map[string]bool, request protocol.Array) bool {
+	names, err := bulkStrings("command", request.Elems[:min(len(request.Elems), 2)])
+	if err != nil || len(names) == 0 {
+		return false
+	}
+	name := strings.ToUpper(names[0])
+	if len(names) == 2 && set[name+"|"+strings.ToUpper(names[1])] {
+		return true
+	}
+	return set[name]
+}
+
+// bulkStrings converts the arguments of command name to strings.
+func bulkStrings(name string, elems []protocol.Frame) ([]string, error) {
+	args := make([]string, len(elems))
+	for i, elem := range elems {
+		bulk, ok := elem.(protocol.BulkString)
 		if !ok {
-			return nil, fmt.Errorf("config argument must be a bulk string")
-		}
-		switch string(config.Bytes) {
-		case "dir":
-			return ConfigCommand{Config: string(config.Bytes)}, nil
-		case "dbfilename":
-			return ConfigCommand{Config: string(config.Bytes)}, nil
-		default:
-			return nil, fmt.Errorf("unknown config: %s", string(config.Bytes))
+			return nil, fmt.Errorf("%s arguments must be bulk strings", strings.ToLower(name))
 		}
-	default:
-		return nil, fmt.Errorf("unknown command: %s", cmd)
+		args[i] = string(bulk.Bytes)
+	}
+	return args, nil
+}
+
+// errorFrame converts a store error into its RESP error reply.
+func errorFrame(err error) protocol.Frame {
+	if errors.Is(err, store.ErrWrongType) {
+		return protocol.Error{Prefix: "WRONGTYPE", Message: err.Error()}
+	}
+	if errors.Is(err, store.ErrOOM) {
+		return protocol.Error{Prefix: "OOM", Message: err.Error()}
+	}
+	if errors.Is(err, store.ErrBusyKey) {
+		return protocol.Error{Prefix: "BUSYKEY", Message: err.Error()}
+	}
+	if errors.Is(err, store.ErrBusyGroup) {
+		return protocol.Error{Prefix: "BUSYGROUP", Message: err.Error()}
+	}
+	if errors.Is(err, store.ErrNotHLL) {
+		return protocol.Error{Prefix: "WRONGTYPE", Message: err.Error()}
+	}
+	if errors.Is(err, store.ErrCorruptHLL) {
+		return protocol.Error{Prefix: "INVALIDOBJ", Message: err.Error()}
+	}
+	if errors.As(err, &store.NoGroupError{}) {
+		return protocol.Error{Prefix: "NOGROUP", Message: err.Error()}
+	}
+	if msg, ok := clusterErrorFrame(err); ok {
+		return msg
+	}
+	return protocol.Error{Message: err.Error()}
+}
+
+// bulkArray encodes values as an array of bulk strings.
+func bulkArray(values []string) protocol.Array {
+	elems := make([]protocol.Frame, len(values))
+	for i, value := range values {
+		elems[i] = protocol.BulkString{Bytes: []byte(value)}
 	}
+	return protocol.Array{Elems: elems}
 }
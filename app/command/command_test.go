@@ -78,6 +78,16 @@ func TestFromArray(t *testing.T) {
 			},
 			want: SetTTLCommand{Key: "key", Value: "value", TTL: 10 * time.Millisecond},
 		},
+		{
+			name: "set-ttl pxat",
+			in:   request("SET", "key", "value", "PXAT", "1700000000000"),
+			want: SetAtCommand{Key: "key", Value: "value", At: time.UnixMilli(1700000000000)},
+		},
+		{
+			name:    "set-ttl ex out of range",
+			in:      request("SET", "key", "value", "EX", "1700000000000"),
+			wantErr: true,
+		},
 		{
 			name: "set-ttl invalid",
 			in: protocol.Array{
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// ConfigCommand implements CONFIG GET and CONFIG SET. Subcommand is upper
+// case. Args are the patterns of GET, or the parameter and value pairs of
+// SET, which are applied in order up to the first that fails.
+type ConfigCommand struct {
+	Subcommand string
+	Args       []string
+}
+
+func (c ConfigCommand) Execute(cfg *config.Config) protocol.Frame {
+	if c.Subcommand == "GET" {
+		elems := []protocol.Frame{}
+		for _, pair := range cfg.Get(c.Args) {
+			elems = append(elems, bulk(pair[0]), bulk(pair[1]))
+		}
+		return protocol.Array{Elems: elems}
+	}
+
+	for i := 0; i < len(c.Args); i += 2 {
+		if err := cfg.Set(c.Args[i], c.Args[i+1]); err != nil {
+			return protocol.Error{Message: err.Error()}
+		}
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+func parseConfig(args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for 'config' command")
+	}
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "GET":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("wrong number of arguments for 'config|get' command")
+		}
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return nil, fmt.Errorf("wrong number of arguments for 'config|set' command")
+		}
+	default:
+		return nil, fmt.Errorf("unknown subcommand '%s'. Try CONFIG HELP.", args[0])
+	}
+	return ConfigCommand{Subcommand: subcommand, Args: args[1:]}, nil
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayConfig(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "get", in: request("CONFIG", "get", "dir", "max*"), want: ConfigCommand{Subcommand: "GET", Args: []string{"dir", "max*"}}},
+		{name: "set", in: request("CONFIG", "SET", "maxmemory", "1mb"), want: ConfigCommand{Subcommand: "SET", Args: []string{"maxmemory", "1mb"}}},
+		{name: "get without pattern", in: request("CONFIG", "GET"), wantErr: true},
+		{name: "set without value", in: request("CONFIG", "SET", "maxmemory"), wantErr: true},
+		{name: "unknown", in: request("CONFIG", "REWRITE"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestConfigCommandReplies(t *testing.T) {
+	t.Parallel()
+	value := "0"
+	cfg := config.New()
+	cfg.Register("maxmemory", config.Param{Get: func() string { return value }, Set: func(v string) error {
+		value = v
+		return nil
+	}})
+	cfg.Register("dir", config.Param{Get: func() string { return "/data" }})
+
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, ConfigCommand{Subcommand: "SET", Args: []string{"maxmemory", "100"}}.Execute(cfg))
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{bulk("dir"), bulk("/data"), bulk("maxmemory"), bulk("100")}},
+		ConfigCommand{Subcommand: "GET", Args: []string{"*"}}.Execute(cfg))
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{}}, ConfigCommand{Subcommand: "GET", Args: []string{"missing"}}.Execute(cfg))
+	assert.Equal(t, protocol.Error{Message: "CONFIG SET failed (possibly related to argument 'dir') - can't set immutable config"},
+		ConfigCommand{Subcommand: "SET", Args: []string{"dir", "/tmp"}}.Execute(cfg))
+}
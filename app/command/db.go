@@ -0,0 +1,199 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// DatabasesCommand is a command that spans databases. db is the index of
+// the database the client has selected.
+type DatabasesCommand interface {
+	Execute(dbs *store.Databases, db int) protocol.Frame
+}
+
+// PersistenceCommand is a command that snapshots the databases to the RDB file.
+type PersistenceCommand interface {
+	Execute(dbs *store.Databases, file *rdb.File) protocol.Frame
+}
+
+var errDBIndexOutOfRange = protocol.Error{Message: "DB index is out of range"}
+
+// SelectCommand switches the client to database Index.
+type SelectCommand struct {
+	Index int
+}
+
+// Execute sets db, the client's selected database, to Index.
+func (c SelectCommand) Execute(dbs *store.Databases, db *int) protocol.Frame {
+	if c.Index < 0 || c.Index >= dbs.Len() {
+		return errDBIndexOutOfRange
+	}
+	*db = c.Index
+	return protocol.SimpleString{Value: "OK"}
+}
+
+type MoveCommand struct {
+	Key string
+	DB  int
+}
+
+func (c MoveCommand) Execute(dbs *store.Databases, db int) protocol.Frame {
+	if c.DB < 0 || c.DB >= dbs.Len() {
+		return errDBIndexOutOfRange
+	}
+	moved, err := dbs.Move(db, c.DB, c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: boolToInt(moved)}
+}
+
+type SwapDBCommand struct {
+	First  int
+	Second int
+}
+
+func (c SwapDBCommand) Execute(dbs *store.Databases, db int) protocol.Frame {
+	if c.First < 0 || c.First >= dbs.Len() || c.Second < 0 || c.Second >= dbs.Len() {
+		return errDBIndexOutOfRange
+	}
+	dbs.Swap(c.First, c.Second)
+	return protocol.SimpleString{Value: "OK"}
+}
+
+type DBSizeCommand struct{}
+
+func (c DBSizeCommand) Execute(store *store.Store) protocol.Frame {
+	return protocol.Integer{Value: store.DBSize()}
+}
+
+// FlushDBCommand implements FLUSHDB. With ASYNC the old keyspace is freed
+// in the background.
+type FlushDBCommand struct {
+	Async bool
+}
+
+func (c FlushDBCommand) Execute(store *store.Store) protocol.Frame {
+	store.Flush(c.Async)
+	return protocol.SimpleString{Value: "OK"}
+}
+
+type FlushAllCommand struct {
+	Async bool
+}
+
+func (c FlushAllCommand) Execute(dbs *store.Databases, db int) protocol.Frame {
+	dbs.FlushAll(c.Async)
+	return protocol.SimpleString{Value: "OK"}
+}
+
+type SaveCommand struct{}
+
+func (c SaveCommand) Execute(dbs *store.Databases, file *rdb.File) protocol.Frame {
+	if err := file.Save(dbs.SaveRDB); err != nil {
+		return protocol.Error{Message: err.Error()}
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+type BgSaveCommand struct{}
+
+func (c BgSaveCommand) Execute(dbs *store.Databases, file *rdb.File) protocol.Frame {
+	if err := file.BackgroundSave(dbs.SaveRDB); err != nil {
+		return protocol.Error{Message: err.Error()}
+	}
+	return protocol.SimpleString{Value: "Background saving started"}
+}
+
+type LastSaveCommand struct{}
+
+func (c LastSaveCommand) Execute(dbs *store.Databases, file *rdb.File) protocol.Frame {
+	return protocol.Integer{Value: int(file.LastSave().Unix())}
+}
+
+func parseSelect(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("select command requires 1 argument")
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	return SelectCommand{Index: index}, nil
+}
+
+func parseMove(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("move command requires 2 arguments")
+	}
+	db, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	return MoveCommand{Key: args[0], DB: db}, nil
+}
+
+func parseSwapDB(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("swapdb command requires 2 arguments")
+	}
+	first, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid first DB index")
+	}
+	second, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid second DB index")
+	}
+	return SwapDBCommand{First: first, Second: second}, nil
+}
+
+// parseFlushMode parses the optional ASYNC or SYNC argument of the flush
+// commands and reports whether it is ASYNC.
+func parseFlushMode(name string, args []string) (bool, error) {
+	if len(args) > 1 {
+		return false, fmt.Errorf("%s command takes at most 1 argument", name)
+	}
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch strings.ToUpper(args[0]) {
+	case "ASYNC":
+		return true, nil
+	case "SYNC":
+		return false, nil
+	default:
+		return false, fmt.Errorf("syntax error")
+	}
+}
+
+func parseFlushDB(args []string) (any, error) {
+	async, err := parseFlushMode("flushdb", args)
+	if err != nil {
+		return nil, err
+	}
+	return FlushDBCommand{Async: async}, nil
+}
+
+func parseFlushAll(args []string) (any, error) {
+	async, err := parseFlushMode("flushall", args)
+	if err != nil {
+		return nil, err
+	}
+	return FlushAllCommand{Async: async}, nil
+}
+
+// parseNoArgs returns a parser for a command without arguments.
+func parseNoArgs(name string, cmd any) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("%s command takes no arguments", name)
+		}
+		return cmd, nil
+	}
+}
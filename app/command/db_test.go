@@ -0,0 +1,89 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromArrayDatabases(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "select", in: request("SELECT", "3"), want: SelectCommand{Index: 3}},
+		{name: "select not integer", in: request("SELECT", "x"), wantErr: true},
+		{name: "move", in: request("MOVE", "k", "1"), want: MoveCommand{Key: "k", DB: 1}},
+		{name: "move arity", in: request("MOVE", "k"), wantErr: true},
+		{name: "swapdb", in: request("SWAPDB", "0", "1"), want: SwapDBCommand{First: 0, Second: 1}},
+		{name: "swapdb invalid", in: request("SWAPDB", "0", "b"), wantErr: true},
+		{name: "dbsize", in: request("DBSIZE"), want: DBSizeCommand{}},
+		{name: "flushdb", in: request("FLUSHDB"), want: FlushDBCommand{}},
+		{name: "flushdb async", in: request("FLUSHDB", "async"), want: FlushDBCommand{Async: true}},
+		{name: "flushall sync", in: request("FLUSHALL", "SYNC"), want: FlushAllCommand{}},
+		{name: "flushall bad mode", in: request("FLUSHALL", "LATER"), wantErr: true},
+		{name: "save", in: request("SAVE"), want: SaveCommand{}},
+		{name: "bgsave arity", in: request("BGSAVE", "SCHEDULE", "x"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDatabaseCommandReplies(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(4)
+	ok := protocol.SimpleString{Value: "OK"}
+
+	db := 0
+	assert.Equal(t, errDBIndexOutOfRange, SelectCommand{Index: 4}.Execute(dbs, &db))
+	assert.Equal(t, errDBIndexOutOfRange, SelectCommand{Index: -1}.Execute(dbs, &db))
+	assert.Equal(t, ok, SelectCommand{Index: 2}.Execute(dbs, &db))
+	assert.Equal(t, 2, db)
+
+	SetCommand{Key: "k", Value: "v"}.Execute(dbs.DB(db))
+	assert.Equal(t, protocol.Integer{Value: 1}, DBSizeCommand{}.Execute(dbs.DB(2)))
+	assert.Equal(t, protocol.Integer{Value: 1}, MoveCommand{Key: "k", DB: 0}.Execute(dbs, db))
+	assert.Equal(t, protocol.Integer{Value: 0}, MoveCommand{Key: "k", DB: 0}.Execute(dbs, db))
+	assert.Equal(t, errDBIndexOutOfRange, MoveCommand{Key: "k", DB: 9}.Execute(dbs, db))
+	assert.Equal(t, protocol.Error{Message: "source and destination objects are the same"}, MoveCommand{Key: "k", DB: 2}.Execute(dbs, db))
+
+	assert.Equal(t, ok, SwapDBCommand{First: 0, Second: 2}.Execute(dbs, db))
+	assert.Equal(t, protocol.Integer{Value: 1}, DBSizeCommand{}.Execute(dbs.DB(2)))
+	assert.Equal(t, errDBIndexOutOfRange, SwapDBCommand{First: 0, Second: 4}.Execute(dbs, db))
+
+	assert.Equal(t, ok, FlushDBCommand{}.Execute(dbs.DB(2)))
+	assert.Equal(t, protocol.Integer{Value: 0}, DBSizeCommand{}.Execute(dbs.DB(2)))
+	SetCommand{Key: "k", Value: "v"}.Execute(dbs.DB(1))
+	assert.Equal(t, ok, FlushAllCommand{Async: true}.Execute(dbs, db))
+	assert.Equal(t, protocol.Integer{Value: 0}, DBSizeCommand{}.Execute(dbs.DB(1)))
+}
+
+func TestSaveCommand(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(2)
+	SetCommand{Key: "k", Value: "v"}.Execute(dbs.DB(1))
+	file := rdb.NewFile(t.TempDir(), "dump.rdb")
+
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, SaveCommand{}.Execute(dbs, file))
+
+	loaded := store.NewDatabases(2)
+	require.NoError(t, file.Load(loaded.LoadRDB))
+	assert.Equal(t, bulk("v"), GetCommand{Key: "k"}.Execute(loaded.DB(1)))
+}
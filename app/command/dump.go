@@ -0,0 +1,112 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+type DumpCommand struct {
+	Key string
+}
+
+func (c DumpCommand) Execute(store *store.Store) protocol.Frame {
+	payload, _, ok := store.Dump(c.Key)
+	if !ok {
+		return protocol.BulkNullString{}
+	}
+	return protocol.BulkString{Bytes: payload}
+}
+
+// RestoreCommand implements RESTORE. TTL is in milliseconds, relative
+// unless AbsTTL is set, and 0 means no expiry. IdleTime, in seconds, and
+// Freq are -1 when not given.
+type RestoreCommand struct {
+	Key      string
+	TTL      int64
+	Payload  []byte
+	Replace  bool
+	AbsTTL   bool
+	IdleTime int64
+	Freq     int
+}
+
+func (c RestoreCommand) Execute(s *store.Store) protocol.Frame {
+	opts := store.RestoreOptions{Replace: c.Replace}
+	switch {
+	case c.TTL == 0:
+	case c.AbsTTL:
+		opts.TTL = time.UnixMilli(c.TTL)
+	default:
+		opts.TTL = time.Now().Add(time.Duration(c.TTL) * time.Millisecond)
+	}
+	if c.IdleTime >= 0 {
+		idle := time.Duration(c.IdleTime) * time.Second
+		opts.Idle = &idle
+	}
+	if c.Freq >= 0 {
+		freq := uint8(c.Freq)
+		opts.Freq = &freq
+	}
+	if err := s.Restore(c.Key, c.Payload, opts); err != nil {
+		return errorFrame(err)
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+func parseDump(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("dump command requires 1 argument")
+	}
+	return DumpCommand{Key: args[0]}, nil
+}
+
+func parseRestore(args []string) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("restore command requires at least 3 arguments")
+	}
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("Invalid TTL value, must be >= 0")
+	}
+	cmd := RestoreCommand{Key: args[0], TTL: ttl, Payload: []byte(args[2]), IdleTime: -1, Freq: -1}
+
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "REPLACE":
+			cmd.Replace = true
+		case option == "ABSTTL":
+			cmd.AbsTTL = true
+		case option == "IDLETIME" && i+1 < len(args) && cmd.Freq == -1:
+			i++
+			idle, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			if idle < 0 {
+				return nil, fmt.Errorf("Invalid IDLETIME value, must be >= 0")
+			}
+			cmd.IdleTime = idle
+		case option == "FREQ" && i+1 < len(args) && cmd.IdleTime == -1:
+			i++
+			freq, err := strconv.Atoi(args[i])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			if freq < 0 || freq > 255 {
+				return nil, fmt.Errorf("Invalid FREQ value, must be >= 0 and <= 255")
+			}
+			cmd.Freq = freq
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+	return cmd, nil
+}
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayRestore(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "dump", in: request("DUMP", "k"), want: DumpCommand{Key: "k"}},
+		{
+			name: "restore",
+			in:   request("RESTORE", "k", "0", "payload"),
+			want: RestoreCommand{Key: "k", Payload: []byte("payload"), IdleTime: -1, Freq: -1},
+		},
+		{
+			name: "restore options",
+			in:   request("RESTORE", "k", "1700000000000", "p", "replace", "ABSTTL", "IDLETIME", "10"),
+			want: RestoreCommand{Key: "k", TTL: 1700000000000, Payload: []byte("p"), Replace: true, AbsTTL: true, IdleTime: 10, Freq: -1},
+		},
+		{name: "restore freq", in: request("RESTORE", "k", "0", "p", "FREQ", "255"), want: RestoreCommand{Key: "k", Payload: []byte("p"), IdleTime: -1, Freq: 255}},
+		{name: "restore negative ttl", in: request("RESTORE", "k", "-1", "p"), wantErr: true},
+		{name: "restore freq out of range", in: request("RESTORE", "k", "0", "p", "FREQ", "256"), wantErr: true},
+		{name: "restore idletime and freq", in: request("RESTORE", "k", "0", "p", "IDLETIME", "1", "FREQ", "2"), wantErr: true},
+		{name: "restore unknown option", in: request("RESTORE", "k", "0", "p", "KEEPTTL"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDumpRestoreReplies(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	SetCommand{Key: "k", Value: "hello"}.Execute(s)
+
+	assert.Equal(t, protocol.BulkNullString{}, DumpCommand{Key: "missing"}.Execute(s))
+	payload := DumpCommand{Key: "k"}.Execute(s).(protocol.BulkString).Bytes
+
+	restore := RestoreCommand{Key: "k", Payload: payload, IdleTime: -1, Freq: -1}
+	assert.Equal(t, protocol.Error{Prefix: "BUSYKEY", Message: "Target key name already exists."}, restore.Execute(s))
+
+	restore.Key = "copy"
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, restore.Execute(s))
+	assert.Equal(t, bulk("hello"), GetCommand{Key: "copy"}.Execute(s))
+
+	corrupt := append([]byte(nil), payload...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	restore.Key, restore.Payload = "bad", corrupt
+	assert.Equal(t, protocol.Error{Message: "DUMP payload version or checksum are wrong"}, restore.Execute(s))
+}
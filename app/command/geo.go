@@ -0,0 +1,396 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// GeoAddCommand implements GEOADD on top of ZADD, with the members scored
+// by their geohash.
+type GeoAddCommand struct {
+	Key          string
+	Flags        store.ZAddFlags
+	CountChanged bool
+	Members      []store.ScoredMember
+}
+
+func (c GeoAddCommand) Execute(store *store.Store) protocol.Frame {
+	changed, err := store.ZAdd(c.Key, c.Flags, c.CountChanged, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: changed}
+}
+
+type GeoPosCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c GeoPosCommand) Execute(s *store.Store) protocol.Frame {
+	scores, found, err := s.ZMScore(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	elems := make([]protocol.Frame, len(scores))
+	for i, score := range scores {
+		if !found[i] {
+			elems[i] = protocol.Array{Null: true}
+			continue
+		}
+		lon, lat := store.GeoDecode(score)
+		elems[i] = geoCoordFrame(lon, lat)
+	}
+	return protocol.Array{Elems: elems}
+}
+
+type GeoHashCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c GeoHashCommand) Execute(s *store.Store) protocol.Frame {
+	scores, found, err := s.ZMScore(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	elems := make([]protocol.Frame, len(scores))
+	for i, score := range scores {
+		if !found[i] {
+			elems[i] = protocol.BulkNullString{}
+			continue
+		}
+		elems[i] = bulk(store.GeoHashString(score))
+	}
+	return protocol.Array{Elems: elems}
+}
+
+// GeoDistCommand implements GEODIST. Unit is the number of meters per unit.
+type GeoDistCommand struct {
+	Key     string
+	Member1 string
+	Member2 string
+	Unit    float64
+}
+
+func (c GeoDistCommand) Execute(s *store.Store) protocol.Frame {
+	scores, found, err := s.ZMScore(c.Key, []string{c.Member1, c.Member2})
+	if err != nil {
+		return errorFrame(err)
+	}
+	if !found[0] || !found[1] {
+		return protocol.BulkNullString{}
+	}
+	lon1, lat1 := store.GeoDecode(scores[0])
+	lon2, lat2 := store.GeoDecode(scores[1])
+	return bulk(formatGeoDistance(store.GeoDistance(lon1, lat1, lon2, lat2) / c.Unit))
+}
+
+// GeoSearchCommand implements GEOSEARCH.
+type GeoSearchCommand struct {
+	Key       string
+	Query     store.GeoQuery
+	WithCoord bool
+	WithDist  bool
+	WithHash  bool
+}
+
+func (c GeoSearchCommand) Execute(store *store.Store) protocol.Frame {
+	matches, err := store.GeoSearch(c.Key, c.Query)
+	if err != nil {
+		return errorFrame(err)
+	}
+
+	elems := make([]protocol.Frame, len(matches))
+	for i, m := range matches {
+		if !c.WithDist && !c.WithHash && !c.WithCoord {
+			elems[i] = bulk(m.Member)
+			continue
+		}
+		match := []protocol.Frame{bulk(m.Member)}
+		if c.WithDist {
+			match = append(match, bulk(formatGeoDistance(m.Dist)))
+		}
+		if c.WithHash {
+			match = append(match, protocol.Integer{Value: int(m.Score)})
+		}
+		if c.WithCoord {
+			match = append(match, geoCoordFrame(m.Lon, m.Lat))
+		}
+		elems[i] = protocol.Array{Elems: match}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+// GeoSearchStoreCommand implements GEOSEARCHSTORE.
+type GeoSearchStoreCommand struct {
+	Destination string
+	Source      string
+	Query       store.GeoQuery
+	StoreDist   bool
+}
+
+func (c GeoSearchStoreCommand) Execute(store *store.Store) protocol.Frame {
+	n, err := store.GeoSearchStore(c.Destination, c.Source, c.Query, c.StoreDist)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+// formatGeoDistance renders a distance with the four decimals Redis uses.
+func formatGeoDistance(dist float64) string {
+	return strconv.FormatFloat(dist, 'f', 4, 64)
+}
+
+// formatGeoCoord renders a coordinate with up to 17 decimals and no
+// trailing zeros, as Redis does.
+func formatGeoCoord(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func geoCoordFrame(lon float64, lat float64) protocol.Array {
+	return bulkArray([]string{formatGeoCoord(lon), formatGeoCoord(lat)})
+}
+
+// parseGeoUnit returns the number of meters in unit.
+func parseGeoUnit(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	default:
+		return 0, fmt.Errorf("unsupported unit provided. please use M, KM, FT, MI")
+	}
+}
+
+// parseLonLat parses a longitude and latitude that can be indexed.
+func parseLonLat(lonArg string, latArg string) (float64, float64, error) {
+	lon, err := parseScore(lonArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, err := parseScore(latArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !store.GeoValid(lon, lat) {
+		return 0, 0, fmt.Errorf("invalid longitude,latitude pair %s,%s",
+			strconv.FormatFloat(lon, 'f', 6, 64), strconv.FormatFloat(lat, 'f', 6, 64))
+	}
+	return lon, lat, nil
+}
+
+// parseGeoSize parses a non-negative search size.
+func parseGeoSize(arg string, name string, negative string) (float64, error) {
+	size, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, fmt.Errorf("need numeric %s", name)
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("%s", negative)
+	}
+	return size, nil
+}
+
+func parseGeoAdd(args []string) (any, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("geoadd command requires at least 4 arguments")
+	}
+
+	cmd := GeoAddCommand{Key: args[0]}
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			cmd.Flags.NX = true
+		case "XX":
+			cmd.Flags.XX = true
+		case "CH":
+			cmd.CountChanged = true
+		default:
+			break options
+		}
+	}
+	if (len(args)-i)%3 != 0 || i == len(args) {
+		return nil, fmt.Errorf("syntax error")
+	}
+	if cmd.Flags.NX && cmd.Flags.XX {
+		return nil, fmt.Errorf("XX and NX options at the same time are not compatible")
+	}
+
+	for ; i < len(args); i += 3 {
+		lon, lat, err := parseLonLat(args[i], args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Members = append(cmd.Members, store.ScoredMember{Member: args[i+2], Score: store.GeoScore(lon, lat)})
+	}
+	return cmd, nil
+}
+
+func parseGeoPos(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("geopos command requires at least 1 argument")
+	}
+	return GeoPosCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseGeoHash(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("geohash command requires at least 1 argument")
+	}
+	return GeoHashCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseGeoDist(args []string) (any, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, fmt.Errorf("syntax error")
+	}
+	cmd := GeoDistCommand{Key: args[0], Member1: args[1], Member2: args[2], Unit: 1}
+	if len(args) == 4 {
+		unit, err := parseGeoUnit(args[3])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Unit = unit
+	}
+	return cmd, nil
+}
+
+// geoSearchOptions are the GEOSEARCH arguments after the key, shared with
+// GEOSEARCHSTORE which additionally accepts STOREDIST.
+type geoSearchOptions struct {
+	query     store.GeoQuery
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+func parseGeoSearchOptions(name string, args []string, storing bool) (geoSearchOptions, error) {
+	var opts geoSearchOptions
+	q := &opts.query
+	fromLonLat, byRadius := false, false
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		switch arg := strings.ToUpper(args[i]); {
+		case arg == "WITHCOORD":
+			opts.withCoord = true
+		case arg == "WITHDIST":
+			opts.withDist = true
+		case arg == "WITHHASH":
+			opts.withHash = true
+		case arg == "ANY":
+			q.Any = true
+		case arg == "ASC":
+			q.Sort = store.GeoSortAsc
+		case arg == "DESC":
+			q.Sort = store.GeoSortDesc
+		case arg == "STOREDIST" && storing:
+			opts.storeDist = true
+		case arg == "COUNT" && remaining >= 1:
+			count, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return opts, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count <= 0 {
+				return opts, fmt.Errorf("COUNT must be > 0")
+			}
+			q.Count = int(count)
+			i++
+		case arg == "FROMMEMBER" && remaining >= 1 && !q.FromMember && !fromLonLat:
+			q.FromMember, q.Member = true, args[i+1]
+			i++
+		case arg == "FROMLONLAT" && remaining >= 2 && !q.FromMember && !fromLonLat:
+			lon, lat, err := parseLonLat(args[i+1], args[i+2])
+			if err != nil {
+				return opts, err
+			}
+			q.Lon, q.Lat, fromLonLat = lon, lat, true
+			i += 2
+		case arg == "BYRADIUS" && remaining >= 2 && !byRadius && !q.ByBox:
+			radius, err := parseGeoSize(args[i+1], "radius", "radius cannot be negative")
+			if err != nil {
+				return opts, err
+			}
+			unit, err := parseGeoUnit(args[i+2])
+			if err != nil {
+				return opts, err
+			}
+			q.Radius, q.Unit, byRadius = radius, unit, true
+			i += 2
+		case arg == "BYBOX" && remaining >= 3 && !byRadius && !q.ByBox:
+			width, err := parseGeoSize(args[i+1], "width", "height or width cannot be negative")
+			if err != nil {
+				return opts, err
+			}
+			height, err := parseGeoSize(args[i+2], "height", "height or width cannot be negative")
+			if err != nil {
+				return opts, err
+			}
+			unit, err := parseGeoUnit(args[i+3])
+			if err != nil {
+				return opts, err
+			}
+			q.Width, q.Height, q.Unit, q.ByBox = width, height, unit, true
+			i += 3
+		default:
+			return opts, fmt.Errorf("syntax error")
+		}
+	}
+
+	if storing && (opts.withCoord || opts.withDist || opts.withHash) {
+		return opts, fmt.Errorf("%s is not compatible with WITHDIST, WITHHASH and WITHCOORD options", name)
+	}
+	if !q.FromMember && !fromLonLat {
+		return opts, fmt.Errorf("exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", strings.ToLower(name))
+	}
+	if !byRadius && !q.ByBox {
+		return opts, fmt.Errorf("exactly one of BYRADIUS and BYBOX can be specified for %s", strings.ToLower(name))
+	}
+	if q.Any && q.Count == 0 {
+		return opts, fmt.Errorf("the ANY argument requires COUNT argument")
+	}
+	return opts, nil
+}
+
+func parseGeoSearch(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("geosearch command requires at least 1 argument")
+	}
+	opts, err := parseGeoSearchOptions("GEOSEARCH", args[1:], false)
+	if err != nil {
+		return nil, err
+	}
+	return GeoSearchCommand{
+		Key:       args[0],
+		Query:     opts.query,
+		WithCoord: opts.withCoord,
+		WithDist:  opts.withDist,
+		WithHash:  opts.withHash,
+	}, nil
+}
+
+func parseGeoSearchStore(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("geosearchstore command requires at least 2 arguments")
+	}
+	opts, err := parseGeoSearchOptions("GEOSEARCHSTORE", args[2:], true)
+	if err != nil {
+		return nil, err
+	}
+	return GeoSearchStoreCommand{Destination: args[0], Source: args[1], Query: opts.query, StoreDist: opts.storeDist}, nil
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayGeo(t *testing.T) {
+	t.Parallel()
+	palermo := store.ScoredMember{Member: "Palermo", Score: 3479099956230698}
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "geoadd",
+			in:   request("GEOADD", "k", "CH", "13.361389", "38.115556", "Palermo"),
+			want: GeoAddCommand{Key: "k", CountChanged: true, Members: []store.ScoredMember{palermo}},
+		},
+		{name: "geoadd invalid pair", in: request("GEOADD", "k", "181", "10", "m"), wantErr: true},
+		{name: "geoadd partial triple", in: request("GEOADD", "k", "1", "2", "m", "3"), wantErr: true},
+		{name: "geoadd nx xx", in: request("GEOADD", "k", "NX", "XX", "1", "2", "m"), wantErr: true},
+		{name: "geodist unit", in: request("GEODIST", "k", "a", "b", "KM"), want: GeoDistCommand{Key: "k", Member1: "a", Member2: "b", Unit: 1000}},
+		{name: "geodist bad unit", in: request("GEODIST", "k", "a", "b", "yd"), wantErr: true},
+		{
+			name: "geosearch",
+			in:   request("GEOSEARCH", "k", "FROMMEMBER", "a", "BYBOX", "4", "2", "mi", "DESC", "COUNT", "3", "ANY", "WITHHASH"),
+			want: GeoSearchCommand{Key: "k", WithHash: true, Query: store.GeoQuery{
+				FromMember: true, Member: "a", ByBox: true, Width: 4, Height: 2, Unit: 1609.34,
+				Sort: store.GeoSortDesc, Count: 3, Any: true,
+			}},
+		},
+		{name: "geosearch without center", in: request("GEOSEARCH", "k", "BYRADIUS", "1", "m"), wantErr: true},
+		{name: "geosearch two centers", in: request("GEOSEARCH", "k", "FROMMEMBER", "a", "FROMLONLAT", "1", "2", "BYRADIUS", "1", "m"), wantErr: true},
+		{name: "geosearch without shape", in: request("GEOSEARCH", "k", "FROMMEMBER", "a"), wantErr: true},
+		{name: "geosearch any without count", in: request("GEOSEARCH", "k", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "ANY"), wantErr: true},
+		{name: "geosearch zero count", in: request("GEOSEARCH", "k", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "COUNT", "0"), wantErr: true},
+		{name: "geosearch negative radius", in: request("GEOSEARCH", "k", "FROMMEMBER", "a", "BYRADIUS", "-1", "m"), wantErr: true},
+		{name: "geosearch storedist", in: request("GEOSEARCH", "k", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "STOREDIST"), wantErr: true},
+		{
+			name: "geosearchstore",
+			in:   request("GEOSEARCHSTORE", "d", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "STOREDIST"),
+			want: GeoSearchStoreCommand{Destination: "d", Source: "k", StoreDist: true, Query: store.GeoQuery{
+				Lon: 15, Lat: 37, Radius: 200, Unit: 1000,
+			}},
+		},
+		{name: "geosearchstore withdist", in: request("GEOSEARCHSTORE", "d", "k", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "WITHDIST"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGeoCommandReplies(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	s.ZAdd("Sicily", store.ZAddFlags{}, false, []store.ScoredMember{
+		{Member: "Palermo", Score: store.GeoScore(13.361389, 38.115556)},
+		{Member: "Catania", Score: store.GeoScore(15.087269, 37.502669)},
+	})
+
+	got := GeoPosCommand{Key: "Sicily", Members: []string{"Palermo", "x"}}.Execute(s)
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{
+		bulkArray([]string{"13.36138933897018433", "38.11555639549629859"}),
+		protocol.Array{Null: true},
+	}}, got)
+
+	got = GeoDistCommand{Key: "Sicily", Member1: "Palermo", Member2: "Catania", Unit: 1000}.Execute(s)
+	assert.Equal(t, bulk("166.2742"), got)
+
+	got = GeoHashCommand{Key: "Sicily", Members: []string{"Catania"}}.Execute(s)
+	assert.Equal(t, bulkArray([]string{"sqdtr74hyu0"}), got)
+
+	got = GeoSearchCommand{
+		Key:      "Sicily",
+		Query:    store.GeoQuery{Lon: 15, Lat: 37, Radius: 100, Unit: 1000},
+		WithDist: true,
+	}.Execute(s)
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{
+		protocol.Array{Elems: []protocol.Frame{bulk("Catania"), bulk("56.4413")}},
+	}}, got)
+}
@@ -0,0 +1,66 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+type PFAddCommand struct {
+	Key      string
+	Elements []string
+}
+
+func (c PFAddCommand) Execute(store *store.Store) protocol.Frame {
+	updated, err := store.PFAdd(c.Key, c.Elements)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: boolToInt(updated)}
+}
+
+type PFCountCommand struct {
+	Keys []string
+}
+
+func (c PFCountCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.PFCount(c.Keys)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: int(count)}
+}
+
+type PFMergeCommand struct {
+	Destination string
+	Keys        []string
+}
+
+func (c PFMergeCommand) Execute(store *store.Store) protocol.Frame {
+	if err := store.PFMerge(c.Destination, c.Keys); err != nil {
+		return errorFrame(err)
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+func parsePFAdd(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("pfadd command requires at least 1 argument")
+	}
+	return PFAddCommand{Key: args[0], Elements: args[1:]}, nil
+}
+
+func parsePFCount(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("pfcount command requires at least 1 argument")
+	}
+	return PFCountCommand{Keys: args}, nil
+}
+
+func parsePFMerge(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("pfmerge command requires at least 1 argument")
+	}
+	return PFMergeCommand{Destination: args[0], Keys: args[1:]}, nil
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayHyperLogLog(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "pfadd no elements", in: request("PFADD", "k"), want: PFAddCommand{Key: "k", Elements: []string{}}},
+		{name: "pfadd", in: request("PFADD", "k", "a", "b"), want: PFAddCommand{Key: "k", Elements: []string{"a", "b"}}},
+		{name: "pfadd no key", in: request("PFADD"), wantErr: true},
+		{name: "pfcount", in: request("PFCOUNT", "a", "b"), want: PFCountCommand{Keys: []string{"a", "b"}}},
+		{name: "pfcount no key", in: request("PFCOUNT"), wantErr: true},
+		{name: "pfmerge", in: request("PFMERGE", "d", "a"), want: PFMergeCommand{Destination: "d", Keys: []string{"a"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestPFCommandsErrors(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	s.Set("str", "plain", nil)
+
+	got := PFAddCommand{Key: "str", Elements: []string{"a"}}.Execute(s)
+	assert.Equal(t, protocol.Error{Prefix: "WRONGTYPE", Message: "Key is not a valid HyperLogLog string value."}, got)
+
+	s.Set("corrupt", "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80", nil)
+	got = PFCountCommand{Keys: []string{"corrupt"}}.Execute(s)
+	assert.Equal(t, protocol.Error{Prefix: "INVALIDOBJ", Message: "Corrupted HLL object detected"}, got)
+}
+
+func TestPFCopyWithGetSet(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	elements := make([]string, 2000)
+	for i := range elements {
+		elements[i] = strconv.Itoa(i)
+	}
+	PFAddCommand{Key: "dense", Elements: elements}.Execute(s)
+	PFAddCommand{Key: "sparse", Elements: elements[:10]}.Execute(s)
+
+	for encoding, key := range []string{"dense", "sparse"} {
+		value := GetCommand{Key: key}.Execute(s).(protocol.BulkString)
+		assert.Equal(t, byte(encoding), value.Bytes[4])
+		SetCommand{Key: key + "-copy", Value: string(value.Bytes)}.Execute(s)
+		assert.Equal(t, value, GetCommand{Key: key + "-copy"}.Execute(s))
+		assert.Equal(t, PFCountCommand{Keys: []string{key}}.Execute(s), PFCountCommand{Keys: []string{key + "-copy"}}.Execute(s))
+	}
+}
@@ -0,0 +1,245 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/stats"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// InfoSources are what INFO reports on. Port is the port the server
+// listens on, and Cluster is nil unless it is in cluster mode.
+type InfoSources struct {
+	DBs     *store.Databases
+	File    *rdb.File
+	Repl    *replication.Replication
+	Stats   *stats.Stats
+	Cluster *cluster.Cluster
+	Port    int
+}
+
+// infoSection is a section of INFO: its title and a function returning its
+// fields in order.
+type infoSection struct {
+	title  string
+	fields func(src InfoSources) [][2]string
+}
+
+var infoSections = []infoSection{
+	{title: "Server", fields: serverInfo},
+	{title: "Clients", fields: clientsInfo},
+	{title: "Memory", fields: memoryInfo},
+	{title: "Persistence", fields: persistenceInfo},
+	{title: "Stats", fields: statsInfo},
+	{title: "Replication", fields: replicationInfo},
+	{title: "Cluster", fields: clusterEnabledInfo},
+	{title: "Keyspace", fields: keyspaceInfo},
+}
+
+// redisVersion is the version of Redis the server reports, whose RDB
+// format and commands it follows.
+const redisVersion = "7.2.0"
+
+func serverInfo(src InfoSources) [][2]string {
+	uptime := src.Stats.Uptime()
+	mode := "standalone"
+	if src.Cluster != nil {
+		mode = "cluster"
+	}
+	return [][2]string{
+		{"redis_version", redisVersion},
+		{"redis_mode", mode},
+		{"os", runtime.GOOS + " " + runtime.GOARCH},
+		{"arch_bits", fmt.Sprint(strconv.IntSize)},
+		{"go_version", runtime.Version()},
+		{"process_id", fmt.Sprint(os.Getpid())},
+		{"run_id", src.Stats.RunID},
+		{"tcp_port", fmt.Sprint(src.Port)},
+		{"server_time_usec", fmt.Sprint(time.Now().UnixMicro())},
+		{"uptime_in_seconds", fmt.Sprint(int64(uptime / time.Second))},
+		{"uptime_in_days", fmt.Sprint(int64(uptime / (24 * time.Hour)))},
+	}
+}
+
+func clientsInfo(src InfoSources) [][2]string {
+	return [][2]string{
+		{"connected_clients", fmt.Sprint(src.Stats.Clients())},
+		{"blocked_clients", fmt.Sprint(src.Stats.BlockedClients())},
+	}
+}
+
+func memoryInfo(src InfoSources) [][2]string {
+	dbs := src.DBs
+	used, limit := dbs.UsedMemory(), dbs.MaxMemory()
+	return [][2]string{
+		{"used_memory", fmt.Sprint(used)},
+		{"used_memory_human", bytesToHuman(used)},
+		{"maxmemory", fmt.Sprint(limit)},
+		{"maxmemory_human", bytesToHuman(limit)},
+		{"maxmemory_policy", dbs.EvictionPolicy().String()},
+		{"lazyfree_pending_objects", fmt.Sprint(store.LazyFreePendingObjects())},
+	}
+}
+
+func persistenceInfo(src InfoSources) [][2]string {
+	status := "ok"
+	if src.File.LastSaveFailed() {
+		status = "err"
+	}
+	return [][2]string{
+		{"loading", "0"},
+		{"rdb_bgsave_in_progress", boolInfo(src.File.Saving())},
+		{"rdb_last_save_time", fmt.Sprint(src.File.LastSave().Unix())},
+		{"rdb_last_bgsave_status", status},
+		{"aof_enabled", "0"},
+	}
+}
+
+func statsInfo(src InfoSources) [][2]string {
+	dbs := src.DBs
+	return [][2]string{
+		{"total_connections_received", fmt.Sprint(src.Stats.TotalConnections())},
+		{"total_commands_processed", fmt.Sprint(src.Stats.Commands())},
+		{"instantaneous_ops_per_sec", fmt.Sprint(src.Stats.OpsPerSec())},
+		{"expired_keys", fmt.Sprint(dbs.ExpiredKeys())},
+		{"evicted_keys", fmt.Sprint(dbs.EvictedKeys())},
+		{"keyspace_hits", fmt.Sprint(dbs.KeyspaceHits())},
+		{"keyspace_misses", fmt.Sprint(dbs.KeyspaceMisses())},
+		{"lazyfreed_objects", fmt.Sprint(store.LazyFreedObjects())},
+	}
+}
+
+func replicationInfo(src InfoSources) [][2]string {
+	info := src.Repl.Info()
+	var fields [][2]string
+	if info.MasterHost == "" {
+		fields = append(fields, [2]string{"role", "master"})
+	} else {
+		status, lastIO := "down", int64(-1)
+		if info.LinkUp {
+			status, lastIO = "up", int64(info.LastIO/time.Second)
+		}
+		fields = append(fields,
+			[2]string{"role", "slave"},
+			[2]string{"master_host", info.MasterHost},
+			[2]string{"master_port", info.MasterPort},
+			[2]string{"master_link_status", status},
+			[2]string{"master_last_io_seconds_ago", fmt.Sprint(lastIO)},
+			[2]string{"master_sync_in_progress", boolInfo(info.Syncing)},
+			[2]string{"slave_repl_offset", fmt.Sprint(info.Offset)},
+		)
+	}
+	fields = append(fields, [2]string{"connected_slaves", fmt.Sprint(len(info.Replicas))})
+	for i, rep := range info.Replicas {
+		state := "online"
+		if rep.Waiting {
+			state = "wait_bgsave"
+		}
+		fields = append(fields, [2]string{
+			fmt.Sprintf("slave%d", i),
+			fmt.Sprintf("ip=%s,port=%d,state=%s,offset=%d,lag=%d", rep.IP, rep.Port, state, rep.Offset, int64(rep.Lag/time.Second)),
+		})
+	}
+	firstByte := int64(0)
+	if info.BacklogActive {
+		firstByte = info.BacklogFirstByte
+	}
+	return append(fields,
+		[2]string{"master_replid", info.ReplID},
+		[2]string{"master_replid2", info.ReplID2},
+		[2]string{"master_repl_offset", fmt.Sprint(info.Offset)},
+		[2]string{"second_repl_offset", fmt.Sprint(info.SecondOffset)},
+		[2]string{"repl_backlog_active", boolInfo(info.BacklogActive)},
+		[2]string{"repl_backlog_size", fmt.Sprint(info.BacklogSize)},
+		[2]string{"repl_backlog_first_byte_offset", fmt.Sprint(firstByte)},
+		[2]string{"repl_backlog_histlen", fmt.Sprint(info.BacklogHistlen)},
+	)
+}
+
+func clusterEnabledInfo(src InfoSources) [][2]string {
+	return [][2]string{{"cluster_enabled", boolInfo(src.Cluster != nil)}}
+}
+
+// keyspaceInfo lists the databases that hold keys.
+func keyspaceInfo(src InfoSources) [][2]string {
+	var fields [][2]string
+	for i := range src.DBs.Len() {
+		db := src.DBs.DB(i)
+		keys := db.DBSize()
+		if keys == 0 {
+			continue
+		}
+		fields = append(fields, [2]string{
+			fmt.Sprintf("db%d", i),
+			fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d", keys, db.Expires(), db.AvgTTL().Milliseconds()),
+		})
+	}
+	return fields
+}
+
+func boolInfo(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// bytesToHuman formats n the way Redis's INFO does, such as 1.50M.
+func bytesToHuman(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n) / 1024
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.2f%c", value, units[i])
+}
+
+// InfoCommand implements INFO. Sections are lower case; none, "default",
+// "all" and "everything" select every section, as there are no others.
+type InfoCommand struct {
+	Sections []string
+}
+
+func (c InfoCommand) Execute(src InfoSources) protocol.Frame {
+	all := len(c.Sections) == 0
+	for _, name := range []string{"default", "all", "everything"} {
+		all = all || slices.Contains(c.Sections, name)
+	}
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !slices.Contains(c.Sections, strings.ToLower(section.title)) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", section.title)
+		for _, field := range section.fields(src) {
+			fmt.Fprintf(&b, "%s:%s\r\n", field[0], field[1])
+		}
+	}
+	return bulk(b.String())
+}
+
+func parseInfo(args []string) (any, error) {
+	sections := make([]string, len(args))
+	for i, arg := range args {
+		sections[i] = strings.ToLower(arg)
+	}
+	return InfoCommand{Sections: sections}, nil
+}
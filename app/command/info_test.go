@@ -0,0 +1,88 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/stats"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// infoSources returns what INFO reports on for a server holding dbs.
+func infoSources(t *testing.T, dbs *store.Databases) InfoSources {
+	repl, st := replication.New(dbs, 6379, nil), stats.New()
+	t.Cleanup(repl.Close)
+	t.Cleanup(st.Close)
+	return InfoSources{
+		DBs:   dbs,
+		File:  rdb.NewFile(t.TempDir(), "dump.rdb"),
+		Repl:  repl,
+		Stats: st,
+		Port:  6379,
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(2)
+	dbs.SetMaxMemory(3 << 20)
+	dbs.SetEvictionPolicy(store.AllKeysLRU)
+	src := infoSources(t, dbs)
+
+	all := string(InfoCommand{}.Execute(src).(protocol.BulkString).Bytes)
+	assert.True(t, strings.HasPrefix(all, "# Server\r\nredis_version:"))
+	assert.Contains(t, all, "tcp_port:6379\r\n")
+	assert.Contains(t, all, "\r\n\r\n# Memory\r\n")
+	assert.Contains(t, all, "maxmemory:3145728\r\nmaxmemory_human:3.00M\r\nmaxmemory_policy:allkeys-lru\r\n")
+	assert.Contains(t, all, "\r\n\r\n# Stats\r\ntotal_connections_received:0\r\n")
+	assert.Contains(t, all, "rdb_last_bgsave_status:ok\r\n")
+	assert.Contains(t, all, "# Replication\r\nrole:master\r\nconnected_slaves:0\r\n")
+	assert.True(t, strings.HasSuffix(all, "# Keyspace\r\n"), "empty databases are not listed")
+	everything := string(InfoCommand{Sections: []string{"everything"}}.Execute(src).(protocol.BulkString).Bytes)
+	assert.Equal(t, strings.Count(all, "# "), strings.Count(everything, "# "))
+
+	got, err := FromArray(request("INFO", "STATS"))
+	require.NoError(t, err)
+	section := string(got.(InfoCommand).Execute(src).(protocol.BulkString).Bytes)
+	assert.True(t, strings.HasPrefix(section, "# Stats\r\n"))
+	assert.NotContains(t, section, "Memory")
+}
+
+func TestInfoKeyspace(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(2)
+	ttl := time.Hour
+	dbs.DB(1).Set("a", "1", &ttl)
+	dbs.DB(1).Set("b", "1", nil)
+	dbs.DB(1).Get("b")
+	dbs.DB(1).Get("c")
+	src := infoSources(t, dbs)
+
+	keyspace := string(InfoCommand{Sections: []string{"keyspace", "stats"}}.Execute(src).(protocol.BulkString).Bytes)
+	assert.Contains(t, keyspace, "keyspace_hits:1\r\nkeyspace_misses:1\r\n")
+	assert.Contains(t, keyspace, "# Keyspace\r\ndb1:keys=2,expires=1,avg_ttl=")
+	assert.NotContains(t, keyspace, "db0")
+}
+
+func TestBytesToHuman(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "0B", bytesToHuman(0))
+	assert.Equal(t, "1023B", bytesToHuman(1023))
+	assert.Equal(t, "1.50K", bytesToHuman(1536))
+	assert.Equal(t, "2.00G", bytesToHuman(2<<30))
+}
+
+func TestDenyOOM(t *testing.T) {
+	t.Parallel()
+	assert.True(t, DenyOOM(request("set", "k", "v")))
+	assert.True(t, DenyOOM(request("XGROUP", "create", "s", "g", "$")))
+	assert.False(t, DenyOOM(request("XGROUP", "DESTROY", "s", "g")))
+	assert.False(t, DenyOOM(request("GET", "k")))
+	assert.False(t, DenyOOM(request("SPOP", "k")))
+}
@@ -0,0 +1,196 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+type KeysCommand struct {
+	Pattern string
+}
+
+func (c KeysCommand) Execute(store *store.Store) protocol.Frame {
+	return bulkArray(store.Keys(c.Pattern))
+}
+
+type DelCommand struct {
+	Keys []string
+}
+
+func (c DelCommand) Execute(store *store.Store) protocol.Frame {
+	return protocol.Integer{Value: store.Del(c.Keys)}
+}
+
+// UnlinkCommand implements UNLINK, which is DEL freeing large values in
+// the background.
+type UnlinkCommand struct {
+	Keys []string
+}
+
+func (c UnlinkCommand) Execute(store *store.Store) protocol.Frame {
+	return protocol.Integer{Value: store.Unlink(c.Keys)}
+}
+
+type ScanCommand struct {
+	Cursor  uint64
+	Options store.ScanOptions
+}
+
+func (c ScanCommand) Execute(store *store.Store) protocol.Frame {
+	cursor, keys := store.Scan(c.Cursor, c.Options)
+	return scanFrame(cursor, keys)
+}
+
+type SScanCommand struct {
+	Key     string
+	Cursor  uint64
+	Options store.ScanOptions
+}
+
+func (c SScanCommand) Execute(store *store.Store) protocol.Frame {
+	cursor, members, err := store.SScan(c.Key, c.Cursor, c.Options)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return scanFrame(cursor, members)
+}
+
+type ZScanCommand struct {
+	Key     string
+	Cursor  uint64
+	Options store.ScanOptions
+}
+
+func (c ZScanCommand) Execute(store *store.Store) protocol.Frame {
+	cursor, members, err := store.ZScan(c.Key, c.Cursor, c.Options)
+	if err != nil {
+		return errorFrame(err)
+	}
+	items := make([]string, 0, 2*len(members))
+	for _, m := range members {
+		items = append(items, m.Member, formatScore(m.Score))
+	}
+	return scanFrame(cursor, items)
+}
+
+// HScanCommand implements HSCAN. There is no hash type yet, so a missing key
+// scans as empty and any existing key holds the wrong kind of value.
+type HScanCommand struct {
+	Key     string
+	Cursor  uint64
+	Options store.ScanOptions
+}
+
+func (c HScanCommand) Execute(s *store.Store) protocol.Frame {
+	if _, ok := s.Type(c.Key); ok {
+		return errorFrame(store.ErrWrongType)
+	}
+	return scanFrame(0, []string{})
+}
+
+func scanFrame(cursor uint64, items []string) protocol.Array {
+	return protocol.Array{Elems: []protocol.Frame{
+		bulk(strconv.FormatUint(cursor, 10)),
+		bulkArray(items),
+	}}
+}
+
+// parseScanArgs parses "cursor [MATCH pattern] [COUNT count]", plus
+// [TYPE type] when withType is set.
+func parseScanArgs(args []string, withType bool) (uint64, store.ScanOptions, error) {
+	opts := store.ScanOptions{Count: 10}
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, opts, fmt.Errorf("invalid cursor")
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return 0, opts, fmt.Errorf("syntax error")
+		}
+		switch option := strings.ToUpper(args[i]); {
+		case option == "MATCH":
+			opts.Match = args[i+1]
+			if opts.Match == "*" {
+				opts.Match = ""
+			}
+		case option == "COUNT":
+			count, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return 0, opts, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count < 1 {
+				return 0, opts, fmt.Errorf("syntax error")
+			}
+			opts.Count = int(count)
+		case option == "TYPE" && withType:
+			opts.Type = args[i+1]
+		default:
+			return 0, opts, fmt.Errorf("syntax error")
+		}
+	}
+	return cursor, opts, nil
+}
+
+func parseKeys(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("keys command requires 1 argument")
+	}
+	return KeysCommand{Pattern: args[0]}, nil
+}
+
+func parseDel(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("del command requires at least 1 argument")
+	}
+	return DelCommand{Keys: args}, nil
+}
+
+func parseUnlink(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("unlink command requires at least 1 argument")
+	}
+	return UnlinkCommand{Keys: args}, nil
+}
+
+func parseScan(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("scan command requires at least 1 argument")
+	}
+	cursor, opts, err := parseScanArgs(args, true)
+	if err != nil {
+		return nil, err
+	}
+	return ScanCommand{Cursor: cursor, Options: opts}, nil
+}
+
+// parseKeyScan parses the SSCAN, ZSCAN and HSCAN arguments into a command
+// built by build.
+func parseKeyScan(name string, build func(key string, cursor uint64, opts store.ScanOptions) any) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s command requires at least 2 arguments", name)
+		}
+		cursor, opts, err := parseScanArgs(args[1:], false)
+		if err != nil {
+			return nil, err
+		}
+		return build(args[0], cursor, opts), nil
+	}
+}
+
+var (
+	parseSScan = parseKeyScan("sscan", func(key string, cursor uint64, opts store.ScanOptions) any {
+		return SScanCommand{Key: key, Cursor: cursor, Options: opts}
+	})
+	parseZScan = parseKeyScan("zscan", func(key string, cursor uint64, opts store.ScanOptions) any {
+		return ZScanCommand{Key: key, Cursor: cursor, Options: opts}
+	})
+	parseHScan = parseKeyScan("hscan", func(key string, cursor uint64, opts store.ScanOptions) any {
+		return HScanCommand{Key: key, Cursor: cursor, Options: opts}
+	})
+)
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayScan(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "del", in: request("DEL", "a", "b"), want: DelCommand{Keys: []string{"a", "b"}}},
+		{name: "del no keys", in: request("DEL"), wantErr: true},
+		{name: "unlink", in: request("unlink", "a"), want: UnlinkCommand{Keys: []string{"a"}}},
+		{name: "unlink no keys", in: request("UNLINK"), wantErr: true},
+		{name: "keys", in: request("KEYS", "user:*"), want: KeysCommand{Pattern: "user:*"}},
+		{name: "scan defaults", in: request("SCAN", "0"), want: ScanCommand{Options: store.ScanOptions{Count: 10}}},
+		{
+			name: "scan options",
+			in:   request("SCAN", "17", "match", "k*", "COUNT", "100", "TYPE", "zset"),
+			want: ScanCommand{Cursor: 17, Options: store.ScanOptions{Match: "k*", Count: 100, Type: "zset"}},
+		},
+		{name: "scan match all", in: request("SCAN", "0", "MATCH", "*"), want: ScanCommand{Options: store.ScanOptions{Count: 10}}},
+		{name: "scan invalid cursor", in: request("SCAN", "-1"), wantErr: true},
+		{name: "scan zero count", in: request("SCAN", "0", "COUNT", "0"), wantErr: true},
+		{name: "scan dangling option", in: request("SCAN", "0", "MATCH"), wantErr: true},
+		{name: "sscan", in: request("SSCAN", "s", "5", "COUNT", "2"), want: SScanCommand{Key: "s", Cursor: 5, Options: store.ScanOptions{Count: 2}}},
+		{name: "zscan type", in: request("ZSCAN", "z", "0", "TYPE", "zset"), wantErr: true},
+		{name: "hscan", in: request("HSCAN", "h", "0"), want: HScanCommand{Key: "h", Options: store.ScanOptions{Count: 10}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestScanCommandReplies(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	s.ZAdd("z", store.ZAddFlags{}, false, []store.ScoredMember{{Member: "a", Score: 1.5}})
+
+	s.Set("a", "1", nil)
+	s.Set("b", "2", nil)
+	assert.Equal(t, protocol.Integer{Value: 1}, DelCommand{Keys: []string{"a", "missing"}}.Execute(s))
+	assert.Equal(t, protocol.Integer{Value: 1}, UnlinkCommand{Keys: []string{"b", "b"}}.Execute(s))
+
+	got := ZScanCommand{Key: "z", Options: store.ScanOptions{Count: 10}}.Execute(s)
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{bulk("0"), bulkArray([]string{"a", "1.5"})}}, got)
+
+	got = HScanCommand{Key: "missing"}.Execute(s)
+	assert.Equal(t, protocol.Array{Elems: []protocol.Frame{bulk("0"), bulkArray([]string{})}}, got)
+	got = HScanCommand{Key: "z"}.Execute(s)
+	assert.Equal(t, errorFrame(store.ErrWrongType), got)
+}
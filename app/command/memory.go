@@ -0,0 +1,212 @@
+package command
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+var memoryHelp = []string{
+	"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"DOCTOR",
+	"    Return memory problems reports.",
+	"PURGE",
+	"    Attempt to purge dirty pages for reclamation by the allocator.",
+	"STATS",
+	"    Return information about the memory usage of the server.",
+	"USAGE <key> [SAMPLES <count>]",
+	"    Return memory in bytes used by <key> and its value. Nested values are",
+	"    sampled up to <count> times (default: 5, 0 means sample all).",
+	"HELP",
+	"    Print this help.",
+}
+
+// heapPeak is the largest Go heap seen by readMemStats, standing in for
+// the peak Redis's allocator keeps track of.
+var heapPeak atomic.Uint64
+
+// readMemStats reads the Go runtime's memory statistics and records the
+// heap peak.
+func readMemStats() runtime.MemStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	for peak := heapPeak.Load(); ms.HeapAlloc > peak; peak = heapPeak.Load() {
+		if heapPeak.CompareAndSwap(peak, ms.HeapAlloc) {
+			break
+		}
+	}
+	return ms
+}
+
+// MemoryCommand implements MEMORY. Subcommand is upper case; Key and
+// Samples are the arguments of USAGE.
+type MemoryCommand struct {
+	Subcommand string
+	Key        string
+	Samples    int
+}
+
+func (c MemoryCommand) Execute(dbs *store.Databases, db int) protocol.Frame {
+	switch c.Subcommand {
+	case "USAGE":
+		n, ok := dbs.DB(db).MemoryUsage(c.Key, c.Samples)
+		if !ok {
+			return protocol.BulkNullString{}
+		}
+		return protocol.Integer{Value: n}
+	case "STATS":
+		return memoryStats(dbs, readMemStats())
+	case "DOCTOR":
+		return bulk(memoryDoctor(readMemStats(), heapPeak.Load()))
+	case "PURGE":
+		debug.FreeOSMemory()
+		return protocol.SimpleString{Value: "OK"}
+	default:
+		elems := make([]protocol.Frame, len(memoryHelp))
+		for i, line := range memoryHelp {
+			elems[i] = protocol.SimpleString{Value: line}
+		}
+		return protocol.Array{Elems: elems}
+	}
+}
+
+// memoryStats builds the MEMORY STATS reply. The allocator figures are the
+// Go heap's; the dataset and overhead come from the keyspace accounting.
+func memoryStats(dbs *store.Databases, ms runtime.MemStats) protocol.Array {
+	keyspaces := dbs.KeyspaceMemory()
+	var overhead, dataset int64
+	keys := 0
+	for _, m := range keyspaces {
+		overhead += m.Main + m.Expires
+		dataset += m.Dataset
+		keys += m.Keys
+	}
+	total := int64(ms.HeapAlloc)
+	peak := max(heapPeak.Load(), ms.HeapAlloc)
+	resident := ms.HeapSys - ms.HeapReleased
+	perKey := int64(0)
+	if keys > 0 {
+		perKey = total / int64(keys)
+	}
+
+	elems := []protocol.Frame{}
+	add := func(name string, value protocol.Frame) {
+		elems = append(elems, bulk(name), value)
+	}
+	integer := func(n int64) protocol.Frame { return protocol.Integer{Value: int(n)} }
+	ratio := func(a float64, b float64) protocol.Frame {
+		if b == 0 {
+			return bulk("0")
+		}
+		return bulk(strconv.FormatFloat(a/b, 'g', -1, 64))
+	}
+
+	add("peak.allocated", integer(int64(peak)))
+	add("total.allocated", integer(total))
+	add("overhead.total", integer(overhead))
+	for _, m := range keyspaces {
+		add(fmt.Sprintf("db.%d", m.DB), protocol.Array{Elems: []protocol.Frame{
+			bulk("overhead.hashtable.main"), integer(m.Main),
+			bulk("overhead.hashtable.expires"), integer(m.Expires),
+		}})
+	}
+	add("keys.count", integer(int64(keys)))
+	add("keys.bytes-per-key", integer(perKey))
+	add("dataset.bytes", integer(dataset))
+	add("dataset.percentage", ratio(float64(dataset)*100, float64(total)))
+	add("peak.percentage", ratio(float64(total)*100, float64(peak)))
+	add("allocator.allocated", integer(total))
+	add("allocator.active", integer(int64(ms.HeapInuse)))
+	add("allocator.resident", integer(int64(resident)))
+	add("allocator-fragmentation.ratio", ratio(float64(ms.HeapInuse), float64(ms.HeapAlloc)))
+	add("allocator-fragmentation.bytes", integer(int64(ms.HeapInuse)-total))
+	add("allocator.rss-ratio", ratio(float64(resident), float64(ms.HeapInuse)))
+	add("allocator.rss-bytes", integer(int64(resident)-int64(ms.HeapInuse)))
+	add("heap.objects", integer(int64(ms.HeapObjects)))
+	add("gc.cycles", integer(int64(ms.NumGC)))
+	return protocol.Array{Elems: elems}
+}
+
+// memoryDoctor returns the MEMORY DOCTOR report, which checks the Go heap
+// for the conditions Redis checks its allocator for.
+func memoryDoctor(ms runtime.MemStats, peak uint64) string {
+	const (
+		smallHeap   = 5 << 20
+		minWasted   = 10 << 20
+		maxOverhead = 1.1
+	)
+	resident := ms.HeapSys - ms.HeapReleased
+	empty := ms.HeapAlloc < smallHeap
+	bigPeak := float64(peak) > float64(ms.HeapAlloc)*1.5
+	highFrag := float64(ms.HeapInuse) > float64(ms.HeapAlloc)*maxOverhead && ms.HeapInuse-ms.HeapAlloc > minWasted
+	highRSS := float64(resident) > float64(ms.HeapInuse)*maxOverhead && resident-ms.HeapInuse > minWasted
+
+	switch {
+	case empty:
+		return "Hi Sam, this instance is empty or is using very little memory, my issues detector can't be used in these conditions. " +
+			"Please, leave for your mission on Earth and fill it with some data. " +
+			"The new Sam and I will be back to our programming as soon as I finished rebooting.\n"
+	case !bigPeak && !highFrag && !highRSS:
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("Sam, I detected a few issues in this Redis instance memory implants:\n\n")
+	if bigPeak {
+		b.WriteString(" * Peak memory: In the past this instance used more than 150% the memory that is currently using. " +
+			"The Go runtime returns memory to the system only gradually after a peak, so you can expect to see a big fragmentation ratio, " +
+			"however this is actually harmless and is only due to the memory peak, and the memory will be used as soon as you fill the instance with more data. " +
+			"If the memory peak was only occasional and you want to try to reclaim memory, please try the MEMORY PURGE command.\n\n")
+	}
+	if highFrag {
+		b.WriteString(" * High allocator fragmentation: This instance has a heap fragmentation greater than 1.1. " +
+			"This problem is usually due either to a large peak memory (check if there is a peak memory entry above in the report) " +
+			"or may result from a workload that leaves many partially used spans in the Go heap. " +
+			"If the problem is a large peak memory, then there is no issue.\n\n")
+	}
+	if highRSS {
+		b.WriteString(" * High allocator RSS overhead: This instance has an RSS memory overhead greater than 1.1 " +
+			"(this means that the heap memory the Go runtime holds is much larger than the spans in use). " +
+			"This problem is usually due to a large peak memory (check if there is a peak memory entry above in the report). " +
+			"Try the MEMORY PURGE command to return the unused memory to the system.\n\n")
+	}
+	b.WriteString("I'm here to keep you safe, Sam. I want to help you.\n")
+	return b.String()
+}
+
+func parseMemory(args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for 'memory' command")
+	}
+	subcommand := strings.ToUpper(args[0])
+	switch {
+	case subcommand == "USAGE" && len(args) >= 2:
+		cmd := MemoryCommand{Subcommand: subcommand, Key: args[1], Samples: store.DefaultMemorySamples}
+		rest := args[2:]
+		for len(rest) > 0 {
+			if len(rest) < 2 || !strings.EqualFold(rest[0], "SAMPLES") {
+				return nil, fmt.Errorf("syntax error")
+			}
+			samples, err := strconv.Atoi(rest[1])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			if samples < 0 {
+				return nil, fmt.Errorf("syntax error")
+			}
+			cmd.Samples = samples
+			rest = rest[2:]
+		}
+		return cmd, nil
+	case (subcommand == "STATS" || subcommand == "DOCTOR" || subcommand == "PURGE" || subcommand == "HELP") && len(args) == 1:
+		return MemoryCommand{Subcommand: subcommand}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand or wrong number of arguments for '%s'. Try MEMORY HELP.", args[0])
+	}
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromArrayMemory(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "usage", in: request("MEMORY", "usage", "k"), want: MemoryCommand{Subcommand: "USAGE", Key: "k", Samples: store.DefaultMemorySamples}},
+		{name: "usage samples", in: request("MEMORY", "USAGE", "k", "samples", "0"), want: MemoryCommand{Subcommand: "USAGE", Key: "k", Samples: 0}},
+		{name: "stats", in: request("MEMORY", "STATS"), want: MemoryCommand{Subcommand: "STATS"}},
+		{name: "doctor", in: request("MEMORY", "doctor"), want: MemoryCommand{Subcommand: "DOCTOR"}},
+		{name: "negative samples", in: request("MEMORY", "USAGE", "k", "SAMPLES", "-1"), wantErr: true},
+		{name: "bad samples", in: request("MEMORY", "USAGE", "k", "SAMPLES", "x"), wantErr: true},
+		{name: "dangling option", in: request("MEMORY", "USAGE", "k", "SAMPLES"), wantErr: true},
+		{name: "usage without key", in: request("MEMORY", "USAGE"), wantErr: true},
+		{name: "stats with argument", in: request("MEMORY", "STATS", "x"), wantErr: true},
+		{name: "unknown", in: request("MEMORY", "MALLOC-STATS"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMemoryCommandReplies(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(2)
+	SetCommand{Key: "k", Value: "v"}.Execute(dbs.DB(1))
+
+	usage, ok := MemoryCommand{Subcommand: "USAGE", Key: "k"}.Execute(dbs, 1).(protocol.Integer)
+	require.True(t, ok)
+	assert.Positive(t, usage.Value)
+	assert.Equal(t, protocol.BulkNullString{}, MemoryCommand{Subcommand: "USAGE", Key: "k"}.Execute(dbs, 0))
+
+	stats := MemoryCommand{Subcommand: "STATS"}.Execute(dbs, 0).(protocol.Array)
+	fields := map[string]protocol.Frame{}
+	for i := 0; i < len(stats.Elems); i += 2 {
+		fields[string(stats.Elems[i].(protocol.BulkString).Bytes)] = stats.Elems[i+1]
+	}
+	assert.Equal(t, protocol.Integer{Value: 1}, fields["keys.count"])
+	assert.Contains(t, fields, "db.1")
+	assert.NotContains(t, fields, "db.0")
+	assert.Positive(t, fields["total.allocated"].(protocol.Integer).Value)
+
+	assert.IsType(t, protocol.BulkString{}, MemoryCommand{Subcommand: "DOCTOR"}.Execute(dbs, 0))
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, MemoryCommand{Subcommand: "PURGE"}.Execute(dbs, 0))
+}
+
+func TestMemoryDoctor(t *testing.T) {
+	t.Parallel()
+	const mb = 1 << 20
+	healthy := runtime.MemStats{HeapAlloc: 100 * mb, HeapInuse: 105 * mb, HeapSys: 110 * mb}
+	assert.Contains(t, memoryDoctor(healthy, 100*mb), "I can't find any memory issue")
+	assert.Contains(t, memoryDoctor(runtime.MemStats{HeapAlloc: mb}, mb), "very little memory")
+
+	report := memoryDoctor(healthy, 200*mb)
+	assert.Contains(t, report, "* Peak memory")
+	assert.NotContains(t, report, "fragmentation greater")
+
+	fragmented := runtime.MemStats{HeapAlloc: 100 * mb, HeapInuse: 150 * mb, HeapSys: 300 * mb, HeapReleased: 10 * mb}
+	report = memoryDoctor(fragmented, 100*mb)
+	assert.Contains(t, report, "* High allocator fragmentation")
+	assert.Contains(t, report, "* High allocator RSS overhead")
+	assert.NotContains(t, report, "* Peak memory")
+}
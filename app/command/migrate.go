@@ -0,0 +1,162 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// defaultMigrateTimeout is the timeout of MIGRATE when it is given as 0.
+const defaultMigrateTimeout = time.Second
+
+// MigrateCommand implements MIGRATE: it moves Keys to database DB of the
+// instance at Host and Port, restoring their DUMP there with RESTORE-ASKING
+// so that a node importing their slot accepts them. Copy keeps the keys,
+// Replace overwrites those the target has, and Password, with Username if
+// not empty, authenticates first. Timeout bounds connecting and each
+// exchange with the target.
+type MigrateCommand struct {
+	Host     string
+	Port     string
+	Keys     []string
+	DB       int
+	Timeout  time.Duration
+	Copy     bool
+	Replace  bool
+	Username string
+	Password string
+}
+
+// Execute moves the keys that exist, replying NOKEY if none does. Each key
+// is removed once the target restored it, and the first error the target
+// replied is returned for the rest.
+func (c MigrateCommand) Execute(s *store.Store) protocol.Frame {
+	var keys []string
+	var buf []byte
+	switch {
+	case c.Username != "":
+		buf = protocol.AppendCommand(buf, "AUTH", c.Username, c.Password)
+	case c.Password != "":
+		buf = protocol.AppendCommand(buf, "AUTH", c.Password)
+	}
+	buf = protocol.AppendCommand(buf, "SELECT", strconv.Itoa(c.DB))
+	for _, key := range c.Keys {
+		payload, expiry, ok := s.Dump(key)
+		if !ok {
+			continue
+		}
+		ttl := int64(0)
+		if !expiry.IsZero() {
+			ttl = max(time.Until(expiry).Milliseconds(), 1)
+		}
+		args := []string{"RESTORE-ASKING", key, strconv.FormatInt(ttl, 10), string(payload)}
+		if c.Replace {
+			args = append(args, "REPLACE")
+		}
+		buf = protocol.AppendCommand(buf, args...)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return protocol.SimpleString{Value: "NOKEY"}
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.Host, c.Port), c.Timeout)
+	if err != nil {
+		return protocol.Error{Prefix: "IOERR", Message: "error or timeout connecting to the client"}
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+	if _, err := conn.Write(buf); err != nil {
+		return protocol.Error{Prefix: "IOERR", Message: "error or timeout writing to target instance"}
+	}
+
+	// The target replies to AUTH, if sent, SELECT and then each
+	// RESTORE-ASKING. An error read keeps its code in Message.
+	replies := 1 + len(keys)
+	if c.Password != "" {
+		replies++
+	}
+	// Keys restored after a failed AUTH or SELECT are not where they were
+	// asked to go, so none is removed then.
+	reader := bufio.NewReader(conn)
+	setup := replies - len(keys)
+	failed, selected := "", true
+	var moved []string
+	for i := range replies {
+		conn.SetReadDeadline(time.Now().Add(c.Timeout))
+		frame, err := protocol.ReadFrame(reader)
+		if err != nil {
+			return protocol.Error{Prefix: "IOERR", Message: "error or timeout reading to target instance"}
+		}
+		e, refused := frame.(protocol.Error)
+		if refused && failed == "" {
+			failed = e.Message
+		}
+		switch {
+		case i < setup:
+			selected = selected && !refused
+		case selected && !refused:
+			moved = append(moved, keys[i-setup])
+		}
+	}
+	if !c.Copy {
+		s.Del(moved)
+	}
+	if failed != "" {
+		return protocol.Error{Message: "Target instance replied with error: " + failed}
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+func parseMigrate(args []string) (any, error) {
+	if len(args) < 5 {
+		return nil, fmt.Errorf("wrong number of arguments for 'migrate' command")
+	}
+	cmd := MigrateCommand{Host: args[0], Port: args[1]}
+	db, err := strconv.Atoi(args[3])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	timeout, err := strconv.ParseInt(args[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	cmd.DB, cmd.Timeout = db, time.Duration(timeout)*time.Millisecond
+	if timeout <= 0 {
+		cmd.Timeout = defaultMigrateTimeout
+	}
+
+	keys := false
+	for i := 5; i < len(args) && !keys; i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "COPY":
+			cmd.Copy = true
+		case option == "REPLACE":
+			cmd.Replace = true
+		case option == "AUTH" && i+1 < len(args):
+			cmd.Password = args[i+1]
+			i++
+		case option == "AUTH2" && i+2 < len(args):
+			cmd.Username, cmd.Password = args[i+1], args[i+2]
+			i += 2
+		case option == "KEYS":
+			if args[2] != "" {
+				return nil, fmt.Errorf("When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			cmd.Keys = args[i+1:]
+			keys = true
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+	if !keys {
+		cmd.Keys = []string{args[2]}
+	}
+	return cmd, nil
+}
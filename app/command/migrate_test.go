@@ -0,0 +1,147 @@
+package command
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromArrayMigrate(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "key",
+			in:   request("MIGRATE", "127.0.0.1", "7001", "k", "2", "500"),
+			want: MigrateCommand{Host: "127.0.0.1", Port: "7001", Keys: []string{"k"}, DB: 2, Timeout: 500 * time.Millisecond},
+		},
+		{
+			name: "keys",
+			in:   request("MIGRATE", "h", "7001", "", "0", "0", "copy", "REPLACE", "AUTH2", "u", "p", "KEYS", "a", "b"),
+			want: MigrateCommand{Host: "h", Port: "7001", Keys: []string{"a", "b"}, Timeout: time.Second, Copy: true, Replace: true, Username: "u", Password: "p"},
+		},
+		{
+			name: "auth",
+			in:   request("MIGRATE", "h", "7001", "k", "0", "10", "AUTH", "p"),
+			want: MigrateCommand{Host: "h", Port: "7001", Keys: []string{"k"}, Timeout: 10 * time.Millisecond, Password: "p"},
+		},
+		{name: "keys with key", in: request("MIGRATE", "h", "7001", "k", "0", "0", "KEYS", "a"), wantErr: true},
+		{name: "invalid db", in: request("MIGRATE", "h", "7001", "k", "x", "0"), wantErr: true},
+		{name: "auth without password", in: request("MIGRATE", "h", "7001", "k", "0", "0", "AUTH"), wantErr: true},
+		{name: "too few arguments", in: request("MIGRATE", "h", "7001", "k", "0"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// serveRestores serves SELECT and RESTORE-ASKING into db on a listener,
+// for MIGRATE, returning its port.
+func serveRestores(t *testing.T, db *store.Store) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			reader, writer := bufio.NewReader(conn), bufio.NewWriter(conn)
+			for {
+				frame, err := protocol.ReadFrame(reader)
+				if err != nil {
+					conn.Close()
+					break
+				}
+				var res protocol.Frame = protocol.SimpleString{Value: "OK"}
+				switch c, err := FromArray(frame.(protocol.Array)); c := c.(type) {
+				case RestoreCommand:
+					res = c.Execute(db)
+				case SelectCommand:
+				default:
+					res = protocol.Error{Message: err.Error()}
+				}
+				res.Write(writer)
+			}
+		}
+	}()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+	source, target := store.NewStore(), store.NewStore()
+	port := serveRestores(t, target)
+	ttl := time.Hour
+	source.Set("a", "1", &ttl)
+	source.Set("b", "2", nil)
+
+	migrate := MigrateCommand{Host: "127.0.0.1", Port: port, Keys: []string{"a", "b", "missing"}, Timeout: time.Second}
+	assert.Equal(t, protocol.SimpleString{Value: "OK"}, migrate.Execute(source))
+	assert.Equal(t, 0, source.DBSize())
+	value, ok := target.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", value)
+	_, expiry, _ := target.Dump("a")
+	assert.WithinDuration(t, time.Now().Add(ttl), expiry, time.Minute)
+
+	assert.Equal(t, protocol.SimpleString{Value: "NOKEY"}, migrate.Execute(source))
+
+	source.Set("b", "3", nil)
+	source.Set("c", "4", nil)
+	migrate = MigrateCommand{Host: "127.0.0.1", Port: port, Keys: []string{"b", "c"}, Timeout: time.Second}
+	res := migrate.Execute(source)
+	assert.Equal(t, protocol.Error{Message: "Target instance replied with error: BUSYKEY Target key name already exists."}, res)
+	assert.Equal(t, 1, source.DBSize(), "keys are kept when the target refuses them")
+	value, _ = source.Get("b")
+	assert.Equal(t, "3", value)
+	value, _ = target.Get("c")
+	assert.Equal(t, "4", value, "keys the target restored are moved")
+	writes := Writes(0, source, request("MIGRATE", "127.0.0.1", port, "", "0", "0", "KEYS", "b", "c"), migrate, res)
+	require.Len(t, writes, 1)
+	assert.Equal(t, []string{"DEL", "c"}, writes[0].Args)
+
+	assert.Equal(t, protocol.SimpleString{Value: "OK"},
+		MigrateCommand{Host: "127.0.0.1", Port: port, Keys: []string{"b"}, Timeout: time.Second, Copy: true, Replace: true}.Execute(source))
+	assert.Equal(t, 1, source.DBSize())
+	value, _ = target.Get("b")
+	assert.Equal(t, "3", value)
+}
+
+func TestWritesMigrate(t *testing.T) {
+	t.Parallel()
+	req := request("MIGRATE", "h", "1", "", "0", "0", "KEYS", "a", "b")
+	cmd := MigrateCommand{Keys: []string{"a", "b"}}
+	s := store.NewStore()
+	writes := Writes(0, s, req, cmd, protocol.SimpleString{Value: "OK"})
+	require.Len(t, writes, 1)
+	assert.Equal(t, []string{"DEL", "a", "b"}, writes[0].Args)
+	assert.Empty(t, Writes(0, s, req, cmd, protocol.SimpleString{Value: "NOKEY"}))
+	assert.Empty(t, Writes(0, s, req, cmd, protocol.Error{Prefix: "IOERR", Message: "error or timeout connecting to the client"}))
+	s.Set("a", "1", nil)
+	s.Set("b", "2", nil)
+	assert.Empty(t, Writes(0, s, req, cmd, protocol.Error{Message: "Target instance replied with error: ERR"}), "nothing moved")
+	cmd.Copy = true
+	assert.Empty(t, Writes(0, s, req, cmd, protocol.SimpleString{Value: "OK"}))
+}
@@ -0,0 +1,78 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// ObjectCommand implements OBJECT. Subcommand is upper case.
+type ObjectCommand struct {
+	Subcommand string
+	Key        string
+}
+
+// Execute inspects Key in database db. FREQ fails unless the eviction
+// policy is an LFU one, since the counter is only meaningful then.
+func (c ObjectCommand) Execute(dbs *store.Databases, db int) protocol.Frame {
+	if c.Subcommand == "HELP" {
+		elems := make([]protocol.Frame, len(objectHelp))
+		for i, line := range objectHelp {
+			elems[i] = protocol.SimpleString{Value: line}
+		}
+		return protocol.Array{Elems: elems}
+	}
+
+	info, ok := dbs.DB(db).Object(c.Key)
+	if !ok {
+		return protocol.BulkNullString{}
+	}
+	switch c.Subcommand {
+	case "ENCODING":
+		return bulk(info.Encoding)
+	case "REFCOUNT":
+		return protocol.Integer{Value: info.RefCount}
+	case "IDLETIME":
+		return protocol.Integer{Value: int(info.Idle.Seconds())}
+	default:
+		if !dbs.EvictionPolicy().LFU() {
+			return protocol.Error{Message: "An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."}
+		}
+		return protocol.Integer{Value: int(info.Freq)}
+	}
+}
+
+func parseObject(args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("object command requires at least 1 argument")
+	}
+	subcommand := strings.ToUpper(args[0])
+	switch {
+	case subcommand == "HELP" && len(args) == 1:
+		return ObjectCommand{Subcommand: subcommand}, nil
+	case (subcommand == "ENCODING" || subcommand == "REFCOUNT" || subcommand == "IDLETIME" || subcommand == "FREQ") && len(args) == 2:
+		return ObjectCommand{Subcommand: subcommand, Key: args[1]}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP.", args[0])
+	}
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayObject(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "encoding", in: request("OBJECT", "encoding", "k"), want: ObjectCommand{Subcommand: "ENCODING", Key: "k"}},
+		{name: "freq", in: request("OBJECT", "FREQ", "k"), want: ObjectCommand{Subcommand: "FREQ", Key: "k"}},
+		{name: "help", in: request("OBJECT", "HELP"), want: ObjectCommand{Subcommand: "HELP"}},
+		{name: "missing key", in: request("OBJECT", "IDLETIME"), wantErr: true},
+		{name: "unknown", in: request("OBJECT", "SIZE", "k"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestObjectCommandReplies(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(1)
+	s := dbs.DB(0)
+	SetCommand{Key: "k", Value: "12"}.Execute(s)
+
+	assert.Equal(t, bulk("int"), ObjectCommand{Subcommand: "ENCODING", Key: "k"}.Execute(dbs, 0))
+	assert.Equal(t, protocol.Integer{Value: 2147483647}, ObjectCommand{Subcommand: "REFCOUNT", Key: "k"}.Execute(dbs, 0))
+	assert.Equal(t, protocol.Integer{Value: 0}, ObjectCommand{Subcommand: "IDLETIME", Key: "k"}.Execute(dbs, 0))
+	assert.Equal(t, protocol.Error{Message: "An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."},
+		ObjectCommand{Subcommand: "FREQ", Key: "k"}.Execute(dbs, 0))
+	dbs.SetEvictionPolicy(store.AllKeysLFU)
+	assert.Equal(t, protocol.Integer{Value: 5}, ObjectCommand{Subcommand: "FREQ", Key: "k"}.Execute(dbs, 0))
+	assert.Equal(t, protocol.BulkNullString{}, ObjectCommand{Subcommand: "ENCODING", Key: "missing"}.Execute(dbs, 0))
+
+	help := ObjectCommand{Subcommand: "HELP"}.Execute(dbs, 0).(protocol.Array)
+	assert.Equal(t, protocol.SimpleString{Value: objectHelp[0]}, help.Elems[0])
+}
@@ -0,0 +1,502 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// ReplicationCommand is a command that changes the replication role of
+// the server.
+type ReplicationCommand interface {
+	Execute(repl *replication.Replication) protocol.Frame
+}
+
+// ReplicaOfCommand implements REPLICAOF and SLAVEOF. NoOne is set for
+// REPLICAOF NO ONE, which turns a replica back into a master.
+type ReplicaOfCommand struct {
+	Host  string
+	Port  string
+	NoOne bool
+}
+
+func (c ReplicaOfCommand) Execute(repl *replication.Replication) protocol.Frame {
+	if c.NoOne {
+		repl.ReplicaOfNoOne()
+		return protocol.SimpleString{Value: "OK"}
+	}
+	if !repl.ReplicaOf(c.Host, c.Port) {
+		return protocol.SimpleString{Value: "OK Already connected to specified master"}
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+// ReplConfCommand implements REPLCONF, which a replica sends to its master
+// during the handshake. ListeningPort is 0 when not given. CapaEOF is set
+// when the replica announces the eof capability, which diskless syncs
+// need; other capabilities are accepted and ignored. Ack is set for ACK
+// and GETACK, which only mean something on a replication link and get no
+// reply anywhere else.
+type ReplConfCommand struct {
+	ListeningPort int
+	CapaEOF       bool
+	Ack           bool
+}
+
+// PSyncCommand implements PSYNC, with which a replica requests the data of
+// its master. The server takes over the connection to serve it.
+type PSyncCommand struct {
+	ReplID string
+	Offset int64
+}
+
+// WaitCommand implements WAIT, which waits until NumReplicas replicas
+// acknowledge the writes of the client, for at most Timeout unless it is 0.
+type WaitCommand struct {
+	NumReplicas int
+	Timeout     time.Duration
+}
+
+// Execute waits for the replicas to reach offset, the end of the last
+// write of the client.
+func (c WaitCommand) Execute(repl *replication.Replication, offset int64) protocol.Frame {
+	if repl.IsReplica() {
+		return protocol.Error{Message: "WAIT cannot be used with replica instances. Please also note that since Redis 4.0 if a replica is configured to be writable (which is not the default) writes to replicas are just local and are not propagated."}
+	}
+	return protocol.Integer{Value: repl.Wait(c.NumReplicas, offset, c.Timeout, false)}
+}
+
+// WaitAOFCommand implements WAITAOF, which waits until the writes of the
+// client are written to the AOF of NumLocal servers, this one included,
+// and NumReplicas replicas. There is no AOF here, so only replicas with
+// one could count.
+type WaitAOFCommand struct {
+	NumLocal    int
+	NumReplicas int
+	Timeout     time.Duration
+}
+
+func (c WaitAOFCommand) Execute(repl *replication.Replication, offset int64) protocol.Frame {
+	if repl.IsReplica() {
+		return protocol.Error{Message: "WAITAOF cannot be used with replica instances. Please also note that writes to replicas are just local and are not propagated."}
+	}
+	if c.NumLocal > 0 {
+		return protocol.Error{Message: "WAITAOF cannot be used when numlocal is set but appendonly is disabled."}
+	}
+	n := repl.Wait(c.NumReplicas, offset, c.Timeout, true)
+	return protocol.Array{Elems: []protocol.Frame{protocol.Integer{Value: 0}, protocol.Integer{Value: n}}}
+}
+
+func parseReplicaOf(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for 'replicaof' command")
+	}
+	if strings.EqualFold(args[0], "NO") && strings.EqualFold(args[1], "ONE") {
+		return ReplicaOfCommand{NoOne: true}, nil
+	}
+	if port, err := strconv.Atoi(args[1]); err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("Invalid master port")
+	}
+	return ReplicaOfCommand{Host: args[0], Port: args[1]}, nil
+}
+
+func parseReplConf(args []string) (any, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("syntax error")
+	}
+	cmd := ReplConfCommand{}
+	for i := 0; i < len(args); i += 2 {
+		switch strings.ToLower(args[i]) {
+		case "listening-port":
+			port, err := strconv.Atoi(args[i+1])
+			if err != nil || port < 0 || port > 65535 {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			cmd.ListeningPort = port
+		case "capa":
+			cmd.CapaEOF = cmd.CapaEOF || strings.EqualFold(args[i+1], "eof")
+		case "ip-address":
+		case "ack", "fack", "getack":
+			cmd.Ack = true
+		default:
+			return nil, fmt.Errorf("Unrecognized REPLCONF option: %s", args[i])
+		}
+	}
+	return cmd, nil
+}
+
+func parsePSync(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for 'psync' command")
+	}
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	return PSyncCommand{ReplID: args[0], Offset: offset}, nil
+}
+
+func parseWait(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for 'wait' command")
+	}
+	numReplicas, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	timeout, err := parseWaitTimeout(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return WaitCommand{NumReplicas: numReplicas, Timeout: timeout}, nil
+}
+
+func parseWaitAOF(args []string) (any, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("wrong number of arguments for 'waitaof' command")
+	}
+	numLocal, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	numReplicas, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	timeout, err := parseWaitTimeout(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return WaitAOFCommand{NumLocal: numLocal, NumReplicas: numReplicas, Timeout: timeout}, nil
+}
+
+// parseWaitTimeout parses a timeout in milliseconds.
+func parseWaitTimeout(arg string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return 0, fmt.Errorf("timeout is negative")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// staleCommands are the commands a replica serves while its link to the
+// master is down even with replica-serve-stale-data off, as they do not
+// use the data.
+var staleCommands = map[string]bool{
+	"PING":      true,
+	"INFO":      true,
+	"CONFIG":    true,
+	"SELECT":    true,
+	"MULTI":     true,
+	"EXEC":      true,
+	"DISCARD":   true,
+	"REPLICAOF": true,
+	"SLAVEOF":   true,
+	"REPLCONF":  true,
+	"PSYNC":     true,
+}
+
+// ReplicaRefusal returns the error with which a replica refuses request,
+// if it does: writes on a read only replica, and the commands that use the
+// data when it is stale and must not be served.
+func ReplicaRefusal(request protocol.Array, repl *replication.Replication) (protocol.Error, bool) {
+	if !repl.IsReplica() {
+		return protocol.Error{}, false
+	}
+	options := repl.Options()
+	if options.ReadOnly.Load() && IsWrite(request) {
+		return protocol.Error{Prefix: "READONLY", Message: "You can't write against a read only replica."}, true
+	}
+	if !options.ServeStaleData.Load() && !commandIn(staleCommands, request) && repl.LinkDown() {
+		return protocol.Error{Prefix: "MASTERDOWN", Message: "Link with MASTER is down and replica-serve-stale-data is set to 'no'."}, true
+	}
+	return protocol.Error{}, false
+}
+
+// Evict evicts keys from dbs as needed to fit maxmemory, ordered with the
+// writes and sending each evicted key to replicas as a DEL. Replicas evict
+// nothing and leave it to their master, as Redis does with
+// replica-ignore-maxmemory, so they never refuse a command for memory.
+func Evict(dbs *store.Databases, repl *replication.Replication) error {
+	if repl.IsReplica() || !dbs.OverMaxMemory() {
+		return nil
+	}
+	repl.Lock()
+	defer repl.Unlock()
+	return dbs.Evict(func(db int, key string) {
+		repl.Propagate([]replication.Command{{DB: db, Args: []string{"DEL", key}}})
+	})
+}
+
+// writeCommands are the commands that may change the keyspace, which are
+// sent to replicas. Subcommands are listed as "NAME|SUBCOMMAND".
+var writeCommands = map[string]bool{
+	"SET":                   true,
+	"INCR":                  true,
+	"SADD":                  true,
+	"SREM":                  true,
+	"SPOP":                  true,
+	"SMOVE":                 true,
+	"SINTERSTORE":           true,
+	"SUNIONSTORE":           true,
+	"SDIFFSTORE":            true,
+	"ZADD":                  true,
+	"ZINCRBY":               true,
+	"ZREM":                  true,
+	"ZUNIONSTORE":           true,
+	"ZINTERSTORE":           true,
+	"ZDIFFSTORE":            true,
+	"ZRANGESTORE":           true,
+	"ZPOPMIN":               true,
+	"ZPOPMAX":               true,
+	"BZPOPMIN":              true,
+	"BZPOPMAX":              true,
+	"ZMPOP":                 true,
+	"BZMPOP":                true,
+	"ZREMRANGEBYRANK":       true,
+	"ZREMRANGEBYSCORE":      true,
+	"ZREMRANGEBYLEX":        true,
+	"XADD":                  true,
+	"XDEL":                  true,
+	"XTRIM":                 true,
+	"XGROUP|CREATE":         true,
+	"XGROUP|SETID":          true,
+	"XGROUP|DESTROY":        true,
+	"XGROUP|CREATECONSUMER": true,
+	"XGROUP|DELCONSUMER":    true,
+	"XREADGROUP":            true,
+	"XACK":                  true,
+	"XCLAIM":                true,
+	"XAUTOCLAIM":            true,
+	"SETBIT":                true,
+	"BITOP":                 true,
+	"BITFIELD":              true,
+	"PFADD":                 true,
+	"PFMERGE":               true,
+	"GEOADD":                true,
+	"GEOSEARCHSTORE":        true,
+	"DEL":                   true,
+	"UNLINK":                true,
+	"MOVE":                  true,
+	"SWAPDB":                true,
+	"FLUSHDB":               true,
+	"FLUSHALL":              true,
+	"RESTORE":               true,
+	"RESTORE-ASKING":        true,
+	"MIGRATE":               true,
+}
+
+// IsWrite reports whether request is a command that may change the
+// keyspace.
+func IsWrite(request protocol.Array) bool {
+	return commandIn(writeCommands, request)
+}
+
+// Writes returns what to send to replicas for cmd, parsed from request,
+// which ran against database db, s, and replied res: nothing when it failed
+// or changed nothing, and otherwise the request itself, unless replaying it
+// could have another outcome. Random and blocking pops are sent as removals
+// of what they popped, XADD with the ID it generated, and MIGRATE as the
+// removal of the keys it moved, even when the target refused others. XADD
+// and XTRIM trimming with "~" are sent with the exact length they left. SET
+// and RESTORE with a relative expiry are sent with the absolute one they
+// set, so replicas expire the key at the same time.
+// XREADGROUP, XCLAIM and XAUTOCLAIM are sent as the state they left the
+// entries and groups they touched in, read from s, since their outcome
+// depends on what else was read and on the clock.
+func Writes(db int, s *store.Store, request protocol.Array, cmd any, res protocol.Frame) []replication.Command {
+	if !IsWrite(request) {
+		return nil
+	}
+	switch res := res.(type) {
+	case protocol.Error:
+		// MIGRATE removes the keys the target restored even when it
+		// refused others.
+		if _, ok := cmd.(MigrateCommand); !ok || res.Prefix == "IOERR" {
+			return nil
+		}
+	case protocol.Array:
+		if res.Null {
+			return nil
+		}
+	}
+	args, err := bulkStrings("command", request.Elems)
+	if err != nil {
+		return nil
+	}
+
+	switch c := cmd.(type) {
+	case SetTTLCommand:
+		at, ok := s.ExpireTime(c.Key)
+		if !ok {
+			return nil
+		}
+		args = []string{"SET", c.Key, c.Value, "PXAT", strconv.FormatInt(at.UnixMilli(), 10)}
+	case RestoreCommand:
+		if c.TTL == 0 || c.AbsTTL {
+			break
+		}
+		at, ok := s.ExpireTime(c.Key)
+		if !ok {
+			return []replication.Command{{DB: db, Args: []string{"DEL", c.Key}}}
+		}
+		args[2] = strconv.FormatInt(at.UnixMilli(), 10)
+		args = append(args, "ABSTTL")
+	case SPopCommand:
+		members := bulkValues(res)
+		if len(members) == 0 {
+			return nil
+		}
+		args = append([]string{"SREM", c.Key}, members...)
+	case BZPopCommand:
+		// The reply is the key, the member and its score.
+		popped := bulkValues(res)
+		args = []string{"ZREM", popped[0], popped[1]}
+	case BZMPopCommand:
+		// The reply is the key and the member and score pairs.
+		elems := res.(protocol.Array).Elems
+		args = []string{"ZREM", bulkValues(elems[0])[0]}
+		for _, pair := range elems[1].(protocol.Array).Elems {
+			args = append(args, bulkValues(pair)[0])
+		}
+	case MigrateCommand:
+		if c.Copy || res == (protocol.SimpleString{Value: "NOKEY"}) {
+			return nil
+		}
+		args = []string{"DEL"}
+		for _, key := range c.Keys {
+			if _, ok := s.Type(key); !ok {
+				args = append(args, key)
+			}
+		}
+		if len(args) == 1 {
+			return nil
+		}
+	case XAddCommand:
+		id := bulkValues(res)
+		if len(id) == 0 {
+			return nil
+		}
+		args[len(args)-len(c.Fields)-1] = id[0]
+		if c.Trim != nil && c.Trim.Approx {
+			args = []string{"XADD", c.Key}
+			if c.NoMkStream {
+				args = append(args, "NOMKSTREAM")
+			}
+			args = append(args, exactTrim(s, c.Key)...)
+			args = append(append(args, id[0]), c.Fields...)
+		}
+	case XTrimCommand:
+		if c.Trim.Approx {
+			args = append([]string{"XTRIM", c.Key}, exactTrim(s, c.Key)...)
+		}
+	case XReadGroupCommand:
+		// The reply has the key and the entries of every stream read.
+		var writes []replication.Command
+		for _, read := range res.(protocol.Array).Elems {
+			elems := read.(protocol.Array).Elems
+			key := bulkValues(elems[0])[0]
+			if !c.readsNew(key) {
+				continue
+			}
+			writes = append(writes, replication.Command{DB: db, Args: []string{"XGROUP", "CREATECONSUMER", key, c.Group, c.Consumer}})
+			writes = append(writes, groupWrites(db, s, key, c.Group, entryIDs(elems[1]), true)...)
+		}
+		return writes
+	case XClaimCommand:
+		return groupWrites(db, s, c.Key, c.Group, c.IDs, c.Options.LastID != nil)
+	case XAutoClaimCommand:
+		// The reply has the next ID, the claimed entries and the deleted IDs.
+		elems := res.(protocol.Array).Elems
+		ids := append(entryIDs(elems[1]), entryIDs(elems[2])...)
+		writes := []replication.Command{{DB: db, Args: []string{"XGROUP", "CREATECONSUMER", c.Key, c.Group, c.Consumer}}}
+		return append(writes, groupWrites(db, s, c.Key, c.Group, ids, false)...)
+	}
+	return []replication.Command{{DB: db, Args: args}}
+}
+
+// exactTrim returns the trim arguments that keep the entries an approximate
+// trim of the stream at key kept in s. Which entries that trim removes
+// depends on how the stream is laid out in blocks, which differs on a
+// replica loaded from a snapshot.
+func exactTrim(s *store.Store, key string) []string {
+	length, _ := s.XLen(key)
+	return []string{"MAXLEN", "=", strconv.Itoa(length)}
+}
+
+// groupWrites sets ids of group in the stream at key on replicas as they
+// are in s: an XCLAIM with the delivery time and count of each pending one,
+// forced to the consumer it is pending for, and an XACK of the others. With
+// setID the last delivered ID and entries read of the group follow.
+func groupWrites(db int, s *store.Store, key string, group string, ids []store.StreamID, setID bool) []replication.Command {
+	lastID, entriesRead, pending, err := s.XGroupState(key, group, ids)
+	if err != nil {
+		return nil
+	}
+
+	var writes []replication.Command
+	isPending := make(map[store.StreamID]bool, len(pending))
+	for _, entry := range pending {
+		isPending[entry.ID] = true
+		writes = append(writes, replication.Command{DB: db, Args: []string{
+			"XCLAIM", key, group, entry.Consumer, "0", entry.ID.String(),
+			"TIME", strconv.FormatInt(entry.DeliveryTime.UnixMilli(), 10),
+			"RETRYCOUNT", strconv.Itoa(entry.DeliveryCount),
+			"FORCE", "JUSTID", "LASTID", lastID.String(),
+		}})
+	}
+	ack := []string{"XACK", key, group}
+	for _, id := range ids {
+		if !isPending[id] {
+			ack = append(ack, id.String())
+		}
+	}
+	if len(ack) > 3 {
+		writes = append(writes, replication.Command{DB: db, Args: ack})
+	}
+	if setID {
+		writes = append(writes, replication.Command{DB: db, Args: []string{
+			"XGROUP", "SETID", key, group, lastID.String(), "ENTRIESREAD", strconv.FormatInt(entriesRead, 10),
+		}})
+	}
+	return writes
+}
+
+// entryIDs returns the IDs of entries, an array of stream entries or of IDs.
+func entryIDs(entries protocol.Frame) []store.StreamID {
+	var ids []store.StreamID
+	for _, entry := range entries.(protocol.Array).Elems {
+		if e, ok := entry.(protocol.Array); ok {
+			entry = e.Elems[0]
+		}
+		if id, err := parseStreamID(bulkValues(entry)[0], 0); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// bulkValues returns the bulk strings of res, a bulk string or an array.
+func bulkValues(res protocol.Frame) []string {
+	switch res := res.(type) {
+	case protocol.BulkString:
+		return []string{string(res.Bytes)}
+	case protocol.Array:
+		var values []string
+		for _, elem := range res.Elems {
+			if bulk, ok := elem.(protocol.BulkString); ok {
+				values = append(values, string(bulk.Bytes))
+			}
+		}
+		return values
+	}
+	return nil
+}
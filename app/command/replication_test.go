@@ -0,0 +1,241 @@
+package command
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromArrayReplication(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "replicaof", in: request("REPLICAOF", "localhost", "6379"), want: ReplicaOfCommand{Host: "localhost", Port: "6379"}},
+		{name: "slaveof no one", in: request("SLAVEOF", "no", "one"), want: ReplicaOfCommand{NoOne: true}},
+		{name: "replicaof bad port", in: request("REPLICAOF", "localhost", "x"), wantErr: true},
+		{name: "replicaof arity", in: request("REPLICAOF", "localhost"), wantErr: true},
+		{name: "replconf port", in: request("REPLCONF", "listening-port", "6380", "capa", "psync2"), want: ReplConfCommand{ListeningPort: 6380}},
+		{name: "replconf capa eof", in: request("REPLCONF", "capa", "eof", "capa", "psync2"), want: ReplConfCommand{CapaEOF: true}},
+		{name: "replconf unknown", in: request("REPLCONF", "what", "1"), wantErr: true},
+		{name: "replconf odd", in: request("REPLCONF", "capa"), wantErr: true},
+		{name: "psync", in: request("PSYNC", "?", "-1"), want: PSyncCommand{ReplID: "?", Offset: -1}},
+		{name: "psync offset", in: request("PSYNC", "?", "x"), wantErr: true},
+		{name: "replconf ack", in: request("REPLCONF", "ACK", "42", "FACK", "0"), want: ReplConfCommand{Ack: true}},
+		{name: "wait", in: request("WAIT", "1", "500"), want: WaitCommand{NumReplicas: 1, Timeout: 500 * time.Millisecond}},
+		{name: "wait negative timeout", in: request("WAIT", "1", "-1"), wantErr: true},
+		{name: "wait arity", in: request("WAIT", "1"), wantErr: true},
+		{name: "waitaof", in: request("WAITAOF", "0", "2", "0"), want: WaitAOFCommand{NumReplicas: 2}},
+		{name: "waitaof numlocal", in: request("WAITAOF", "x", "2", "0"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestIsWrite(t *testing.T) {
+	t.Parallel()
+	assert.True(t, IsWrite(request("set", "k", "v")))
+	assert.True(t, IsWrite(request("XGROUP", "destroy", "s", "g")))
+	assert.False(t, IsWrite(request("XGROUP", "HELP")))
+	assert.False(t, IsWrite(request("GET", "k")))
+}
+
+// run executes request against s and returns what it sends to replicas.
+func run(t *testing.T, s *store.Store, args ...string) []replication.Command {
+	t.Helper()
+	req := request(args...)
+	cmd, err := FromArray(req)
+	require.NoError(t, err)
+	return Writes(3, s, req, cmd, cmd.(StoreCommand).Execute(s))
+}
+
+func TestWrites(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+
+	assert.Equal(t, []replication.Command{{DB: 3, Args: []string{"SADD", "s", "a"}}}, run(t, s, "SADD", "s", "a"))
+	assert.Nil(t, run(t, s, "SMEMBERS", "s"))
+	assert.Nil(t, run(t, s, "ZADD", "s", "1", "a"), "failed commands are not sent")
+	assert.Equal(t, []replication.Command{{DB: 3, Args: []string{"SREM", "s", "a"}}}, run(t, s, "SPOP", "s"))
+	assert.Nil(t, run(t, s, "SPOP", "s", "2"))
+
+	got := run(t, s, "SET", "k", "v", "EX", "100")
+	require.Len(t, got, 1)
+	at, _ := s.ExpireTime("k")
+	assert.Equal(t, []string{"SET", "k", "v", "PXAT", strconv.FormatInt(at.UnixMilli(), 10)}, got[0].Args)
+	assert.WithinDuration(t, time.Now().Add(100*time.Second), at, time.Second)
+	assert.Equal(t, got, run(t, s, got[0].Args...), "absolute expiries are sent as they are")
+
+	payload, _, ok := s.Dump("k")
+	require.True(t, ok)
+	got = run(t, s, "RESTORE", "r", "100000", string(payload))
+	require.Len(t, got, 1)
+	at, _ = s.ExpireTime("r")
+	assert.Equal(t, []string{"RESTORE", "r", strconv.FormatInt(at.UnixMilli(), 10), string(payload), "ABSTTL"}, got[0].Args)
+	assert.WithinDuration(t, time.Now().Add(100*time.Second), at, time.Second)
+	abs := []string{"RESTORE", "r", got[0].Args[2], string(payload), "REPLACE", "ABSTTL"}
+	assert.Equal(t, []replication.Command{{DB: 3, Args: abs}}, run(t, s, abs...), "absolute expiries are sent as they are")
+
+	got = run(t, s, "XADD", "x", "MAXLEN", "10", "*", "f", "v")
+	require.Len(t, got, 1)
+	assert.Equal(t, []string{"XADD", "x", "MAXLEN", "10"}, got[0].Args[:4])
+	assert.NotEqual(t, "*", got[0].Args[4])
+	assert.Equal(t, []string{"f", "v"}, got[0].Args[5:])
+
+	for i := range 300 {
+		run(t, s, "XADD", "t", strconv.Itoa(i+1), "f", "v")
+	}
+	got = run(t, s, "XADD", "t", "NOMKSTREAM", "MAXLEN", "~", "100", "LIMIT", "1000", "301", "f", "v")
+	length, _ := s.XLen("t")
+	assert.Greater(t, length, 100, "approximate trims keep whole blocks")
+	assert.Equal(t, []string{"XADD", "t", "NOMKSTREAM", "MAXLEN", "=", strconv.Itoa(length), "301-0", "f", "v"}, got[0].Args)
+	got = run(t, s, "XTRIM", "t", "MINID", "~", "250")
+	length, _ = s.XLen("t")
+	assert.Equal(t, []string{"XTRIM", "t", "MAXLEN", "=", strconv.Itoa(length)}, got[0].Args)
+	got = run(t, s, "XTRIM", "t", "MINID", "260")
+	assert.Equal(t, []string{"XTRIM", "t", "MINID", "260"}, got[0].Args, "exact trims are sent as they are")
+
+	run(t, s, "ZADD", "z", "1", "a", "2", "b", "3", "c")
+	assert.Equal(t, []replication.Command{{DB: 3, Args: []string{"ZREM", "z", "a"}}}, run(t, s, "BZPOPMIN", "z", "0"))
+	assert.Equal(t, []replication.Command{{DB: 3, Args: []string{"ZREM", "z", "c", "b"}}}, run(t, s, "BZMPOP", "0", "1", "z", "MAX", "COUNT", "5"))
+	assert.Nil(t, run(t, s, "BZPOPMIN", "z", "0"), "nothing popped")
+}
+
+func TestReplicaRefusal(t *testing.T) {
+	t.Parallel()
+	repl := replication.New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(repl.Close)
+	_, refused := ReplicaRefusal(request("SET", "k", "v"), repl)
+	assert.False(t, refused, "masters refuse nothing")
+
+	// Nothing listens on port 0, so the link stays down.
+	repl.ReplicaOf("127.0.0.1", "0")
+	msg, refused := ReplicaRefusal(request("SET", "k", "v"), repl)
+	assert.True(t, refused)
+	assert.Equal(t, "READONLY", msg.Prefix)
+	_, refused = ReplicaRefusal(request("GET", "k"), repl)
+	assert.False(t, refused)
+
+	repl.Options().ServeStaleData.Store(false)
+	msg, refused = ReplicaRefusal(request("GET", "k"), repl)
+	assert.True(t, refused)
+	assert.Equal(t, "MASTERDOWN", msg.Prefix)
+	_, refused = ReplicaRefusal(request("INFO", "replication"), repl)
+	assert.False(t, refused)
+
+	repl.Options().ReadOnly.Store(false)
+	msg, _ = ReplicaRefusal(request("SET", "k", "v"), repl)
+	assert.Equal(t, "MASTERDOWN", msg.Prefix)
+}
+
+// groupState returns the last delivered ID, entries read and PEL of group g
+// of stream x, with delivery times in milliseconds as replicas get them.
+func groupState(t *testing.T, s *store.Store) (store.StreamID, int64, []store.PendingEntry) {
+	t.Helper()
+	lastID, entriesRead, _, err := s.XGroupState("x", "g", nil)
+	require.NoError(t, err)
+	pel, err := s.XPendingRange("x", "g", store.XPendingSpec{End: store.MaxStreamID, Count: 100})
+	require.NoError(t, err)
+	for i := range pel {
+		pel[i].DeliveryTime = time.UnixMilli(pel[i].DeliveryTime.UnixMilli())
+	}
+	return lastID, entriesRead, pel
+}
+
+func TestWritesStreamGroups(t *testing.T) {
+	t.Parallel()
+	master, replica := store.NewStore(), store.NewStore()
+	// replicate runs args on the master and twice what it sends on the
+	// replica, as a full sync racing a write may, then compares the group.
+	replicate := func(args ...string) []replication.Command {
+		t.Helper()
+		writes := run(t, master, args...)
+		for range 2 {
+			for _, write := range writes {
+				cmd, err := FromArray(request(write.Args...))
+				require.NoError(t, err, write.Args)
+				cmd.(StoreCommand).Execute(replica)
+			}
+		}
+		if _, _, _, err := master.XGroupState("x", "g", nil); err == nil {
+			lastID, entriesRead, pel := groupState(t, master)
+			gotLastID, gotEntriesRead, gotPEL := groupState(t, replica)
+			assert.Equal(t, lastID, gotLastID)
+			assert.Equal(t, entriesRead, gotEntriesRead)
+			assert.Equal(t, pel, gotPEL)
+		}
+		return writes
+	}
+
+	replicate("XADD", "x", "1-1", "f", "v")
+	replicate("XADD", "x", "2-1", "f", "v")
+	replicate("XGROUP", "CREATE", "x", "g", "0")
+	replicate("XADD", "x", "3-1", "f", "v")
+
+	writes := replicate("XREADGROUP", "GROUP", "g", "alice", "COUNT", "2", "STREAMS", "x", ">")
+	require.Len(t, writes, 4)
+	assert.Equal(t, []string{"XGROUP", "CREATECONSUMER", "x", "g", "alice"}, writes[0].Args)
+	assert.Equal(t, []string{"XCLAIM", "x", "g", "alice", "0", "1-1", "TIME"}, writes[1].Args[:7])
+	assert.Equal(t, []string{"RETRYCOUNT", "1", "FORCE", "JUSTID", "LASTID", "2-1"}, writes[1].Args[8:])
+	assert.Equal(t, []string{"XGROUP", "SETID", "x", "g", "2-1", "ENTRIESREAD", "2"}, writes[3].Args)
+
+	writes = replicate("XREADGROUP", "GROUP", "g", "alice", "NOACK", "STREAMS", "x", ">")
+	assert.Equal(t, []string{"XACK", "x", "g", "3-1"}, writes[1].Args, "NOACK leaves nothing pending")
+	assert.Nil(t, replicate("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "x", "0"), "history reads change nothing")
+
+	writes = replicate("XCLAIM", "x", "g", "bob", "0", "1-1", "3-1")
+	require.Len(t, writes, 2)
+	assert.Equal(t, []string{"XCLAIM", "x", "g", "bob", "0", "1-1", "TIME"}, writes[0].Args[:7])
+	assert.Equal(t, []string{"RETRYCOUNT", "2"}, writes[0].Args[8:10], "the delivery count the master reached")
+	assert.Equal(t, []string{"XACK", "x", "g", "3-1"}, writes[1].Args)
+
+	replicate("XDEL", "x", "2-1")
+	writes = replicate("XAUTOCLAIM", "x", "g", "carol", "0", "0")
+	assert.Equal(t, []string{"XGROUP", "CREATECONSUMER", "x", "g", "carol"}, writes[0].Args)
+	assert.Equal(t, []string{"XACK", "x", "g", "2-1"}, writes[len(writes)-1].Args, "deleted entries are acknowledged")
+	_, _, pel := groupState(t, replica)
+	require.Len(t, pel, 1)
+	assert.Equal(t, "carol", pel[0].Consumer)
+	assert.Equal(t, 3, pel[0].DeliveryCount)
+}
+
+func TestEvict(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(1)
+	dbs.SetEvictionPolicy(store.AllKeysRandom)
+	for _, key := range []string{"a", "b", "c"} {
+		dbs.DB(0).Set(key, "v", nil)
+	}
+	dbs.SetMaxMemory(dbs.UsedMemory() - 1)
+	repl := replication.New(dbs, 0, nil)
+	t.Cleanup(repl.Close)
+
+	// Nothing listens on port 0, so the link stays down.
+	repl.ReplicaOf("127.0.0.1", "0")
+	require.NoError(t, Evict(dbs, repl))
+	assert.Equal(t, 3, dbs.DB(0).DBSize(), "replicas leave eviction to their master")
+
+	repl.ReplicaOfNoOne()
+	require.NoError(t, Evict(dbs, repl))
+	assert.Equal(t, 2, dbs.DB(0).DBSize())
+	assert.Equal(t, int64(1), dbs.EvictedKeys())
+}
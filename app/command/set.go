@@ -0,0 +1,360 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+type SAddCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c SAddCommand) Execute(store *store.Store) protocol.Frame {
+	added, err := store.SAdd(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: added}
+}
+
+type SRemCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c SRemCommand) Execute(store *store.Store) protocol.Frame {
+	removed, err := store.SRem(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: removed}
+}
+
+type SMembersCommand struct {
+	Key string
+}
+
+func (c SMembersCommand) Execute(store *store.Store) protocol.Frame {
+	members, err := store.SMembers(c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return bulkArray(members)
+}
+
+type SIsMemberCommand struct {
+	Key    string
+	Member string
+}
+
+func (c SIsMemberCommand) Execute(store *store.Store) protocol.Frame {
+	ok, err := store.SIsMember(c.Key, c.Member)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: boolToInt(ok)}
+}
+
+type SMIsMemberCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c SMIsMemberCommand) Execute(store *store.Store) protocol.Frame {
+	found, err := store.SMIsMember(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	elems := make([]protocol.Frame, len(found))
+	for i, ok := range found {
+		elems[i] = protocol.Integer{Value: boolToInt(ok)}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+type SCardCommand struct {
+	Key string
+}
+
+func (c SCardCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.SCard(c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+// SPopCommand replies with a single bulk string unless a count was given.
+type SPopCommand struct {
+	Key      string
+	Count    int
+	HasCount bool
+}
+
+func (c SPopCommand) Execute(store *store.Store) protocol.Frame {
+	count := c.Count
+	if !c.HasCount {
+		count = 1
+	}
+	members, err := store.SPop(c.Key, count)
+	if err != nil {
+		return errorFrame(err)
+	}
+	if c.HasCount {
+		return bulkArray(members)
+	}
+	if len(members) == 0 {
+		return protocol.BulkNullString{}
+	}
+	return protocol.BulkString{Bytes: []byte(members[0])}
+}
+
+// SRandMemberCommand replies with a single bulk string unless a count was given.
+type SRandMemberCommand struct {
+	Key      string
+	Count    int
+	HasCount bool
+}
+
+func (c SRandMemberCommand) Execute(store *store.Store) protocol.Frame {
+	count := c.Count
+	if !c.HasCount {
+		count = 1
+	}
+	members, err := store.SRandMember(c.Key, count)
+	if err != nil {
+		return errorFrame(err)
+	}
+	if c.HasCount {
+		return bulkArray(members)
+	}
+	if len(members) == 0 {
+		return protocol.BulkNullString{}
+	}
+	return protocol.BulkString{Bytes: []byte(members[0])}
+}
+
+type SMoveCommand struct {
+	Source      string
+	Destination string
+	Member      string
+}
+
+func (c SMoveCommand) Execute(store *store.Store) protocol.Frame {
+	moved, err := store.SMove(c.Source, c.Destination, c.Member)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: boolToInt(moved)}
+}
+
+// SetOpCommand implements SINTER, SUNION and SDIFF.
+type SetOpCommand struct {
+	Op   store.SetOp
+	Keys []string
+}
+
+func (c SetOpCommand) Execute(s *store.Store) protocol.Frame {
+	var members []string
+	var err error
+	switch c.Op {
+	case store.SetOpInter:
+		members, err = s.SInter(c.Keys)
+	case store.SetOpUnion:
+		members, err = s.SUnion(c.Keys)
+	case store.SetOpDiff:
+		members, err = s.SDiff(c.Keys)
+	default:
+		panic("unknown set operation")
+	}
+	if err != nil {
+		return errorFrame(err)
+	}
+	return bulkArray(members)
+}
+
+// SetOpStoreCommand implements SINTERSTORE, SUNIONSTORE and SDIFFSTORE.
+type SetOpStoreCommand struct {
+	Op          store.SetOp
+	Destination string
+	Keys        []string
+}
+
+func (c SetOpStoreCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.SetOpStore(c.Op, c.Destination, c.Keys)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+type SInterCardCommand struct {
+	Keys  []string
+	Limit int
+}
+
+func (c SInterCardCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.SInterCard(c.Keys, c.Limit)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+func parseSAdd(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("sadd command requires at least 2 arguments")
+	}
+	return SAddCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseSRem(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("srem command requires at least 2 arguments")
+	}
+	return SRemCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseSMembers(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("smembers command requires 1 argument")
+	}
+	return SMembersCommand{Key: args[0]}, nil
+}
+
+func parseSIsMember(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("sismember command requires 2 arguments")
+	}
+	return SIsMemberCommand{Key: args[0], Member: args[1]}, nil
+}
+
+func parseSMIsMember(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("smismember command requires at least 2 arguments")
+	}
+	return SMIsMemberCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseSCard(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("scard command requires 1 argument")
+	}
+	return SCardCommand{Key: args[0]}, nil
+}
+
+func parseSPop(args []string) (any, error) {
+	switch len(args) {
+	case 1:
+		return SPopCommand{Key: args[0]}, nil
+	case 2:
+		count, err := strconv.Atoi(args[1])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("value is out of range, must be positive")
+		}
+		return SPopCommand{Key: args[0], Count: count, HasCount: true}, nil
+	default:
+		return nil, fmt.Errorf("spop command requires 1 or 2 arguments")
+	}
+}
+
+func parseSRandMember(args []string) (any, error) {
+	switch len(args) {
+	case 1:
+		return SRandMemberCommand{Key: args[0]}, nil
+	case 2:
+		count, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		// Redis bounds the count so that the reply size can be computed.
+		if count < -math.MaxInt/2 || count > math.MaxInt/2 {
+			return nil, fmt.Errorf("value is out of range")
+		}
+		return SRandMemberCommand{Key: args[0], Count: count, HasCount: true}, nil
+	default:
+		return nil, fmt.Errorf("srandmember command requires 1 or 2 arguments")
+	}
+}
+
+func parseSMove(args []string) (any, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("smove command requires 3 arguments")
+	}
+	return SMoveCommand{Source: args[0], Destination: args[1], Member: args[2]}, nil
+}
+
+func parseSetOp(op store.SetOp) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("set operation requires at least 1 key")
+		}
+		return SetOpCommand{Op: op, Keys: args}, nil
+	}
+}
+
+func parseSetOpStore(op store.SetOp) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("set store operation requires a destination and at least 1 key")
+		}
+		return SetOpStoreCommand{Op: op, Destination: args[0], Keys: args[1:]}, nil
+	}
+}
+
+func parseSInterCard(args []string) (any, error) {
+	keys, rest, err := parseNumKeys("sintercard", args)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 0
+	switch len(rest) {
+	case 0:
+	case 2:
+		if strings.ToUpper(rest[0]) != "LIMIT" {
+			return nil, fmt.Errorf("syntax error")
+		}
+		limit, err = strconv.Atoi(rest[1])
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		if limit < 0 {
+			return nil, fmt.Errorf("LIMIT can't be negative")
+		}
+	default:
+		return nil, fmt.Errorf("syntax error")
+	}
+	return SInterCardCommand{Keys: keys, Limit: limit}, nil
+}
+
+// parseNumKeys splits "numkeys key [key ...] rest..." into the keys and the remaining arguments.
+func parseNumKeys(name string, args []string) ([]string, []string, error) {
+	if len(args) < 1 {
+		return nil, nil, fmt.Errorf("%s command requires numkeys", name)
+	}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, nil, fmt.Errorf("numkeys should be greater than 0")
+	}
+	if numKeys > len(args)-1 {
+		return nil, nil, fmt.Errorf("Number of keys can't be greater than number of args")
+	}
+	return args[1 : 1+numKeys], args[1+numKeys:], nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// request builds a command array from plain string arguments.
+func request(args ...string) protocol.Array {
+	elems := make([]protocol.Frame, len(args))
+	for i, arg := range args {
+		elems[i] = protocol.BulkString{Bytes: []byte(arg)}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+func TestFromArraySet(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{name: "sadd", in: request("SADD", "k", "a", "b"), want: SAddCommand{Key: "k", Members: []string{"a", "b"}}},
+		{name: "sadd missing member", in: request("SADD", "k"), wantErr: true},
+		{name: "spop", in: request("spop", "k"), want: SPopCommand{Key: "k"}},
+		{name: "spop count", in: request("SPOP", "k", "2"), want: SPopCommand{Key: "k", Count: 2, HasCount: true}},
+		{name: "spop negative count", in: request("SPOP", "k", "-2"), wantErr: true},
+		{name: "srandmember negative count", in: request("SRANDMEMBER", "k", "-2"), want: SRandMemberCommand{Key: "k", Count: -2, HasCount: true}},
+		{name: "srandmember lowest count", in: request("SRANDMEMBER", "k", "-9223372036854775808"), wantErr: true},
+		{name: "srandmember count out of range", in: request("SRANDMEMBER", "k", "4611686018427387904"), wantErr: true},
+		{name: "srandmember largest count", in: request("SRANDMEMBER", "k", "-4611686018427387903"), want: SRandMemberCommand{Key: "k", Count: -4611686018427387903, HasCount: true}},
+		{name: "sunionstore", in: request("SUNIONSTORE", "d", "a", "b"), want: SetOpStoreCommand{Op: store.SetOpUnion, Destination: "d", Keys: []string{"a", "b"}}},
+		{name: "sintercard limit", in: request("SINTERCARD", "2", "a", "b", "LIMIT", "1"), want: SInterCardCommand{Keys: []string{"a", "b"}, Limit: 1}},
+		{name: "sintercard too few keys", in: request("SINTERCARD", "3", "a", "b"), wantErr: true},
+		{name: "sintercard negative limit", in: request("SINTERCARD", "1", "a", "LIMIT", "-1"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetCommandsWrongType(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	s.Set("k", "v", nil)
+	got := SAddCommand{Key: "k", Members: []string{"a"}}.Execute(s)
+	assert.Equal(t, protocol.Error{Prefix: "WRONGTYPE", Message: store.ErrWrongType.Error()}, got)
+}
+
+func TestSRandMemberOutOfRange(t *testing.T) {
+	t.Parallel()
+	_, err := FromArray(request("SRANDMEMBER", "k", "-9223372036854775808"))
+	assert.EqualError(t, err, "value is out of range")
+}
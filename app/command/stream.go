@@ -0,0 +1,497 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+type XAddCommand struct {
+	Key        string
+	ID         store.StreamIDSpec
+	Fields     []string
+	NoMkStream bool
+	Trim       *store.StreamTrim
+}
+
+func (c XAddCommand) Execute(store *store.Store) protocol.Frame {
+	id, ok, err := store.XAdd(c.Key, c.ID, c.Fields, c.NoMkStream, c.Trim)
+	if err != nil {
+		return errorFrame(err)
+	}
+	if !ok {
+		return protocol.BulkNullString{}
+	}
+	return protocol.BulkString{Bytes: []byte(id.String())}
+}
+
+// XRangeCommand implements XRANGE and XREVRANGE. Count is negative when no
+// COUNT was given.
+type XRangeCommand struct {
+	Key   string
+	Start store.StreamID
+	End   store.StreamID
+	Count int
+	Rev   bool
+}
+
+func (c XRangeCommand) Execute(store *store.Store) protocol.Frame {
+	entries, err := store.XRange(c.Key, c.Start, c.End, c.Count, c.Rev)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return streamEntriesArray(entries)
+}
+
+type XLenCommand struct {
+	Key string
+}
+
+func (c XLenCommand) Execute(store *store.Store) protocol.Frame {
+	length, err := store.XLen(c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: length}
+}
+
+type XDelCommand struct {
+	Key string
+	IDs []store.StreamID
+}
+
+func (c XDelCommand) Execute(store *store.Store) protocol.Frame {
+	deleted, err := store.XDel(c.Key, c.IDs)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: deleted}
+}
+
+type XTrimCommand struct {
+	Key  string
+	Trim store.StreamTrim
+}
+
+func (c XTrimCommand) Execute(store *store.Store) protocol.Frame {
+	removed, err := store.XTrim(c.Key, c.Trim)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: removed}
+}
+
+// XReadCommand implements XREAD. Count is negative when no COUNT was given
+// and Block is nil unless BLOCK was given, in which case a zero duration
+// waits forever.
+type XReadCommand struct {
+	Keys   []string
+	Starts []store.XReadStart
+	Count  int
+	Block  *time.Duration
+}
+
+// Execute reads without blocking, which is also how XREAD BLOCK behaves
+// inside MULTI.
+func (c XReadCommand) Execute(store *store.Store) protocol.Frame {
+	after, err := store.XReadResolve(c.Keys, c.Starts)
+	if err != nil {
+		return errorFrame(err)
+	}
+	res, ok := c.try(store, after)
+	if !ok {
+		return protocol.Array{Null: true}
+	}
+	return res
+}
+
+func (c XReadCommand) ExecuteBlocking(store *store.Store, attempt Attempt) protocol.Frame {
+	if c.Block == nil {
+		res, _ := attempt(func() (protocol.Frame, bool) { return c.Execute(store), true })
+		return res
+	}
+	after, err := store.XReadResolve(c.Keys, c.Starts)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return waitForKeys(store, c.Keys, *c.Block, attempt, func() (protocol.Frame, bool) { return c.try(store, after) })
+}
+
+func (c XReadCommand) try(store *store.Store, after []store.StreamID) (protocol.Frame, bool) {
+	reads, err := store.XRead(c.Keys, after, c.Count)
+	if err != nil {
+		return errorFrame(err), true
+	}
+	if len(reads) == 0 {
+		return nil, false
+	}
+	return streamReadsArray(reads), true
+}
+
+// streamEntryFrame encodes an entry as [id, [field, value, ...]]. Entries
+// without fields, which XREADGROUP reports for pending entries deleted from
+// the stream, have a null array instead.
+func streamEntryFrame(entry store.StreamEntry) protocol.Frame {
+	fields := protocol.Array{Null: true}
+	if entry.Fields != nil {
+		fields = bulkArray(entry.Fields)
+	}
+	return protocol.Array{Elems: []protocol.Frame{
+		protocol.BulkString{Bytes: []byte(entry.ID.String())},
+		fields,
+	}}
+}
+
+func streamEntriesArray(entries []store.StreamEntry) protocol.Array {
+	elems := make([]protocol.Frame, len(entries))
+	for i, entry := range entries {
+		elems[i] = streamEntryFrame(entry)
+	}
+	return protocol.Array{Elems: elems}
+}
+
+var errInvalidStreamID = fmt.Errorf("Invalid stream ID specified as stream command argument")
+
+// parseStreamID parses "<ms>-<seq>" or "<ms>", in which case the sequence is missingSeq.
+func parseStreamID(arg string, missingSeq uint64) (store.StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(arg, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return store.StreamID{}, errInvalidStreamID
+	}
+	if !hasSeq {
+		return store.StreamID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return store.StreamID{}, errInvalidStreamID
+	}
+	return store.StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// parseXAddID parses the XADD ID argument: "*", "<ms>-*" or an explicit ID.
+func parseXAddID(arg string) (store.StreamIDSpec, error) {
+	if arg == "*" {
+		return store.StreamIDSpec{AutoMs: true}, nil
+	}
+	if msPart, ok := strings.CutSuffix(arg, "-*"); ok {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return store.StreamIDSpec{}, errInvalidStreamID
+		}
+		return store.StreamIDSpec{ID: store.StreamID{Ms: ms}, AutoSeq: true}, nil
+	}
+	id, err := parseStreamID(arg, 0)
+	if err != nil {
+		return store.StreamIDSpec{}, err
+	}
+	return store.StreamIDSpec{ID: id}, nil
+}
+
+// parseRangeStart parses an XRANGE lower bound: "-", "+", an ID whose missing
+// sequence defaults to 0, or "(" followed by an ID to exclude it.
+func parseRangeStart(arg string) (store.StreamID, error) {
+	switch arg {
+	case "-":
+		return store.StreamID{}, nil
+	case "+":
+		return store.MaxStreamID, nil
+	}
+	if rest, ok := strings.CutPrefix(arg, "("); ok {
+		id, err := parseStreamID(rest, 0)
+		if err != nil {
+			return store.StreamID{}, err
+		}
+		next, ok := id.Next()
+		if !ok {
+			return store.StreamID{}, fmt.Errorf("invalid start ID for the interval")
+		}
+		return next, nil
+	}
+	return parseStreamID(arg, 0)
+}
+
+// parseRangeEnd parses an XRANGE upper bound, where a missing sequence defaults to the maximum.
+func parseRangeEnd(arg string) (store.StreamID, error) {
+	switch arg {
+	case "-":
+		return store.StreamID{}, nil
+	case "+":
+		return store.MaxStreamID, nil
+	}
+	if rest, ok := strings.CutPrefix(arg, "("); ok {
+		id, err := parseStreamID(rest, math.MaxUint64)
+		if err != nil {
+			return store.StreamID{}, err
+		}
+		prev, ok := id.Prev()
+		if !ok {
+			return store.StreamID{}, fmt.Errorf("invalid end ID for the interval")
+		}
+		return prev, nil
+	}
+	return parseStreamID(arg, math.MaxUint64)
+}
+
+// parseStreamTrim parses "MAXLEN|MINID [=|~] threshold [LIMIT count]"
+// starting at args[0] and returns the number of arguments consumed.
+func parseStreamTrim(args []string) (store.StreamTrim, int, error) {
+	var trim store.StreamTrim
+	switch strings.ToUpper(args[0]) {
+	case "MAXLEN":
+		trim.Strategy = store.StreamTrimMaxLen
+	case "MINID":
+		trim.Strategy = store.StreamTrimMinID
+	default:
+		return store.StreamTrim{}, 0, fmt.Errorf("syntax error")
+	}
+
+	i := 1
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		trim.Approx = args[i] == "~"
+		i++
+	}
+	if i >= len(args) {
+		return store.StreamTrim{}, 0, fmt.Errorf("syntax error")
+	}
+
+	switch trim.Strategy {
+	case store.StreamTrimMaxLen:
+		maxLen, err := strconv.Atoi(args[i])
+		if err != nil {
+			return store.StreamTrim{}, 0, fmt.Errorf("value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return store.StreamTrim{}, 0, fmt.Errorf("The MAXLEN argument must be >= 0.")
+		}
+		trim.MaxLen = maxLen
+	case store.StreamTrimMinID:
+		minID, err := parseStreamID(args[i], 0)
+		if err != nil {
+			return store.StreamTrim{}, 0, err
+		}
+		trim.MinID = minID
+	}
+	i++
+
+	if i+1 < len(args) && strings.ToUpper(args[i]) == "LIMIT" {
+		limit, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return store.StreamTrim{}, 0, fmt.Errorf("value is not an integer or out of range")
+		}
+		if limit < 0 {
+			return store.StreamTrim{}, 0, fmt.Errorf("The LIMIT argument must be >= 0.")
+		}
+		if !trim.Approx {
+			return store.StreamTrim{}, 0, fmt.Errorf("syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		trim.Limit = limit
+		i += 2
+	}
+	return trim, i, nil
+}
+
+func parseXAdd(args []string) (any, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("xadd command requires at least 4 arguments")
+	}
+
+	cmd := XAddCommand{Key: args[0]}
+	i := 1
+options:
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "NOMKSTREAM":
+			cmd.NoMkStream = true
+			i++
+		case "MAXLEN", "MINID":
+			trim, consumed, err := parseStreamTrim(args[i:])
+			if err != nil {
+				return nil, err
+			}
+			cmd.Trim = &trim
+			i += consumed
+		default:
+			break options
+		}
+	}
+
+	rest := args[i:]
+	if len(rest) < 3 || len(rest)%2 != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for 'xadd' command")
+	}
+	id, err := parseXAddID(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd.ID = id
+	cmd.Fields = rest[1:]
+	return cmd, nil
+}
+
+func parseXRange(rev bool) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) != 3 && len(args) != 5 {
+			return nil, fmt.Errorf("xrange command requires 3 or 5 arguments")
+		}
+
+		startArg, endArg := args[1], args[2]
+		if rev {
+			startArg, endArg = endArg, startArg
+		}
+		start, err := parseRangeStart(startArg)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseRangeEnd(endArg)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd := XRangeCommand{Key: args[0], Start: start, End: end, Count: -1, Rev: rev}
+		if len(args) == 5 {
+			if strings.ToUpper(args[3]) != "COUNT" {
+				return nil, fmt.Errorf("syntax error")
+			}
+			count, err := strconv.Atoi(args[4])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			cmd.Count = max(count, 0)
+		}
+		return cmd, nil
+	}
+}
+
+func parseXLen(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("xlen command requires 1 argument")
+	}
+	return XLenCommand{Key: args[0]}, nil
+}
+
+func parseXDel(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("xdel command requires at least 2 arguments")
+	}
+	ids := make([]store.StreamID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return XDelCommand{Key: args[0], IDs: ids}, nil
+}
+
+func parseXTrim(args []string) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("xtrim command requires at least 3 arguments")
+	}
+	trim, consumed, err := parseStreamTrim(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if consumed != len(args)-1 {
+		return nil, fmt.Errorf("syntax error")
+	}
+	return XTrimCommand{Key: args[0], Trim: trim}, nil
+}
+
+// parseXReadStart parses an XREAD ID: "$", "+" or an ID whose missing sequence defaults to 0.
+func parseXReadStart(arg string) (store.XReadStart, error) {
+	switch arg {
+	case ">":
+		return store.XReadStart{}, fmt.Errorf("The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+	case "$":
+		return store.XReadStart{From: store.XReadNew}, nil
+	case "+":
+		return store.XReadStart{From: store.XReadLast}, nil
+	}
+	id, err := parseStreamID(arg, 0)
+	if err != nil {
+		return store.XReadStart{}, err
+	}
+	return store.XReadStart{ID: id}, nil
+}
+
+func parseXRead(args []string) (any, error) {
+	cmd, noAck, err := parseXReadArgs("xread", args, parseXReadStart)
+	if err != nil {
+		return nil, err
+	}
+	if noAck {
+		return nil, fmt.Errorf("syntax error")
+	}
+	return cmd, nil
+}
+
+// parseXReadArgs parses "[COUNT count] [BLOCK ms] [NOACK] STREAMS key... id..."
+// for XREAD and XREADGROUP, using parseStart for the IDs.
+func parseXReadArgs(name string, args []string, parseStart func(string) (store.XReadStart, error)) (XReadCommand, bool, error) {
+	cmd := XReadCommand{Count: -1}
+	noAck := false
+	i := 0
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		if option == "STREAMS" {
+			break
+		}
+		if option == "NOACK" {
+			noAck = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return XReadCommand{}, false, fmt.Errorf("syntax error")
+		}
+		switch option {
+		case "COUNT":
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return XReadCommand{}, false, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count > 0 {
+				cmd.Count = count
+			}
+		case "BLOCK":
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || ms > int64(math.MaxInt64/time.Millisecond) {
+				return XReadCommand{}, false, fmt.Errorf("timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return XReadCommand{}, false, fmt.Errorf("timeout is negative")
+			}
+			block := time.Duration(ms) * time.Millisecond
+			cmd.Block = &block
+		default:
+			return XReadCommand{}, false, fmt.Errorf("syntax error")
+		}
+		i++
+	}
+
+	streams := args[min(i+1, len(args)):]
+	if i == len(args) || len(streams) == 0 {
+		return XReadCommand{}, false, fmt.Errorf("syntax error")
+	}
+	if len(streams)%2 != 0 {
+		return XReadCommand{}, false, fmt.Errorf("Unbalanced '%s' list of streams: for each stream key an ID or '$' must be specified.", name)
+	}
+
+	n := len(streams) / 2
+	cmd.Keys = streams[:n]
+	cmd.Starts = make([]store.XReadStart, n)
+	for j, arg := range streams[n:] {
+		start, err := parseStart(arg)
+		if err != nil {
+			return XReadCommand{}, false, err
+		}
+		cmd.Starts[j] = start
+	}
+	return cmd, noAck, nil
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayStream(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "xadd auto",
+			in:   request("XADD", "s", "*", "f", "v"),
+			want: XAddCommand{Key: "s", ID: store.StreamIDSpec{AutoMs: true}, Fields: []string{"f", "v"}},
+		},
+		{
+			name: "xadd auto seq with trim",
+			in:   request("XADD", "s", "NOMKSTREAM", "MAXLEN", "~", "10", "LIMIT", "5", "7-*", "f", "v"),
+			want: XAddCommand{Key: "s", ID: store.StreamIDSpec{ID: store.StreamID{Ms: 7}, AutoSeq: true}, Fields: []string{"f", "v"}, NoMkStream: true, Trim: &store.StreamTrim{MaxLen: 10, Approx: true, Limit: 5}},
+		},
+		{name: "xadd limit without approx", in: request("XADD", "s", "MAXLEN", "10", "LIMIT", "5", "*", "f", "v"), wantErr: true},
+		{name: "xadd odd fields", in: request("XADD", "s", "*", "f", "v", "g"), wantErr: true},
+		{name: "xadd bad id", in: request("XADD", "s", "abc", "f", "v"), wantErr: true},
+		{
+			name: "xrange specials",
+			in:   request("XRANGE", "s", "-", "+"),
+			want: XRangeCommand{Key: "s", Start: store.StreamID{}, End: store.MaxStreamID, Count: -1},
+		},
+		{
+			name: "xrange incomplete ids",
+			in:   request("XRANGE", "s", "5", "6", "COUNT", "2"),
+			want: XRangeCommand{Key: "s", Start: store.StreamID{Ms: 5}, End: store.StreamID{Ms: 6, Seq: math.MaxUint64}, Count: 2},
+		},
+		{
+			name: "xrevrange exclusive",
+			in:   request("XREVRANGE", "s", "(5-0", "(1-2"),
+			want: XRangeCommand{Key: "s", Start: store.StreamID{Ms: 1, Seq: 3}, End: store.StreamID{Ms: 4, Seq: math.MaxUint64}, Count: -1, Rev: true},
+		},
+		{name: "xrange exclusive overflow", in: request("XRANGE", "s", "(18446744073709551615-18446744073709551615", "+"), wantErr: true},
+		{
+			name: "xtrim minid",
+			in:   request("XTRIM", "s", "MINID", "=", "5-1"),
+			want: XTrimCommand{Key: "s", Trim: store.StreamTrim{Strategy: store.StreamTrimMinID, MinID: store.StreamID{Ms: 5, Seq: 1}}},
+		},
+		{name: "xtrim negative maxlen", in: request("XTRIM", "s", "MAXLEN", "-1"), wantErr: true},
+		{
+			name: "xread specials",
+			in:   request("XREAD", "COUNT", "2", "BLOCK", "0", "STREAMS", "a", "b", "c", "$", "+", "5"),
+			want: XReadCommand{
+				Keys:   []string{"a", "b", "c"},
+				Starts: []store.XReadStart{{From: store.XReadNew}, {From: store.XReadLast}, {ID: store.StreamID{Ms: 5}}},
+				Count:  2,
+				Block:  new(time.Duration),
+			},
+		},
+		{name: "xread unbalanced", in: request("XREAD", "STREAMS", "a", "b", "0"), wantErr: true},
+		{name: "xread missing streams", in: request("XREAD", "COUNT", "1"), wantErr: true},
+		{name: "xread negative block", in: request("XREAD", "BLOCK", "-1", "STREAMS", "a", "0"), wantErr: true},
+		{name: "xdel", in: request("XDEL", "s", "1-1", "2"), want: XDelCommand{Key: "s", IDs: []store.StreamID{{Ms: 1, Seq: 1}, {Ms: 2}}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestXAddCommandErrors(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	XAddCommand{Key: "s", ID: store.StreamIDSpec{ID: store.StreamID{Ms: 5}}, Fields: []string{"f", "v"}}.Execute(s)
+	got := XAddCommand{Key: "s", ID: store.StreamIDSpec{ID: store.StreamID{Ms: 4}}, Fields: []string{"f", "v"}}.Execute(s)
+	assert.Equal(t, protocol.Error{Message: store.ErrStreamIDTooSmall.Error()}, got)
+}
+
+func TestXRead(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	for _, id := range []string{"1-1", "1-2", "2-0"} {
+		cmd, _ := FromArray(request("XADD", "a", id, "f", id))
+		cmd.(XAddCommand).Execute(s)
+	}
+	entry := func(id string) protocol.Frame {
+		return streamEntryFrame(store.StreamEntry{ID: mustStreamID(t, id), Fields: []string{"f", id}})
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		want protocol.Frame
+	}{
+		{
+			name: "after id with count",
+			args: []string{"XREAD", "COUNT", "2", "STREAMS", "a", "missing", "1", "0"},
+			want: protocol.Array{Elems: []protocol.Frame{
+				protocol.Array{Elems: []protocol.Frame{protocol.BulkString{Bytes: []byte("a")}, protocol.Array{Elems: []protocol.Frame{entry("1-1"), entry("1-2")}}}},
+			}},
+		},
+		{
+			name: "last entry",
+			args: []string{"XREAD", "STREAMS", "a", "+"},
+			want: protocol.Array{Elems: []protocol.Frame{
+				protocol.Array{Elems: []protocol.Frame{protocol.BulkString{Bytes: []byte("a")}, protocol.Array{Elems: []protocol.Frame{entry("2-0")}}}},
+			}},
+		},
+		{name: "new only", args: []string{"XREAD", "STREAMS", "a", "$"}, want: protocol.Array{Null: true}},
+		{name: "nothing newer", args: []string{"XREAD", "BLOCK", "10", "STREAMS", "a", "2-0"}, want: protocol.Array{Null: true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cmd, err := FromArray(request(tc.args...))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, cmd.(XReadCommand).ExecuteBlocking(s, attemptNow))
+		})
+	}
+}
+
+func TestXReadBlocksUntilXAdd(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	XAddCommand{Key: "a", ID: store.StreamIDSpec{ID: store.StreamID{Ms: 1}}, Fields: []string{"f", "old"}}.Execute(s)
+
+	block := 5 * time.Second
+	done := make(chan protocol.Frame)
+	go func() {
+		done <- XReadCommand{
+			Keys:   []string{"a", "b"},
+			Starts: []store.XReadStart{{From: store.XReadNew}, {From: store.XReadNew}},
+			Count:  -1,
+			Block:  &block,
+		}.ExecuteBlocking(s, attemptNow)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	XAddCommand{Key: "b", ID: store.StreamIDSpec{ID: store.StreamID{Ms: 2}}, Fields: []string{"f", "new"}}.Execute(s)
+
+	select {
+	case got := <-done:
+		want := protocol.Array{Elems: []protocol.Frame{
+			protocol.Array{Elems: []protocol.Frame{
+				protocol.BulkString{Bytes: []byte("b")},
+				streamEntriesArray([]store.StreamEntry{{ID: store.StreamID{Ms: 2}, Fields: []string{"f", "new"}}}),
+			}},
+		}}
+		assert.Equal(t, want, got)
+	case <-time.After(time.Second):
+		t.Fatal("XREAD did not wake up")
+	}
+}
+
+func mustStreamID(t *testing.T, arg string) store.StreamID {
+	t.Helper()
+	id, err := parseStreamID(arg, 0)
+	assert.NoError(t, err)
+	return id
+}
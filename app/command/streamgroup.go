@@ -0,0 +1,794 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// XGroupCreateCommand implements XGROUP CREATE. UseLastID is set for the "$" ID.
+type XGroupCreateCommand struct {
+	Key         string
+	Group       string
+	ID          store.StreamID
+	UseLastID   bool
+	MkStream    bool
+	EntriesRead int64
+}
+
+func (c XGroupCreateCommand) Execute(store *store.Store) protocol.Frame {
+	if err := store.XGroupCreate(c.Key, c.Group, c.ID, c.UseLastID, c.MkStream, c.EntriesRead); err != nil {
+		return errorFrame(err)
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+// XGroupSetIDCommand implements XGROUP SETID.
+type XGroupSetIDCommand struct {
+	Key         string
+	Group       string
+	ID          store.StreamID
+	UseLastID   bool
+	EntriesRead int64
+}
+
+func (c XGroupSetIDCommand) Execute(store *store.Store) protocol.Frame {
+	if err := store.XGroupSetID(c.Key, c.Group, c.ID, c.UseLastID, c.EntriesRead); err != nil {
+		return errorFrame(err)
+	}
+	return protocol.SimpleString{Value: "OK"}
+}
+
+type XGroupDestroyCommand struct {
+	Key   string
+	Group string
+}
+
+func (c XGroupDestroyCommand) Execute(store *store.Store) protocol.Frame {
+	destroyed, err := store.XGroupDestroy(c.Key, c.Group)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: boolToInt(destroyed)}
+}
+
+type XGroupCreateConsumerCommand struct {
+	Key      string
+	Group    string
+	Consumer string
+}
+
+func (c XGroupCreateConsumerCommand) Execute(store *store.Store) protocol.Frame {
+	created, err := store.XGroupCreateConsumer(c.Key, c.Group, c.Consumer)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: boolToInt(created)}
+}
+
+type XGroupDelConsumerCommand struct {
+	Key      string
+	Group    string
+	Consumer string
+}
+
+func (c XGroupDelConsumerCommand) Execute(store *store.Store) protocol.Frame {
+	pending, err := store.XGroupDelConsumer(c.Key, c.Group, c.Consumer)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: pending}
+}
+
+// XReadGroupCommand implements XREADGROUP. Starts hold store.XReadNew for
+// the ">" ID.
+type XReadGroupCommand struct {
+	XReadCommand
+	Group    string
+	Consumer string
+	NoAck    bool
+}
+
+// Execute reads without blocking, which is also how XREADGROUP BLOCK behaves
+// inside MULTI.
+func (c XReadGroupCommand) Execute(store *store.Store) protocol.Frame {
+	res, ok := c.try(store)
+	if !ok {
+		return protocol.Array{Null: true}
+	}
+	return res
+}
+
+func (c XReadGroupCommand) ExecuteBlocking(store *store.Store, attempt Attempt) protocol.Frame {
+	if c.Block == nil {
+		res, _ := attempt(func() (protocol.Frame, bool) { return c.Execute(store), true })
+		return res
+	}
+	return waitForKeys(store, c.Keys, *c.Block, attempt, func() (protocol.Frame, bool) { return c.try(store) })
+}
+
+func (c XReadGroupCommand) try(store *store.Store) (protocol.Frame, bool) {
+	reads, err := store.XReadGroup(c.Keys, c.Starts, c.Group, c.Consumer, c.Count, c.NoAck)
+	if err != nil {
+		return noKeyOrGroupFrame(err, " in XREADGROUP with GROUP option"), true
+	}
+	if len(reads) == 0 {
+		return nil, false
+	}
+	return streamReadsArray(reads), true
+}
+
+// readsNew reports whether c reads key with the ">" ID.
+func (c XReadGroupCommand) readsNew(key string) bool {
+	for i, k := range c.Keys {
+		if k == key && c.Starts[i].From == store.XReadNew {
+			return true
+		}
+	}
+	return false
+}
+
+type XAckCommand struct {
+	Key   string
+	Group string
+	IDs   []store.StreamID
+}
+
+func (c XAckCommand) Execute(store *store.Store) protocol.Frame {
+	acked, err := store.XAck(c.Key, c.Group, c.IDs)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: acked}
+}
+
+// XPendingCommand implements XPENDING. Without Extended it replies with the
+// summary of the group, otherwise with the entries selected by Spec.
+type XPendingCommand struct {
+	Key      string
+	Group    string
+	Extended bool
+	Spec     store.XPendingSpec
+}
+
+func (c XPendingCommand) Execute(store *store.Store) protocol.Frame {
+	if !c.Extended {
+		summary, err := store.XPendingSummary(c.Key, c.Group)
+		if err != nil {
+			return noKeyOrGroupFrame(err, "")
+		}
+		return pendingSummaryFrame(summary)
+	}
+
+	entries, err := store.XPendingRange(c.Key, c.Group, c.Spec)
+	if err != nil {
+		return noKeyOrGroupFrame(err, "")
+	}
+	now := time.Now()
+	elems := make([]protocol.Frame, len(entries))
+	for i, entry := range entries {
+		elems[i] = protocol.Array{Elems: []protocol.Frame{
+			protocol.BulkString{Bytes: []byte(entry.ID.String())},
+			protocol.BulkString{Bytes: []byte(entry.Consumer)},
+			protocol.Integer{Value: int(now.Sub(entry.DeliveryTime).Milliseconds())},
+			protocol.Integer{Value: entry.DeliveryCount},
+		}}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+func pendingSummaryFrame(summary store.PendingSummary) protocol.Frame {
+	if summary.Count == 0 {
+		return protocol.Array{Elems: []protocol.Frame{
+			protocol.Integer{Value: 0},
+			protocol.BulkNullString{},
+			protocol.BulkNullString{},
+			protocol.Array{Null: true},
+		}}
+	}
+	consumers := make([]protocol.Frame, len(summary.Consumers))
+	for i, consumer := range summary.Consumers {
+		consumers[i] = bulkArray([]string{consumer.Consumer, strconv.Itoa(consumer.Count)})
+	}
+	return protocol.Array{Elems: []protocol.Frame{
+		protocol.Integer{Value: summary.Count},
+		protocol.BulkString{Bytes: []byte(summary.Min.String())},
+		protocol.BulkString{Bytes: []byte(summary.Max.String())},
+		protocol.Array{Elems: consumers},
+	}}
+}
+
+type XClaimCommand struct {
+	Key      string
+	Group    string
+	Consumer string
+	MinIdle  time.Duration
+	IDs      []store.StreamID
+	Options  store.XClaimOptions
+}
+
+func (c XClaimCommand) Execute(store *store.Store) protocol.Frame {
+	claimed, err := store.XClaim(c.Key, c.Group, c.Consumer, c.MinIdle, c.IDs, c.Options)
+	if err != nil {
+		return noKeyOrGroupFrame(err, "")
+	}
+	return claimedFrame(claimed, c.Options.JustID)
+}
+
+type XAutoClaimCommand struct {
+	Key      string
+	Group    string
+	Consumer string
+	MinIdle  time.Duration
+	Start    store.StreamID
+	Count    int
+	JustID   bool
+}
+
+func (c XAutoClaimCommand) Execute(store *store.Store) protocol.Frame {
+	next, claimed, deleted, err := store.XAutoClaim(c.Key, c.Group, c.Consumer, c.MinIdle, c.Start, c.Count, c.JustID)
+	if err != nil {
+		return noKeyOrGroupFrame(err, "")
+	}
+	deletedIDs := make([]string, len(deleted))
+	for i, id := range deleted {
+		deletedIDs[i] = id.String()
+	}
+	return protocol.Array{Elems: []protocol.Frame{
+		protocol.BulkString{Bytes: []byte(next.String())},
+		claimedFrame(claimed, c.JustID),
+		bulkArray(deletedIDs),
+	}}
+}
+
+// claimedFrame encodes the entries returned by XCLAIM and XAUTOCLAIM, or
+// only their IDs with JUSTID.
+func claimedFrame(claimed []store.StreamEntry, justID bool) protocol.Frame {
+	if !justID {
+		return streamEntriesArray(claimed)
+	}
+	ids := make([]string, len(claimed))
+	for i, entry := range claimed {
+		ids[i] = entry.ID.String()
+	}
+	return bulkArray(ids)
+}
+
+// XInfoStreamCommand implements XINFO STREAM. Count only applies with Full,
+// where 0 means no limit.
+type XInfoStreamCommand struct {
+	Key   string
+	Full  bool
+	Count int
+}
+
+func (c XInfoStreamCommand) Execute(store *store.Store) protocol.Frame {
+	info, err := store.XInfoStream(c.Key, c.Full, c.Count)
+	if err != nil {
+		return errorFrame(err)
+	}
+
+	fields := []protocol.Frame{
+		bulk("length"), protocol.Integer{Value: info.Length},
+		bulk("radix-tree-keys"), protocol.Integer{Value: info.RadixTreeKeys},
+		bulk("radix-tree-nodes"), protocol.Integer{Value: info.RadixTreeNodes},
+		bulk("last-generated-id"), bulk(info.LastGeneratedID.String()),
+		bulk("max-deleted-entry-id"), bulk(info.MaxDeletedEntryID.String()),
+		bulk("entries-added"), protocol.Integer{Value: int(info.EntriesAdded)},
+		bulk("recorded-first-entry-id"), bulk(info.RecordedFirstEntryID.String()),
+	}
+	if !c.Full {
+		return protocol.Array{Elems: append(fields,
+			bulk("groups"), protocol.Integer{Value: len(info.Groups)},
+			bulk("first-entry"), optionalEntryFrame(info.FirstEntry),
+			bulk("last-entry"), optionalEntryFrame(info.LastEntry),
+		)}
+	}
+
+	groups := make([]protocol.Frame, len(info.Groups))
+	for i, group := range info.Groups {
+		pel := make([]protocol.Frame, len(group.PEL))
+		for j, entry := range group.PEL {
+			pel[j] = protocol.Array{Elems: []protocol.Frame{
+				bulk(entry.ID.String()),
+				bulk(entry.Consumer),
+				protocol.Integer{Value: int(entry.DeliveryTime.UnixMilli())},
+				protocol.Integer{Value: entry.DeliveryCount},
+			}}
+		}
+		consumers := make([]protocol.Frame, len(group.ConsumerDetails))
+		for j, consumer := range group.ConsumerDetails {
+			consumerPEL := make([]protocol.Frame, len(consumer.PEL))
+			for k, entry := range consumer.PEL {
+				consumerPEL[k] = protocol.Array{Elems: []protocol.Frame{
+					bulk(entry.ID.String()),
+					protocol.Integer{Value: int(entry.DeliveryTime.UnixMilli())},
+					protocol.Integer{Value: entry.DeliveryCount},
+				}}
+			}
+			activeTime := -1
+			if !consumer.ActiveTime.IsZero() {
+				activeTime = int(consumer.ActiveTime.UnixMilli())
+			}
+			consumers[j] = protocol.Array{Elems: []protocol.Frame{
+				bulk("name"), bulk(consumer.Name),
+				bulk("seen-time"), protocol.Integer{Value: int(consumer.SeenTime.UnixMilli())},
+				bulk("active-time"), protocol.Integer{Value: activeTime},
+				bulk("pel-count"), protocol.Integer{Value: consumer.Pending},
+				bulk("pending"), protocol.Array{Elems: consumerPEL},
+			}}
+		}
+		groups[i] = protocol.Array{Elems: []protocol.Frame{
+			bulk("name"), bulk(group.Name),
+			bulk("last-delivered-id"), bulk(group.LastDeliveredID.String()),
+			bulk("entries-read"), entriesReadFrame(group.EntriesRead),
+			bulk("lag"), lagFrame(group),
+			bulk("pel-count"), protocol.Integer{Value: group.Pending},
+			bulk("pending"), protocol.Array{Elems: pel},
+			bulk("consumers"), protocol.Array{Elems: consumers},
+		}}
+	}
+	return protocol.Array{Elems: append(fields,
+		bulk("entries"), streamEntriesArray(info.Entries),
+		bulk("groups"), protocol.Array{Elems: groups},
+	)}
+}
+
+type XInfoGroupsCommand struct {
+	Key string
+}
+
+func (c XInfoGroupsCommand) Execute(store *store.Store) protocol.Frame {
+	groups, err := store.XInfoGroups(c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
+	elems := make([]protocol.Frame, len(groups))
+	for i, group := range groups {
+		elems[i] = protocol.Array{Elems: []protocol.Frame{
+			bulk("name"), bulk(group.Name),
+			bulk("consumers"), protocol.Integer{Value: group.Consumers},
+			bulk("pending"), protocol.Integer{Value: group.Pending},
+			bulk("last-delivered-id"), bulk(group.LastDeliveredID.String()),
+			bulk("entries-read"), entriesReadFrame(group.EntriesRead),
+			bulk("lag"), lagFrame(group),
+		}}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+type XInfoConsumersCommand struct {
+	Key   string
+	Group string
+}
+
+func (c XInfoConsumersCommand) Execute(store *store.Store) protocol.Frame {
+	consumers, err := store.XInfoConsumers(c.Key, c.Group)
+	if err != nil {
+		return errorFrame(err)
+	}
+	now := time.Now()
+	elems := make([]protocol.Frame, len(consumers))
+	for i, consumer := range consumers {
+		inactive := -1
+		if !consumer.ActiveTime.IsZero() {
+			inactive = int(now.Sub(consumer.ActiveTime).Milliseconds())
+		}
+		elems[i] = protocol.Array{Elems: []protocol.Frame{
+			bulk("name"), bulk(consumer.Name),
+			bulk("pending"), protocol.Integer{Value: consumer.Pending},
+			bulk("idle"), protocol.Integer{Value: int(now.Sub(consumer.SeenTime).Milliseconds())},
+			bulk("inactive"), protocol.Integer{Value: inactive},
+		}}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+func bulk(s string) protocol.BulkString {
+	return protocol.BulkString{Bytes: []byte(s)}
+}
+
+func optionalEntryFrame(entry *store.StreamEntry) protocol.Frame {
+	if entry == nil {
+		return protocol.BulkNullString{}
+	}
+	return streamEntryFrame(*entry)
+}
+
+func entriesReadFrame(entriesRead int64) protocol.Frame {
+	if entriesRead == store.InvalidEntriesRead {
+		return protocol.BulkNullString{}
+	}
+	return protocol.Integer{Value: int(entriesRead)}
+}
+
+func lagFrame(group store.GroupInfo) protocol.Frame {
+	if !group.LagValid {
+		return protocol.BulkNullString{}
+	}
+	return protocol.Integer{Value: int(group.Lag)}
+}
+
+// streamReadsArray encodes XREAD and XREADGROUP replies as [[key, entries], ...].
+func streamReadsArray(reads []store.StreamRead) protocol.Array {
+	elems := make([]protocol.Frame, len(reads))
+	for i, read := range reads {
+		elems[i] = protocol.Array{Elems: []protocol.Frame{
+			bulk(read.Key),
+			streamEntriesArray(read.Entries),
+		}}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+// noKeyOrGroupFrame reports a missing group the way XREADGROUP, XPENDING and
+// XCLAIM do, which do not tell a missing key apart from a missing group.
+func noKeyOrGroupFrame(err error, suffix string) protocol.Frame {
+	var noGroup store.NoGroupError
+	if errors.As(err, &noGroup) {
+		return protocol.Error{
+			Prefix:  "NOGROUP",
+			Message: fmt.Sprintf("No such key '%s' or consumer group '%s'%s", noGroup.Key, noGroup.Group, suffix),
+		}
+	}
+	return errorFrame(err)
+}
+
+// parseGroupStart parses the ID of XGROUP CREATE and SETID, where "$" is the
+// last ID of the stream.
+func parseGroupStart(arg string) (store.StreamID, bool, error) {
+	if arg == "$" {
+		return store.StreamID{}, true, nil
+	}
+	id, err := parseStreamID(arg, 0)
+	return id, false, err
+}
+
+// parseEntriesRead parses the optional trailing options of XGROUP CREATE and
+// SETID. MKSTREAM is only accepted when allowMkStream is set.
+func parseEntriesRead(args []string, allowMkStream bool) (int64, bool, error) {
+	entriesRead := int64(store.InvalidEntriesRead)
+	mkStream := false
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "MKSTREAM":
+			if !allowMkStream {
+				return 0, false, fmt.Errorf("syntax error")
+			}
+			mkStream = true
+		case "ENTRIESREAD":
+			if i+1 >= len(args) {
+				return 0, false, fmt.Errorf("syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return 0, false, fmt.Errorf("value is not an integer or out of range")
+			}
+			if n < 0 && n != store.InvalidEntriesRead {
+				return 0, false, fmt.Errorf("value for ENTRIESREAD must be positive or -1")
+			}
+			entriesRead = n
+			i++
+		default:
+			return 0, false, fmt.Errorf("syntax error")
+		}
+	}
+	return entriesRead, mkStream, nil
+}
+
+func parseXGroup(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("xgroup command requires a subcommand")
+	}
+	subcommand := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch subcommand {
+	case "CREATE":
+		if len(args) < 3 {
+			return nil, fmt.Errorf("xgroup create command requires at least 3 arguments")
+		}
+		id, useLastID, err := parseGroupStart(args[2])
+		if err != nil {
+			return nil, err
+		}
+		entriesRead, mkStream, err := parseEntriesRead(args[3:], true)
+		if err != nil {
+			return nil, err
+		}
+		return XGroupCreateCommand{Key: args[0], Group: args[1], ID: id, UseLastID: useLastID, MkStream: mkStream, EntriesRead: entriesRead}, nil
+	case "SETID":
+		if len(args) < 3 {
+			return nil, fmt.Errorf("xgroup setid command requires at least 3 arguments")
+		}
+		id, useLastID, err := parseGroupStart(args[2])
+		if err != nil {
+			return nil, err
+		}
+		entriesRead, _, err := parseEntriesRead(args[3:], false)
+		if err != nil {
+			return nil, err
+		}
+		return XGroupSetIDCommand{Key: args[0], Group: args[1], ID: id, UseLastID: useLastID, EntriesRead: entriesRead}, nil
+	case "DESTROY":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("xgroup destroy command requires 2 arguments")
+		}
+		return XGroupDestroyCommand{Key: args[0], Group: args[1]}, nil
+	case "CREATECONSUMER":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("xgroup createconsumer command requires 3 arguments")
+		}
+		return XGroupCreateConsumerCommand{Key: args[0], Group: args[1], Consumer: args[2]}, nil
+	case "DELCONSUMER":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("xgroup delconsumer command requires 3 arguments")
+		}
+		return XGroupDelConsumerCommand{Key: args[0], Group: args[1], Consumer: args[2]}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand '%s'. Try XGROUP HELP.", subcommand)
+	}
+}
+
+// parseXReadGroupStart parses an XREADGROUP ID: ">" or an ID of the consumer's history.
+func parseXReadGroupStart(arg string) (store.XReadStart, error) {
+	switch arg {
+	case ">":
+		return store.XReadStart{From: store.XReadNew}, nil
+	case "$":
+		return store.XReadStart{}, fmt.Errorf("The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+	}
+	id, err := parseStreamID(arg, 0)
+	if err != nil {
+		return store.XReadStart{}, err
+	}
+	return store.XReadStart{ID: id}, nil
+}
+
+func parseXReadGroup(args []string) (any, error) {
+	if len(args) < 3 || strings.ToUpper(args[0]) != "GROUP" {
+		return nil, fmt.Errorf("Missing GROUP option for XREADGROUP")
+	}
+	cmd, noAck, err := parseXReadArgs("xreadgroup", args[3:], parseXReadGroupStart)
+	if err != nil {
+		return nil, err
+	}
+	return XReadGroupCommand{XReadCommand: cmd, Group: args[1], Consumer: args[2], NoAck: noAck}, nil
+}
+
+func parseStreamIDs(args []string) ([]store.StreamID, error) {
+	ids := make([]store.StreamID, len(args))
+	for i, arg := range args {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func parseXAck(args []string) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("xack command requires at least 3 arguments")
+	}
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	return XAckCommand{Key: args[0], Group: args[1], IDs: ids}, nil
+}
+
+func parseXPending(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("xpending command requires at least 2 arguments")
+	}
+	cmd := XPendingCommand{Key: args[0], Group: args[1]}
+	rest := args[2:]
+	if len(rest) == 0 {
+		return cmd, nil
+	}
+
+	if strings.ToUpper(rest[0]) == "IDLE" && len(rest) >= 2 {
+		idle, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		cmd.Spec.MinIdle = time.Duration(max(idle, 0)) * time.Millisecond
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return nil, fmt.Errorf("syntax error")
+	}
+
+	start, err := parseRangeStart(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseRangeEnd(rest[1])
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	cmd.Extended = true
+	cmd.Spec.Start, cmd.Spec.End, cmd.Spec.Count = start, end, max(count, 0)
+	if len(rest) == 4 {
+		cmd.Spec.Consumer = rest[3]
+	}
+	return cmd, nil
+}
+
+// parseMinIdle parses the min-idle-time of XCLAIM and XAUTOCLAIM in milliseconds.
+func parseMinIdle(name string, arg string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid min-idle-time argument for %s", name)
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond, nil
+}
+
+func parseXClaim(args []string) (any, error) {
+	if len(args) < 5 {
+		return nil, fmt.Errorf("xclaim command requires at least 5 arguments")
+	}
+	minIdle, err := parseMinIdle("XCLAIM", args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := XClaimCommand{Key: args[0], Group: args[1], Consumer: args[2], MinIdle: minIdle, Options: store.XClaimOptions{RetryCount: -1}}
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			break
+		}
+		cmd.IDs = append(cmd.IDs, id)
+	}
+	if len(cmd.IDs) == 0 {
+		return nil, errInvalidStreamID
+	}
+
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch option {
+		case "FORCE":
+			cmd.Options.Force = true
+			continue
+		case "JUSTID":
+			cmd.Options.JustID = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("Unrecognized XCLAIM option '%s'", args[i])
+		}
+		value := args[i+1]
+		i++
+
+		switch option {
+		case "IDLE":
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid IDLE option argument for XCLAIM")
+			}
+			cmd.Options.DeliveryTime = time.Now().Add(-time.Duration(ms) * time.Millisecond)
+		case "TIME":
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid TIME option argument for XCLAIM")
+			}
+			cmd.Options.DeliveryTime = time.UnixMilli(ms)
+		case "RETRYCOUNT":
+			retryCount, err := strconv.Atoi(value)
+			if err != nil || retryCount < 0 {
+				return nil, fmt.Errorf("Invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			cmd.Options.RetryCount = retryCount
+		case "LASTID":
+			lastID, err := parseStreamID(value, 0)
+			if err != nil {
+				return nil, err
+			}
+			cmd.Options.LastID = &lastID
+		default:
+			return nil, fmt.Errorf("Unrecognized XCLAIM option '%s'", args[i-1])
+		}
+	}
+	return cmd, nil
+}
+
+func parseXAutoClaim(args []string) (any, error) {
+	if len(args) < 5 {
+		return nil, fmt.Errorf("xautoclaim command requires at least 5 arguments")
+	}
+	minIdle, err := parseMinIdle("XAUTOCLAIM", args[3])
+	if err != nil {
+		return nil, err
+	}
+	start, err := parseRangeStart(args[4])
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := XAutoClaimCommand{Key: args[0], Group: args[1], Consumer: args[2], MinIdle: minIdle, Start: start, Count: 100}
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "JUSTID":
+			cmd.JustID = true
+		case "COUNT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("syntax error")
+			}
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count < 1 || count > math.MaxInt/10 {
+				return nil, fmt.Errorf("COUNT must be > 0")
+			}
+			cmd.Count = count
+			i++
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+	return cmd, nil
+}
+
+func parseXInfo(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("xinfo command requires a subcommand")
+	}
+	subcommand := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch subcommand {
+	case "STREAM":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("xinfo stream command requires a key")
+		}
+		cmd := XInfoStreamCommand{Key: args[0], Count: 10}
+		rest := args[1:]
+		if len(rest) == 0 {
+			return cmd, nil
+		}
+		if strings.ToUpper(rest[0]) != "FULL" {
+			return nil, fmt.Errorf("syntax error")
+		}
+		cmd.Full = true
+		rest = rest[1:]
+		if len(rest) == 0 {
+			return cmd, nil
+		}
+		if len(rest) != 2 || strings.ToUpper(rest[0]) != "COUNT" {
+			return nil, fmt.Errorf("syntax error")
+		}
+		count, err := strconv.Atoi(rest[1])
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		cmd.Count = max(count, 0)
+		return cmd, nil
+	case "GROUPS":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("xinfo groups command requires 1 argument")
+		}
+		return XInfoGroupsCommand{Key: args[0]}, nil
+	case "CONSUMERS":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("xinfo consumers command requires 2 arguments")
+		}
+		return XInfoConsumersCommand{Key: args[0], Group: args[1]}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand '%s'. Try XINFO HELP.", subcommand)
+	}
+}
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayStreamGroup(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "xgroup create",
+			in:   request("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM", "ENTRIESREAD", "3"),
+			want: XGroupCreateCommand{Key: "s", Group: "g", UseLastID: true, MkStream: true, EntriesRead: 3},
+		},
+		{name: "xgroup setid mkstream", in: request("XGROUP", "SETID", "s", "g", "0", "MKSTREAM"), wantErr: true},
+		{name: "xgroup bad entriesread", in: request("XGROUP", "CREATE", "s", "g", "0", "ENTRIESREAD", "-2"), wantErr: true},
+		{name: "xgroup unknown", in: request("XGROUP", "NOPE"), wantErr: true},
+		{
+			name: "xreadgroup",
+			in:   request("XREADGROUP", "GROUP", "g", "c", "COUNT", "1", "NOACK", "STREAMS", "a", "b", ">", "0"),
+			want: XReadGroupCommand{
+				XReadCommand: XReadCommand{Keys: []string{"a", "b"}, Starts: []store.XReadStart{{From: store.XReadNew}, {}}, Count: 1},
+				Group:        "g",
+				Consumer:     "c",
+				NoAck:        true,
+			},
+		},
+		{name: "xreadgroup dollar", in: request("XREADGROUP", "GROUP", "g", "c", "STREAMS", "a", "$"), wantErr: true},
+		{name: "xread noack", in: request("XREAD", "NOACK", "STREAMS", "a", "0"), wantErr: true},
+		{
+			name: "xpending extended",
+			in:   request("XPENDING", "s", "g", "IDLE", "50", "(1-0", "+", "5", "c"),
+			want: XPendingCommand{Key: "s", Group: "g", Extended: true, Spec: store.XPendingSpec{
+				Start: store.StreamID{Ms: 1, Seq: 1}, End: store.MaxStreamID, Count: 5, Consumer: "c", MinIdle: 50 * time.Millisecond,
+			}},
+		},
+		{name: "xpending idle without range", in: request("XPENDING", "s", "g", "IDLE", "50"), wantErr: true},
+		{
+			name: "xclaim options",
+			in:   request("XCLAIM", "s", "g", "c", "10", "1-1", "2", "RETRYCOUNT", "3", "FORCE", "JUSTID", "LASTID", "5-5"),
+			want: XClaimCommand{
+				Key: "s", Group: "g", Consumer: "c", MinIdle: 10 * time.Millisecond,
+				IDs:     []store.StreamID{{Ms: 1, Seq: 1}, {Ms: 2}},
+				Options: store.XClaimOptions{RetryCount: 3, Force: true, JustID: true, LastID: &store.StreamID{Ms: 5, Seq: 5}},
+			},
+		},
+		{name: "xclaim bad option", in: request("XCLAIM", "s", "g", "c", "10", "1-1", "BOGUS"), wantErr: true},
+		{
+			name: "xautoclaim",
+			in:   request("XAUTOCLAIM", "s", "g", "c", "0", "-", "COUNT", "7", "JUSTID"),
+			want: XAutoClaimCommand{Key: "s", Group: "g", Consumer: "c", Count: 7, JustID: true},
+		},
+		{name: "xautoclaim zero count", in: request("XAUTOCLAIM", "s", "g", "c", "0", "-", "COUNT", "0"), wantErr: true},
+		{name: "xinfo stream full", in: request("XINFO", "STREAM", "s", "FULL", "COUNT", "0"), want: XInfoStreamCommand{Key: "s", Full: true}},
+		{name: "xinfo consumers", in: request("XINFO", "CONSUMERS", "s", "g"), want: XInfoConsumersCommand{Key: "s", Group: "g"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestXReadGroupBlocksUntilXAdd(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	XGroupCreateCommand{Key: "s", Group: "g", UseLastID: true, MkStream: true, EntriesRead: store.InvalidEntriesRead}.Execute(s)
+
+	block := 5 * time.Second
+	cmd := XReadGroupCommand{
+		XReadCommand: XReadCommand{Keys: []string{"s"}, Starts: []store.XReadStart{{From: store.XReadNew}}, Count: -1, Block: &block},
+		Group:        "g",
+		Consumer:     "c",
+	}
+	done := make(chan protocol.Frame)
+	go func() { done <- cmd.ExecuteBlocking(s, attemptNow) }()
+
+	time.Sleep(20 * time.Millisecond)
+	XAddCommand{Key: "s", ID: store.StreamIDSpec{ID: store.StreamID{Ms: 1}}, Fields: []string{"f", "v"}}.Execute(s)
+
+	select {
+	case got := <-done:
+		want := streamReadsArray([]store.StreamRead{{Key: "s", Entries: []store.StreamEntry{{ID: store.StreamID{Ms: 1}, Fields: []string{"f", "v"}}}}})
+		assert.Equal(t, want, got)
+	case <-time.After(time.Second):
+		t.Fatal("XREADGROUP did not wake up")
+	}
+}
+
+func TestXReadGroupWokenByDestroy(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	XGroupCreateCommand{Key: "s", Group: "g", UseLastID: true, MkStream: true, EntriesRead: store.InvalidEntriesRead}.Execute(s)
+
+	cmd := XReadGroupCommand{
+		XReadCommand: XReadCommand{Keys: []string{"s"}, Starts: []store.XReadStart{{From: store.XReadNew}}, Count: -1, Block: new(time.Duration)},
+		Group:        "g",
+		Consumer:     "c",
+	}
+	done := make(chan protocol.Frame)
+	go func() { done <- cmd.ExecuteBlocking(s, attemptNow) }()
+
+	time.Sleep(20 * time.Millisecond)
+	XGroupDestroyCommand{Key: "s", Group: "g"}.Execute(s)
+
+	select {
+	case got := <-done:
+		assert.Equal(t, protocol.Error{Prefix: "NOGROUP", Message: "No such key 's' or consumer group 'g' in XREADGROUP with GROUP option"}, got)
+	case <-time.After(time.Second):
+		t.Fatal("XREADGROUP did not wake up")
+	}
+}
@@ -0,0 +1,458 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// ZAddCommand implements ZADD. With Flags.Incr it holds exactly one member
+// whose score is the increment.
+type ZAddCommand struct {
+	Key          string
+	Flags        store.ZAddFlags
+	CountChanged bool
+	Members      []store.ScoredMember
+}
+
+func (c ZAddCommand) Execute(store *store.Store) protocol.Frame {
+	if c.Flags.Incr {
+		if len(c.Members) != 1 {
+			panic("ZADD INCR requires exactly one member")
+		}
+		score, ok, err := store.ZAddIncr(c.Key, c.Flags, c.Members[0].Member, c.Members[0].Score)
+		if err != nil {
+			return errorFrame(err)
+		}
+		if !ok {
+			return protocol.BulkNullString{}
+		}
+		return scoreFrame(score)
+	}
+
+	changed, err := store.ZAdd(c.Key, c.Flags, c.CountChanged, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: changed}
+}
+
+type ZIncrByCommand struct {
+	Key       string
+	Increment float64
+	Member    string
+}
+
+func (c ZIncrByCommand) Execute(s *store.Store) protocol.Frame {
+	score, _, err := s.ZAddIncr(c.Key, store.ZAddFlags{}, c.Member, c.Increment)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return scoreFrame(score)
+}
+
+type ZRemCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c ZRemCommand) Execute(store *store.Store) protocol.Frame {
+	removed, err := store.ZRem(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: removed}
+}
+
+type ZScoreCommand struct {
+	Key    string
+	Member string
+}
+
+func (c ZScoreCommand) Execute(store *store.Store) protocol.Frame {
+	score, ok, err := store.ZScore(c.Key, c.Member)
+	if err != nil {
+		return errorFrame(err)
+	}
+	if !ok {
+		return protocol.BulkNullString{}
+	}
+	return scoreFrame(score)
+}
+
+type ZMScoreCommand struct {
+	Key     string
+	Members []string
+}
+
+func (c ZMScoreCommand) Execute(store *store.Store) protocol.Frame {
+	scores, found, err := store.ZMScore(c.Key, c.Members)
+	if err != nil {
+		return errorFrame(err)
+	}
+	elems := make([]protocol.Frame, len(scores))
+	for i, score := range scores {
+		if !found[i] {
+			elems[i] = protocol.BulkNullString{}
+			continue
+		}
+		elems[i] = scoreFrame(score)
+	}
+	return protocol.Array{Elems: elems}
+}
+
+type ZCardCommand struct {
+	Key string
+}
+
+func (c ZCardCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.ZCard(c.Key)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+type ZCountCommand struct {
+	Key   string
+	Range store.ScoreRange
+}
+
+func (c ZCountCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.ZCount(c.Key, c.Range)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+// ZRankCommand implements ZRANK and ZREVRANK.
+type ZRankCommand struct {
+	Key       string
+	Member    string
+	Rev       bool
+	WithScore bool
+}
+
+func (c ZRankCommand) Execute(store *store.Store) protocol.Frame {
+	rank, score, ok, err := store.ZRank(c.Key, c.Member, c.Rev)
+	if err != nil {
+		return errorFrame(err)
+	}
+	if !ok {
+		if c.WithScore {
+			return protocol.Array{Null: true}
+		}
+		return protocol.BulkNullString{}
+	}
+	if c.WithScore {
+		return protocol.Array{Elems: []protocol.Frame{protocol.Integer{Value: rank}, scoreFrame(score)}}
+	}
+	return protocol.Integer{Value: rank}
+}
+
+type ZRangeCommand struct {
+	Key        string
+	Spec       store.ZRangeSpec
+	WithScores bool
+}
+
+func (c ZRangeCommand) Execute(store *store.Store) protocol.Frame {
+	members, err := store.ZRange(c.Key, c.Spec)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return scoredMembersArray(members, c.WithScores)
+}
+
+// scoredMembersArray encodes members as a flat array, interleaving scores when withScores is set.
+func scoredMembersArray(members []store.ScoredMember, withScores bool) protocol.Array {
+	size := len(members)
+	if withScores {
+		size *= 2
+	}
+	elems := make([]protocol.Frame, 0, size)
+	for _, m := range members {
+		elems = append(elems, protocol.BulkString{Bytes: []byte(m.Member)})
+		if withScores {
+			elems = append(elems, scoreFrame(m.Score))
+		}
+	}
+	return protocol.Array{Elems: elems}
+}
+
+func scoreFrame(score float64) protocol.BulkString {
+	return protocol.BulkString{Bytes: []byte(formatScore(score))}
+}
+
+// formatScore renders a score the way Redis replies with doubles.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+// parseScore parses a score, accepting inf, +inf and -inf but rejecting NaN.
+func parseScore(arg string) (float64, error) {
+	score, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, fmt.Errorf("value is not a valid float")
+	}
+	return score, nil
+}
+
+// parseScoreBound parses a ZRANGE/ZCOUNT score bound where a leading "(" makes it exclusive.
+func parseScoreBound(arg string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	score, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false, fmt.Errorf("min or max is not a float")
+	}
+	return score, exclusive, nil
+}
+
+func parseScoreRange(minArg string, maxArg string) (store.ScoreRange, error) {
+	lower, lowerExclusive, err := parseScoreBound(minArg)
+	if err != nil {
+		return store.ScoreRange{}, err
+	}
+	upper, upperExclusive, err := parseScoreBound(maxArg)
+	if err != nil {
+		return store.ScoreRange{}, err
+	}
+	return store.ScoreRange{Min: lower, Max: upper, MinExclusive: lowerExclusive, MaxExclusive: upperExclusive}, nil
+}
+
+// parseLexBound parses "-", "+", "[value" or "(value".
+func parseLexBound(arg string) (store.LexBound, error) {
+	switch {
+	case arg == "-":
+		return store.LexBound{Infinity: -1}, nil
+	case arg == "+":
+		return store.LexBound{Infinity: 1}, nil
+	case strings.HasPrefix(arg, "["):
+		return store.LexBound{Value: arg[1:]}, nil
+	case strings.HasPrefix(arg, "("):
+		return store.LexBound{Value: arg[1:], Exclusive: true}, nil
+	default:
+		return store.LexBound{}, fmt.Errorf("min or max not valid string range item")
+	}
+}
+
+func parseLexRange(minArg string, maxArg string) (store.LexRange, error) {
+	lower, err := parseLexBound(minArg)
+	if err != nil {
+		return store.LexRange{}, err
+	}
+	upper, err := parseLexBound(maxArg)
+	if err != nil {
+		return store.LexRange{}, err
+	}
+	return store.LexRange{Min: lower, Max: upper}, nil
+}
+
+func parseZAdd(args []string) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("zadd command requires at least 3 arguments")
+	}
+
+	cmd := ZAddCommand{Key: args[0]}
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			cmd.Flags.NX = true
+		case "XX":
+			cmd.Flags.XX = true
+		case "GT":
+			cmd.Flags.GT = true
+		case "LT":
+			cmd.Flags.LT = true
+		case "CH":
+			cmd.CountChanged = true
+		case "INCR":
+			cmd.Flags.Incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("syntax error")
+	}
+	if cmd.Flags.NX && cmd.Flags.XX {
+		return nil, fmt.Errorf("XX and NX options at the same time are not compatible")
+	}
+	if (cmd.Flags.GT && cmd.Flags.LT) || (cmd.Flags.NX && (cmd.Flags.GT || cmd.Flags.LT)) {
+		return nil, fmt.Errorf("GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if cmd.Flags.Incr && len(pairs) != 2 {
+		return nil, fmt.Errorf("INCR option supports a single increment-element pair")
+	}
+
+	cmd.Members = make([]store.ScoredMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseScore(pairs[j])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Members = append(cmd.Members, store.ScoredMember{Member: pairs[j+1], Score: score})
+	}
+	return cmd, nil
+}
+
+func parseZIncrBy(args []string) (any, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("zincrby command requires 3 arguments")
+	}
+	increment, err := parseScore(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return ZIncrByCommand{Key: args[0], Increment: increment, Member: args[2]}, nil
+}
+
+func parseZRem(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("zrem command requires at least 2 arguments")
+	}
+	return ZRemCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseZScore(args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("zscore command requires 2 arguments")
+	}
+	return ZScoreCommand{Key: args[0], Member: args[1]}, nil
+}
+
+func parseZMScore(args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("zmscore command requires at least 2 arguments")
+	}
+	return ZMScoreCommand{Key: args[0], Members: args[1:]}, nil
+}
+
+func parseZCard(args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("zcard command requires 1 argument")
+	}
+	return ZCardCommand{Key: args[0]}, nil
+}
+
+func parseZCount(args []string) (any, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("zcount command requires 3 arguments")
+	}
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	return ZCountCommand{Key: args[0], Range: r}, nil
+}
+
+func parseZRank(rev bool) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		switch {
+		case len(args) == 2:
+			return ZRankCommand{Key: args[0], Member: args[1], Rev: rev}, nil
+		case len(args) == 3 && strings.ToUpper(args[2]) == "WITHSCORE":
+			return ZRankCommand{Key: args[0], Member: args[1], Rev: rev, WithScore: true}, nil
+		case len(args) == 3:
+			return nil, fmt.Errorf("syntax error")
+		default:
+			return nil, fmt.Errorf("zrank command requires 2 or 3 arguments")
+		}
+	}
+}
+
+func parseZRange(args []string) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("zrange command requires at least 3 arguments")
+	}
+
+	cmd := ZRangeCommand{Key: args[0], Spec: store.ZRangeSpec{Count: -1}}
+	hasLimit := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BYSCORE":
+			cmd.Spec.By = store.ZRangeByScore
+		case "BYLEX":
+			cmd.Spec.By = store.ZRangeByLex
+		case "REV":
+			cmd.Spec.Rev = true
+		case "WITHSCORES":
+			cmd.WithScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return nil, fmt.Errorf("syntax error")
+			}
+			offset, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			count, err := strconv.Atoi(args[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			cmd.Spec.Offset, cmd.Spec.Count = offset, count
+			hasLimit = true
+			i += 2
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+
+	if hasLimit && cmd.Spec.By == store.ZRangeByRank {
+		return nil, fmt.Errorf("syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if cmd.WithScores && cmd.Spec.By == store.ZRangeByLex {
+		return nil, fmt.Errorf("syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// With REV the first bound is the upper end of score and lex ranges.
+	minArg, maxArg := args[1], args[2]
+	if cmd.Spec.Rev {
+		minArg, maxArg = maxArg, minArg
+	}
+
+	switch cmd.Spec.By {
+	case store.ZRangeByRank:
+		start, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		stop, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+		cmd.Spec.Start, cmd.Spec.Stop = start, stop
+	case store.ZRangeByScore:
+		r, err := parseScoreRange(minArg, maxArg)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Spec.Score = r
+	case store.ZRangeByLex:
+		r, err := parseLexRange(minArg, maxArg)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Spec.Lex = r
+	}
+	return cmd, nil
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"math"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayZSet(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "zadd",
+			in:   request("ZADD", "z", "1", "a", "2.5", "b"),
+			want: ZAddCommand{Key: "z", Members: []store.ScoredMember{{Member: "a", Score: 1}, {Member: "b", Score: 2.5}}},
+		},
+		{
+			name: "zadd options",
+			in:   request("ZADD", "z", "xx", "GT", "CH", "+inf", "a"),
+			want: ZAddCommand{Key: "z", Flags: store.ZAddFlags{XX: true, GT: true}, CountChanged: true, Members: []store.ScoredMember{{Member: "a", Score: math.Inf(1)}}},
+		},
+		{name: "zadd nx xx", in: request("ZADD", "z", "NX", "XX", "1", "a"), wantErr: true},
+		{name: "zadd nx gt", in: request("ZADD", "z", "NX", "GT", "1", "a"), wantErr: true},
+		{name: "zadd incr pairs", in: request("ZADD", "z", "INCR", "1", "a", "2", "b"), wantErr: true},
+		{name: "zadd nan", in: request("ZADD", "z", "nan", "a"), wantErr: true},
+		{name: "zadd odd pairs", in: request("ZADD", "z", "1", "a", "2"), wantErr: true},
+		{
+			name: "zcount exclusive",
+			in:   request("ZCOUNT", "z", "(1", "+inf"),
+			want: ZCountCommand{Key: "z", Range: store.ScoreRange{Min: 1, Max: math.Inf(1), MinExclusive: true}},
+		},
+		{name: "zcount bad bound", in: request("ZCOUNT", "z", "x", "1"), wantErr: true},
+		{name: "zrevrank withscore", in: request("ZREVRANK", "z", "a", "WITHSCORE"), want: ZRankCommand{Key: "z", Member: "a", Rev: true, WithScore: true}},
+		{
+			name: "zrange by rank",
+			in:   request("ZRANGE", "z", "0", "-1", "WITHSCORES"),
+			want: ZRangeCommand{Key: "z", Spec: store.ZRangeSpec{Start: 0, Stop: -1, Count: -1}, WithScores: true},
+		},
+		{
+			name: "zrange byscore rev limit",
+			in:   request("ZRANGE", "z", "(5", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"),
+			want: ZRangeCommand{Key: "z", Spec: store.ZRangeSpec{By: store.ZRangeByScore, Rev: true, Score: store.ScoreRange{Min: math.Inf(-1), Max: 5, MaxExclusive: true}, Offset: 1, Count: 2}},
+		},
+		{
+			name: "zrange bylex",
+			in:   request("ZRANGE", "z", "[a", "+", "BYLEX"),
+			want: ZRangeCommand{Key: "z", Spec: store.ZRangeSpec{By: store.ZRangeByLex, Lex: store.LexRange{Min: store.LexBound{Value: "a"}, Max: store.LexBound{Infinity: 1}}, Count: -1}},
+		},
+		{name: "zrange bylex withscores", in: request("ZRANGE", "z", "-", "+", "BYLEX", "WITHSCORES"), wantErr: true},
+		{name: "zrange limit by rank", in: request("ZRANGE", "z", "0", "1", "LIMIT", "0", "1"), wantErr: true},
+		{name: "zrange bad lex", in: request("ZRANGE", "z", "a", "+", "BYLEX"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestZRangeCommandWithScores(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	ZAddCommand{Key: "z", Members: []store.ScoredMember{{Member: "a", Score: 1.5}, {Member: "b", Score: math.Inf(1)}}}.Execute(s)
+	got := ZRangeCommand{Key: "z", Spec: store.ZRangeSpec{Start: 0, Stop: -1, Count: -1}, WithScores: true}.Execute(s)
+	assert.Equal(t, bulkArray([]string{"a", "1.5", "b", "inf"}), got)
+}
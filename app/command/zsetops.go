@@ -0,0 +1,405 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// ZCombineCommand implements ZUNION, ZINTER and ZDIFF.
+type ZCombineCommand struct {
+	Spec       store.ZCombineSpec
+	WithScores bool
+}
+
+func (c ZCombineCommand) Execute(store *store.Store) protocol.Frame {
+	members, err := store.ZCombine(c.Spec)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return scoredMembersArray(members, c.WithScores)
+}
+
+// ZCombineStoreCommand implements ZUNIONSTORE, ZINTERSTORE and ZDIFFSTORE.
+type ZCombineStoreCommand struct {
+	Destination string
+	Spec        store.ZCombineSpec
+}
+
+func (c ZCombineStoreCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.ZCombineStore(c.Destination, c.Spec)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+// ZPopCommand implements ZPOPMIN and ZPOPMAX.
+type ZPopCommand struct {
+	Key   string
+	Max   bool
+	Count int
+}
+
+func (c ZPopCommand) Execute(store *store.Store) protocol.Frame {
+	_, members, err := store.ZPop([]string{c.Key}, c.Max, c.Count)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return scoredMembersArray(members, true)
+}
+
+// BZPopCommand implements BZPOPMIN and BZPOPMAX. A zero Timeout waits forever.
+type BZPopCommand struct {
+	Keys    []string
+	Max     bool
+	Timeout time.Duration
+}
+
+func (c BZPopCommand) Execute(store *store.Store) protocol.Frame {
+	res, ok := c.try(store)
+	if !ok {
+		return protocol.Array{Null: true}
+	}
+	return res
+}
+
+func (c BZPopCommand) ExecuteBlocking(store *store.Store, attempt Attempt) protocol.Frame {
+	return waitForKeys(store, c.Keys, c.Timeout, attempt, func() (protocol.Frame, bool) { return c.try(store) })
+}
+
+func (c BZPopCommand) try(store *store.Store) (protocol.Frame, bool) {
+	key, members, err := store.ZPop(c.Keys, c.Max, 1)
+	if err != nil {
+		return errorFrame(err), true
+	}
+	if len(members) == 0 {
+		return nil, false
+	}
+	return protocol.Array{Elems: []protocol.Frame{
+		protocol.BulkString{Bytes: []byte(key)},
+		protocol.BulkString{Bytes: []byte(members[0].Member)},
+		scoreFrame(members[0].Score),
+	}}, true
+}
+
+// ZMPopCommand implements ZMPOP.
+type ZMPopCommand struct {
+	Keys  []string
+	Max   bool
+	Count int
+}
+
+func (c ZMPopCommand) Execute(store *store.Store) protocol.Frame {
+	res, ok := c.try(store)
+	if !ok {
+		return protocol.Array{Null: true}
+	}
+	return res
+}
+
+func (c ZMPopCommand) try(store *store.Store) (protocol.Frame, bool) {
+	key, members, err := store.ZPop(c.Keys, c.Max, c.Count)
+	if err != nil {
+		return errorFrame(err), true
+	}
+	if len(members) == 0 {
+		return nil, false
+	}
+
+	pairs := make([]protocol.Frame, len(members))
+	for i, m := range members {
+		pairs[i] = protocol.Array{Elems: []protocol.Frame{protocol.BulkString{Bytes: []byte(m.Member)}, scoreFrame(m.Score)}}
+	}
+	return protocol.Array{Elems: []protocol.Frame{protocol.BulkString{Bytes: []byte(key)}, protocol.Array{Elems: pairs}}}, true
+}
+
+// BZMPopCommand implements BZMPOP. A zero Timeout waits forever.
+type BZMPopCommand struct {
+	ZMPopCommand
+	Timeout time.Duration
+}
+
+func (c BZMPopCommand) ExecuteBlocking(store *store.Store, attempt Attempt) protocol.Frame {
+	return waitForKeys(store, c.Keys, c.Timeout, attempt, func() (protocol.Frame, bool) { return c.try(store) })
+}
+
+type ZRangeStoreCommand struct {
+	Destination string
+	Source      string
+	Spec        store.ZRangeSpec
+}
+
+func (c ZRangeStoreCommand) Execute(store *store.Store) protocol.Frame {
+	count, err := store.ZRangeStore(c.Destination, c.Source, c.Spec)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: count}
+}
+
+// ZRemRangeCommand implements ZREMRANGEBYRANK, ZREMRANGEBYSCORE and ZREMRANGEBYLEX.
+type ZRemRangeCommand struct {
+	Key  string
+	Spec store.ZRangeSpec
+}
+
+func (c ZRemRangeCommand) Execute(store *store.Store) protocol.Frame {
+	removed, err := store.ZRemRange(c.Key, c.Spec)
+	if err != nil {
+		return errorFrame(err)
+	}
+	return protocol.Integer{Value: removed}
+}
+
+// waitForKeys calls try through attempt until it reports a reply, sleeping
+// until one of keys is written in between. It replies with a null array once
+// timeout elapses; a zero timeout waits forever.
+func waitForKeys(s *store.Store, keys []string, timeout time.Duration, attempt Attempt, try func() (protocol.Frame, bool)) protocol.Frame {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		wake, cancel := s.Watch(keys)
+		res, ok := attempt(try)
+		if ok {
+			cancel()
+			return res
+		}
+
+		select {
+		case <-wake:
+			cancel()
+		case <-deadline:
+			cancel()
+			return protocol.Array{Null: true}
+		}
+	}
+}
+
+// parseTimeout parses a blocking timeout given in (possibly fractional) seconds.
+func parseTimeout(arg string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(arg, 64)
+	if err != nil || seconds > float64(time.Duration(1<<62)/time.Second) {
+		return 0, fmt.Errorf("timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, fmt.Errorf("timeout is negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// parseZCombine parses the keys and options shared by ZUNION, ZINTER and ZDIFF.
+func parseZCombine(name string, op store.SetOp, args []string, allowWithScores bool) (store.ZCombineSpec, bool, error) {
+	keys, rest, err := parseNumKeys(name, args)
+	if err != nil {
+		return store.ZCombineSpec{}, false, err
+	}
+
+	spec := store.ZCombineSpec{Op: op, Keys: keys}
+	withScores := false
+	for i := 0; i < len(rest); i++ {
+		switch option := strings.ToUpper(rest[i]); {
+		case option == "WEIGHTS" && op != store.SetOpDiff:
+			if i+len(keys) >= len(rest) {
+				return store.ZCombineSpec{}, false, fmt.Errorf("syntax error")
+			}
+			spec.Weights = make([]float64, len(keys))
+			for j := range keys {
+				weight, err := strconv.ParseFloat(rest[i+1+j], 64)
+				if err != nil {
+					return store.ZCombineSpec{}, false, fmt.Errorf("weight value is not a float")
+				}
+				spec.Weights[j] = weight
+			}
+			i += len(keys)
+		case option == "AGGREGATE" && op != store.SetOpDiff:
+			if i+1 >= len(rest) {
+				return store.ZCombineSpec{}, false, fmt.Errorf("syntax error")
+			}
+			switch strings.ToUpper(rest[i+1]) {
+			case "SUM":
+				spec.Aggregate = store.ZAggregateSum
+			case "MIN":
+				spec.Aggregate = store.ZAggregateMin
+			case "MAX":
+				spec.Aggregate = store.ZAggregateMax
+			default:
+				return store.ZCombineSpec{}, false, fmt.Errorf("syntax error")
+			}
+			i++
+		case option == "WITHSCORES" && allowWithScores:
+			withScores = true
+		default:
+			return store.ZCombineSpec{}, false, fmt.Errorf("syntax error")
+		}
+	}
+	return spec, withScores, nil
+}
+
+func parseZCombineCommand(name string, op store.SetOp) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		spec, withScores, err := parseZCombine(name, op, args, true)
+		if err != nil {
+			return nil, err
+		}
+		return ZCombineCommand{Spec: spec, WithScores: withScores}, nil
+	}
+}
+
+func parseZCombineStoreCommand(name string, op store.SetOp) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("%s command requires at least 3 arguments", name)
+		}
+		spec, _, err := parseZCombine(name, op, args[1:], false)
+		if err != nil {
+			return nil, err
+		}
+		return ZCombineStoreCommand{Destination: args[0], Spec: spec}, nil
+	}
+}
+
+func parseZPop(highest bool) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		switch len(args) {
+		case 1:
+			return ZPopCommand{Key: args[0], Max: highest, Count: 1}, nil
+		case 2:
+			count, err := strconv.Atoi(args[1])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("value is out of range, must be positive")
+			}
+			return ZPopCommand{Key: args[0], Max: highest, Count: count}, nil
+		default:
+			return nil, fmt.Errorf("zpop command requires 1 or 2 arguments")
+		}
+	}
+}
+
+func parseBZPop(highest bool) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("bzpop command requires at least 2 arguments")
+		}
+		timeout, err := parseTimeout(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		return BZPopCommand{Keys: args[:len(args)-1], Max: highest, Timeout: timeout}, nil
+	}
+}
+
+func parseZMPopArgs(args []string) (ZMPopCommand, error) {
+	keys, rest, err := parseNumKeys("zmpop", args)
+	if err != nil {
+		return ZMPopCommand{}, err
+	}
+	if len(rest) == 0 {
+		return ZMPopCommand{}, fmt.Errorf("syntax error")
+	}
+
+	cmd := ZMPopCommand{Keys: keys, Count: 1}
+	switch strings.ToUpper(rest[0]) {
+	case "MIN":
+	case "MAX":
+		cmd.Max = true
+	default:
+		return ZMPopCommand{}, fmt.Errorf("syntax error")
+	}
+
+	switch len(rest) {
+	case 1:
+	case 3:
+		if strings.ToUpper(rest[1]) != "COUNT" {
+			return ZMPopCommand{}, fmt.Errorf("syntax error")
+		}
+		count, err := strconv.Atoi(rest[2])
+		if err != nil || count <= 0 {
+			return ZMPopCommand{}, fmt.Errorf("count should be greater than 0")
+		}
+		cmd.Count = count
+	default:
+		return ZMPopCommand{}, fmt.Errorf("syntax error")
+	}
+	return cmd, nil
+}
+
+func parseZMPop(args []string) (any, error) {
+	return parseZMPopArgs(args)
+}
+
+func parseBZMPop(args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("bzmpop command requires a timeout")
+	}
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := parseZMPopArgs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return BZMPopCommand{ZMPopCommand: cmd, Timeout: timeout}, nil
+}
+
+func parseZRangeStore(args []string) (any, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("zrangestore command requires at least 4 arguments")
+	}
+	parsed, err := parseZRange(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	zrange := parsed.(ZRangeCommand)
+	if zrange.WithScores {
+		return nil, fmt.Errorf("syntax error")
+	}
+	return ZRangeStoreCommand{Destination: args[0], Source: zrange.Key, Spec: zrange.Spec}, nil
+}
+
+func parseZRemRange(by store.ZRangeBy) func(args []string) (any, error) {
+	return func(args []string) (any, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("zremrange command requires 3 arguments")
+		}
+
+		spec := store.ZRangeSpec{By: by, Count: -1}
+		switch by {
+		case store.ZRangeByRank:
+			start, err := strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			stop, err := strconv.Atoi(args[2])
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			spec.Start, spec.Stop = start, stop
+		case store.ZRangeByScore:
+			r, err := parseScoreRange(args[1], args[2])
+			if err != nil {
+				return nil, err
+			}
+			spec.Score = r
+		case store.ZRangeByLex:
+			r, err := parseLexRange(args[1], args[2])
+			if err != nil {
+				return nil, err
+			}
+			spec.Lex = r
+		default:
+			panic("unknown zrange type")
+		}
+		return ZRemRangeCommand{Key: args[0], Spec: spec}, nil
+	}
+}
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromArrayZSetOps(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		in      protocol.Array
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "zunionstore weights aggregate",
+			in:   request("ZUNIONSTORE", "d", "2", "a", "b", "WEIGHTS", "2", "3", "AGGREGATE", "max"),
+			want: ZCombineStoreCommand{Destination: "d", Spec: store.ZCombineSpec{Op: store.SetOpUnion, Keys: []string{"a", "b"}, Weights: []float64{2, 3}, Aggregate: store.ZAggregateMax}},
+		},
+		{name: "zunionstore missing weights", in: request("ZUNIONSTORE", "d", "2", "a", "b", "WEIGHTS", "2"), wantErr: true},
+		{name: "zinterstore withscores", in: request("ZINTERSTORE", "d", "1", "a", "WITHSCORES"), wantErr: true},
+		{name: "zdiff weights", in: request("ZDIFF", "2", "a", "b", "WEIGHTS", "1", "1"), wantErr: true},
+		{
+			name: "zinter withscores",
+			in:   request("ZINTER", "1", "a", "WITHSCORES"),
+			want: ZCombineCommand{Spec: store.ZCombineSpec{Op: store.SetOpInter, Keys: []string{"a"}}, WithScores: true},
+		},
+		{name: "zpopmax count", in: request("ZPOPMAX", "z", "3"), want: ZPopCommand{Key: "z", Max: true, Count: 3}},
+		{name: "bzpopmin", in: request("BZPOPMIN", "a", "b", "0.5"), want: BZPopCommand{Keys: []string{"a", "b"}, Timeout: 500 * time.Millisecond}},
+		{name: "bzpopmin negative timeout", in: request("BZPOPMIN", "a", "-1"), wantErr: true},
+		{name: "zmpop", in: request("ZMPOP", "2", "a", "b", "MAX", "COUNT", "2"), want: ZMPopCommand{Keys: []string{"a", "b"}, Max: true, Count: 2}},
+		{name: "zmpop zero count", in: request("ZMPOP", "1", "a", "MIN", "COUNT", "0"), wantErr: true},
+		{
+			name: "bzmpop",
+			in:   request("BZMPOP", "1", "1", "a", "MIN"),
+			want: BZMPopCommand{ZMPopCommand: ZMPopCommand{Keys: []string{"a"}, Count: 1}, Timeout: time.Second},
+		},
+		{
+			name: "zrangestore",
+			in:   request("ZRANGESTORE", "d", "s", "0", "-1"),
+			want: ZRangeStoreCommand{Destination: "d", Source: "s", Spec: store.ZRangeSpec{Start: 0, Stop: -1, Count: -1}},
+		},
+		{
+			name: "zremrangebyscore",
+			in:   request("ZREMRANGEBYSCORE", "z", "-inf", "(2"),
+			want: ZRemRangeCommand{Key: "z", Spec: store.ZRangeSpec{By: store.ZRangeByScore, Score: store.ScoreRange{Min: math.Inf(-1), Max: 2, MaxExclusive: true}, Count: -1}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := FromArray(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// attemptNow runs the attempts of a blocking command as they come.
+func attemptNow(try func() (protocol.Frame, bool)) (protocol.Frame, bool) {
+	return try()
+}
+
+func TestBZPopPopsWithinAttempt(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	var mu sync.Mutex
+	var replies []protocol.Frame
+	// attempt holds mu while popping, as the server holds the replication
+	// lock, so the ZADD below cannot run between a pop and its reply.
+	attempt := func(try func() (protocol.Frame, bool)) (protocol.Frame, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		res, ok := try()
+		if ok {
+			replies = append(replies, res)
+		}
+		return res, ok
+	}
+	done := make(chan protocol.Frame)
+	go func() {
+		done <- BZPopCommand{Keys: []string{"z"}, Timeout: 5 * time.Second}.ExecuteBlocking(s, attempt)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	ZAddCommand{Key: "z", Members: []store.ScoredMember{{Member: "a", Score: 1}}}.Execute(s)
+	mu.Unlock()
+
+	select {
+	case got := <-done:
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []protocol.Frame{got}, replies, "only the attempt that popped replies")
+	case <-time.After(time.Second):
+		t.Fatal("BZPOPMIN did not wake up")
+	}
+}
+
+func TestBZPopBlocksUntilZAdd(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	done := make(chan protocol.Frame)
+	go func() {
+		done <- BZPopCommand{Keys: []string{"z"}, Timeout: 5 * time.Second}.ExecuteBlocking(s, attemptNow)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	ZAddCommand{Key: "z", Members: []store.ScoredMember{{Member: "a", Score: 1}}}.Execute(s)
+
+	select {
+	case got := <-done:
+		assert.Equal(t, bulkArray([]string{"z", "a", "1"}), got)
+	case <-time.After(time.Second):
+		t.Fatal("BZPOPMIN did not wake up")
+	}
+}
+
+func TestBZPopTimesOut(t *testing.T) {
+	t.Parallel()
+	s := store.NewStore()
+	got := BZPopCommand{Keys: []string{"z"}, Timeout: 10 * time.Millisecond}.ExecuteBlocking(s, attemptNow)
+	assert.Equal(t, protocol.Array{Null: true}, got)
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// ErrImmutable is returned when setting a parameter that can only be given
+// at startup.
+var ErrImmutable = errors.New("can't set immutable config")
+
+// Param is a configuration parameter. Set is nil for parameters that can
+// only be given on the command line.
+type Param struct {
+	Get func() string
+	Set func(value string) error
+}
+
+// Config holds the parameters of CONFIG GET and CONFIG SET. Each is bound
+// to the part of the server that owns the setting.
+type Config struct {
+	mu     sync.Mutex
+	params map[string]Param
+}
+
+func New() *Config {
+	return &Config{params: make(map[string]Param)}
+}
+
+// Register adds a parameter. name must be lower case.
+func (c *Config) Register(name string, p Param) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.params[name] = p
+}
+
+// Get returns the names and values of the parameters matching any of the
+// glob-style patterns, sorted by name.
+func (c *Config) Get(patterns []string) [][2]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for name := range c.params {
+		for _, pattern := range patterns {
+			if store.MatchGlob(strings.ToLower(pattern), name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	slices.Sort(names)
+
+	pairs := make([][2]string, len(names))
+	for i, name := range names {
+		pairs[i] = [2]string{name, c.params[name].Get()}
+	}
+	return pairs
+}
+
+// UnknownError is returned when setting a parameter that doesn't exist.
+type UnknownError struct {
+	Name string
+}
+
+func (e UnknownError) Error() string {
+	return fmt.Sprintf("Unknown option or number of arguments for CONFIG SET - '%s'", e.Name)
+}
+
+// Set sets the parameter name to value.
+func (c *Config) Set(name string, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.params[strings.ToLower(name)]
+	if !ok {
+		return UnknownError{Name: name}
+	}
+	err := ErrImmutable
+	if p.Set != nil {
+		err = p.Set(value)
+	}
+	if err != nil {
+		return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - %w", name, err)
+	}
+	return nil
+}
+
+var memoryUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1000,
+	"kb": 1024,
+	"m":  1000 * 1000,
+	"mb": 1024 * 1024,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+// ParseMemory parses a memory size such as "100mb" the way Redis's
+// configuration does: k, m and g are powers of 1000 and kb, mb and gb
+// powers of 1024.
+func ParseMemory(value string) (int64, error) {
+	lower := strings.ToLower(value)
+	digits := strings.TrimRight(lower, "bkmg")
+	unit, ok := memoryUnits[lower[len(digits):]]
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if !ok || err != nil || n < 0 || n > (1<<63-1)/unit {
+		return 0, errors.New("argument must be a memory value")
+	}
+	return n * unit, nil
+}
+
+// ParseBool parses the yes or no of a boolean parameter, ignoring case.
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, errors.New("argument must be 'yes' or 'no'")
+	}
+}
+
+// FormatBool formats b as a boolean parameter.
+func FormatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
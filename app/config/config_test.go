@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMemory(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1024", want: 1024},
+		{in: "100b", want: 100},
+		{in: "1k", want: 1000},
+		{in: "1KB", want: 1024},
+		{in: "2m", want: 2000000},
+		{in: "2mb", want: 2 << 20},
+		{in: "1gb", want: 1 << 30},
+		{in: "", wantErr: true},
+		{in: "mb", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "1tb", wantErr: true},
+		{in: "9223372036854775807gb", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseMemory(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]bool{"yes": true, "YES": true, "no": false, "No": false} {
+		got, err := ParseBool(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseBool("true")
+	assert.EqualError(t, err, "argument must be 'yes' or 'no'")
+	assert.Equal(t, "yes", FormatBool(true))
+	assert.Equal(t, "no", FormatBool(false))
+}
+
+func TestConfigGetSet(t *testing.T) {
+	t.Parallel()
+	value := "1"
+	cfg := New()
+	cfg.Register("fixed", Param{Get: func() string { return "x" }})
+	cfg.Register("max-things", Param{
+		Get: func() string { return value },
+		Set: func(v string) error {
+			if v == "bad" {
+				return errors.New("argument must be a number")
+			}
+			value = v
+			return nil
+		},
+	})
+
+	assert.Equal(t, [][2]string{{"fixed", "x"}, {"max-things", "1"}}, cfg.Get([]string{"*"}))
+	assert.Equal(t, [][2]string{{"max-things", "1"}}, cfg.Get([]string{"MAX-*", "nothing"}))
+	assert.Empty(t, cfg.Get([]string{"nothing"}))
+
+	assert.NoError(t, cfg.Set("Max-Things", "2"))
+	assert.Equal(t, "2", value)
+	assert.EqualError(t, cfg.Set("max-things", "bad"), "CONFIG SET failed (possibly related to argument 'max-things') - argument must be a number")
+	assert.ErrorIs(t, cfg.Set("fixed", "y"), ErrImmutable)
+	assert.ErrorAs(t, cfg.Set("missing", "y"), &UnknownError{})
+}
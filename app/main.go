@@ -4,7 +4,13 @@ import (
 	"flag"
 	"log"
 	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/rdb"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/store"
@@ -13,25 +19,82 @@ import (
 func main() {
 	dirFlag := flag.String("dir", "/tmp/redis-data", "directory containing the RDB file")
 	dbFilenameFlag := flag.String("dbfilename", "dump.rdb", "RDB filename")
+	databasesFlag := flag.Int("databases", 16, "number of databases")
+	maxMemoryFlag := flag.String("maxmemory", "0", "memory limit of the keyspace, such as 100mb; 0 for none")
+	policyFlag := flag.String("maxmemory-policy", "noeviction", "keys to evict when over maxmemory")
+	portFlag := flag.Int("port", 6379, "port to listen on")
+	replicaOfFlag := flag.String("replicaof", "", `master to replicate, as "host port"`)
+	clusterEnabledFlag := flag.String("cluster-enabled", "no", "whether to run as a node of a cluster")
+	clusterIPFlag := flag.String("cluster-announce-ip", "127.0.0.1", "IP other nodes and clients reach this node at")
+	clusterSlotsFlag := flag.String("cluster-slots", "", `hash slots a new node serves, such as "0-5460 7000"`)
+	clusterConfigFileFlag := flag.String("cluster-config-file", "nodes.conf", "file in dir the cluster configuration of the node is kept in")
+	clusterNodeTimeoutFlag := flag.Int("cluster-node-timeout", 15000, "milliseconds a node may not reply before it is considered failing")
 	flag.Parse()
 
+	if *databasesFlag < 1 {
+		log.Fatalf("invalid number of databases: %d", *databasesFlag)
+	}
+	maxMemory, err := config.ParseMemory(*maxMemoryFlag)
+	if err != nil {
+		log.Fatalf("invalid maxmemory %q: %v", *maxMemoryFlag, err)
+	}
+	policy, ok := store.ParseEvictionPolicy(*policyFlag)
+	if !ok {
+		log.Fatalf("invalid maxmemory-policy: %s", *policyFlag)
+	}
+	var masterHost, masterPort string
+	if *replicaOfFlag != "" {
+		fields := strings.Fields(*replicaOfFlag)
+		if len(fields) != 2 {
+			log.Fatalf("invalid replicaof %q: want \"host port\"", *replicaOfFlag)
+		}
+		masterHost, masterPort = fields[0], fields[1]
+	}
+	clusterEnabled, err := config.ParseBool(*clusterEnabledFlag)
+	if err != nil {
+		log.Fatalf("invalid cluster-enabled %q: %v", *clusterEnabledFlag, err)
+	}
+	if *clusterNodeTimeoutFlag < 1 {
+		log.Fatalf("invalid cluster-node-timeout: %d", *clusterNodeTimeoutFlag)
+	}
+	slots, err := cluster.ParseSlots(*clusterSlotsFlag)
+	if err != nil {
+		log.Fatalf("invalid cluster-slots %q: %v", *clusterSlotsFlag, err)
+	}
+
 	file := rdb.NewFile(*dirFlag, *dbFilenameFlag)
 	if err := file.Open(); err != nil {
 		log.Fatalf("open rdb file: %v", err)
 	}
 	defer file.Close()
 
-	listener, err := net.Listen("tcp", ":6379")
+	dbs := store.NewDatabases(*databasesFlag)
+	dbs.SetMaxMemory(maxMemory)
+	dbs.SetEvictionPolicy(policy)
+	if err := file.Load(dbs.LoadRDB); err != nil {
+		log.Fatalf("load rdb file: %v", err)
+	}
+
+	addr := ":" + strconv.Itoa(*portFlag)
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatalf("Listen error: %v", err)
 	}
 
-	store := store.NewStore()
-
-	server := server.NewServer(listener, store, file)
+	server := server.NewServer(listener, dbs, file)
 	defer server.Close()
+	if clusterEnabled {
+		configFile := filepath.Join(*dirFlag, *clusterConfigFileFlag)
+		nodeTimeout := time.Duration(*clusterNodeTimeoutFlag) * time.Millisecond
+		if err := server.EnableCluster(*clusterIPFlag, configFile, nodeTimeout, slots); err != nil {
+			log.Fatalf("enable cluster: %v", err)
+		}
+	}
+	if masterHost != "" {
+		server.ReplicaOf(masterHost, masterPort)
+	}
 
-	log.Println("Listening on :6379")
+	log.Println("Listening on " + addr)
 	for {
 		conn, err := server.Accept()
 		if err != nil {
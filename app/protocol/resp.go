@@ -53,17 +53,24 @@ func (i Integer) Write(w *bufio.Writer) error {
 }
 
 // Error represents a RESP Error: -ERR message\r\n
+// Prefix replaces the default ERR code, e.g. -WRONGTYPE message\r\n
 type Error struct {
+	Prefix  string
 	Message string
 }
 
 func (e Error) Write(w *bufio.Writer) error {
-	n, err := w.WriteString("-ERR " + e.Message + "\r\n")
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "ERR"
+	}
+	line := "-" + prefix + " " + e.Message + "\r\n"
+	n, err := w.WriteString(line)
 	if err != nil {
 		return err
 	}
-	if n != len(e.Message)+7 {
-		return fmt.Errorf("expected to write %d bytes, wrote %d", len(e.Message)+7, n)
+	if n != len(line) {
+		return fmt.Errorf("expected to write %d bytes, wrote %d", len(line), n)
 	}
 
 	return w.Flush()
@@ -133,10 +140,10 @@ func (a Array) Write(w *bufio.Writer) error {
 		if err != nil {
 			return err
 		}
-		if n != 4 {
-			return fmt.Errorf("expected to write 4 bytes, wrote %d", n)
+		if n != 5 {
+			return fmt.Errorf("expected to write 5 bytes, wrote %d", n)
 		}
-		return err
+		return w.Flush()
 	}
 	n, err := fmt.Fprintf(w, "*%d\r\n", len(a.Elems))
 	if err != nil {
@@ -244,3 +251,19 @@ func readCRLFLine(r *bufio.Reader) (string, error) {
 	}
 	return s[:len(s)-2], nil
 }
+
+// AppendCommand appends args to buf encoded as an array of bulk strings, the
+// form in which clients send commands.
+func AppendCommand(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
@@ -48,8 +48,10 @@ func TestWriteFrame(t *testing.T) {
 		{"integer 10", Integer{Value: 10}, []byte(":10\r\n")},
 		{"integer -123", Integer{Value: -123}, []byte(":-123\r\n")},
 		{"error", Error{Message: "oops"}, []byte("-ERR oops\r\n")},
+		{"error prefix", Error{Prefix: "WRONGTYPE", Message: "oops"}, []byte("-WRONGTYPE oops\r\n")},
 		{"bulk", BulkString{Bytes: []byte("foo")}, []byte("$3\r\nfoo\r\n")},
 		{"bulk-null", BulkNullString{}, []byte("$-1\r\n")},
+		{"array-null", Array{Null: true}, []byte("*-1\r\n")},
 		{"array", Array{Elems: []Frame{BulkString{Bytes: []byte("PING")}, BulkString{Bytes: []byte("foo")}}}, []byte("*2\r\n$4\r\nPING\r\n$3\r\nfoo\r\n")},
 	}
 	for _, tc := range cases {
@@ -63,3 +65,13 @@ func TestWriteFrame(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendCommand(t *testing.T) {
+	t.Parallel()
+	buf := AppendCommand([]byte("+OK\r\n"), "SET", "k", "")
+	assert.Equal(t, "+OK\r\n*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$0\r\n\r\n", string(buf))
+
+	frame, err := ReadFrame(bufio.NewReader(bytes.NewReader(buf[5:])))
+	assert.NoError(t, err)
+	assert.Equal(t, Array{Elems: []Frame{BulkString{Bytes: []byte("SET")}, BulkString{Bytes: []byte("k")}, BulkString{Bytes: []byte{}}}}, frame)
+}
@@ -0,0 +1,13 @@
+package rdb
+
+import "hash/crc64"
+
+// crc64Jones is the reflected Jones polynomial Redis checksums RDB files
+// and DUMP payloads with.
+var crc64Jones = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// CRC64 extends crc with p. Unlike hash/crc64, Redis neither inverts the
+// initial value nor the result, so both are flipped around the update.
+func CRC64(crc uint64, p []byte) uint64 {
+	return ^crc64.Update(^crc, crc64Jones, p)
+}
@@ -0,0 +1,451 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Version is the RDB format version written, that of Redis 7.2.
+const Version = 11
+
+const magic = "REDIS"
+
+// Value types, as stored before each key.
+const (
+	TypeString           = 0
+	TypeList             = 1
+	TypeSet              = 2
+	TypeZSet             = 3
+	TypeHash             = 4
+	TypeZSet2            = 5
+	TypeSetIntset        = 11
+	TypeZSetZiplist      = 12
+	TypeStreamListpacks  = 15
+	TypeZSetListpack     = 17
+	TypeStreamListpacks2 = 19
+	TypeSetListpack      = 20
+	TypeStreamListpacks3 = 21
+)
+
+// Opcodes, stored where a value type would be.
+const (
+	OpFunction2    = 0xf5
+	OpModuleAux    = 0xf7
+	OpIdle         = 0xf8
+	OpFreq         = 0xf9
+	OpAux          = 0xfa
+	OpResizeDB     = 0xfb
+	OpExpireTimeMs = 0xfc
+	OpExpireTime   = 0xfd
+	OpSelectDB     = 0xfe
+	OpEOF          = 0xff
+)
+
+// Special string encodings, flagged by the top two bits of the length.
+const (
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+var (
+	ErrBadMagic    = errors.New("wrong signature trying to load DB from file")
+	ErrChecksum    = errors.New("wrong RDB checksum")
+	ErrDumpPayload = errors.New("DUMP payload version or checksum are wrong")
+)
+
+// dumpFooterSize is the size of the format version and checksum ending a
+// DUMP payload.
+const dumpFooterSize = 10
+
+// Encoder writes the RDB format while keeping the checksum of everything
+// written. The first error is kept and returned by Err; later writes do
+// nothing.
+type Encoder struct {
+	w   io.Writer
+	crc uint64
+	err error
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Checksum returns the CRC64 of the bytes written so far.
+func (e *Encoder) Checksum() uint64 {
+	return e.crc
+}
+
+// WriteRaw writes p as is.
+func (e *Encoder) WriteRaw(p []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc = CRC64(e.crc, p)
+	_, e.err = e.w.Write(p)
+}
+
+// WriteType writes a value type or an opcode.
+func (e *Encoder) WriteType(b byte) {
+	e.WriteRaw([]byte{b})
+}
+
+// WriteHeader writes the magic string and the format version.
+func (e *Encoder) WriteHeader() {
+	e.WriteRaw(fmt.Appendf(nil, "%s%04d", magic, Version))
+}
+
+// WriteLength writes n in 1, 2, 5 or 9 bytes.
+func (e *Encoder) WriteLength(n uint64) {
+	var buf []byte
+	switch {
+	case n < 1<<6:
+		buf = []byte{byte(n)}
+	case n < 1<<14:
+		buf = []byte{0x40 | byte(n>>8), byte(n)}
+	case n <= math.MaxUint32:
+		buf = binary.BigEndian.AppendUint32([]byte{0x80}, uint32(n))
+	default:
+		buf = binary.BigEndian.AppendUint64([]byte{0x81}, n)
+	}
+	e.WriteRaw(buf)
+}
+
+// WriteString writes a length-prefixed string, or a small integer encoding
+// when s is the canonical representation of a 32-bit integer.
+func (e *Encoder) WriteString(s string) {
+	if len(s) <= 11 {
+		if v, err := strconv.ParseInt(s, 10, 32); err == nil && strconv.FormatInt(v, 10) == s {
+			switch {
+			case v >= math.MinInt8 && v <= math.MaxInt8:
+				e.WriteRaw([]byte{0xc0 | encInt8, byte(v)})
+			case v >= math.MinInt16 && v <= math.MaxInt16:
+				e.WriteRaw(binary.LittleEndian.AppendUint16([]byte{0xc0 | encInt16}, uint16(v)))
+			default:
+				e.WriteRaw(binary.LittleEndian.AppendUint32([]byte{0xc0 | encInt32}, uint32(v)))
+			}
+			return
+		}
+	}
+	e.WriteLength(uint64(len(s)))
+	e.WriteRaw([]byte(s))
+}
+
+// WriteBinaryDouble writes f as a little-endian IEEE 754 double.
+func (e *Encoder) WriteBinaryDouble(f float64) {
+	e.WriteRaw(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+// WriteMillis writes a little-endian time in milliseconds since the epoch.
+func (e *Encoder) WriteMillis(ms int64) {
+	e.WriteRaw(binary.LittleEndian.AppendUint64(nil, uint64(ms)))
+}
+
+func (e *Encoder) WriteAux(key string, value string) {
+	e.WriteType(OpAux)
+	e.WriteString(key)
+	e.WriteString(value)
+}
+
+func (e *Encoder) WriteSelectDB(db int) {
+	e.WriteType(OpSelectDB)
+	e.WriteLength(uint64(db))
+}
+
+// WriteResizeDB writes the number of keys of the database and how many of
+// them have an expiry, so that a reader can size its tables up front.
+func (e *Encoder) WriteResizeDB(size int, expires int) {
+	e.WriteType(OpResizeDB)
+	e.WriteLength(uint64(size))
+	e.WriteLength(uint64(expires))
+}
+
+func (e *Encoder) WriteExpireTime(t time.Time) {
+	e.WriteType(OpExpireTimeMs)
+	e.WriteMillis(t.UnixMilli())
+}
+
+// WriteEOF ends the file with the checksum of everything before it.
+func (e *Encoder) WriteEOF() {
+	e.WriteType(OpEOF)
+	e.WriteRaw(binary.LittleEndian.AppendUint64(nil, e.crc))
+}
+
+// WriteDumpFooter ends a DUMP payload with the format version and the
+// checksum of everything before it, both little-endian.
+func (e *Encoder) WriteDumpFooter() {
+	e.WriteRaw(binary.LittleEndian.AppendUint16(nil, Version))
+	e.WriteRaw(binary.LittleEndian.AppendUint64(nil, e.crc))
+}
+
+// VerifyDumpPayload checks the footer of a DUMP payload and returns the
+// serialized value it ends. Payloads from newer format versions are
+// rejected as they may hold encodings this version cannot read.
+func VerifyDumpPayload(p []byte) ([]byte, error) {
+	if len(p) < dumpFooterSize {
+		return nil, ErrDumpPayload
+	}
+	body, footer := p[:len(p)-dumpFooterSize], p[len(p)-dumpFooterSize:]
+	if binary.LittleEndian.Uint16(footer) > Version {
+		return nil, ErrDumpPayload
+	}
+	if binary.LittleEndian.Uint64(footer[2:]) != CRC64(0, p[:len(p)-8]) {
+		return nil, ErrDumpPayload
+	}
+	return body, nil
+}
+
+// Decoder reads the RDB format while keeping the checksum of everything read.
+type Decoder struct {
+	r   *bufio.Reader
+	crc uint64
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Checksum returns the CRC64 of the bytes read so far.
+func (d *Decoder) Checksum() uint64 {
+	return d.crc
+}
+
+// AtEOF reports whether everything has been read.
+func (d *Decoder) AtEOF() bool {
+	_, err := d.r.Peek(1)
+	return err == io.EOF
+}
+
+// rawChunk is the most ReadRaw allocates ahead of the bytes it has read, so
+// that a corrupt length runs out of input instead of allocating what it
+// claims.
+const rawChunk = 64 << 10
+
+var errLengthRange = errors.New("length out of range")
+
+// ReadRaw reads exactly n bytes.
+func (d *Decoder) ReadRaw(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errLengthRange
+	}
+	var buf []byte
+	if n <= rawChunk {
+		buf = make([]byte, n)
+		if _, err := io.ReadFull(d.r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	} else {
+		var b bytes.Buffer
+		if _, err := io.CopyN(&b, d.r, int64(n)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		buf = b.Bytes()
+	}
+	d.crc = CRC64(d.crc, buf)
+	return buf, nil
+}
+
+func (d *Decoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	d.crc = CRC64(d.crc, []byte{b})
+	return b, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadHeader checks the magic string and returns the format version.
+func (d *Decoder) ReadHeader() (int, error) {
+	buf, err := d.ReadRaw(len(magic) + 4)
+	if err != nil {
+		return 0, err
+	}
+	if string(buf[:len(magic)]) != magic {
+		return 0, ErrBadMagic
+	}
+	version, err := strconv.Atoi(string(buf[len(magic):]))
+	if err != nil || version < 1 {
+		return 0, ErrBadMagic
+	}
+	if version > Version {
+		return 0, fmt.Errorf("can't handle RDB format version %d", version)
+	}
+	return version, nil
+}
+
+// readLength returns a length, or the special string encoding when encoded
+// is set.
+func (d *Decoder) readLength() (n uint64, encoded bool, err error) {
+	b, err := d.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3f), false, nil
+	case 1:
+		next, err := d.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3f)<<8 | uint64(next), false, nil
+	case 2:
+		switch b {
+		case 0x80:
+			buf, err := d.ReadRaw(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return uint64(binary.BigEndian.Uint32(buf)), false, nil
+		case 0x81:
+			buf, err := d.ReadRaw(8)
+			if err != nil {
+				return 0, false, err
+			}
+			return binary.BigEndian.Uint64(buf), false, nil
+		default:
+			return 0, false, fmt.Errorf("unknown length encoding %#x", b)
+		}
+	default:
+		return uint64(b & 0x3f), true, nil
+	}
+}
+
+func (d *Decoder) ReadLength() (uint64, error) {
+	n, encoded, err := d.readLength()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, errors.New("unexpected string encoding for a length")
+	}
+	return n, nil
+}
+
+// ReadString reads a string in any of its encodings.
+func (d *Decoder) ReadString() (string, error) {
+	n, encoded, err := d.readLength()
+	if err != nil {
+		return "", err
+	}
+	if !encoded {
+		buf, err := d.ReadRaw(int(n))
+		return string(buf), err
+	}
+
+	switch n {
+	case encInt8:
+		b, err := d.ReadByte()
+		return strconv.Itoa(int(int8(b))), err
+	case encInt16:
+		buf, err := d.ReadRaw(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(buf)))), nil
+	case encInt32:
+		buf, err := d.ReadRaw(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(buf)))), nil
+	case encLZF:
+		clen, err := d.ReadLength()
+		if err != nil {
+			return "", err
+		}
+		size, err := d.ReadLength()
+		if err != nil {
+			return "", err
+		}
+		compressed, err := d.ReadRaw(int(clen))
+		if err != nil {
+			return "", err
+		}
+		buf, err := lzfDecompress(compressed, int(size))
+		return string(buf), err
+	default:
+		return "", fmt.Errorf("unknown string encoding %d", n)
+	}
+}
+
+// ReadDouble reads a double stored as a length-prefixed decimal string, with
+// the lengths 253, 254 and 255 standing for NaN, +inf and -inf.
+func (d *Decoder) ReadDouble() (float64, error) {
+	n, err := d.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	buf, err := d.ReadRaw(int(n))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(buf), 64)
+}
+
+func (d *Decoder) ReadBinaryDouble() (float64, error) {
+	buf, err := d.ReadRaw(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
+}
+
+// ReadMillis reads a little-endian time in milliseconds since the epoch.
+func (d *Decoder) ReadMillis() (int64, error) {
+	buf, err := d.ReadRaw(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf)), nil
+}
+
+// ReadSeconds reads a little-endian time in seconds since the epoch.
+func (d *Decoder) ReadSeconds() (time.Time, error) {
+	buf, err := d.ReadRaw(4)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(binary.LittleEndian.Uint32(buf)), 0), nil
+}
+
+// ReadChecksum reads the checksum following the EOF opcode and verifies
+// it. A zero checksum means the writer had checksums disabled.
+func (d *Decoder) ReadChecksum() error {
+	want := d.crc
+	buf, err := d.ReadRaw(8)
+	if err != nil {
+		return err
+	}
+	if got := binary.LittleEndian.Uint64(buf); got != 0 && got != want {
+		return ErrChecksum
+	}
+	return nil
+}
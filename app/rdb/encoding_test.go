@@ -0,0 +1,124 @@
+package rdb
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRC64(t *testing.T) {
+	t.Parallel()
+	// The check value of Redis's crc64 test.
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), CRC64(0, []byte("123456789")))
+	assert.Equal(t, CRC64(0, []byte("123456789")), CRC64(CRC64(0, []byte("1234")), []byte("56789")))
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	t.Parallel()
+	lengths := []uint64{0, 63, 64, 16383, 16384, math.MaxUint32, math.MaxUint32 + 1}
+	strs := []string{"", "hello", "0", "-128", "127", "-32768", "40000", "2147483647", "2147483648", "007", "1.5", strings.Repeat("x", 20000)}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.WriteHeader()
+	for _, n := range lengths {
+		enc.WriteLength(n)
+	}
+	for _, s := range strs {
+		enc.WriteString(s)
+	}
+	enc.WriteBinaryDouble(-2.5)
+	enc.WriteMillis(1700000000123)
+	enc.WriteEOF()
+	require.NoError(t, enc.Err())
+
+	dec := NewDecoder(&buf)
+	version, err := dec.ReadHeader()
+	require.NoError(t, err)
+	assert.Equal(t, Version, version)
+	for _, n := range lengths {
+		got, err := dec.ReadLength()
+		require.NoError(t, err)
+		assert.Equal(t, n, got)
+	}
+	for _, s := range strs {
+		got, err := dec.ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, s, got)
+	}
+	f, err := dec.ReadBinaryDouble()
+	require.NoError(t, err)
+	assert.Equal(t, -2.5, f)
+	ms, err := dec.ReadMillis()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000123), ms)
+	op, err := dec.ReadByte()
+	require.NoError(t, err)
+	assert.Equal(t, byte(OpEOF), op)
+	assert.NoError(t, dec.ReadChecksum())
+}
+
+func TestEncoderIntegerStrings(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		in   string
+		want []byte
+	}{
+		{"12", []byte{0xc0, 12}},
+		{"-1", []byte{0xc0, 0xff}},
+		{"1000", []byte{0xc1, 0xe8, 0x03}},
+		{"100000", []byte{0xc2, 0xa0, 0x86, 0x01, 0x00}},
+		{"01", []byte{0x02, '0', '1'}},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.WriteString(tc.in)
+		assert.Equal(t, tc.want, buf.Bytes(), tc.in)
+	}
+}
+
+func TestDecoderLZF(t *testing.T) {
+	t.Parallel()
+	// "abc" as literals, then a back reference copying 6 bytes from 3 back.
+	compressed := []byte{0x02, 'a', 'b', 'c', 0x80, 0x02}
+	payload := append([]byte{0xc3, byte(len(compressed)), 9}, compressed...)
+	got, err := NewDecoder(bytes.NewReader(payload)).ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "abcabcabc", got)
+
+	_, err = NewDecoder(bytes.NewReader([]byte{0xc3, 2, 9, 0x80, 0x02})).ReadString()
+	assert.Error(t, err)
+}
+
+func TestDecoderChecksum(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.WriteHeader()
+	enc.WriteEOF()
+	data := buf.Bytes()
+	data[len(data)-1] ^= 1
+
+	dec := NewDecoder(bytes.NewReader(data))
+	_, err := dec.ReadHeader()
+	require.NoError(t, err)
+	_, err = dec.ReadByte()
+	require.NoError(t, err)
+	assert.ErrorIs(t, dec.ReadChecksum(), ErrChecksum)
+}
+
+func TestDecoderHeader(t *testing.T) {
+	t.Parallel()
+	_, err := NewDecoder(strings.NewReader("REDIX0011")).ReadHeader()
+	assert.ErrorIs(t, err, ErrBadMagic)
+	_, err = NewDecoder(strings.NewReader("REDIS0099")).ReadHeader()
+	assert.Error(t, err)
+	version, err := NewDecoder(strings.NewReader("REDIS0003")).ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+}
@@ -0,0 +1,246 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// ErrCorruptListpack is returned when a listpack or intset blob is malformed.
+var ErrCorruptListpack = errors.New("corrupt listpack")
+
+const (
+	listpackHeaderSize = 6
+	listpackEnd        = 0xff
+	// listpackUnknownCount is stored when a listpack holds too many
+	// elements for the header's count field.
+	listpackUnknownCount = math.MaxUint16
+)
+
+// Listpack builds the compact encoding Redis uses for small collections and
+// stream nodes: a header with the total size and element count, then each
+// element as an encoding byte, its data and its own length stored backwards
+// so that the list can be walked in both directions, then an end byte.
+type Listpack struct {
+	elems []byte
+	count int
+}
+
+// AppendString adds s, stored as an integer when it is the canonical
+// representation of one, as Redis does.
+func (lp *Listpack) AppendString(s string) {
+	if len(s) <= 20 {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(v, 10) == s {
+			lp.AppendInt(v)
+			return
+		}
+	}
+
+	start := len(lp.elems)
+	switch n := len(s); {
+	case n < 64:
+		lp.elems = append(lp.elems, 0x80|byte(n))
+	case n < 4096:
+		lp.elems = append(lp.elems, 0xe0|byte(n>>8), byte(n))
+	default:
+		lp.elems = append(lp.elems, 0xf0)
+		lp.elems = binary.LittleEndian.AppendUint32(lp.elems, uint32(n))
+	}
+	lp.elems = append(lp.elems, s...)
+	lp.appendBacklen(len(lp.elems) - start)
+	lp.count++
+}
+
+// AppendInt adds v in the smallest integer encoding that holds it.
+func (lp *Listpack) AppendInt(v int64) {
+	start := len(lp.elems)
+	switch {
+	case v >= 0 && v <= 127:
+		lp.elems = append(lp.elems, byte(v))
+	case v >= -4096 && v <= 4095:
+		u := uint64(v) & 0x1fff
+		lp.elems = append(lp.elems, 0xc0|byte(u>>8), byte(u))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		lp.elems = append(lp.elems, 0xf1)
+		lp.elems = binary.LittleEndian.AppendUint16(lp.elems, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		lp.elems = append(lp.elems, 0xf2, byte(v), byte(v>>8), byte(v>>16))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		lp.elems = append(lp.elems, 0xf3)
+		lp.elems = binary.LittleEndian.AppendUint32(lp.elems, uint32(v))
+	default:
+		lp.elems = append(lp.elems, 0xf4)
+		lp.elems = binary.LittleEndian.AppendUint64(lp.elems, uint64(v))
+	}
+	lp.appendBacklen(len(lp.elems) - start)
+	lp.count++
+}
+
+// appendBacklen stores the size of the element just appended in groups of
+// seven bits, most significant first, with the high bit set on all but the
+// first so that a reader walking backwards knows where it stops.
+func (lp *Listpack) appendBacklen(size int) {
+	n := backlenSize(size)
+	for i := n - 1; i >= 0; i-- {
+		b := byte(size>>(7*i)) & 0x7f
+		if i != n-1 {
+			b |= 0x80
+		}
+		lp.elems = append(lp.elems, b)
+	}
+}
+
+func backlenSize(size int) int {
+	switch {
+	case size <= 127:
+		return 1
+	case size < 16383:
+		return 2
+	case size < 2097151:
+		return 3
+	case size < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func (lp *Listpack) Len() int {
+	return lp.count
+}
+
+// Bytes returns the encoded listpack.
+func (lp *Listpack) Bytes() []byte {
+	buf := make([]byte, 0, listpackHeaderSize+len(lp.elems)+1)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(listpackHeaderSize+len(lp.elems)+1))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(min(lp.count, listpackUnknownCount)))
+	buf = append(buf, lp.elems...)
+	return append(buf, listpackEnd)
+}
+
+// ParseListpack returns the elements of an encoded listpack, with integers
+// formatted in decimal.
+func ParseListpack(data []byte) ([]string, error) {
+	if len(data) < listpackHeaderSize+1 || int(binary.LittleEndian.Uint32(data)) != len(data) || data[len(data)-1] != listpackEnd {
+		return nil, ErrCorruptListpack
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+
+	var elems []string
+	for p := listpackHeaderSize; data[p] != listpackEnd; {
+		elem, size, err := parseListpackElem(data[p : len(data)-1])
+		if err != nil {
+			return nil, err
+		}
+		p += size + backlenSize(size)
+		if p >= len(data) {
+			return nil, ErrCorruptListpack
+		}
+		elems = append(elems, elem)
+	}
+	if count != listpackUnknownCount && count != len(elems) {
+		return nil, ErrCorruptListpack
+	}
+	return elems, nil
+}
+
+// parseListpackElem decodes the element at the start of data and returns it
+// with the size of its encoding and data.
+func parseListpackElem(data []byte) (string, int, error) {
+	need := func(n int) error {
+		if len(data) < n {
+			return ErrCorruptListpack
+		}
+		return nil
+	}
+	str := func(header int, n int) (string, int, error) {
+		if err := need(header + n); err != nil {
+			return "", 0, err
+		}
+		return string(data[header : header+n]), header + n, nil
+	}
+	integer := func(v int64, size int) (string, int, error) {
+		return strconv.FormatInt(v, 10), size, nil
+	}
+
+	b := data[0]
+	switch {
+	case b&0x80 == 0:
+		return integer(int64(b), 1)
+	case b&0xc0 == 0x80:
+		return str(1, int(b&0x3f))
+	case b&0xe0 == 0xc0:
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		u := int64(b&0x1f)<<8 | int64(data[1])
+		if u >= 1<<12 {
+			u -= 1 << 13
+		}
+		return integer(u, 2)
+	case b&0xf0 == 0xe0:
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		return str(2, int(b&0x0f)<<8|int(data[1]))
+	}
+
+	switch b {
+	case 0xf0:
+		if err := need(5); err != nil {
+			return "", 0, err
+		}
+		return str(5, int(binary.LittleEndian.Uint32(data[1:])))
+	case 0xf1:
+		if err := need(3); err != nil {
+			return "", 0, err
+		}
+		return integer(int64(int16(binary.LittleEndian.Uint16(data[1:]))), 3)
+	case 0xf2:
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		u := int32(data[1]) | int32(data[2])<<8 | int32(data[3])<<16
+		return integer(int64(u<<8>>8), 4)
+	case 0xf3:
+		if err := need(5); err != nil {
+			return "", 0, err
+		}
+		return integer(int64(int32(binary.LittleEndian.Uint32(data[1:]))), 5)
+	case 0xf4:
+		if err := need(9); err != nil {
+			return "", 0, err
+		}
+		return integer(int64(binary.LittleEndian.Uint64(data[1:])), 9)
+	default:
+		return "", 0, ErrCorruptListpack
+	}
+}
+
+// ParseIntset returns the members of an encoded intset: a little-endian
+// uint32 width in bytes, a uint32 count and the sorted members.
+func ParseIntset(data []byte) ([]int64, error) {
+	if len(data) < 8 {
+		return nil, ErrCorruptListpack
+	}
+	width := int(binary.LittleEndian.Uint32(data))
+	count := int(binary.LittleEndian.Uint32(data[4:]))
+	if (width != 2 && width != 4 && width != 8) || len(data) != 8+width*count {
+		return nil, ErrCorruptListpack
+	}
+
+	members := make([]int64, count)
+	for i := range members {
+		p := data[8+i*width:]
+		switch width {
+		case 2:
+			members[i] = int64(int16(binary.LittleEndian.Uint16(p)))
+		case 4:
+			members[i] = int64(int32(binary.LittleEndian.Uint32(p)))
+		default:
+			members[i] = int64(binary.LittleEndian.Uint64(p))
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,81 @@
+package rdb
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListpackBytes(t *testing.T) {
+	t.Parallel()
+	lp := &Listpack{}
+	lp.AppendString("hello")
+	lp.AppendInt(1)
+	want := []byte{16, 0, 0, 0, 2, 0, 0x85, 'h', 'e', 'l', 'l', 'o', 6, 0x01, 1, 0xff}
+	assert.Equal(t, want, lp.Bytes())
+	assert.Equal(t, 2, lp.Len())
+}
+
+func TestListpackRoundTrip(t *testing.T) {
+	t.Parallel()
+	values := []string{
+		"", "a", "127", "128", "-1", "4095", "-4096", "4096", "32767", "-32769",
+		"8388607", "-8388609", "2147483648", "-9223372036854775808", "9223372036854775807",
+		"007", "+1", "1e3", strings.Repeat("y", 63), strings.Repeat("y", 64),
+		strings.Repeat("z", 4095), strings.Repeat("z", 4096), strings.Repeat("w", 20000),
+	}
+	lp := &Listpack{}
+	for _, v := range values {
+		lp.AppendString(v)
+	}
+	got, err := ParseListpack(lp.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, values, got)
+}
+
+func TestListpackManyElements(t *testing.T) {
+	t.Parallel()
+	lp := &Listpack{}
+	want := make([]string, 70000)
+	for i := range want {
+		want[i] = strconv.Itoa(i)
+		lp.AppendInt(int64(i))
+	}
+	got, err := ParseListpack(lp.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestParseListpackCorrupt(t *testing.T) {
+	t.Parallel()
+	lp := &Listpack{}
+	lp.AppendString("hello")
+	data := lp.Bytes()
+
+	_, err := ParseListpack(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrCorruptListpack)
+
+	bad := append([]byte(nil), data...)
+	bad[4] = 3
+	_, err = ParseListpack(bad)
+	assert.ErrorIs(t, err, ErrCorruptListpack)
+
+	bad = append([]byte(nil), data...)
+	bad[6] = 0x8a
+	_, err = ParseListpack(bad)
+	assert.ErrorIs(t, err, ErrCorruptListpack)
+}
+
+func TestParseIntset(t *testing.T) {
+	t.Parallel()
+	data := []byte{2, 0, 0, 0, 3, 0, 0, 0, 0xfe, 0xff, 1, 0, 0x10, 0x27}
+	got, err := ParseIntset(data)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-2, 1, 10000}, got)
+
+	_, err = ParseIntset(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrCorruptListpack)
+}
@@ -0,0 +1,62 @@
+package rdb
+
+import "errors"
+
+var errCorruptLZF = errors.New("corrupt LZF compressed string")
+
+// lzfMaxExpansion bounds how many times larger than its compressed form a
+// string decompresses to: a back reference takes three bytes and copies
+// at most 264.
+const lzfMaxExpansion = 88
+
+// lzfDecompress expands an LZF compressed string of length size. A control
+// byte below 32 starts a run of that many plus one literal bytes; above, its
+// top three bits (extended by the next byte when all set) are the length
+// minus two of a back reference whose offset minus one is the low five bits
+// followed by one more byte.
+func lzfDecompress(in []byte, size int) ([]byte, error) {
+	if size < 0 || size > lzfMaxExpansion*len(in) {
+		return nil, errCorruptLZF
+	}
+	out := make([]byte, 0, size)
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+
+		if ctrl < 32 {
+			n := ctrl + 1
+			if ip+n > len(in) || len(out)+n > size {
+				return nil, errCorruptLZF
+			}
+			out = append(out, in[ip:ip+n]...)
+			ip += n
+			continue
+		}
+
+		n := ctrl >> 5
+		if n == 7 {
+			if ip >= len(in) {
+				return nil, errCorruptLZF
+			}
+			n += int(in[ip])
+			ip++
+		}
+		if ip >= len(in) {
+			return nil, errCorruptLZF
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[ip]) - 1
+		ip++
+		n += 2
+		if ref < 0 || len(out)+n > size {
+			return nil, errCorruptLZF
+		}
+		// The reference may overlap the bytes being written.
+		for i := range n {
+			out = append(out, out[ref+i])
+		}
+	}
+	if len(out) != size {
+		return nil, errCorruptLZF
+	}
+	return out, nil
+}
@@ -1,18 +1,34 @@
 package rdb
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
+// ErrSaveInProgress is returned when a save is requested while another runs.
+var ErrSaveInProgress = errors.New("Background save already in progress")
+
 type File struct {
 	Dir        string
 	DBFilename string
 	db         *os.File
+
+	mu       sync.Mutex
+	saving   bool
+	lastSave time.Time
+	// lastErr is whether the last save failed.
+	lastErr bool
 }
 
 func NewFile(dir, dbFilename string) *File {
-	return &File{Dir: dir, DBFilename: dbFilename}
+	return &File{Dir: dir, DBFilename: dbFilename, lastSave: time.Now()}
 }
 
 func (f *File) Open() error {
@@ -35,3 +51,121 @@ func (f *File) Open() error {
 func (f *File) Close() error {
 	return f.db.Close()
 }
+
+func (f *File) Path() string {
+	return filepath.Join(f.Dir, f.DBFilename)
+}
+
+// Load calls read with the contents of the RDB file. A missing or empty
+// file, as left by Open, holds no data and read is not called.
+func (f *File) Load(read func(r io.Reader) error) error {
+	db, err := os.Open(f.Path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	info, err := db.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	return read(bufio.NewReader(db))
+}
+
+// LastSave returns when the last successful save completed, or when the
+// file was created if none did.
+func (f *File) LastSave() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastSave
+}
+
+// Saving reports whether a save is running.
+func (f *File) Saving() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.saving
+}
+
+// LastSaveFailed reports whether the last save failed.
+func (f *File) LastSaveFailed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastErr
+}
+
+// claim marks a save as running, failing if one already is.
+func (f *File) claim() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.saving {
+		return ErrSaveInProgress
+	}
+	f.saving = true
+	return nil
+}
+
+func (f *File) release(ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saving = false
+	f.lastErr = !ok
+	if ok {
+		f.lastSave = time.Now()
+	}
+}
+
+// Save calls write to produce a snapshot in a temporary file that then
+// replaces the RDB file, so that a failed save never leaves it truncated.
+// Only one save runs at a time.
+func (f *File) Save(write func(w io.Writer) error) error {
+	if err := f.claim(); err != nil {
+		return err
+	}
+	err := f.save(write)
+	f.release(err == nil)
+	return err
+}
+
+// BackgroundSave is like Save but returns once the save has started.
+// Errors are logged.
+func (f *File) BackgroundSave(write func(w io.Writer) error) error {
+	if err := f.claim(); err != nil {
+		return err
+	}
+	go func() {
+		err := f.save(write)
+		if err != nil {
+			log.Printf("background save: %v", err)
+		}
+		f.release(err == nil)
+	}()
+	return nil
+}
+
+func (f *File) save(write func(w io.Writer) error) error {
+	tmp, err := os.Create(filepath.Join(f.Dir, fmt.Sprintf("temp-%d.rdb", os.Getpid())))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path())
+}
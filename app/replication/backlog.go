@@ -0,0 +1,48 @@
+package replication
+
+// DefaultBacklogSize is the default repl-backlog-size.
+const DefaultBacklogSize = 1 << 20
+
+// backlog is a circular buffer of the latest bytes of the replication
+// stream, from which replicas that lost their link resume.
+type backlog struct {
+	buf []byte
+	// idx is where the next byte goes and histlen how many bytes are held.
+	idx     int
+	histlen int
+}
+
+func newBacklog(size int) *backlog {
+	return &backlog{buf: make([]byte, size)}
+}
+
+func (b *backlog) write(p []byte) {
+	if len(p) > len(b.buf) {
+		p = p[len(p)-len(b.buf):]
+	}
+	for len(p) > 0 {
+		n := copy(b.buf[b.idx:], p)
+		b.idx = (b.idx + n) % len(b.buf)
+		b.histlen = min(b.histlen+n, len(b.buf))
+		p = p[n:]
+	}
+}
+
+// tail returns a copy of the last n bytes held, n at most histlen.
+func (b *backlog) tail(n int) []byte {
+	out := make([]byte, 0, n)
+	start := (b.idx - n + len(b.buf)) % len(b.buf)
+	if start+n <= len(b.buf) {
+		return append(out, b.buf[start:start+n]...)
+	}
+	out = append(out, b.buf[start:]...)
+	return append(out, b.buf[:n-(len(b.buf)-start)]...)
+}
+
+// resized returns a backlog of size bytes holding as much of the history
+// of b as fits.
+func (b *backlog) resized(size int) *backlog {
+	nb := newBacklog(size)
+	nb.write(b.tail(min(b.histlen, size)))
+	return nb
+}
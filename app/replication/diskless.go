@@ -0,0 +1,162 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDisklessSyncDelay is the default repl-diskless-sync-delay.
+const DefaultDisklessSyncDelay = 5 * time.Second
+
+// keepalivePeriod is how often replicas waiting for a diskless sync are
+// sent a newline, so that they do not time out.
+const keepalivePeriod = time.Second
+
+// SyncOptions are the settings of full resynchronizations. With
+// repl-diskless-sync a master streams the snapshot to the replicas that
+// support it as it is produced, waiting DisklessDelay, the
+// repl-diskless-sync-delay, for more replicas to share it. SwapDBLoad is
+// repl-diskless-load swapdb: a replica loads the snapshot of its master
+// aside and keeps serving its old data until it is loaded.
+type SyncOptions struct {
+	Diskless      atomic.Bool
+	DisklessDelay atomic.Int64
+	SwapDBLoad    atomic.Bool
+}
+
+// SyncOptions returns the settings of full resynchronizations.
+func (r *Replication) SyncOptions() *SyncOptions {
+	return &r.syncOptions
+}
+
+// waitDiskless queues rep for the next diskless sync, starting one unless
+// it is pending. The caller must hold r.mu.
+func (r *Replication) waitDiskless(rep *replica) {
+	r.waiting[rep] = struct{}{}
+	if !r.syncPending {
+		r.syncPending = true
+		go r.disklessSync(time.Duration(r.syncOptions.DisklessDelay.Load()))
+	}
+}
+
+// disklessSync waits delay for replicas to gather, then streams a snapshot
+// to all of them at once: "+FULLRESYNC <replID> <offset>", then
+// "$EOF:<mark>\r\n", the RDB file and the mark again, as the length of the
+// RDB is not known in advance. The writes wait until it is sent, after
+// which the replicas receive the stream.
+func (r *Replication) disklessSync(delay time.Duration) {
+	end := time.Now().Add(delay)
+	for time.Until(end) > keepalivePeriod {
+		time.Sleep(keepalivePeriod)
+		r.mu.Lock()
+		for rep := range r.waiting {
+			rep.send([]byte("\n"))
+		}
+		r.mu.Unlock()
+	}
+	time.Sleep(time.Until(end))
+
+	r.exec.Lock()
+	defer r.exec.Unlock()
+	r.mu.Lock()
+	reps := make(replicaWriter, 0, len(r.waiting))
+	for rep := range r.waiting {
+		reps = append(reps, rep)
+		delete(r.waiting, rep)
+	}
+	r.syncPending = false
+	if len(reps) == 0 {
+		r.mu.Unlock()
+		return
+	}
+	if r.backlog == nil {
+		r.replID, r.replID2, r.secondOffset = newReplID(), noReplID, -1
+		r.backlog = newBacklog(r.backlogSize)
+	}
+	mark := newReplID()
+	reps.Write(fmt.Appendf(nil, "+FULLRESYNC %s %d\r\n$EOF:%s\r\n", r.replID, r.offset, mark))
+	r.seldb = -1
+	r.mu.Unlock()
+
+	w := bufio.NewWriterSize(reps, 16<<10)
+	err := r.dbs.SaveRDB(w)
+	if err == nil {
+		err = w.Flush()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rep := range reps {
+		if err != nil {
+			rep.close()
+			continue
+		}
+		rep.send([]byte(mark))
+		rep.mu.Lock()
+		closed := rep.closed
+		rep.mu.Unlock()
+		if !closed {
+			r.replicas[rep] = struct{}{}
+		}
+	}
+	if err != nil {
+		log.Printf("diskless sync: %v", err)
+	}
+}
+
+// replicaWriter sends what is written to every replica.
+type replicaWriter []*replica
+
+func (w replicaWriter) Write(p []byte) (int, error) {
+	for _, rep := range w {
+		rep.send(p)
+	}
+	return len(p), nil
+}
+
+// eofReader reads a snapshot sent by a diskless sync from r up to mark,
+// which it consumes but does not return.
+type eofReader struct {
+	r    *bufio.Reader
+	mark []byte
+	done bool
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	if e.done {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// At least the length of the mark is buffered, so that a mark that
+	// starts in the last bytes buffered is held back until it is whole.
+	if _, err := e.r.Peek(len(e.mark)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	buf, _ := e.r.Peek(e.r.Buffered())
+	if i := bytes.Index(buf, e.mark); i >= 0 {
+		n := copy(p, buf[:i])
+		e.r.Discard(n)
+		if n < i {
+			return n, nil
+		}
+		e.r.Discard(len(e.mark))
+		e.done = true
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+	n := copy(p, buf[:len(buf)-len(e.mark)+1])
+	e.r.Discard(n)
+	return n, nil
+}
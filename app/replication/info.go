@@ -0,0 +1,86 @@
+package replication
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// Info is the replication state reported by INFO replication.
+type Info struct {
+	// Master is the address of the master of a replica, empty on a master.
+	MasterHost string
+	MasterPort string
+	// LinkUp is set while a replica is in sync with its master, and
+	// Syncing while it connects or loads the snapshot. LastIO is how long
+	// ago the master last sent something.
+	LinkUp  bool
+	Syncing bool
+	LastIO  time.Duration
+
+	ReplID       string
+	ReplID2      string
+	Offset       int64
+	SecondOffset int64
+	// The backlog holds BacklogHistlen bytes of the stream, starting at
+	// BacklogFirstByte, and is only active once a replica has connected.
+	BacklogActive    bool
+	BacklogSize      int
+	BacklogFirstByte int64
+	BacklogHistlen   int
+
+	Replicas []ReplicaInfo
+}
+
+// ReplicaInfo describes a replica connected to this server. Waiting is set
+// while it waits for a diskless sync to start.
+type ReplicaInfo struct {
+	IP      string
+	Port    int
+	Waiting bool
+	// Offset is the offset it last acknowledged, Lag how long ago.
+	Offset int64
+	Lag    time.Duration
+}
+
+// Info returns the replication state.
+func (r *Replication) Info() Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	info := Info{
+		ReplID:       r.replID,
+		ReplID2:      r.replID2,
+		Offset:       r.offset,
+		SecondOffset: r.secondOffset,
+		BacklogSize:  r.backlogSize,
+	}
+	if l := r.master; l != nil {
+		info.MasterHost, info.MasterPort = l.host, l.port
+		info.LinkUp = l.up
+		info.Syncing = l.conn != nil && !l.up
+		if l.up {
+			info.LastIO = now.Sub(l.lastIO)
+		}
+	}
+	if r.backlog != nil {
+		info.BacklogActive = true
+		info.BacklogHistlen = r.backlog.histlen
+		info.BacklogFirstByte = r.offset - int64(r.backlog.histlen) + 1
+	}
+	for _, reps := range []map[*replica]struct{}{r.replicas, r.waiting} {
+		for rep := range reps {
+			host, port, _ := net.SplitHostPort(rep.addr)
+			p, _ := strconv.Atoi(port)
+			_, waiting := r.waiting[rep]
+			info.Replicas = append(info.Replicas, ReplicaInfo{
+				IP:      host,
+				Port:    p,
+				Waiting: waiting,
+				Offset:  rep.ackOffset,
+				Lag:     now.Sub(rep.ackTime),
+			})
+		}
+	}
+	return info
+}
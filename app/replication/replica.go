@@ -0,0 +1,395 @@
+package replication
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// replTimeout is how long a replica waits for its master, Redis's
+// repl-timeout, before it drops the link and reconnects.
+const replTimeout = 60 * time.Second
+
+// reconnectDelay is how long a replica waits before retrying a failed link.
+const reconnectDelay = time.Second
+
+var errLinkClosed = errors.New("link closed")
+
+// link is the connection of a replica to its master. conn, up, which is
+// set once the replica is in sync, and lastIO, when the master last sent
+// something, are guarded by the mu of the Replication. db is the database
+// selected in the stream, which carries over when the replica resumes it.
+type link struct {
+	host   string
+	port   string
+	done   chan struct{}
+	conn   net.Conn
+	up     bool
+	lastIO time.Time
+	db     int
+	// writeMu serializes the acknowledgements written to conn.
+	writeMu sync.Mutex
+}
+
+// ReplicaOf makes the server a replica of the master at host and port. It
+// reports false if the server already replicates that master. The server
+// tries to resume the stream of the new master from its own history, which
+// works when the master was promoted from a replica in sync with it, and
+// otherwise discards its data for a snapshot of the master's. Replicas of
+// the server are dropped so that they resync from it again.
+func (r *Replication) ReplicaOf(host string, port string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.master != nil && r.master.host == host && r.master.port == port {
+		return false
+	}
+	r.stopLink()
+	r.disconnectReplicas()
+	r.master = &link{host: host, port: port, done: make(chan struct{})}
+	go r.replicate(r.master)
+	return true
+}
+
+// ReplicaOfNoOne turns a replica into a master that keeps its data. It
+// starts a new history, but replicas of its old master that were as far
+// along can still resume from the old one.
+func (r *Replication) ReplicaOfNoOne() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.master == nil {
+		return
+	}
+	r.stopLink()
+	r.master = nil
+	r.replID2, r.secondOffset = r.replID, r.offset+1
+	r.replID = newReplID()
+	r.seldb = -1
+	r.disconnectReplicas()
+}
+
+// stopLink closes the link to the master, if any. The caller must hold
+// r.mu.
+func (r *Replication) stopLink() {
+	if r.master == nil {
+		return
+	}
+	close(r.master.done)
+	if r.master.conn != nil {
+		r.master.conn.Close()
+	}
+}
+
+// replicate keeps l in sync with its master until it is stopped,
+// reconnecting whenever the link breaks.
+func (r *Replication) replicate(l *link) {
+	addr := net.JoinHostPort(l.host, l.port)
+	for {
+		err := r.sync(l, addr)
+		select {
+		case <-l.done:
+			return
+		default:
+		}
+		log.Printf("replication from master %s: %v", addr, err)
+
+		r.mu.Lock()
+		l.conn, l.up = nil, false
+		r.mu.Unlock()
+		select {
+		case <-l.done:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// sync connects to the master, loads its snapshot and applies the commands
+// it streams until the connection fails or l is stopped.
+func (r *Replication) sync(l *link, addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, replTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r.mu.Lock()
+	select {
+	case <-l.done:
+		r.mu.Unlock()
+		return errLinkClosed
+	default:
+	}
+	l.conn = conn
+	r.mu.Unlock()
+
+	reader := bufio.NewReader(deadlineReader{conn: conn, timeout: replTimeout})
+	reply, err := r.handshake(conn, reader)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(reply)
+	switch {
+	case len(fields) == 3 && fields[0] == "FULLRESYNC":
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+		}
+		if err := r.load(l, reader, fields[1], offset); err != nil {
+			return err
+		}
+		log.Printf("replication from master %s: in sync", addr)
+	case len(fields) <= 2 && fields[0] == "CONTINUE":
+		replID := ""
+		if len(fields) == 2 {
+			replID = fields[1]
+		}
+		r.resume(l, replID)
+		log.Printf("replication from master %s: resumed", addr)
+	default:
+		return fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go r.ackPeriodically(l, conn, stop)
+	return r.stream(l, conn, reader)
+}
+
+// handshake introduces the replica to its master and asks to resume the
+// stream after the last byte it has. It returns the reply to PSYNC, before
+// which a master waiting to start a diskless sync sends newlines.
+func (r *Replication) handshake(conn net.Conn, reader *bufio.Reader) (string, error) {
+	r.mu.Lock()
+	psync := []string{"PSYNC", r.replID, strconv.FormatInt(r.offset+1, 10)}
+	r.mu.Unlock()
+	steps := [][]string{
+		{"PING"},
+		{"REPLCONF", "listening-port", strconv.Itoa(r.port)},
+		{"REPLCONF", "capa", "eof", "capa", "psync2"},
+		psync,
+	}
+	var reply protocol.SimpleString
+	for _, args := range steps {
+		conn.SetWriteDeadline(time.Now().Add(replTimeout))
+		if _, err := conn.Write(protocol.AppendCommand(nil, args...)); err != nil {
+			return "", err
+		}
+		if err := skipNewlines(reader); err != nil {
+			return "", err
+		}
+		frame, err := protocol.ReadFrame(reader)
+		if err != nil {
+			return "", err
+		}
+		var ok bool
+		if reply, ok = frame.(protocol.SimpleString); !ok {
+			return "", fmt.Errorf("unexpected reply to %s: %v", strings.Join(args, " "), frame)
+		}
+	}
+	return reply.Value, nil
+}
+
+// resume continues the stream of the master from the backlog. A master
+// that replies with another replication ID than the replica's has changed
+// the history: the replica adopts the new ID, keeping the old one as its
+// second so that its own replicas can follow.
+func (r *Replication) resume(l *link, replID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if replID != "" && replID != r.replID {
+		r.replID2, r.secondOffset = r.replID, r.offset+1
+		r.replID = replID
+		r.disconnectReplicas()
+	}
+	if r.backlog == nil {
+		r.backlog = newBacklog(r.backlogSize)
+	}
+	l.up, l.lastIO = true, time.Now()
+}
+
+// load replaces the data with the snapshot the master sends after
+// "+FULLRESYNC <replID> <offset>": "$<length>\r\n" followed by an RDB
+// file, without the CRLF of a bulk string, or for a diskless sync
+// "$EOF:<mark>\r\n" followed by the RDB file and the mark. While it
+// prepares the snapshot the master may send newlines to keep the link
+// alive. The replica then holds the history replID up to offset.
+func (r *Replication) load(l *link, reader *bufio.Reader, replID string, offset int64) error {
+	if err := skipNewlines(reader); err != nil {
+		return err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	header := strings.TrimSuffix(strings.TrimPrefix(line, "$"), "\r\n")
+	var payload io.Reader
+	if mark, ok := strings.CutPrefix(header, "EOF:"); ok && len(mark) > 0 {
+		payload = &eofReader{r: reader, mark: []byte(mark)}
+	} else if size, err := strconv.ParseInt(header, 10, 64); err == nil && size >= 0 {
+		payload = io.LimitReader(reader, size)
+	}
+	if !strings.HasPrefix(line, "$") || payload == nil {
+		return fmt.Errorf("bad snapshot header %q", line)
+	}
+
+	r.exec.Lock()
+	defer r.exec.Unlock()
+	if r.syncOptions.SwapDBLoad.Load() {
+		err = r.loadAside(payload)
+	} else {
+		err = r.loadInPlace(payload)
+	}
+	if err != nil {
+		return fmt.Errorf("loading snapshot: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, payload); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replID, r.offset = replID, offset
+	r.backlog = newBacklog(r.backlogSize)
+	l.up, l.lastIO, l.db = true, time.Now(), 0
+	// The replicas of this server hold the data it just replaced.
+	r.disconnectReplicas()
+	return nil
+}
+
+// loadInPlace replaces the data with the snapshot read from payload.
+// Until the snapshot is loaded the data belongs to no history, so that a
+// failed load is never resumed.
+func (r *Replication) loadInPlace(payload io.Reader) error {
+	r.mu.Lock()
+	r.replID, r.replID2, r.secondOffset = newReplID(), noReplID, -1
+	r.mu.Unlock()
+	r.dbs.FlushAll(false)
+	return r.dbs.LoadRDB(payload)
+}
+
+// loadAside loads the snapshot read from payload into fresh databases,
+// while clients keep reading the old data, and then swaps them in. A
+// failed load leaves the old data alone.
+func (r *Replication) loadAside(payload io.Reader) error {
+	fresh := store.NewDatabases(r.dbs.Len())
+	fresh.SetEvictionPolicy(r.dbs.EvictionPolicy())
+	if err := fresh.LoadRDB(payload); err != nil {
+		return err
+	}
+	r.dbs.Exchange(fresh)
+	fresh.FlushAll(true)
+	return nil
+}
+
+// skipNewlines consumes the newlines a master sends to keep the link alive.
+func skipNewlines(reader *bufio.Reader) error {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return err
+		}
+		if b[0] != '\n' {
+			return nil
+		}
+		reader.Discard(1)
+	}
+}
+
+// stream applies the commands the master sends, and answers its requests
+// for acknowledgement on conn. The commands of a
+// transaction are applied at its EXEC, all together, and only then count
+// towards the offset, so that a replica that loses its link in the middle
+// resumes from the start of the transaction.
+func (r *Replication) stream(l *link, conn net.Conn, reader *bufio.Reader) error {
+	var multi []protocol.Array
+	var pending []byte
+	for {
+		frame, err := protocol.ReadFrame(reader)
+		if err != nil {
+			return err
+		}
+		request, args, ok := commandArgs(frame)
+		if !ok {
+			return fmt.Errorf("unexpected frame from master: %v", frame)
+		}
+		pending = protocol.AppendCommand(pending, args...)
+
+		switch name := strings.ToUpper(args[0]); {
+		case name == "MULTI":
+			multi = []protocol.Array{}
+			continue
+		case multi != nil && name != "EXEC":
+			multi = append(multi, request)
+			continue
+		case name == "REPLCONF" && len(args) > 1 && strings.EqualFold(args[1], "GETACK"):
+			// The acknowledged offset excludes the request itself.
+			if err := r.sendAck(l, conn); err != nil {
+				return err
+			}
+			r.applyStream(l, nil, &l.db, pending)
+		case name == "EXEC":
+			r.applyStream(l, multi, &l.db, pending)
+			multi = nil
+		default:
+			r.applyStream(l, []protocol.Array{request}, &l.db, pending)
+		}
+		pending = pending[:0]
+	}
+}
+
+// applyStream applies requests and passes raw, the bytes of the stream
+// they came in, on to the replicas of this server. Nothing is applied once
+// l is stopped.
+func (r *Replication) applyStream(l *link, requests []protocol.Array, db *int, raw []byte) {
+	r.exec.Lock()
+	defer r.exec.Unlock()
+	select {
+	case <-l.done:
+		return
+	default:
+	}
+	if len(requests) > 0 {
+		r.apply(requests, db)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l.lastIO = time.Now()
+	r.feed(raw)
+}
+
+// commandArgs returns the arguments of a command sent as an array of bulk
+// strings.
+func commandArgs(frame protocol.Frame) (protocol.Array, []string, bool) {
+	request, ok := frame.(protocol.Array)
+	if !ok || request.Null || len(request.Elems) == 0 {
+		return request, nil, false
+	}
+	args := make([]string, len(request.Elems))
+	for i, elem := range request.Elems {
+		bulk, ok := elem.(protocol.BulkString)
+		if !ok {
+			return request, nil, false
+		}
+		args[i] = string(bulk.Bytes)
+	}
+	return request, args, true
+}
+
+// deadlineReader fails reads from conn that wait more than timeout.
+type deadlineReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (d deadlineReader) Read(p []byte) (int, error) {
+	d.conn.SetReadDeadline(time.Now().Add(d.timeout))
+	return d.conn.Read(p)
+}
@@ -0,0 +1,416 @@
+// Package replication keeps replicas in sync with their master. A master
+// sends each replica a snapshot of its databases followed by the stream of
+// write commands it runs, which the replica applies in the same order.
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// ErrNoMasterLink is returned when a replica that is not in sync with its
+// own master is asked for a snapshot.
+var ErrNoMasterLink = errors.New("Can't SYNC while not connected with my master")
+
+// pingPeriod is how often a master pings its replicas, Redis's
+// repl-ping-replica-period, so that they can tell a quiet master from a
+// lost one.
+const pingPeriod = 10 * time.Second
+
+// Command is a write command as sent to replicas, with the database it ran
+// against.
+type Command struct {
+	DB   int
+	Args []string
+}
+
+// ApplyFunc runs requests received from the master: a single command or
+// the commands of a transaction. db is the database selected in the
+// replication stream, which SELECT changes.
+type ApplyFunc func(requests []protocol.Array, db *int)
+
+// ReplicaOptions are the settings of what the clients of a replica may
+// do: replica-read-only refuses their writes, and with
+// replica-serve-stale-data off they may not use the data while the link to
+// the master is down.
+type ReplicaOptions struct {
+	ReadOnly       atomic.Bool
+	ServeStaleData atomic.Bool
+}
+
+// Replication is the replication state of a server, which is either a
+// master or the replica of another server.
+type Replication struct {
+	dbs         *store.Databases
+	port        int
+	apply       ApplyFunc
+	options     ReplicaOptions
+	syncOptions SyncOptions
+
+	// exec orders writes: it is held while a write command runs and is
+	// sent to replicas, and while a snapshot is taken, so that replicas see
+	// the writes in the order they ran and each exactly once.
+	exec sync.Mutex
+
+	mu sync.Mutex
+	// replID names the history of the data, up to offset bytes of the
+	// replication stream. replID2 is the ID it had before the last change
+	// of master, which replicas may still resume from up to secondOffset.
+	replID       string
+	replID2      string
+	offset       int64
+	secondOffset int64
+	backlog      *backlog
+	backlogSize  int
+	// seldb is the database last selected in the stream sent to replicas,
+	// or -1 when the next command must select one.
+	seldb    int
+	replicas map[*replica]struct{}
+	// waiting are the replicas waiting for a diskless sync, which is
+	// pending when syncPending is set.
+	waiting     map[*replica]struct{}
+	syncPending bool
+	// acked is closed and replaced whenever a replica acknowledges an
+	// offset, waking up WAIT.
+	acked chan struct{}
+	// master is the link to the master of a replica, nil on a master.
+	master *link
+
+	// done is closed by Close to stop pinging replicas.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns the replication state of a master serving dbs. port is the
+// port the server listens on, which it reports to its master when it
+// becomes a replica, and apply runs the commands received from the master.
+func New(dbs *store.Databases, port int, apply ApplyFunc) *Replication {
+	r := &Replication{
+		dbs:          dbs,
+		port:         port,
+		apply:        apply,
+		replID:       newReplID(),
+		replID2:      noReplID,
+		secondOffset: -1,
+		backlogSize:  DefaultBacklogSize,
+		seldb:        -1,
+		replicas:     make(map[*replica]struct{}),
+		waiting:      make(map[*replica]struct{}),
+		acked:        make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	r.options.ReadOnly.Store(true)
+	r.options.ServeStaleData.Store(true)
+	r.syncOptions.DisklessDelay.Store(int64(DefaultDisklessSyncDelay))
+	go r.pingReplicas()
+	return r
+}
+
+// Options returns the settings of what the clients of a replica may do.
+func (r *Replication) Options() *ReplicaOptions {
+	return &r.options
+}
+
+// noReplID is the replication ID of no history.
+const noReplID = "0000000000000000000000000000000000000000"
+
+// newReplID returns a random replication ID of 40 hex digits.
+func newReplID() string {
+	var b [20]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Lock is held while write commands run and are propagated.
+func (r *Replication) Lock() {
+	r.exec.Lock()
+}
+
+func (r *Replication) Unlock() {
+	r.exec.Unlock()
+}
+
+// Propagate sends cmds, which ran in this order while holding the lock, to
+// the replicas. Several commands are sent as a transaction, so that
+// replicas apply all or none of them. Replicas propagate nothing of their
+// own: their replicas receive the stream of their master. Until a replica
+// first connects there is no stream at all.
+func (r *Replication) Propagate(cmds []Command) {
+	if len(cmds) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.master != nil || r.backlog == nil {
+		return
+	}
+
+	var buf []byte
+	if len(cmds) > 1 {
+		buf = protocol.AppendCommand(buf, "MULTI")
+	}
+	for _, cmd := range cmds {
+		if cmd.DB != r.seldb {
+			buf = protocol.AppendCommand(buf, "SELECT", strconv.Itoa(cmd.DB))
+			r.seldb = cmd.DB
+		}
+		buf = protocol.AppendCommand(buf, cmd.Args...)
+	}
+	if len(cmds) > 1 {
+		buf = protocol.AppendCommand(buf, "EXEC")
+	}
+	r.feed(buf)
+}
+
+// feed appends b to the replication stream. The caller must hold r.mu.
+func (r *Replication) feed(b []byte) {
+	r.offset += int64(len(b))
+	if r.backlog != nil {
+		r.backlog.write(b)
+	}
+	for rep := range r.replicas {
+		rep.send(b)
+	}
+}
+
+// Close stops pinging replicas.
+func (r *Replication) Close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
+
+// pingReplicas pings the replicas periodically until Close. Like writes,
+// pings wait while a snapshot is taken, as they move the offset.
+func (r *Replication) pingReplicas() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.done:
+			return
+		}
+		r.exec.Lock()
+		r.mu.Lock()
+		if r.master == nil && len(r.replicas) > 0 {
+			r.feed(protocol.AppendCommand(nil, "PING"))
+		}
+		r.mu.Unlock()
+		r.exec.Unlock()
+	}
+}
+
+// replica is a replica connected to this server, and its pending output.
+// addr is the address it accepts connections on: its IP and the port it
+// reported with REPLCONF listening-port. ackOffset and aofOffset are the
+// offsets it last acknowledged having applied and written to its AOF,
+// guarded by the mu of the Replication. eof is set when it accepts a
+// snapshot framed by an end mark, as sent by diskless syncs.
+type replica struct {
+	addr      string
+	eof       bool
+	ackOffset int64
+	aofOffset int64
+	ackTime   time.Time
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	out    []byte
+	closed bool
+}
+
+func newReplica(addr string) *replica {
+	rep := &replica{addr: addr}
+	rep.cond = sync.NewCond(&rep.mu)
+	return rep
+}
+
+func (rep *replica) send(b []byte) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.closed {
+		return
+	}
+	rep.out = append(rep.out, b...)
+	rep.cond.Signal()
+}
+
+func (rep *replica) close() {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.closed = true
+	rep.out = nil
+	rep.cond.Signal()
+}
+
+// writeTo writes the output of rep to w as it is produced, until rep is
+// closed or writing fails.
+func (rep *replica) writeTo(w io.Writer) error {
+	for {
+		rep.mu.Lock()
+		for len(rep.out) == 0 && !rep.closed {
+			rep.cond.Wait()
+		}
+		if rep.closed {
+			rep.mu.Unlock()
+			return nil
+		}
+		out := rep.out
+		rep.out = nil
+		rep.mu.Unlock()
+
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// Sync serves "PSYNC replID offset" from a replica connected on conn. The
+// replica resumes the replication stream from the backlog when it still
+// holds offset, the first byte it misses, of the history named replID.
+// Otherwise it gets a full resynchronization: a snapshot of the databases
+// followed by the stream. reader reads from conn, which from then on
+// carries only replication traffic, and listeningPort is the port the
+// replica reported, or 0. eof is set when the replica announced the eof
+// capability, so that it can be sent a diskless sync. Sync returns once the replica disconnects, or an
+// error if it could not be served, in which case conn is left alone.
+func (r *Replication) Sync(conn net.Conn, reader *bufio.Reader, listeningPort int, eof bool, replID string, offset int64) error {
+	host, port, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	if listeningPort != 0 {
+		port = strconv.Itoa(listeningPort)
+	}
+	rep := newReplica(net.JoinHostPort(host, port))
+	rep.ackTime = time.Now()
+	rep.eof = eof
+	if err := r.addReplica(rep, replID, offset); err != nil {
+		return err
+	}
+	defer r.removeReplica(rep)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rep.writeTo(conn)
+		// Whether writing failed or the replica was dropped, closing conn
+		// ends the read loop below.
+		conn.Close()
+	}()
+	for {
+		frame, err := protocol.ReadFrame(reader)
+		if err != nil {
+			break
+		}
+		if _, args, ok := commandArgs(frame); ok {
+			r.receiveAck(rep, args)
+		}
+	}
+	rep.close()
+	<-done
+	return nil
+}
+
+// addReplica queues the reply to PSYNC replID offset for rep, with the
+// missing part of the stream or a snapshot of the databases, and starts
+// sending it the replication stream. No write runs in between, so the
+// stream picks up exactly where the reply ends. A replica that gets a
+// diskless sync waits for it instead.
+func (r *Replication) addReplica(rep *replica, replID string, offset int64) error {
+	r.exec.Lock()
+	defer r.exec.Unlock()
+	r.mu.Lock()
+	if r.master != nil && !r.master.up {
+		r.mu.Unlock()
+		return ErrNoMasterLink
+	}
+	if r.canContinue(replID, offset) {
+		defer r.mu.Unlock()
+		rep.out = fmt.Appendf(nil, "+CONTINUE %s\r\n", r.replID)
+		rep.out = append(rep.out, r.backlog.tail(int(r.offset-offset+1))...)
+		r.replicas[rep] = struct{}{}
+		return nil
+	}
+	if rep.eof && r.syncOptions.Diskless.Load() {
+		defer r.mu.Unlock()
+		r.waitDiskless(rep)
+		return nil
+	}
+	r.mu.Unlock()
+
+	var snapshot bytes.Buffer
+	if err := r.dbs.SaveRDB(&snapshot); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.backlog == nil {
+		// The stream starts here, as a new history.
+		r.replID, r.replID2, r.secondOffset = newReplID(), noReplID, -1
+		r.backlog = newBacklog(r.backlogSize)
+	}
+	rep.out = fmt.Appendf(nil, "+FULLRESYNC %s %d\r\n$%d\r\n", r.replID, r.offset, snapshot.Len())
+	rep.out = append(rep.out, snapshot.Bytes()...)
+	r.replicas[rep] = struct{}{}
+	r.seldb = -1
+	return nil
+}
+
+// canContinue reports whether the stream of history replID can be resumed
+// from offset. The caller must hold r.mu.
+func (r *Replication) canContinue(replID string, offset int64) bool {
+	if r.backlog == nil {
+		return false
+	}
+	if replID != r.replID && (replID != r.replID2 || offset > r.secondOffset) {
+		return false
+	}
+	return offset >= r.offset-int64(r.backlog.histlen)+1 && offset <= r.offset+1
+}
+
+// SetBacklogSize sets the size of the backlog, keeping as much of its
+// history as fits.
+func (r *Replication) SetBacklogSize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backlogSize = size
+	if r.backlog != nil {
+		r.backlog = r.backlog.resized(size)
+	}
+}
+
+// BacklogSize returns the size of the backlog.
+func (r *Replication) BacklogSize() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.backlogSize
+}
+
+func (r *Replication) removeReplica(rep *replica) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.replicas, rep)
+	delete(r.waiting, rep)
+}
+
+// disconnectReplicas drops every replica, which reconnect and resync. The
+// caller must hold r.mu.
+func (r *Replication) disconnectReplicas() {
+	for _, reps := range []map[*replica]struct{}{r.replicas, r.waiting} {
+		for rep := range reps {
+			rep.close()
+			delete(reps, rep)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagate(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(16)
+	dbs.DB(0).Set("k", "v", nil)
+	r := New(dbs, 0, nil)
+	t.Cleanup(r.Close)
+
+	r.Propagate([]Command{{DB: 0, Args: []string{"SET", "a", "1"}}})
+	assert.Zero(t, r.offset, "nothing is sent without replicas")
+
+	rep := newReplica("127.0.0.1:6380")
+	require.NoError(t, r.addReplica(rep, "?", -1))
+	reader := bufio.NewReader(bytes.NewReader(rep.out))
+	header, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("+FULLRESYNC %s 0\r\n", r.replID), header)
+	var size int64
+	_, err = fmt.Fscanf(reader, "$%d\r\n", &size)
+	require.NoError(t, err)
+	loaded := store.NewDatabases(16)
+	require.NoError(t, loaded.LoadRDB(io.LimitReader(reader, size)))
+	assert.Equal(t, 1, loaded.DB(0).DBSize())
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	rep.out = nil
+	r.Propagate([]Command{{DB: 2, Args: []string{"SET", "a", "1"}}})
+	r.Propagate([]Command{{DB: 2, Args: []string{"DEL", "a"}}, {DB: 0, Args: []string{"DEL", "b"}}})
+	want := protocol.AppendCommand(nil, "SELECT", "2")
+	want = protocol.AppendCommand(want, "SET", "a", "1")
+	want = protocol.AppendCommand(want, "MULTI")
+	want = protocol.AppendCommand(want, "DEL", "a")
+	want = protocol.AppendCommand(want, "SELECT", "0")
+	want = protocol.AppendCommand(want, "DEL", "b")
+	want = protocol.AppendCommand(want, "EXEC")
+	assert.Equal(t, string(want), string(rep.out))
+	assert.Equal(t, int64(len(want)), r.offset)
+
+	r.mu.Lock()
+	r.disconnectReplicas()
+	r.mu.Unlock()
+	var out bytes.Buffer
+	assert.NoError(t, rep.writeTo(&out))
+	assert.Zero(t, out.Len())
+}
+
+func TestReplicaStream(t *testing.T) {
+	t.Parallel()
+	master := store.NewDatabases(16)
+	master.DB(1).Set("old", "v", nil)
+	var snapshot bytes.Buffer
+	require.NoError(t, master.SaveRDB(&snapshot))
+
+	var applied [][]string
+	var dbs []int
+	replicaDBs := store.NewDatabases(16)
+	replicaDBs.DB(0).Set("stale", "v", nil)
+	r := New(replicaDBs, 0, func(requests []protocol.Array, db *int) {
+		var batch []string
+		for _, request := range requests {
+			_, args, _ := commandArgs(request)
+			if args[0] == "SELECT" {
+				fmt.Sscan(args[1], db)
+			}
+			batch = append(batch, strings.Join(args, " "))
+		}
+		applied = append(applied, batch)
+		dbs = append(dbs, *db)
+	})
+	t.Cleanup(r.Close)
+	l := &link{done: make(chan struct{})}
+	sub := newReplica("127.0.0.1:6381")
+	r.replicas[sub] = struct{}{}
+
+	stream := fmt.Appendf(nil, "\n\n$%d\r\n", snapshot.Len())
+	stream = append(stream, snapshot.Bytes()...)
+	var commands []byte
+	commands = protocol.AppendCommand(commands, "SELECT", "1")
+	commands = protocol.AppendCommand(commands, "SET", "a", "1")
+	commands = protocol.AppendCommand(commands, "MULTI")
+	commands = protocol.AppendCommand(commands, "INCR", "n")
+	commands = protocol.AppendCommand(commands, "DEL", "a")
+	commands = protocol.AppendCommand(commands, "EXEC")
+	commands = protocol.AppendCommand(commands, "MULTI")
+	commands = protocol.AppendCommand(commands, "SET", "unfinished", "1")
+	stream = append(stream, commands...)
+
+	reader := bufio.NewReader(bytes.NewReader(stream))
+	require.NoError(t, r.load(l, reader, "8de1787ba490483314a4d30f1c628bc8d26e7a1a", 100))
+	assert.True(t, l.up)
+	assert.Zero(t, replicaDBs.DB(0).DBSize(), "the snapshot replaces the data")
+	assert.Equal(t, 1, replicaDBs.DB(1).DBSize())
+	assert.Empty(t, r.replicas, "replicas resync after the data is replaced")
+
+	sub = newReplica("127.0.0.1:6381")
+	r.replicas[sub] = struct{}{}
+	assert.ErrorIs(t, r.stream(l, nil, reader), io.EOF)
+	assert.Equal(t, [][]string{{"SELECT 1"}, {"SET a 1"}, {"INCR n", "DEL a"}}, applied)
+	assert.Equal(t, []int{1, 1, 1}, dbs)
+	complete := len(commands) - len(protocol.AppendCommand(protocol.AppendCommand(nil, "MULTI"), "SET", "unfinished", "1"))
+	assert.Equal(t, string(commands[:complete]), string(sub.out), "replicas receive the stream as applied")
+	assert.Equal(t, "8de1787ba490483314a4d30f1c628bc8d26e7a1a", r.replID)
+	assert.Equal(t, int64(100+complete), r.offset)
+	assert.Equal(t, 1, l.db)
+}
+
+func TestReplicaOf(t *testing.T) {
+	t.Parallel()
+	r := New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(r.Close)
+	// Nothing listens on port 0, so the link keeps retrying.
+	assert.True(t, r.ReplicaOf("127.0.0.1", "0"))
+	assert.False(t, r.ReplicaOf("127.0.0.1", "0"))
+	assert.ErrorIs(t, r.addReplica(newReplica(""), "?", -1), ErrNoMasterLink)
+	replID := r.replID
+
+	r.ReplicaOfNoOne()
+	assert.Nil(t, r.master)
+	assert.NotEqual(t, replID, r.replID)
+	assert.Equal(t, replID, r.replID2)
+	assert.Equal(t, r.offset+1, r.secondOffset)
+	assert.NoError(t, r.addReplica(newReplica(""), "?", -1))
+}
+
+func TestBacklog(t *testing.T) {
+	t.Parallel()
+	b := newBacklog(8)
+	b.write([]byte("abc"))
+	assert.Equal(t, "abc", string(b.tail(3)))
+	assert.Equal(t, "bc", string(b.tail(2)))
+	b.write([]byte("defghij"))
+	assert.Equal(t, 8, b.histlen)
+	assert.Equal(t, "cdefghij", string(b.tail(8)))
+	b.write([]byte("0123456789"))
+	assert.Equal(t, "23456789", string(b.tail(8)))
+
+	assert.Equal(t, "6789", string(b.resized(4).tail(4)))
+	bigger := b.resized(16)
+	assert.Equal(t, 8, bigger.histlen)
+	assert.Equal(t, "23456789", string(bigger.tail(8)))
+}
+
+func TestPartialResync(t *testing.T) {
+	t.Parallel()
+	r := New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(r.Close)
+	require.NoError(t, r.addReplica(newReplica(""), "?", -1))
+	sel := protocol.AppendCommand(nil, "SELECT", "0")
+	set := protocol.AppendCommand(sel, "SET", "k", "v")
+	r.Propagate([]Command{{DB: 0, Args: []string{"SET", "k", "v"}}})
+	require.Equal(t, int64(len(set)), r.offset)
+
+	// A replica that has everything, one that misses the SET and one
+	// that missed the SELECT as well.
+	for _, missing := range []int{0, len(set) - len(sel), len(set)} {
+		rep := newReplica("")
+		require.NoError(t, r.addReplica(rep, r.replID, r.offset-int64(missing)+1))
+		assert.Equal(t, "+CONTINUE "+r.replID+"\r\n"+string(set[len(set)-missing:]), string(rep.out))
+	}
+
+	for name, psync := range map[string]struct {
+		replID string
+		offset int64
+	}{
+		"unknown history":  {newReplID(), 1},
+		"before backlog":   {r.replID, 0},
+		"ahead of master":  {r.replID, r.offset + 2},
+		"full resync":      {"?", -1},
+		"old history late": {noReplID, 1},
+	} {
+		rep := newReplica("")
+		require.NoError(t, r.addReplica(rep, psync.replID, psync.offset))
+		assert.True(t, bytes.HasPrefix(rep.out, []byte("+FULLRESYNC "+r.replID)), name)
+	}
+
+	// After a failover, replicas of the old master resume up to the point
+	// where the history changed.
+	r.replID2, r.secondOffset = newReplID(), r.offset+1
+	rep := newReplica("")
+	require.NoError(t, r.addReplica(rep, r.replID2, r.offset+1))
+	assert.Equal(t, "+CONTINUE "+r.replID+"\r\n", string(rep.out))
+	assert.False(t, r.canContinue(r.replID2, r.offset+2))
+
+	r.SetBacklogSize(4)
+	assert.Equal(t, 4, r.BacklogSize())
+	assert.False(t, r.canContinue(r.replID, r.offset-4))
+	assert.True(t, r.canContinue(r.replID, r.offset-3))
+}
+
+func TestResume(t *testing.T) {
+	t.Parallel()
+	r := New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(r.Close)
+	replID := r.replID
+	r.offset = 41
+	sub := newReplica("")
+	r.replicas[sub] = struct{}{}
+	l := &link{}
+
+	r.resume(l, replID)
+	assert.True(t, l.up)
+	assert.Equal(t, replID, r.replID)
+	assert.Len(t, r.replicas, 1)
+	assert.NotNil(t, r.backlog)
+
+	r.resume(l, "8de1787ba490483314a4d30f1c628bc8d26e7a1a")
+	assert.Equal(t, "8de1787ba490483314a4d30f1c628bc8d26e7a1a", r.replID)
+	assert.Equal(t, replID, r.replID2)
+	assert.Equal(t, int64(42), r.secondOffset)
+	assert.Empty(t, r.replicas, "replicas learn of the new history by reconnecting")
+}
+
+func TestWait(t *testing.T) {
+	t.Parallel()
+	r := New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(r.Close)
+	assert.Zero(t, r.Wait(1, 0, time.Millisecond, false), "no replicas")
+
+	rep := newReplica("")
+	require.NoError(t, r.addReplica(rep, "?", -1))
+	r.Propagate([]Command{{DB: 0, Args: []string{"SET", "a", "1"}}})
+	offset := r.Offset()
+	rep.out = nil
+	assert.Zero(t, r.Wait(1, offset, 10*time.Millisecond, false))
+	assert.Equal(t, string(protocol.AppendCommand(nil, "REPLCONF", "GETACK", "*")), string(rep.out))
+	assert.Zero(t, r.Wait(0, offset, 0, false), "nothing to wait for")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.receiveAck(rep, []string{"REPLCONF", "ACK", strconv.FormatInt(offset, 10)})
+	}()
+	assert.Equal(t, 1, r.Wait(1, offset, 0, false))
+	assert.Zero(t, r.Wait(1, offset, time.Millisecond, true), "the replica has no AOF")
+
+	r.receiveAck(rep, []string{"REPLCONF", "ACK", "1", "FACK", strconv.FormatInt(offset, 10)})
+	assert.Equal(t, offset, rep.ackOffset, "acknowledgements never go back")
+	assert.Equal(t, 1, r.Wait(1, offset, 0, true))
+}
+
+func TestGetAck(t *testing.T) {
+	t.Parallel()
+	r := New(store.NewDatabases(1), 0, func([]protocol.Array, *int) {})
+	t.Cleanup(r.Close)
+	l := &link{done: make(chan struct{})}
+	set := protocol.AppendCommand(nil, "SET", "a", "1")
+	getAck := protocol.AppendCommand(nil, "REPLCONF", "GETACK", "*")
+	conn, master := net.Pipe()
+	defer conn.Close()
+
+	acks := make(chan string, 1)
+	go func() {
+		frame, err := protocol.ReadFrame(bufio.NewReader(master))
+		if err == nil {
+			_, args, _ := commandArgs(frame)
+			acks <- strings.Join(args, " ")
+		}
+		close(acks)
+	}()
+	reader := bufio.NewReader(bytes.NewReader(append(set, getAck...)))
+	assert.ErrorIs(t, r.stream(l, conn, reader), io.EOF)
+	assert.Equal(t, fmt.Sprintf("REPLCONF ACK %d", len(set)), <-acks)
+	assert.Equal(t, int64(len(set)+len(getAck)), r.Offset(), "GETACK counts towards the offset after the reply")
+}
+
+func TestInfo(t *testing.T) {
+	t.Parallel()
+	r := New(store.NewDatabases(1), 0, nil)
+	t.Cleanup(r.Close)
+	info := r.Info()
+	assert.Empty(t, info.MasterHost)
+	assert.False(t, info.BacklogActive)
+
+	rep := newReplica("127.0.0.1:6380")
+	rep.ackTime = time.Now()
+	require.NoError(t, r.addReplica(rep, "?", -1))
+	r.Propagate([]Command{{DB: 0, Args: []string{"SET", "a", "1"}}})
+	r.receiveAck(rep, []string{"REPLCONF", "ACK", "5"})
+	info = r.Info()
+	assert.True(t, info.BacklogActive)
+	assert.Equal(t, int64(1), info.BacklogFirstByte)
+	assert.Equal(t, int(info.Offset), info.BacklogHistlen)
+	require.Len(t, info.Replicas, 1)
+	assert.Equal(t, "127.0.0.1", info.Replicas[0].IP)
+	assert.Equal(t, 6380, info.Replicas[0].Port)
+	assert.Equal(t, int64(5), info.Replicas[0].Offset)
+
+	assert.True(t, r.ReplicaOf("127.0.0.1", "0"))
+	info = r.Info()
+	assert.Equal(t, "127.0.0.1", info.MasterHost)
+	assert.False(t, info.LinkUp)
+}
+
+func TestEOFReader(t *testing.T) {
+	t.Parallel()
+	mark := newReplID()
+	payload := bytes.Repeat([]byte("0123456789"), 50)
+	stream := append(append(append([]byte{}, payload...), mark...), "*1\r\n$4\r\nPING\r\n"...)
+
+	// Reading a byte at a time splits the mark across reads.
+	reader := bufio.NewReaderSize(iotest.OneByteReader(bytes.NewReader(stream)), 64)
+	got, err := io.ReadAll(&eofReader{r: reader, mark: []byte(mark)})
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "*1\r\n$4\r\nPING\r\n", string(rest), "nothing after the mark is consumed")
+
+	_, err = io.ReadAll(&eofReader{r: bufio.NewReader(bytes.NewReader(payload)), mark: []byte(mark)})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDisklessSync(t *testing.T) {
+	t.Parallel()
+	dbs := store.NewDatabases(2)
+	dbs.DB(1).Set("k", "v", nil)
+	r := New(dbs, 0, nil)
+	t.Cleanup(r.Close)
+	r.SyncOptions().Diskless.Store(true)
+	r.SyncOptions().DisklessDelay.Store(int64(20 * time.Millisecond))
+
+	reps := []*replica{newReplica(""), newReplica("")}
+	for _, rep := range reps {
+		rep.eof = true
+		require.NoError(t, r.addReplica(rep, "?", -1))
+	}
+	disk := newReplica("")
+	require.NoError(t, r.addReplica(disk, "?", -1))
+	assert.True(t, bytes.HasPrefix(disk.out, []byte("+FULLRESYNC ")), "replicas without the eof capability get a snapshot right away")
+	assert.Len(t, r.replicas, 1)
+	assert.Len(t, r.Info().Replicas, 3)
+	assert.True(t, r.Info().Replicas[2].Waiting)
+
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.replicas) == 3
+	}, time.Second, time.Millisecond)
+	r.Propagate([]Command{{DB: 0, Args: []string{"SET", "a", "1"}}})
+
+	for _, rep := range reps {
+		rep.mu.Lock()
+		out := rep.out
+		rep.mu.Unlock()
+		reader := bufio.NewReader(bytes.NewReader(out))
+		require.NoError(t, skipNewlines(reader))
+		header, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("+FULLRESYNC %s 0\r\n", r.replID), header)
+
+		replica := New(store.NewDatabases(2), 0, func([]protocol.Array, *int) {})
+		t.Cleanup(replica.Close)
+		require.NoError(t, replica.load(&link{}, reader, r.replID, 0))
+		got, _ := replica.dbs.DB(1).Get("k")
+		assert.Equal(t, "v", got)
+		frame, err := protocol.ReadFrame(reader)
+		require.NoError(t, err)
+		_, args, _ := commandArgs(frame)
+		assert.Equal(t, []string{"SELECT", "0"}, args, "the stream follows the snapshot")
+	}
+}
+
+func TestSwapDBLoad(t *testing.T) {
+	t.Parallel()
+	master := store.NewDatabases(1)
+	master.DB(0).Set("new", "v", nil)
+	var snapshot bytes.Buffer
+	require.NoError(t, master.SaveRDB(&snapshot))
+	mark := newReplID()
+	framed := fmt.Appendf(nil, "\n$EOF:%s\r\n%s%s", mark, snapshot.Bytes(), mark)
+
+	dbs := store.NewDatabases(1)
+	dbs.DB(0).Set("old", "v", nil)
+	r := New(dbs, 0, nil)
+	t.Cleanup(r.Close)
+	r.SyncOptions().SwapDBLoad.Store(true)
+	replID := r.replID
+
+	corrupt := append([]byte{}, framed...)
+	corrupt[len(corrupt)-len(mark)-1] ^= 0xff
+	assert.Error(t, r.load(&link{}, bufio.NewReader(bytes.NewReader(corrupt)), newReplID(), 10))
+	_, ok := dbs.DB(0).Get("old")
+	assert.True(t, ok, "a failed load keeps the old data")
+	assert.Equal(t, replID, r.replID, "and its history")
+
+	require.NoError(t, r.load(&link{}, bufio.NewReader(bytes.NewReader(framed)), "8de1787ba490483314a4d30f1c628bc8d26e7a1a", 10))
+	_, ok = dbs.DB(0).Get("old")
+	assert.False(t, ok)
+	_, ok = dbs.DB(0).Get("new")
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), r.offset)
+}
@@ -0,0 +1,144 @@
+package replication
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// ackPeriod is how often a replica acknowledges the offset it reached.
+const ackPeriod = time.Second
+
+// receiveAck records "REPLCONF ACK <offset> [FACK <aofoffset>]" from rep.
+// Anything else a replica sends is ignored.
+func (r *Replication) receiveAck(rep *replica, args []string) {
+	if len(args) < 3 || !strings.EqualFold(args[0], "REPLCONF") || !strings.EqualFold(args[1], "ACK") {
+		return
+	}
+	offset, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return
+	}
+	aofOffset := int64(0)
+	if len(args) == 5 && strings.EqualFold(args[3], "FACK") {
+		aofOffset, _ = strconv.ParseInt(args[4], 10, 64)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep.ackOffset = max(rep.ackOffset, offset)
+	rep.aofOffset = max(rep.aofOffset, aofOffset)
+	rep.ackTime = time.Now()
+	close(r.acked)
+	r.acked = make(chan struct{})
+}
+
+// Wait waits until numReplicas replicas acknowledge offset, or timeout
+// passes unless it is 0, and returns how many did. With aof set the
+// replicas must have written offset to their AOF, which a replica without
+// one never does. Replicas are asked to acknowledge right away rather
+// than at their next periodic acknowledgement.
+func (r *Replication) Wait(numReplicas int, offset int64, timeout time.Duration, aof bool) int {
+	r.exec.Lock()
+	r.mu.Lock()
+	n := r.ackedReplicas(offset, aof)
+	if n < numReplicas && r.master == nil && len(r.replicas) > 0 {
+		r.feed(protocol.AppendCommand(nil, "REPLCONF", "GETACK", "*"))
+	}
+	r.mu.Unlock()
+	r.exec.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		r.mu.Lock()
+		n := r.ackedReplicas(offset, aof)
+		acked := r.acked
+		r.mu.Unlock()
+		if n >= numReplicas {
+			return n
+		}
+		select {
+		case <-acked:
+		case <-expired:
+			return n
+		}
+	}
+}
+
+// ackedReplicas returns how many replicas acknowledged offset. The caller
+// must hold r.mu.
+func (r *Replication) ackedReplicas(offset int64, aof bool) int {
+	n := 0
+	for rep := range r.replicas {
+		acked := rep.ackOffset
+		if aof {
+			acked = rep.aofOffset
+		}
+		if acked >= offset {
+			n++
+		}
+	}
+	return n
+}
+
+// Offset returns the replication offset: how many bytes of the stream the
+// server has produced or, on a replica, applied.
+func (r *Replication) Offset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.offset
+}
+
+// IsReplica reports whether the server is the replica of another.
+func (r *Replication) IsReplica() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.master != nil
+}
+
+// LinkDown reports whether the server is a replica that is not in sync
+// with its master.
+func (r *Replication) LinkDown() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.master != nil && !r.master.up
+}
+
+// sendAck tells the master over conn the offset the replica reached. It
+// has no AOF to report.
+func (r *Replication) sendAck(l *link, conn net.Conn) error {
+	r.mu.Lock()
+	ack := protocol.AppendCommand(nil, "REPLCONF", "ACK", strconv.FormatInt(r.offset, 10))
+	r.mu.Unlock()
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(replTimeout))
+	_, err := conn.Write(ack)
+	return err
+}
+
+// ackPeriodically sends acknowledgements to the master until stop is
+// closed, which lets the master see its replica is alive and lets WAIT
+// return without asking.
+func (r *Replication) ackPeriodically(l *link, conn net.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(ackPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.sendAck(l, conn); err != nil {
+				return
+			}
+		}
+	}
+}
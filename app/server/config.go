@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
+	"github.com/codecrafters-io/redis-starter-go/app/command"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// newConfig returns the parameters of CONFIG GET and SET, bound to the
+// server's databases, RDB file and replication.
+func newConfig(dbs *store.Databases, file *rdb.File, repl *replication.Replication) *config.Config {
+	cfg := config.New()
+	cfg.Register("dir", config.Param{Get: func() string { return file.Dir }})
+	cfg.Register("dbfilename", config.Param{Get: func() string { return file.DBFilename }})
+	cfg.Register("databases", config.Param{Get: func() string { return strconv.Itoa(dbs.Len()) }})
+
+	cfg.Register("maxmemory", config.Param{
+		Get: func() string { return strconv.FormatInt(dbs.MaxMemory(), 10) },
+		Set: func(value string) error {
+			limit, err := config.ParseMemory(value)
+			if err != nil {
+				return err
+			}
+			dbs.SetMaxMemory(limit)
+			// Like Redis, start evicting right away rather than on the
+			// next write. CONFIG SET may run inside MULTI, which holds
+			// the lock eviction takes, so it runs apart.
+			go command.Evict(dbs, repl)
+			return nil
+		},
+	})
+	cfg.Register("maxmemory-policy", config.Param{
+		Get: func() string { return dbs.EvictionPolicy().String() },
+		Set: func(value string) error {
+			policy, ok := store.ParseEvictionPolicy(value)
+			if !ok {
+				return errors.New("argument(s) must be one of the following: volatile-lru, allkeys-lru, volatile-lfu, allkeys-lfu, volatile-random, allkeys-random, volatile-ttl, noeviction")
+			}
+			dbs.SetEvictionPolicy(policy)
+			return nil
+		},
+	})
+	cfg.Register("maxmemory-samples", config.Param{
+		Get: func() string { return strconv.Itoa(dbs.EvictionSamples()) },
+		Set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 64 {
+				return errors.New("argument must be between 1 and 64 inclusive")
+			}
+			dbs.SetEvictionSamples(n)
+			return nil
+		},
+	})
+
+	lazyFree := dbs.LazyFree()
+	for name, option := range map[string]*atomic.Bool{
+		"lazyfree-lazy-eviction":   &lazyFree.Eviction,
+		"lazyfree-lazy-expire":     &lazyFree.Expire,
+		"lazyfree-lazy-server-del": &lazyFree.ServerDel,
+		"lazyfree-lazy-user-del":   &lazyFree.UserDel,
+	} {
+		cfg.Register(name, boolParam(option))
+	}
+
+	options := repl.Options()
+	cfg.Register("replica-read-only", boolParam(&options.ReadOnly))
+	cfg.Register("replica-serve-stale-data", boolParam(&options.ServeStaleData))
+	syncOptions := repl.SyncOptions()
+	cfg.Register("repl-diskless-sync", boolParam(&syncOptions.Diskless))
+	cfg.Register("repl-diskless-sync-delay", config.Param{
+		Get: func() string { return strconv.FormatInt(syncOptions.DisklessDelay.Load()/int64(time.Second), 10) },
+		Set: func(value string) error {
+			delay, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || delay < 0 {
+				return errors.New("argument must be between 0 and 2147483647 inclusive")
+			}
+			syncOptions.DisklessDelay.Store(delay * int64(time.Second))
+			return nil
+		},
+	})
+	cfg.Register("repl-diskless-load", config.Param{
+		Get: func() string {
+			if syncOptions.SwapDBLoad.Load() {
+				return "swapdb"
+			}
+			return "disabled"
+		},
+		Set: func(value string) error {
+			switch strings.ToLower(value) {
+			case "disabled":
+				syncOptions.SwapDBLoad.Store(false)
+			case "swapdb":
+				syncOptions.SwapDBLoad.Store(true)
+			default:
+				return errors.New("argument(s) must be one of the following: disabled, swapdb")
+			}
+			return nil
+		},
+	})
+	cfg.Register("repl-backlog-size", config.Param{
+		Get: func() string { return strconv.Itoa(repl.BacklogSize()) },
+		Set: func(value string) error {
+			size, err := config.ParseMemory(value)
+			if err != nil {
+				return err
+			}
+			if size < 1 {
+				return errors.New("argument must be between 1 and 9223372036854775807 inclusive")
+			}
+			repl.SetBacklogSize(int(size))
+			return nil
+		},
+	})
+	return cfg
+}
+
+// boolParam binds a yes or no parameter to b.
+func boolParam(b *atomic.Bool) config.Param {
+	return config.Param{
+		Get: func() string { return config.FormatBool(b.Load()) },
+		Set: func(value string) error {
+			v, err := config.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			b.Store(v)
+			return nil
+		},
+	}
+}
+
+// registerClusterConfig adds the parameters of cluster mode, bound to cl.
+func registerClusterConfig(cfg *config.Config, cl *cluster.Cluster, configFile string) {
+	cfg.Register("cluster-config-file", config.Param{Get: func() string { return configFile }})
+	options := cl.Options()
+	cfg.Register("cluster-node-timeout", config.Param{
+		Get: func() string { return strconv.FormatInt(options.NodeTimeout.Load()/int64(time.Millisecond), 10) },
+		Set: func(value string) error {
+			timeout, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || timeout <= 0 {
+				return errors.New("argument must be a positive number of milliseconds")
+			}
+			options.NodeTimeout.Store(timeout * int64(time.Millisecond))
+			return nil
+		},
+	})
+}
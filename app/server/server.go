@@ -2,14 +2,21 @@ package server
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"log"
 	"net"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
 	"github.com/codecrafters-io/redis-starter-go/app/command"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/stats"
 	"github.com/codecrafters-io/redis-starter-go/app/store"
 )
 
@@ -17,14 +24,67 @@ type Server struct {
 	listener   net.Listener
 	readerPool *sync.Pool
 	writerPool *sync.Pool
-	store      *store.Store
+	dbs        *store.Databases
 	file       *rdb.File
+	config     *config.Config
+	repl       *replication.Replication
+	stats      *stats.Stats
+	info       command.InfoSources
 }
 
-func NewServer(listener net.Listener, store *store.Store, file *rdb.File) *Server {
+func NewServer(listener net.Listener, dbs *store.Databases, file *rdb.File) *Server {
 	readerPool := sync.Pool{New: func() any { return bufio.NewReaderSize(nil, 4096) }}
 	writerPool := sync.Pool{New: func() any { return bufio.NewWriterSize(nil, 4096) }}
-	return &Server{listener: listener, readerPool: &readerPool, writerPool: &writerPool, store: store, file: file}
+	s := &Server{listener: listener, readerPool: &readerPool, writerPool: &writerPool, dbs: dbs, file: file}
+	port := 0
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		port = addr.Port
+	}
+	s.repl = replication.New(dbs, port, s.applyReplicated)
+	s.config = newConfig(dbs, file, s.repl)
+	s.stats = stats.New()
+	s.info = command.InfoSources{DBs: dbs, File: file, Repl: s.repl, Stats: s.stats, Port: port}
+	s.config.Register("cluster-enabled", config.Param{Get: func() string {
+		if s.info.Cluster != nil {
+			return "yes"
+		}
+		return "no"
+	}})
+	return s
+}
+
+// EnableCluster puts the server in cluster mode, as a node serving clients
+// at ip and the port it listens on, and runs its cluster bus. The node
+// keeps its configuration in configFile, and serves slots if it is new.
+func (s *Server) EnableCluster(ip string, configFile string, nodeTimeout time.Duration, slots []int) error {
+	cl := cluster.New(ip, s.info.Port, s.repl)
+	cl.Options().NodeTimeout.Store(int64(nodeTimeout))
+	loaded, err := cl.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		if err := cl.AddSlots(slots); err != nil {
+			return err
+		}
+	}
+	bus, err := net.Listen("tcp", ":"+strconv.Itoa(s.info.Port+cluster.BusPortOffset))
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := cl.Serve(bus); err != nil {
+			log.Printf("cluster bus: %v", err)
+		}
+	}()
+	s.info.Cluster = cl
+	registerClusterConfig(s.config, cl, configFile)
+	return nil
+}
+
+// ReplicaOf makes the server a replica of the master at host and port.
+func (s *Server) ReplicaOf(host string, port string) {
+	s.repl.ReplicaOf(host, port)
 }
 
 func (s *Server) Accept() (net.Conn, error) {
@@ -32,6 +92,11 @@ func (s *Server) Accept() (net.Conn, error) {
 }
 
 func (s *Server) Close() error {
+	s.stats.Close()
+	s.repl.Close()
+	if s.info.Cluster != nil {
+		s.info.Cluster.Close()
+	}
 	return s.listener.Close()
 }
 
@@ -41,6 +106,8 @@ func (s *Server) Addr() net.Addr {
 
 func (s *Server) HandleConnection(conn net.Conn) {
 	defer conn.Close()
+	s.stats.Connected()
+	defer s.stats.Disconnected()
 
 	reader := s.readerPool.Get().(*bufio.Reader)
 	reader.Reset(conn)
@@ -56,6 +123,16 @@ func (s *Server) HandleConnection(conn net.Conn) {
 		s.writerPool.Put(writer)
 	}()
 
+	// db is the index of the database selected with SELECT.
+	db := 0
+	// listeningPort is the port a replica reported with REPLCONF, and
+	// capaEOF whether it can be sent a diskless sync.
+	listeningPort, capaEOF := 0, false
+	// woff is the replication offset at the end of the last write of the
+	// client, which WAIT waits for.
+	woff := int64(0)
+	// asking is set by ASKING for the next command of the client only.
+	asking := false
 	for {
 		frame, err := protocol.ReadFrame(reader)
 		if err != nil {
@@ -79,6 +156,7 @@ func (s *Server) HandleConnection(conn net.Conn) {
 			continue
 		}
 
+		s.stats.Command()
 		cmd, err := command.FromArray(request)
 		if err != nil {
 			if err := (protocol.Error{Message: err.Error()}.Write(writer)); err != nil {
@@ -88,6 +166,35 @@ func (s *Server) HandleConnection(conn net.Conn) {
 			return
 		}
 
+		wasAsking := asking
+		asking = false
+
+		// Keys are evicted before running any command, but only those
+		// that may use more memory are refused when that fails.
+		if err := command.Evict(s.dbs, s.repl); err != nil && command.DenyOOM(request) {
+			if err := (protocol.Error{Prefix: "OOM", Message: err.Error()}.Write(writer)); err != nil {
+				log.Printf("writing error response: %v", err)
+				return
+			}
+			continue
+		}
+
+		if msg, refused := command.ClusterRefusal(request, s.info.Cluster, s.dbs.DB(db), wasAsking, nil); refused {
+			if err := msg.Write(writer); err != nil {
+				log.Printf("writing error response: %v", err)
+				return
+			}
+			continue
+		}
+
+		if msg, refused := command.ReplicaRefusal(request, s.repl); refused {
+			if err := msg.Write(writer); err != nil {
+				log.Printf("writing error response: %v", err)
+				return
+			}
+			continue
+		}
+
 		switch c := cmd.(type) {
 		case command.PingCommand:
 			res := c.Execute()
@@ -101,26 +208,50 @@ func (s *Server) HandleConnection(conn net.Conn) {
 				log.Printf("writing response: %v", err)
 				return
 			}
-		case command.SetCommand:
-			res := c.Execute(s.store)
+		case command.SelectCommand:
+			res := c.Execute(s.dbs, &db)
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.DatabasesCommand:
+			res := s.run(db, &woff, request, c, func() protocol.Frame { return c.Execute(s.dbs, db) })
 			if err := res.Write(writer); err != nil {
 				log.Printf("writing response: %v", err)
 				return
 			}
-		case command.SetTTLCommand:
-			res := c.Execute(s.store)
+		case command.PersistenceCommand:
+			res := c.Execute(s.dbs, s.file)
 			if err := res.Write(writer); err != nil {
 				log.Printf("writing response: %v", err)
 				return
 			}
-		case command.GetCommand:
-			res := c.Execute(s.store)
+		case command.BlockingCommand:
+			// Blocking commands do not hold up other writes while they
+			// wait, but each attempt runs as a write, so that what it pops
+			// reaches replicas before any write that follows.
+			attempt := func(try func() (protocol.Frame, bool)) (protocol.Frame, bool) {
+				if !command.IsWrite(request) {
+					return try()
+				}
+				s.repl.Lock()
+				defer s.repl.Unlock()
+				res, ok := try()
+				if ok {
+					s.repl.Propagate(command.Writes(db, s.dbs.DB(db), request, c, res))
+					woff = s.repl.Offset()
+				}
+				return res, ok
+			}
+			s.stats.Blocked()
+			res := c.ExecuteBlocking(s.dbs.DB(db), attempt)
+			s.stats.Unblocked()
 			if err := res.Write(writer); err != nil {
 				log.Printf("writing response: %v", err)
 				return
 			}
-		case command.IncrCommand:
-			res := c.Execute(s.store)
+		case command.StoreCommand:
+			res := s.run(db, &woff, request, c, func() protocol.Frame { return c.Execute(s.dbs.DB(db)) })
 			if err := res.Write(writer); err != nil {
 				log.Printf("writing response: %v", err)
 				return
@@ -138,17 +269,82 @@ func (s *Server) HandleConnection(conn net.Conn) {
 				return
 			}
 		case command.MultiCommand:
-			res := c.Execute(reader, writer, s.store, s.file)
+			c.Asking = wasAsking
+			res := c.Execute(reader, writer, s.dbs, &db, s.file, s.config, s.repl, s.info)
+			woff = s.repl.Offset()
 			if err := res.Write(writer); err != nil {
 				log.Printf("writing response: %v", err)
 				return
 			}
 		case command.ConfigCommand:
-			res := c.Execute(s.file)
+			res := c.Execute(s.config)
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.InfoCommand:
+			res := c.Execute(s.info)
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.ClusterCommand:
+			res := c.Execute(s.info.Cluster, s.dbs.DB(db))
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.AskingCommand:
+			asking = true
+			res := c.Execute(s.info.Cluster)
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.ReplicationCommand:
+			res := c.Execute(s.repl)
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.ReplConfCommand:
+			if c.Ack {
+				continue
+			}
+			if c.ListeningPort != 0 {
+				listeningPort = c.ListeningPort
+			}
+			capaEOF = capaEOF || c.CapaEOF
+			if err := (protocol.SimpleString{Value: "OK"}.Write(writer)); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.WaitCommand:
+			res := c.Execute(s.repl, woff)
+			if err := res.Write(writer); err != nil {
+				log.Printf("writing response: %v", err)
+				return
+			}
+		case command.WaitAOFCommand:
+			res := c.Execute(s.repl, woff)
 			if err := res.Write(writer); err != nil {
 				log.Printf("writing response: %v", err)
 				return
 			}
+		case command.PSyncCommand:
+			// From here on the connection belongs to the replica.
+			err := s.repl.Sync(conn, reader, listeningPort, capaEOF, c.ReplID, c.Offset)
+			if err == nil {
+				return
+			}
+			msg := protocol.Error{Message: err.Error()}
+			if errors.Is(err, replication.ErrNoMasterLink) {
+				msg.Prefix = "NOMASTERLINK"
+			}
+			if err := msg.Write(writer); err != nil {
+				log.Printf("writing error response: %v", err)
+				return
+			}
 		default:
 			if err := (protocol.Error{Message: "unknown command"}.Write(writer)); err != nil {
 				log.Printf("writing error response: %v", err)
@@ -157,5 +353,42 @@ func (s *Server) HandleConnection(conn net.Conn) {
 			return
 		}
 	}
+}
+
+// run executes a command, parsed from request, with exec. A write runs
+// ordered with the other writes and is sent to replicas, and woff is set
+// to the replication offset after it.
+func (s *Server) run(db int, woff *int64, request protocol.Array, cmd any, exec func() protocol.Frame) protocol.Frame {
+	if !command.IsWrite(request) {
+		return exec()
+	}
+	s.repl.Lock()
+	defer s.repl.Unlock()
+	res := exec()
+	s.repl.Propagate(command.Writes(db, s.dbs.DB(db), request, cmd, res))
+	*woff = s.repl.Offset()
+	return res
+}
 
+// applyReplicated runs the commands a replica receives from its master.
+// Replies go nowhere and blocking commands do not wait.
+func (s *Server) applyReplicated(requests []protocol.Array, db *int) {
+	for _, request := range requests {
+		cmd, err := command.FromArray(request)
+		if err != nil {
+			log.Printf("replicated command: %v", err)
+			continue
+		}
+		switch c := cmd.(type) {
+		case command.PingCommand:
+		case command.SelectCommand:
+			c.Execute(s.dbs, db)
+		case command.DatabasesCommand:
+			c.Execute(s.dbs, *db)
+		case command.StoreCommand:
+			c.Execute(s.dbs.DB(*db))
+		default:
+			log.Printf("replicated command not supported: %T", cmd)
+		}
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
 	"github.com/codecrafters-io/redis-starter-go/app/store"
 )
 
@@ -21,6 +22,6 @@ func (l *TestListener) Addr() net.Addr {
 
 func TestServer(t *testing.T) {
 	testListener := &TestListener{}
-	server := NewServer(testListener, store.NewStore())
+	server := NewServer(testListener, store.NewDatabases(16), rdb.NewFile(t.TempDir(), "dump.rdb"))
 	defer server.Close()
 }
@@ -0,0 +1,142 @@
+// Package stats collects the server counters INFO reports: clients,
+// commands processed and the rate at which they are.
+package stats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// samplePeriod is how often the command rate is sampled, and opsSamples
+// how many samples instantaneous_ops_per_sec averages, as in Redis.
+const (
+	samplePeriod = 100 * time.Millisecond
+	opsSamples   = 16
+)
+
+// Stats are the counters of a server.
+type Stats struct {
+	// RunID identifies the run of the server, Start when it started.
+	RunID string
+	Start time.Time
+
+	clients          atomic.Int64
+	totalConnections atomic.Int64
+	blocked          atomic.Int64
+	commands         atomic.Int64
+
+	// mu guards the ring of command rates, in operations per second, and
+	// what the last sample was taken from.
+	mu           sync.Mutex
+	rates        [opsSamples]int64
+	idx          int
+	lastCommands int64
+	lastSample   time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns the counters of a server starting now and starts sampling
+// the command rate until Close.
+func New() *Stats {
+	var id [20]byte
+	rand.Read(id[:])
+	now := time.Now()
+	s := &Stats{RunID: hex.EncodeToString(id[:]), Start: now, lastSample: now, done: make(chan struct{})}
+	ticker := time.NewTicker(samplePeriod)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.sample(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// Close stops sampling the command rate.
+func (s *Stats) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Connected records a client connecting, and Disconnected one leaving.
+func (s *Stats) Connected() {
+	s.clients.Add(1)
+	s.totalConnections.Add(1)
+}
+
+func (s *Stats) Disconnected() {
+	s.clients.Add(-1)
+}
+
+// Blocked records a client blocking in a command, and Unblocked it
+// returning.
+func (s *Stats) Blocked() {
+	s.blocked.Add(1)
+}
+
+func (s *Stats) Unblocked() {
+	s.blocked.Add(-1)
+}
+
+// Command records a command processed.
+func (s *Stats) Command() {
+	s.commands.Add(1)
+}
+
+// Clients returns the number of connected clients.
+func (s *Stats) Clients() int64 {
+	return s.clients.Load()
+}
+
+// TotalConnections returns the number of connections accepted.
+func (s *Stats) TotalConnections() int64 {
+	return s.totalConnections.Load()
+}
+
+// BlockedClients returns the number of clients blocked in a command.
+func (s *Stats) BlockedClients() int64 {
+	return s.blocked.Load()
+}
+
+// Commands returns the number of commands processed.
+func (s *Stats) Commands() int64 {
+	return s.commands.Load()
+}
+
+// Uptime returns how long the server has been running.
+func (s *Stats) Uptime() time.Duration {
+	return time.Since(s.Start)
+}
+
+// OpsPerSec returns the number of commands processed per second over the
+// last samples.
+func (s *Stats) OpsPerSec() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sum int64
+	for _, rate := range s.rates {
+		sum += rate
+	}
+	return sum / opsSamples
+}
+
+// sample records the command rate since the previous sample.
+func (s *Stats) sample(now time.Time) {
+	commands := s.Commands()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elapsed := now.Sub(s.lastSample); elapsed > 0 {
+		s.rates[s.idx] = (commands - s.lastCommands) * int64(time.Second) / int64(elapsed)
+		s.idx = (s.idx + 1) % opsSamples
+	}
+	s.lastCommands, s.lastSample = commands, now
+}
@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClients(t *testing.T) {
+	t.Parallel()
+	s := New()
+	t.Cleanup(s.Close)
+	s.Connected()
+	s.Connected()
+	s.Disconnected()
+	s.Blocked()
+	assert.Equal(t, int64(1), s.Clients())
+	assert.Equal(t, int64(2), s.TotalConnections())
+	assert.Equal(t, int64(1), s.BlockedClients())
+	s.Unblocked()
+	assert.Zero(t, s.BlockedClients())
+	assert.Len(t, s.RunID, 40)
+}
+
+func TestOpsPerSec(t *testing.T) {
+	t.Parallel()
+	s := &Stats{}
+	start := time.Now()
+	s.lastSample = start
+	for i := range opsSamples {
+		for range 50 {
+			s.Command()
+		}
+		s.sample(start.Add(time.Duration(i+1) * samplePeriod))
+	}
+	assert.Equal(t, int64(500), s.OpsPerSec())
+	assert.Equal(t, int64(50*opsSamples), s.Commands())
+
+	// Idle samples bring the rate down.
+	for i := range opsSamples / 2 {
+		s.sample(start.Add(time.Duration(opsSamples+i+1) * samplePeriod))
+	}
+	assert.Equal(t, int64(250), s.OpsPerSec())
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	s := New()
+	s.Close()
+	s.Close()
+	s.mu.Lock()
+	last := s.lastSample
+	s.mu.Unlock()
+	time.Sleep(3 * samplePeriod)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, last, s.lastSample, "no samples are taken once closed")
+}
@@ -0,0 +1,470 @@
+package store
+
+import (
+	"math"
+	"math/bits"
+)
+
+// MaxBitOffset is the greatest bit offset of a string, which is capped at 512MB.
+const MaxBitOffset = 512*1024*1024*8 - 1
+
+// stringAt returns the string entry at key. The caller must hold s.mu.
+func (s *Store) stringAt(key string) (Entry, bool, error) {
+	entry, ok := s.lookup(key)
+	if !ok {
+		return Entry{}, false, nil
+	}
+	if entry.Kind != KindString {
+		return Entry{}, false, ErrWrongType
+	}
+	return entry, true, nil
+}
+
+// growString returns the bytes of value zero-padded to at least size bytes.
+func growString(value string, size uint64) []byte {
+	buf := make([]byte, max(uint64(len(value)), size))
+	copy(buf, value)
+	return buf
+}
+
+// SetBit sets the bit at offset to bit, growing the string as needed, and
+// returns the previous bit. The key keeps its TTL.
+func (s *Store) SetBit(key string, offset uint64, bit int) (int, error) {
+	if offset > MaxBitOffset {
+		panic("bit offset out of range")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, _, err := s.stringAt(key)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := growString(entry.Value, offset/8+1)
+	mask := byte(1) << (7 - offset%8)
+	old := 0
+	if buf[offset/8]&mask != 0 {
+		old = 1
+	}
+	if bit == 1 {
+		buf[offset/8] |= mask
+	} else {
+		buf[offset/8] &^= mask
+	}
+	s.setKey(key, Entry{Value: string(buf), TTL: entry.TTL})
+	return old, nil
+}
+
+func (s *Store) GetBit(key string, offset uint64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, _, err := s.stringAt(key)
+	if err != nil {
+		return 0, err
+	}
+	if offset/8 >= uint64(len(entry.Value)) {
+		return 0, nil
+	}
+	return int(entry.Value[offset/8]>>(7-offset%8)) & 1, nil
+}
+
+// BitRange is the optional range of BITCOUNT and BITPOS. Start and End are
+// inclusive and may be negative to count from the end; they index bits when
+// Bit is set and bytes otherwise. A nil End stands for the end of the string.
+type BitRange struct {
+	Start int64
+	End   *int64
+	Bit   bool
+}
+
+// resolveBitRange clamps r to a string of length bytes and returns the byte
+// range it covers along with masks of the bits of the first and last bytes
+// that fall outside it.
+func resolveBitRange(r BitRange, length int) (start int64, end int64, firstMask byte, lastMask byte) {
+	total := int64(length)
+	if r.Bit {
+		total *= 8
+	}
+	start, end = r.Start, total-1
+	if r.End != nil {
+		end = *r.End
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), min(max(end, 0), total-1)
+
+	if r.Bit && start <= end {
+		firstMask = ^byte(0xff >> (start & 7))
+		lastMask = byte(0xff >> (end&7 + 1))
+		start, end = start>>3, end>>3
+	}
+	return start, end, firstMask, lastMask
+}
+
+// BitCount counts the set bits of the string at key, within r when not nil.
+func (s *Store) BitCount(key string, r *BitRange) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok, err := s.stringAt(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	value := entry.Value
+	if r == nil {
+		return popcount(value), nil
+	}
+	start, end, firstMask, lastMask := resolveBitRange(*r, len(value))
+	if start > end {
+		return 0, nil
+	}
+	count := popcount(value[start : end+1])
+	count -= bits.OnesCount8(value[start] & firstMask)
+	count -= bits.OnesCount8(value[end] & lastMask)
+	return count, nil
+}
+
+func popcount(value string) int {
+	count := 0
+	for i := 0; i < len(value); i++ {
+		count += bits.OnesCount8(value[i])
+	}
+	return count
+}
+
+// BitPos returns the position of the first bit set to bit in the string at
+// key, within r when not nil, or -1 when there is none. Looking for a clear
+// bit without an explicit end finds the first bit past the string when every
+// bit is set, as if the string were padded with zeros.
+func (s *Store) BitPos(key string, bit int, r *BitRange) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok, err := s.stringAt(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	value := entry.Value
+	if r == nil {
+		r = &BitRange{}
+	}
+	start, end, firstMask, lastMask := resolveBitRange(*r, len(value))
+	if start > end {
+		return -1, nil
+	}
+
+	// Force the bits outside the range to the opposite of the wanted bit so
+	// they are never reported.
+	buf := []byte(value[start : end+1])
+	if bit == 1 {
+		buf[0] &^= firstMask
+		buf[len(buf)-1] &^= lastMask
+	} else {
+		buf[0] |= firstMask
+		buf[len(buf)-1] |= lastMask
+	}
+
+	pos := int64(-1)
+	for i, b := range buf {
+		if bit == 0 {
+			b = ^b
+		}
+		if b != 0 {
+			pos = int64(i)*8 + int64(bits.LeadingZeros8(b))
+			break
+		}
+	}
+	if pos == -1 {
+		if bit == 1 || r.End != nil {
+			return -1, nil
+		}
+		pos = int64(len(buf)) * 8
+	}
+	return start*8 + pos, nil
+}
+
+// BitOpKind is the operation of BITOP.
+type BitOpKind int
+
+const (
+	BitOpAnd BitOpKind = iota
+	BitOpOr
+	BitOpXor
+	BitOpNot
+	// BitOpDiff keeps the bits of the first key set in none of the others.
+	BitOpDiff
+)
+
+// BitOp stores the result of op over the strings at keys at destination and
+// returns its length. Missing keys are treated as empty strings, shorter
+// strings are zero-padded, and an empty result deletes destination.
+func (s *Store) BitOp(op BitOpKind, destination string, keys []string) (int, error) {
+	if len(keys) == 0 || (op == BitOpNot && len(keys) != 1) || (op == BitOpDiff && len(keys) < 2) {
+		panic("invalid number of BITOP source keys")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]string, len(keys))
+	length := 0
+	for i, key := range keys {
+		entry, _, err := s.stringAt(key)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = entry.Value
+		length = max(length, len(entry.Value))
+	}
+
+	if length == 0 {
+		s.deleteKey(destination)
+		return 0, nil
+	}
+
+	result := growString(values[0], uint64(length))
+	switch op {
+	case BitOpNot:
+		for i := range result {
+			result[i] = ^result[i]
+		}
+	case BitOpDiff:
+		for _, value := range values[1:] {
+			for i := 0; i < len(value); i++ {
+				result[i] &^= value[i]
+			}
+		}
+	default:
+		for _, value := range values[1:] {
+			for i := range result {
+				var b byte
+				if i < len(value) {
+					b = value[i]
+				}
+				switch op {
+				case BitOpAnd:
+					result[i] &= b
+				case BitOpOr:
+					result[i] |= b
+				case BitOpXor:
+					result[i] ^= b
+				default:
+					panic("unknown bit operation")
+				}
+			}
+		}
+	}
+	s.setKey(destination, Entry{Value: string(result)})
+	return length, nil
+}
+
+// BitFieldType is a BITFIELD integer type such as i8 or u16.
+type BitFieldType struct {
+	Signed bool
+	Bits   uint
+}
+
+// BitFieldOverflow is the overflow behaviour of BITFIELD SET and INCRBY.
+type BitFieldOverflow int
+
+const (
+	BitFieldWrap BitFieldOverflow = iota
+	BitFieldSat
+	BitFieldFail
+)
+
+type BitFieldOpKind int
+
+const (
+	BitFieldGet BitFieldOpKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOp is one BITFIELD operation. Value is the value to set or the
+// increment, and Overflow applies to SET and INCRBY.
+type BitFieldOp struct {
+	Kind     BitFieldOpKind
+	Type     BitFieldType
+	Offset   uint64
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+// BitField runs ops in order against the string at key and returns one value
+// per op: the value read by GET, the previous value for SET and the new value
+// for INCRBY. ok is false for writes refused by the FAIL overflow mode. When
+// ops write, the string is first grown to fit every written field.
+func (s *Store) BitField(key string, ops []BitFieldOp) ([]int64, []bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, _, err := s.stringAt(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var size uint64
+	writes := false
+	for _, op := range ops {
+		if op.Kind != BitFieldGet {
+			writes = true
+			size = max(size, (op.Offset+uint64(op.Type.Bits)-1)/8+1)
+		}
+	}
+	buf := []byte(entry.Value)
+	if writes {
+		buf = growString(entry.Value, size)
+	}
+
+	values := make([]int64, len(ops))
+	oks := make([]bool, len(ops))
+	for i, op := range ops {
+		current := getBitField(buf, op.Offset, op.Type)
+		if op.Kind == BitFieldGet {
+			values[i], oks[i] = current, true
+			continue
+		}
+
+		next, ok := op.Value, true
+		if op.Kind == BitFieldIncrBy {
+			next, ok = bitFieldAdd(current, op.Value, op.Type, op.Overflow)
+		} else {
+			next, ok = bitFieldAdd(op.Value, 0, op.Type, op.Overflow)
+		}
+		if !ok {
+			continue
+		}
+		setBitField(buf, op.Offset, op.Type, next)
+		oks[i] = true
+		if op.Kind == BitFieldSet {
+			values[i] = current
+		} else {
+			values[i] = next
+		}
+	}
+
+	if writes {
+		s.setKey(key, Entry{Value: string(buf), TTL: entry.TTL})
+	}
+	return values, oks, nil
+}
+
+// getBitField reads the field of type t at offset, treating bits past the
+// end of buf as zero.
+func getBitField(buf []byte, offset uint64, t BitFieldType) int64 {
+	var v uint64
+	for i := range uint64(t.Bits) {
+		pos := offset + i
+		var bit uint64
+		if pos/8 < uint64(len(buf)) {
+			bit = uint64(buf[pos/8]>>(7-pos%8)) & 1
+		}
+		v = v<<1 | bit
+	}
+	if t.Signed && t.Bits < 64 && v&(1<<(t.Bits-1)) != 0 {
+		v |= math.MaxUint64 << t.Bits
+	}
+	return int64(v)
+}
+
+func setBitField(buf []byte, offset uint64, t BitFieldType, value int64) {
+	v := uint64(value)
+	for i := range uint64(t.Bits) {
+		pos := offset + i
+		mask := byte(1) << (7 - pos%8)
+		if v>>(uint64(t.Bits)-1-i)&1 != 0 {
+			buf[pos/8] |= mask
+		} else {
+			buf[pos/8] &^= mask
+		}
+	}
+}
+
+// bitFieldAdd adds incr to value within the range of t, handling overflow as
+// Redis does. It returns false when overflow is BitFieldFail and the result
+// does not fit.
+func bitFieldAdd(value int64, incr int64, t BitFieldType, overflow BitFieldOverflow) (int64, bool) {
+	if t.Signed {
+		return signedBitFieldAdd(value, incr, t.Bits, overflow)
+	}
+	return unsignedBitFieldAdd(uint64(value), incr, t.Bits, overflow)
+}
+
+func unsignedBitFieldAdd(value uint64, incr int64, width uint, overflow BitFieldOverflow) (int64, bool) {
+	upper := uint64(1)<<width - 1
+	maxIncr := int64(upper - value)
+	minIncr := -int64(value)
+
+	var limit uint64
+	switch {
+	case value > upper || (incr > 0 && incr > maxIncr):
+		limit = upper
+	case incr < 0 && incr < minIncr:
+		limit = 0
+	default:
+		return int64(value + uint64(incr)), true
+	}
+
+	switch overflow {
+	case BitFieldWrap:
+		return int64((value + uint64(incr)) & upper), true
+	case BitFieldSat:
+		return int64(limit), true
+	default:
+		return 0, false
+	}
+}
+
+func signedBitFieldAdd(value int64, incr int64, width uint, overflow BitFieldOverflow) (int64, bool) {
+	upper := int64(math.MaxInt64)
+	if width < 64 {
+		upper = int64(1)<<(width-1) - 1
+	}
+	lower := -upper - 1
+	maxIncr := int64(uint64(upper) - uint64(value))
+	minIncr := lower - value
+
+	var limit int64
+	switch {
+	case value > upper || (width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		limit = upper
+	case value < lower || (width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		limit = lower
+	default:
+		return value + incr, true
+	}
+
+	switch overflow {
+	case BitFieldWrap:
+		sum := uint64(value) + uint64(incr)
+		if width < 64 {
+			mask := uint64(math.MaxUint64) << width
+			if sum&(1<<(width-1)) != 0 {
+				sum |= mask
+			} else {
+				sum &^= mask
+			}
+		}
+		return int64(sum), true
+	case BitFieldSat:
+		return limit, true
+	default:
+		return 0, false
+	}
+}
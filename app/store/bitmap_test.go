@@ -0,0 +1,185 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestStoreSetBitKeepsTTL(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	ttl := time.Hour
+	s.Set("k", "", &ttl)
+
+	old, err := s.SetBit("k", 9, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, old)
+	value, _ := s.Get("k")
+	assert.Equal(t, "\x00\x40", value)
+	entry, _ := s.store.get("k")
+	assert.False(t, entry.TTL.IsZero())
+
+	bit, _ := s.GetBit("k", 9)
+	assert.Equal(t, 1, bit)
+	bit, _ = s.GetBit("k", 1000)
+	assert.Equal(t, 0, bit)
+}
+
+func TestStoreBitCount(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("k", "foobar", nil)
+	cases := []struct {
+		name string
+		r    *BitRange
+		want int
+	}{
+		{"whole", nil, 26},
+		{"bytes", &BitRange{Start: 1, End: int64Ptr(1)}, 6},
+		{"negative bytes", &BitRange{Start: -2, End: int64Ptr(-1)}, 7},
+		{"bits", &BitRange{Start: 5, End: int64Ptr(30), Bit: true}, 17},
+		{"empty", &BitRange{Start: 4, End: int64Ptr(2)}, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := s.BitCount("k", tc.r)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStoreBitPos(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("mixed", "\xff\xf0\x00", nil)
+	s.Set("ones", "\xff\xff\xff", nil)
+	cases := []struct {
+		name string
+		key  string
+		bit  int
+		r    *BitRange
+		want int64
+	}{
+		{"first clear", "mixed", 0, nil, 12},
+		{"first set from byte", "mixed", 1, &BitRange{Start: 1}, 8},
+		{"none set in range", "mixed", 1, &BitRange{Start: 2}, -1},
+		{"bit range", "mixed", 1, &BitRange{Start: 13, End: int64Ptr(-1), Bit: true}, -1},
+		{"clear past end", "ones", 0, nil, 24},
+		{"clear with explicit end", "ones", 0, &BitRange{Start: 0, End: int64Ptr(-1)}, -1},
+		{"missing key clear", "missing", 0, nil, 0},
+		{"missing key set", "missing", 1, nil, -1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := s.BitPos(tc.key, tc.bit, tc.r)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStoreBitOp(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		op   BitOpKind
+		keys []string
+		want string
+	}{
+		{"and pads", BitOpAnd, []string{"a", "b"}, "\x0f\x00"},
+		{"or", BitOpOr, []string{"a", "b"}, "\xff\xff"},
+		{"xor", BitOpXor, []string{"a", "b"}, "\xf0\xff"},
+		{"not", BitOpNot, []string{"b"}, "\xf0"},
+		{"diff", BitOpDiff, []string{"a", "b", "missing"}, "\xf0\xff"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			s.Set("a", "\xff\xff", nil)
+			s.Set("b", "\x0f", nil)
+			length, err := s.BitOp(tc.op, "dst", tc.keys)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.want), length)
+			got, _ := s.Get("dst")
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	s := NewStore()
+	s.Set("dst", "x", nil)
+	length, err := s.BitOp(BitOpOr, "dst", []string{"missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, length)
+	_, ok := s.Get("dst")
+	assert.False(t, ok, "an empty result deletes the destination")
+}
+
+func TestStoreBitField(t *testing.T) {
+	t.Parallel()
+	i8 := BitFieldType{Signed: true, Bits: 8}
+	u2 := BitFieldType{Bits: 2}
+	i64 := BitFieldType{Signed: true, Bits: 64}
+	cases := []struct {
+		name    string
+		ops     []BitFieldOp
+		want    []int64
+		wantOKs []bool
+	}{
+		{
+			name: "wrap sat fail",
+			ops: []BitFieldOp{
+				{Kind: BitFieldSet, Type: i8, Value: 100},
+				{Kind: BitFieldIncrBy, Type: i8, Value: 100},
+				{Kind: BitFieldIncrBy, Type: i8, Value: 100, Overflow: BitFieldSat},
+				{Kind: BitFieldIncrBy, Type: i8, Value: 100, Overflow: BitFieldFail},
+				{Kind: BitFieldGet, Type: BitFieldType{Bits: 4}},
+			},
+			want:    []int64{0, -56, 44, 0, 2},
+			wantOKs: []bool{true, true, true, false, true},
+		},
+		{
+			name: "unsigned",
+			ops: []BitFieldOp{
+				{Kind: BitFieldSet, Type: u2, Offset: 100, Value: 5},
+				{Kind: BitFieldIncrBy, Type: u2, Offset: 100, Value: -2, Overflow: BitFieldSat},
+				{Kind: BitFieldIncrBy, Type: u2, Offset: 100, Value: 7, Overflow: BitFieldWrap},
+			},
+			want:    []int64{0, 0, 3},
+			wantOKs: []bool{true, true, true},
+		},
+		{
+			name: "i64 wrap",
+			ops: []BitFieldOp{
+				{Kind: BitFieldIncrBy, Type: i64, Value: 1<<63 - 1},
+				{Kind: BitFieldIncrBy, Type: i64, Value: 1<<63 - 1},
+			},
+			want:    []int64{1<<63 - 1, -2},
+			wantOKs: []bool{true, true},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			got, oks, err := s.BitField("k", tc.ops)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantOKs, oks)
+		})
+	}
+
+	s := NewStore()
+	s.BitField("k", []BitFieldOp{{Kind: BitFieldGet, Type: i8}})
+	_, ok := s.Get("k")
+	assert.False(t, ok, "GET alone does not create the key")
+}
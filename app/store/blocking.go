@@ -0,0 +1,51 @@
+package store
+
+// Watch registers interest in keys and returns a channel that receives a value
+// once any of them is written by a command that may serve blocked clients,
+// such as ZADD. The returned cancel function must be called to unregister.
+//
+// Callers register before checking for data so that a write landing between
+// the check and the wait is not lost.
+func (s *Store) Watch(keys []string) (<-chan struct{}, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wake := make(chan struct{}, 1)
+	for _, key := range keys {
+		s.waiters[key] = append(s.waiters[key], wake)
+	}
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, key := range keys {
+			s.removeWaiter(key, wake)
+		}
+	}
+	return wake, cancel
+}
+
+func (s *Store) removeWaiter(key string, wake chan struct{}) {
+	waiters := s.waiters[key]
+	for i, w := range waiters {
+		if w == wake {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(s.waiters, key)
+		return
+	}
+	s.waiters[key] = waiters
+}
+
+// signal wakes every client watching key. The caller must hold s.mu.
+func (s *Store) signal(key string) {
+	for _, wake := range s.waiters[key] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
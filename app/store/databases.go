@@ -0,0 +1,168 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSameDB is returned when MOVE targets the database the key is in.
+var ErrSameDB = errors.New("source and destination objects are the same")
+
+// Databases are the numbered keyspaces of a server, which clients switch
+// between with SELECT. Each is a Store of its own; SWAPDB exchanges their
+// contents rather than the Stores so that clients blocked on a database
+// keep watching the same index.
+type Databases struct {
+	dbs []*Store
+
+	maxMemory atomic.Int64
+	policy    atomic.Int32
+	samples   atomic.Int32
+	evicted   atomic.Int64
+	// evictMu serializes evictions and guards the eviction pool, which is
+	// shared by every database, and the next database of random policies.
+	evictMu sync.Mutex
+	pool    evictionPool
+	nextDB  int
+}
+
+func NewDatabases(n int) *Databases {
+	dbs := make([]*Store, n)
+	lazyFree := &LazyFreeOptions{}
+	stats := &keyspaceStats{}
+	for i := range dbs {
+		dbs[i] = NewStore()
+		dbs[i].lazyFree = lazyFree
+		dbs[i].stats = stats
+	}
+	d := &Databases{dbs: dbs}
+	d.samples.Store(DefaultEvictionSamples)
+	return d
+}
+
+func (d *Databases) Len() int {
+	return len(d.dbs)
+}
+
+// DB returns database i, which must be below Len.
+func (d *Databases) DB(i int) *Store {
+	return d.dbs[i]
+}
+
+// lockPair locks databases i and j in index order so that concurrent calls
+// on the same pair cannot deadlock, and returns the unlock function.
+func (d *Databases) lockPair(i int, j int) func() {
+	if i == j {
+		d.dbs[i].mu.Lock()
+		return d.dbs[i].mu.Unlock
+	}
+	first, second := d.dbs[min(i, j)], d.dbs[max(i, j)]
+	first.mu.Lock()
+	second.mu.Lock()
+	return func() {
+		second.mu.Unlock()
+		first.mu.Unlock()
+	}
+}
+
+// Move moves key with its expiry from database src to dst and reports
+// whether it did, which it doesn't when key is missing from src or
+// already exists in dst.
+func (d *Databases) Move(src int, dst int, key string) (bool, error) {
+	if src == dst {
+		return false, ErrSameDB
+	}
+	defer d.lockPair(src, dst)()
+
+	from, to := d.dbs[src], d.dbs[dst]
+	entry, ok := from.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	if _, ok := to.peek(key); ok {
+		return false, nil
+	}
+	from.unlinkKey(key)
+	to.setKey(key, entry)
+	to.signal(key)
+	return true, nil
+}
+
+// Swap exchanges the contents of databases i and j. Clients blocked on
+// either are woken to check their keys again.
+func (d *Databases) Swap(i int, j int) {
+	defer d.lockPair(i, j)()
+	if i == j {
+		return
+	}
+
+	a, b := d.dbs[i], d.dbs[j]
+	a.store, b.store = b.store, a.store
+	a.expires, b.expires = b.expires, a.expires
+	a.mem, b.mem = b.mem, a.mem
+	for key := range a.waiters {
+		a.signal(key)
+	}
+	for key := range b.waiters {
+		b.signal(key)
+	}
+}
+
+// Exchange exchanges the contents of every database with that of the same
+// index in other, which must have as many, as SWAPDB does with two
+// databases. Clients blocked on the databases are woken to check their
+// keys again.
+func (d *Databases) Exchange(other *Databases) {
+	for i, a := range d.dbs {
+		b := other.dbs[i]
+		a.mu.Lock()
+		b.mu.Lock()
+		a.store, b.store = b.store, a.store
+		a.expires, b.expires = b.expires, a.expires
+		a.mem, b.mem = b.mem, a.mem
+		for key := range a.waiters {
+			a.signal(key)
+		}
+		b.mu.Unlock()
+		a.mu.Unlock()
+	}
+}
+
+// LazyFree returns the lazy freeing options of the databases.
+func (d *Databases) LazyFree() *LazyFreeOptions {
+	return d.dbs[0].lazyFree
+}
+
+// FlushAll removes every key of every database, freeing them in the
+// background when async is set.
+func (d *Databases) FlushAll(async bool) {
+	for _, db := range d.dbs {
+		db.Flush(async)
+	}
+}
+
+// DBSize returns the number of keys, counting those that have expired but
+// were not reclaimed yet.
+func (s *Store) DBSize() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.len()
+}
+
+// Flush removes every key. With async the old keyspace is handed to the
+// reclaiming goroutine, so flushing is constant time; otherwise its values
+// are freed before returning.
+func (s *Store) Flush(async bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.store
+	if async {
+		freer.enqueue(old.len(), func() { releaseAll(old) })
+	} else {
+		releaseAll(old)
+	}
+	s.store = newDict[Entry]()
+	s.expires = newDict[struct{}]()
+	s.mem = &memAccount{}
+}
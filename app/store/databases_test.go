@@ -0,0 +1,110 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabasesMove(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(4)
+	ttl := time.Hour
+	dbs.DB(0).Set("k", "v", &ttl)
+	dbs.DB(0).Set("taken", "a", nil)
+	dbs.DB(2).Set("taken", "b", nil)
+
+	moved, err := dbs.Move(0, 2, "k")
+	assert.NoError(t, err)
+	assert.True(t, moved)
+	_, ok := dbs.DB(0).Get("k")
+	assert.False(t, ok)
+	entry, ok := dbs.DB(2).store.get("k")
+	assert.True(t, ok)
+	assert.Equal(t, "v", entry.Value)
+	assert.False(t, entry.TTL.IsZero())
+
+	moved, err = dbs.Move(0, 2, "taken")
+	assert.NoError(t, err)
+	assert.False(t, moved)
+	got, _ := dbs.DB(0).Get("taken")
+	assert.Equal(t, "a", got)
+
+	moved, err = dbs.Move(0, 1, "missing")
+	assert.NoError(t, err)
+	assert.False(t, moved)
+
+	_, err = dbs.Move(1, 1, "k")
+	assert.ErrorIs(t, err, ErrSameDB)
+}
+
+func TestDatabasesSwap(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(2)
+	dbs.DB(0).Set("a", "0", nil)
+	dbs.DB(1).Set("b", "1", nil)
+	wake, cancel := dbs.DB(0).Watch([]string{"b"})
+	defer cancel()
+
+	dbs.Swap(0, 1)
+	_, ok := dbs.DB(0).Get("a")
+	assert.False(t, ok)
+	got, _ := dbs.DB(0).Get("b")
+	assert.Equal(t, "1", got)
+	got, _ = dbs.DB(1).Get("a")
+	assert.Equal(t, "0", got)
+
+	select {
+	case <-wake:
+	default:
+		t.Fatal("waiter on the swapped database was not woken")
+	}
+
+	dbs.Swap(1, 1)
+	assert.Equal(t, 1, dbs.DB(1).DBSize())
+}
+
+func TestDatabasesExchange(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(2)
+	dbs.DB(1).Set("old", "1", nil)
+	wake, cancel := dbs.DB(1).Watch([]string{"new"})
+	defer cancel()
+	fresh := NewDatabases(2)
+	fresh.DB(1).Set("new", "1", nil)
+
+	dbs.Exchange(fresh)
+	got, _ := dbs.DB(1).Get("new")
+	assert.Equal(t, "1", got)
+	_, ok := dbs.DB(1).Get("old")
+	assert.False(t, ok)
+	assert.Equal(t, 1, fresh.DB(1).DBSize())
+	assert.Equal(t, int64(0), fresh.DB(0).UsedMemory())
+	assert.Equal(t, dbs.UsedMemory(), fresh.UsedMemory(), "memory is accounted with the keys")
+
+	select {
+	case <-wake:
+	default:
+		t.Fatal("waiter on the exchanged database was not woken")
+	}
+}
+
+func TestDatabasesFlush(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(3)
+	for i := range 3 {
+		dbs.DB(i).Set("k", "v", nil)
+		dbs.DB(i).Set("l", "v", nil)
+	}
+	assert.Equal(t, 2, dbs.DB(1).DBSize())
+
+	dbs.DB(1).Flush(false)
+	assert.Equal(t, 0, dbs.DB(1).DBSize())
+	assert.Equal(t, 2, dbs.DB(0).DBSize())
+
+	dbs.FlushAll(true)
+	for i := range 3 {
+		assert.Equal(t, 0, dbs.DB(i).DBSize())
+	}
+}
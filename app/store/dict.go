@@ -0,0 +1,315 @@
+package store
+
+import (
+	"hash/maphash"
+	"iter"
+	"math/bits"
+	"math/rand/v2"
+)
+
+// dictInitialSize is the number of buckets of a non-empty dict's first table.
+const dictInitialSize = 4
+
+// dictMinFill is the inverse of the fill ratio below which a dict shrinks.
+const dictMinFill = 8
+
+type dictEntry[V any] struct {
+	key   string
+	value V
+	next  *dictEntry[V]
+}
+
+// dict is a chained hashtable with power-of-two sizes that resizes
+// incrementally, the way Redis's dict does. While rehashing, entries live in
+// both tables and each operation moves one bucket from the first to the
+// second. Unlike a Go map, it supports scan, a stateless cursor iteration
+// that stays correct across resizes.
+type dict[V any] struct {
+	seed   maphash.Seed
+	tables [2][]*dictEntry[V]
+	used   [2]int
+	// rehashIdx is the next bucket of tables[0] to move, or -1 when not rehashing.
+	rehashIdx int
+}
+
+func newDict[V any]() *dict[V] {
+	return &dict[V]{seed: maphash.MakeSeed(), rehashIdx: -1}
+}
+
+func (d *dict[V]) hash(key string) uint64 {
+	return maphash.String(d.seed, key)
+}
+
+func (d *dict[V]) rehashing() bool {
+	return d.rehashIdx != -1
+}
+
+func (d *dict[V]) len() int {
+	return d.used[0] + d.used[1]
+}
+
+// rehashStep moves one bucket to the new table, visiting at most ten empty
+// buckets so that a sparse table doesn't make a single step slow.
+func (d *dict[V]) rehashStep() {
+	if !d.rehashing() {
+		return
+	}
+	if d.used[0] == 0 {
+		d.finishRehash()
+		return
+	}
+	for empty := 10; d.tables[0][d.rehashIdx] == nil; d.rehashIdx++ {
+		if empty--; empty == 0 {
+			return
+		}
+	}
+
+	mask := uint64(len(d.tables[1]) - 1)
+	for e := d.tables[0][d.rehashIdx]; e != nil; {
+		next := e.next
+		i := d.hash(e.key) & mask
+		e.next = d.tables[1][i]
+		d.tables[1][i] = e
+		d.used[0]--
+		d.used[1]++
+		e = next
+	}
+	d.tables[0][d.rehashIdx] = nil
+	d.rehashIdx++
+
+	if d.used[0] == 0 {
+		d.finishRehash()
+	}
+}
+
+// finishRehash makes the new table the only one once the old one is empty.
+func (d *dict[V]) finishRehash() {
+	d.tables[0], d.tables[1] = d.tables[1], nil
+	d.used[0], d.used[1] = d.used[1], 0
+	d.rehashIdx = -1
+}
+
+// resize starts rehashing into a table of at least size buckets.
+func (d *dict[V]) resize(size int) {
+	if d.rehashing() {
+		return
+	}
+	n := dictInitialSize
+	for n < size {
+		n *= 2
+	}
+	if n == len(d.tables[0]) {
+		return
+	}
+	if d.tables[0] == nil {
+		d.tables[0] = make([]*dictEntry[V], n)
+		return
+	}
+	d.tables[1] = make([]*dictEntry[V], n)
+	d.rehashIdx = 0
+}
+
+// find returns the entry holding key, or nil.
+func (d *dict[V]) find(key string) *dictEntry[V] {
+	if d.len() == 0 {
+		return nil
+	}
+	d.rehashStep()
+	h := d.hash(key)
+	for t := range 2 {
+		if d.tables[t] == nil {
+			break
+		}
+		for e := d.tables[t][h&uint64(len(d.tables[t])-1)]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (d *dict[V]) get(key string) (V, bool) {
+	if e := d.find(key); e != nil {
+		return e.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// set inserts or replaces the value of key and reports whether it was added.
+func (d *dict[V]) set(key string, value V) bool {
+	if e := d.find(key); e != nil {
+		e.value = value
+		return false
+	}
+
+	if !d.rehashing() && d.used[0] >= len(d.tables[0]) {
+		d.resize(d.used[0] + 1)
+	}
+	t := 0
+	if d.rehashing() {
+		t = 1
+	}
+	i := d.hash(key) & uint64(len(d.tables[t])-1)
+	d.tables[t][i] = &dictEntry[V]{key: key, value: value, next: d.tables[t][i]}
+	d.used[t]++
+	return true
+}
+
+// delete removes key and reports whether it was present.
+func (d *dict[V]) delete(key string) bool {
+	if d.len() == 0 {
+		return false
+	}
+	d.rehashStep()
+	h := d.hash(key)
+	for t := range 2 {
+		if d.tables[t] == nil {
+			break
+		}
+		for link := &d.tables[t][h&uint64(len(d.tables[t])-1)]; *link != nil; link = &(*link).next {
+			if (*link).key == key {
+				*link = (*link).next
+				d.used[t]--
+				d.shrinkIfNeeded()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *dict[V]) shrinkIfNeeded() {
+	if !d.rehashing() && len(d.tables[0]) > dictInitialSize && d.used[0]*dictMinFill < len(d.tables[0]) {
+		d.resize(d.used[0])
+	}
+}
+
+// all yields every entry. The dict must not be modified while iterating.
+func (d *dict[V]) all() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for t := range 2 {
+			for _, e := range d.tables[t] {
+				for ; e != nil; e = e.next {
+					if !yield(e.key, e.value) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// scan calls fn for the entries of the buckets at cursor and returns the
+// next cursor, 0 once the iteration is complete. The cursor's bits are
+// incremented from the most significant one, so that no bucket is skipped
+// when a resize splits or merges them: every entry present for the whole
+// iteration is returned at least once, though some may be returned twice.
+func (d *dict[V]) scan(cursor uint64, fn func(key string, value V)) uint64 {
+	if d.len() == 0 {
+		return 0
+	}
+	emit := func(bucket *dictEntry[V]) {
+		for e := bucket; e != nil; e = e.next {
+			fn(e.key, e.value)
+		}
+	}
+	next := func(cursor uint64, mask uint64) uint64 {
+		// Set the bits above the mask so that incrementing the reversed
+		// cursor carries into the masked bits only.
+		cursor |= ^mask
+		return bits.Reverse64(bits.Reverse64(cursor) + 1)
+	}
+
+	if !d.rehashing() {
+		mask := uint64(len(d.tables[0]) - 1)
+		emit(d.tables[0][cursor&mask])
+		return next(cursor, mask)
+	}
+
+	small, large := d.tables[0], d.tables[1]
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	m0, m1 := uint64(len(small)-1), uint64(len(large)-1)
+	emit(small[cursor&m0])
+	// Visit every bucket of the larger table that the smaller table's
+	// bucket expands to.
+	for {
+		emit(large[cursor&m1])
+		cursor = next(cursor, m1)
+		if cursor&(m0^m1) == 0 {
+			return cursor
+		}
+	}
+}
+
+// sample calls fn for up to n entries from consecutive buckets starting at
+// a random one, like Redis's dictGetSomeKeys. The entries are not picked
+// independently, but sampling is cheap however large the dict is. Runs of
+// empty buckets make it jump to another random bucket.
+func (d *dict[V]) sample(n int, fn func(key string, value V)) {
+	n = min(n, d.len())
+	if n == 0 {
+		return
+	}
+	for range n {
+		d.rehashStep()
+	}
+
+	tables := 1
+	size := len(d.tables[0])
+	if d.rehashing() {
+		tables = 2
+		size = max(size, len(d.tables[1]))
+	}
+	mask := uint64(size - 1)
+	i := rand.Uint64() & mask
+	found, empty := 0, 0
+	for steps := n * 10; found < n && steps > 0; steps-- {
+		for t := range tables {
+			if i >= uint64(len(d.tables[t])) {
+				continue
+			}
+			e := d.tables[t][i]
+			if e == nil {
+				empty++
+				if empty >= 5 && empty > n {
+					i = rand.Uint64() & mask
+					empty = 0
+				}
+				continue
+			}
+			empty = 0
+			for ; e != nil && found < n; e = e.next {
+				fn(e.key, e.value)
+				found++
+			}
+		}
+		i = (i + 1) & mask
+	}
+}
+
+// randomKey returns a key picked at random among a sample of entries,
+// like Redis's dictGetFairRandomKey.
+func (d *dict[V]) randomKey() (string, bool) {
+	var keys []string
+	d.sample(20, func(key string, _ V) {
+		keys = append(keys, key)
+	})
+	if len(keys) == 0 {
+		return "", false
+	}
+	return keys[rand.IntN(len(keys))], true
+}
+
+// clear removes every entry, emptying the buckets one by one.
+func (d *dict[V]) clear() {
+	for t := range 2 {
+		clear(d.tables[t])
+		d.tables[t] = nil
+		d.used[t] = 0
+	}
+	d.rehashIdx = -1
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDictMatchesMapModel(t *testing.T) {
+	t.Parallel()
+	d := newDict[int]()
+	model := map[string]int{}
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	for i := range 20000 {
+		key := strconv.Itoa(rng.IntN(2000))
+		switch rng.IntN(3) {
+		case 0, 1:
+			_, exists := model[key]
+			assert.Equal(t, !exists, d.set(key, i))
+			model[key] = i
+		case 2:
+			_, exists := model[key]
+			assert.Equal(t, exists, d.delete(key))
+			delete(model, key)
+		}
+		assert.Equal(t, len(model), d.len())
+	}
+
+	for key, value := range model {
+		got, ok := d.get(key)
+		assert.True(t, ok)
+		assert.Equal(t, value, got)
+	}
+	all := map[string]int{}
+	for key, value := range d.all() {
+		all[key] = value
+	}
+	assert.Equal(t, model, all)
+}
+
+func TestDictScanSurvivesResizes(t *testing.T) {
+	t.Parallel()
+	d := newDict[struct{}]()
+	for i := range 1000 {
+		d.set("stable:"+strconv.Itoa(i), struct{}{})
+	}
+
+	// Grow and then shrink the table while scanning: every key present
+	// for the whole iteration must still be returned.
+	seen := map[string]bool{}
+	cursor, step := uint64(0), 0
+	for {
+		cursor = d.scan(cursor, func(key string, _ struct{}) { seen[key] = true })
+		switch {
+		case step < 50:
+			for j := range 200 {
+				d.set("temp:"+strconv.Itoa(step*200+j), struct{}{})
+			}
+		case step < 100:
+			for j := range 200 {
+				d.delete("temp:" + strconv.Itoa((step-50)*200+j))
+			}
+		}
+		step++
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for i := range 1000 {
+		assert.True(t, seen["stable:"+strconv.Itoa(i)], i)
+	}
+}
+
+func TestDictScanEmpty(t *testing.T) {
+	t.Parallel()
+	d := newDict[int]()
+	assert.Equal(t, uint64(0), d.scan(0, func(string, int) { t.Fail() }))
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+var (
+	ErrBusyKey       = errors.New("Target key name already exists.")
+	ErrBadDataFormat = errors.New("Bad data format")
+)
+
+// Dump serializes the value at key as Redis's DUMP does: its RDB type and
+// encoding, then the format version and a checksum. It returns it with the
+// time the key expires at, or zero.
+func (s *Store) Dump(key string) ([]byte, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.lookup(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	var buf bytes.Buffer
+	enc := rdb.NewEncoder(&buf)
+	enc.WriteType(valueType(entry))
+	writeObject(enc, entry)
+	enc.WriteDumpFooter()
+	return buf.Bytes(), entry.TTL, true
+}
+
+// RestoreOptions are the options of RESTORE. The key expires at TTL unless
+// it is zero, and an existing key is only overwritten with Replace. Idle
+// and Freq set the access metadata of the key when not nil.
+type RestoreOptions struct {
+	TTL     time.Time
+	Replace bool
+	Idle    *time.Duration
+	Freq    *uint8
+}
+
+// Restore creates key from a Dump payload. When the TTL has already
+// passed, the value is not created but a replaced key is still deleted.
+func (s *Store) Restore(key string, payload []byte, opts RestoreOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(key); ok && !opts.Replace {
+		return ErrBusyKey
+	}
+	body, err := rdb.VerifyDumpPayload(payload)
+	if err != nil {
+		return err
+	}
+	dec := rdb.NewDecoder(bytes.NewReader(body))
+	typ, err := dec.ReadByte()
+	if err != nil {
+		return ErrBadDataFormat
+	}
+	entry, ok, err := readValue(dec, typ)
+	if err != nil || !ok || !dec.AtEOF() {
+		return ErrBadDataFormat
+	}
+
+	s.deleteKey(key)
+	now := time.Now()
+	entry.TTL = opts.TTL
+	if entry.expired(now) {
+		return nil
+	}
+	entry.Access, entry.Freq = now, lfuInitVal
+	if opts.Idle != nil {
+		entry.Access = now.Add(-*opts.Idle)
+	}
+	if opts.Freq != nil {
+		entry.Freq = *opts.Freq
+	}
+	s.setKey(key, entry)
+	s.signal(key)
+	return nil
+}
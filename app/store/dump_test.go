@@ -0,0 +1,126 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redisDocsPayload is the DUMP of the value 10 shown in the Redis
+// documentation, written with RDB version 9.
+var redisDocsPayload = []byte("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n")
+
+func TestStoreRestoreRedisPayload(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	require.NoError(t, s.Restore("k", redisDocsPayload, RestoreOptions{}))
+	got, ok := s.Get("k")
+	assert.True(t, ok)
+	assert.Equal(t, "10", got)
+
+	payload, ttl, ok := s.Dump("k")
+	assert.True(t, ok)
+	assert.True(t, ttl.IsZero())
+	assert.Equal(t, []byte("\x00\xc0\n\x0b\x00"), payload[:5])
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	require.NoError(t, s.Restore("expiring", payload, RestoreOptions{TTL: expiry}))
+	_, ttl, ok = s.Dump("expiring")
+	assert.True(t, ok)
+	assert.True(t, expiry.Equal(ttl))
+}
+
+func TestStoreDumpRestoreRoundTrip(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.SAdd("set", []string{"a", "b", "1"})
+	s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{Member: "m", Score: 2}})
+	_, _, err := s.XAdd("stream", StreamIDSpec{ID: StreamID{Ms: 5}}, []string{"f", "v"}, false, nil)
+	require.NoError(t, err)
+
+	dst := NewStore()
+	for _, key := range []string{"set", "z", "stream"} {
+		payload, _, ok := s.Dump(key)
+		require.True(t, ok)
+		require.NoError(t, dst.Restore(key, payload, RestoreOptions{}))
+	}
+	members, _ := dst.SMembers("set")
+	assert.ElementsMatch(t, []string{"a", "b", "1"}, members)
+	score, ok, _ := dst.ZScore("z", "m")
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, score)
+	entries, _ := dst.XRange("stream", StreamID{}, MaxStreamID, -1, false)
+	assert.Equal(t, []StreamEntry{{ID: StreamID{Ms: 5}, Fields: []string{"f", "v"}}}, entries)
+
+	_, _, ok = s.Dump("missing")
+	assert.False(t, ok)
+}
+
+func TestStoreRestoreErrors(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("k", "old", nil)
+
+	assert.ErrorIs(t, s.Restore("k", redisDocsPayload, RestoreOptions{}), ErrBusyKey)
+
+	corrupt := append([]byte(nil), redisDocsPayload...)
+	corrupt[2] = '7'
+	assert.Error(t, s.Restore("new", corrupt, RestoreOptions{}))
+
+	// A checksum that is right does not make a newer version readable.
+	future := append([]byte("\x00\xc0\n"), 99, 0)
+	future = binary.LittleEndian.AppendUint64(future, rdb.CRC64(0, future))
+	assert.ErrorIs(t, s.Restore("new", future, RestoreOptions{}), rdb.ErrDumpPayload)
+
+	assert.Error(t, s.Restore("new", []byte("short"), RestoreOptions{}))
+
+	require.NoError(t, s.Restore("k", redisDocsPayload, RestoreOptions{TTL: time.Now().Add(time.Minute), Replace: true}))
+	got, _ := s.Get("k")
+	assert.Equal(t, "10", got)
+
+	// An expiry in the past only deletes the replaced key.
+	require.NoError(t, s.Restore("k", redisDocsPayload, RestoreOptions{TTL: time.Now().Add(-time.Minute), Replace: true}))
+	_, ok := s.Get("k")
+	assert.False(t, ok)
+}
+
+// dumpPayload frames body, a value type and its encoding, as a DUMP.
+func dumpPayload(body []byte) []byte {
+	var buf bytes.Buffer
+	enc := rdb.NewEncoder(&buf)
+	enc.WriteRaw(body)
+	enc.WriteDumpFooter()
+	return buf.Bytes()
+}
+
+func TestStoreRestoreOversizedLengths(t *testing.T) {
+	t.Parallel()
+	lengths := map[string][]byte{
+		"64-bit":  binary.BigEndian.AppendUint64([]byte{0x81}, 1<<63),
+		"32-bit":  binary.BigEndian.AppendUint32([]byte{0x80}, 0xfffffff0),
+		"lzf":     append([]byte{0xc3, 0x01}, append(binary.BigEndian.AppendUint32([]byte{0x80}, 0xfffffff0), 0x00)...),
+		"lzf 64":  append([]byte{0xc3}, binary.BigEndian.AppendUint64([]byte{0x81}, 1<<63)...),
+		"lzf neg": append([]byte{0xc3, 0x01}, append(binary.BigEndian.AppendUint64([]byte{0x81}, 1<<63), 0x00)...),
+	}
+	types := []byte{
+		rdb.TypeString, rdb.TypeSet, rdb.TypeSetIntset, rdb.TypeSetListpack,
+		rdb.TypeZSet, rdb.TypeZSet2, rdb.TypeZSetListpack,
+		rdb.TypeStreamListpacks, rdb.TypeStreamListpacks2, rdb.TypeStreamListpacks3,
+	}
+	s := NewStore()
+	for name, length := range lengths {
+		for _, typ := range types {
+			// The length stands for a count as well as for the string
+			// that follows it.
+			body := append([]byte{typ}, length...)
+			body = append(body, length...)
+			assert.ErrorIs(t, s.Restore("k", dumpPayload(body), RestoreOptions{}), ErrBadDataFormat, "%s type %d", name, typ)
+		}
+	}
+	assert.Zero(t, s.DBSize())
+}
@@ -0,0 +1,290 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrOOM is returned for commands that may use more memory while the
+// keyspace is over maxmemory and no key can be evicted.
+var ErrOOM = errors.New("command not allowed when used memory > 'maxmemory'.")
+
+// EvictionPolicy selects the keys evicted when the keyspace grows over
+// maxmemory. Volatile policies only consider keys with a TTL.
+type EvictionPolicy int32
+
+const (
+	NoEviction EvictionPolicy = iota
+	AllKeysLRU
+	AllKeysLFU
+	AllKeysRandom
+	VolatileLRU
+	VolatileLFU
+	VolatileRandom
+	VolatileTTL
+)
+
+var evictionPolicyNames = []string{
+	NoEviction:     "noeviction",
+	AllKeysLRU:     "allkeys-lru",
+	AllKeysLFU:     "allkeys-lfu",
+	AllKeysRandom:  "allkeys-random",
+	VolatileLRU:    "volatile-lru",
+	VolatileLFU:    "volatile-lfu",
+	VolatileRandom: "volatile-random",
+	VolatileTTL:    "volatile-ttl",
+}
+
+func (p EvictionPolicy) String() string {
+	return evictionPolicyNames[p]
+}
+
+// ParseEvictionPolicy returns the policy named name, ignoring case.
+func ParseEvictionPolicy(name string) (EvictionPolicy, bool) {
+	i := slices.Index(evictionPolicyNames, strings.ToLower(name))
+	return EvictionPolicy(i), i >= 0
+}
+
+func (p EvictionPolicy) volatile() bool {
+	return p >= VolatileLRU
+}
+
+func (p EvictionPolicy) random() bool {
+	return p == AllKeysRandom || p == VolatileRandom
+}
+
+// LFU reports whether the policy evicts the least frequently used keys.
+func (p EvictionPolicy) LFU() bool {
+	return p == AllKeysLFU || p == VolatileLFU
+}
+
+// score rates e as an eviction candidate, higher being evicted first: the
+// idle time for LRU, the inverse of the access counter for LFU and the
+// inverse of the expiry time for volatile-ttl.
+func (p EvictionPolicy) score(e Entry, now time.Time) uint64 {
+	switch p {
+	case AllKeysLRU, VolatileLRU:
+		return uint64(max(now.Sub(e.Access).Milliseconds(), 0))
+	case AllKeysLFU, VolatileLFU:
+		return math.MaxUint8 - uint64(e.decayedFreq(now))
+	case VolatileTTL:
+		return math.MaxUint64 - uint64(e.TTL.UnixMilli())
+	default:
+		panic("policy does not rank keys")
+	}
+}
+
+// DefaultEvictionSamples is the default of maxmemory-samples, the number of
+// keys sampled from each database to refill the eviction pool.
+const DefaultEvictionSamples = 5
+
+// evictionPoolSize is Redis's EVPOOL_SIZE.
+const evictionPoolSize = 16
+
+type evictionCandidate struct {
+	db    int
+	key   string
+	score uint64
+}
+
+// evictionPool holds the best candidates seen by the sampling so far,
+// ordered by ascending score. Keeping them across samplings approximates
+// true LRU, LFU or TTL order much better than the samples alone would.
+type evictionPool struct {
+	candidates []evictionCandidate
+}
+
+// insert adds c unless the pool is full of better candidates, dropping the
+// worst one to make room.
+func (p *evictionPool) insert(c evictionCandidate) {
+	i := sort.Search(len(p.candidates), func(i int) bool { return p.candidates[i].score >= c.score })
+	if len(p.candidates) == evictionPoolSize {
+		if i == 0 {
+			return
+		}
+		p.candidates = slices.Delete(p.candidates, 0, 1)
+		i--
+	}
+	p.candidates = slices.Insert(p.candidates, i, c)
+}
+
+// pop removes and returns the best candidate.
+func (p *evictionPool) pop() (evictionCandidate, bool) {
+	n := len(p.candidates)
+	if n == 0 {
+		return evictionCandidate{}, false
+	}
+	c := p.candidates[n-1]
+	p.candidates = p.candidates[:n-1]
+	return c, true
+}
+
+// populateEvictionPool samples up to samples keys of s, database db, into
+// pool and returns how many keys the policy could evict from s.
+func (s *Store) populateEvictionPool(db int, policy EvictionPolicy, samples int, pool *evictionPool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	add := func(key string, e Entry) {
+		pool.insert(evictionCandidate{db: db, key: key, score: policy.score(e, now)})
+	}
+	if !policy.volatile() {
+		s.store.sample(samples, add)
+		return s.store.len()
+	}
+	s.expires.sample(samples, func(key string, _ struct{}) {
+		if e, ok := s.store.get(key); ok {
+			add(key, e)
+		}
+	})
+	return s.expires.len()
+}
+
+// randomEvictionKey picks a key the policy could evict from s at random.
+func (s *Store) randomEvictionKey(policy EvictionPolicy) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if policy.volatile() {
+		return s.expires.randomKey()
+	}
+	return s.store.randomKey()
+}
+
+// evict deletes key if it is still there and, for volatile policies, still
+// has a TTL. Its value is freed in the background when
+// lazyfree-lazy-eviction is set.
+func (s *Store) evict(key string, policy EvictionPolicy) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if policy.volatile() {
+		if _, ok := s.expires.get(key); !ok {
+			return false
+		}
+	}
+	old, ok := s.unlinkKey(key)
+	if ok {
+		free(old, s.lazyFree.Eviction.Load())
+	}
+	return ok
+}
+
+// SetMaxMemory sets the memory limit in bytes, 0 meaning none.
+func (d *Databases) SetMaxMemory(limit int64) {
+	d.maxMemory.Store(limit)
+}
+
+func (d *Databases) MaxMemory() int64 {
+	return d.maxMemory.Load()
+}
+
+// SetEvictionPolicy changes the eviction policy. The candidates found under
+// the previous one are discarded, as they were ranked differently.
+func (d *Databases) SetEvictionPolicy(policy EvictionPolicy) {
+	d.evictMu.Lock()
+	defer d.evictMu.Unlock()
+	d.policy.Store(int32(policy))
+	d.pool = evictionPool{}
+}
+
+func (d *Databases) EvictionPolicy() EvictionPolicy {
+	return EvictionPolicy(d.policy.Load())
+}
+
+// SetEvictionSamples sets the number of keys sampled from each database
+// when looking for a key to evict.
+func (d *Databases) SetEvictionSamples(n int) {
+	d.samples.Store(int32(n))
+}
+
+func (d *Databases) EvictionSamples() int {
+	return int(d.samples.Load())
+}
+
+// EvictedKeys returns the number of keys evicted since startup.
+func (d *Databases) EvictedKeys() int64 {
+	return d.evicted.Load()
+}
+
+// UsedMemory returns the approximate memory held by every database.
+func (d *Databases) UsedMemory() int64 {
+	var used int64
+	for _, db := range d.dbs {
+		used += db.UsedMemory()
+	}
+	return used
+}
+
+// OverMaxMemory reports whether the memory used exceeds maxmemory.
+func (d *Databases) OverMaxMemory() bool {
+	limit := d.MaxMemory()
+	return limit != 0 && d.UsedMemory() > limit
+}
+
+// Evict deletes keys following the eviction policy until the memory used
+// is within maxmemory, the way Redis does before running each command, and
+// calls evicted, unless nil, with the database and name of each. It returns
+// ErrOOM when that is not possible, because the policy is noeviction or no
+// key is left that it could evict.
+func (d *Databases) Evict(evicted func(db int, key string)) error {
+	if !d.OverMaxMemory() {
+		return nil
+	}
+
+	d.evictMu.Lock()
+	defer d.evictMu.Unlock()
+	policy := d.EvictionPolicy()
+	if policy == NoEviction {
+		return ErrOOM
+	}
+	for d.OverMaxMemory() {
+		db, key, ok := d.evictOne(policy)
+		if !ok {
+			return ErrOOM
+		}
+		d.evicted.Add(1)
+		if evicted != nil {
+			evicted(db, key)
+		}
+	}
+	return nil
+}
+
+// evictOne evicts a single key and returns its database and name, if there
+// was one. The caller must hold d.evictMu.
+func (d *Databases) evictOne(policy EvictionPolicy) (int, string, bool) {
+	if policy.random() {
+		// Databases are visited in turn so that evictions are spread
+		// across them.
+		for range d.dbs {
+			d.nextDB = (d.nextDB + 1) % len(d.dbs)
+			db := d.dbs[d.nextDB]
+			if key, ok := db.randomEvictionKey(policy); ok && db.evict(key, policy) {
+				return d.nextDB, key, true
+			}
+		}
+		return 0, "", false
+	}
+
+	samples := d.EvictionSamples()
+	for {
+		keys := 0
+		for i, db := range d.dbs {
+			keys += db.populateEvictionPool(i, policy, samples, &d.pool)
+		}
+		if keys == 0 {
+			return 0, "", false
+		}
+		// Candidates may have been deleted or lost their TTL since they
+		// were sampled, in which case the next best is tried.
+		for c, ok := d.pool.pop(); ok; c, ok = d.pool.pop() {
+			if d.dbs[c.db].evict(c.key, policy) {
+				return c.db, c.key, true
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvictionPolicy(t *testing.T) {
+	t.Parallel()
+	for _, name := range evictionPolicyNames {
+		policy, ok := ParseEvictionPolicy(name)
+		assert.True(t, ok)
+		assert.Equal(t, name, policy.String())
+	}
+	policy, ok := ParseEvictionPolicy("AllKeys-LRU")
+	assert.True(t, ok)
+	assert.Equal(t, AllKeysLRU, policy)
+	_, ok = ParseEvictionPolicy("lru")
+	assert.False(t, ok)
+}
+
+func TestEvictionPoolKeepsBestCandidates(t *testing.T) {
+	t.Parallel()
+	var pool evictionPool
+	for i := range 2 * evictionPoolSize {
+		pool.insert(evictionCandidate{key: fmt.Sprint(i), score: uint64(i)})
+	}
+	pool.insert(evictionCandidate{key: "worst", score: 0})
+	require.Len(t, pool.candidates, evictionPoolSize)
+
+	for i := 2*evictionPoolSize - 1; i >= evictionPoolSize; i-- {
+		c, ok := pool.pop()
+		require.True(t, ok)
+		assert.Equal(t, fmt.Sprint(i), c.key)
+	}
+	_, ok := pool.pop()
+	assert.False(t, ok)
+}
+
+// fillEvictionTest stores ten keys of equal size, k0 being the most
+// recently and most often used and k9 the least, and returns the size of
+// one key.
+func fillEvictionTest(dbs *Databases) int64 {
+	now := time.Now()
+	for i := range 10 {
+		key := fmt.Sprintf("k%d", i)
+		dbs.DB(i%dbs.Len()).Set(key, "v", nil)
+		e := dbs.DB(i % dbs.Len()).store.find(key)
+		e.value.Access = now.Add(-time.Duration(i) * time.Minute)
+		e.value.Freq = uint8(100 - i)
+	}
+	return int64(keyOverhead + len("k0") + len("v"))
+}
+
+func liveKeys(dbs *Databases) []string {
+	var keys []string
+	for i := range dbs.Len() {
+		for key := range dbs.DB(i).store.all() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestEvictLeastRecentlyAndFrequentlyUsed(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []EvictionPolicy{AllKeysLRU, AllKeysLFU} {
+		t.Run(policy.String(), func(t *testing.T) {
+			t.Parallel()
+			dbs := NewDatabases(3)
+			// Sampling every key makes the choice exact.
+			dbs.SetEvictionSamples(64)
+			dbs.SetEvictionPolicy(policy)
+			size := fillEvictionTest(dbs)
+			dbs.SetMaxMemory(7 * size)
+
+			require.NoError(t, dbs.Evict(nil))
+			assert.Equal(t, int64(3), dbs.EvictedKeys())
+			assert.ElementsMatch(t, []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6"}, liveKeys(dbs))
+			assert.LessOrEqual(t, dbs.UsedMemory(), dbs.MaxMemory())
+		})
+	}
+}
+
+func TestEvictVolatileOnlyTouchesKeysWithTTL(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(1)
+	dbs.SetEvictionPolicy(VolatileTTL)
+	db := dbs.DB(0)
+	for i, ttl := range []time.Duration{time.Hour, time.Minute, 2 * time.Hour} {
+		db.Set(fmt.Sprintf("v%d", i), "v", &ttl)
+	}
+	db.Set("persistent", "v", nil)
+	size := int64(keyOverhead + len("v0") + len("v") + expireOverhead)
+	dbs.SetMaxMemory(dbs.UsedMemory() - size)
+
+	require.NoError(t, dbs.Evict(nil))
+	assert.ElementsMatch(t, []string{"v0", "v2", "persistent"}, liveKeys(dbs), "the key expiring first goes")
+
+	dbs.SetMaxMemory(1)
+	assert.ErrorIs(t, dbs.Evict(nil), ErrOOM)
+	assert.Equal(t, []string{"persistent"}, liveKeys(dbs))
+	assert.Equal(t, int64(3), dbs.EvictedKeys())
+}
+
+func TestEvictRandom(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(3)
+	dbs.SetEvictionPolicy(AllKeysRandom)
+	size := fillEvictionTest(dbs)
+	dbs.SetMaxMemory(4 * size)
+
+	var evicted []string
+	require.NoError(t, dbs.Evict(func(db int, key string) {
+		_, ok := dbs.DB(db).Get(key)
+		assert.False(t, ok)
+		evicted = append(evicted, key)
+	}))
+	assert.Len(t, liveKeys(dbs), 4)
+	assert.Len(t, evicted, 6, "every evicted key is reported")
+
+	dbs.SetEvictionPolicy(VolatileRandom)
+	dbs.SetMaxMemory(size)
+	assert.ErrorIs(t, dbs.Evict(nil), ErrOOM)
+	assert.Len(t, liveKeys(dbs), 4)
+}
+
+func TestNoEvictionRefusesWrites(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(1)
+	assert.NoError(t, dbs.Evict(nil), "no limit")
+
+	size := fillEvictionTest(dbs)
+	dbs.SetMaxMemory(10 * size)
+	assert.NoError(t, dbs.Evict(nil))
+	dbs.SetMaxMemory(size)
+	assert.ErrorIs(t, dbs.Evict(nil), ErrOOM)
+	assert.Len(t, liveKeys(dbs), 10)
+	assert.Zero(t, dbs.EvictedKeys())
+}
+
+func TestDictSample(t *testing.T) {
+	t.Parallel()
+	d := newDict[int]()
+	d.sample(5, func(string, int) { t.Fatal("sampled an empty dict") })
+
+	for i := range 1000 {
+		d.set(fmt.Sprint(i), i)
+	}
+	seen := 0
+	d.sample(16, func(key string, value int) {
+		assert.Equal(t, fmt.Sprint(value), key)
+		seen++
+	})
+	assert.Equal(t, 16, seen)
+
+	key, ok := d.randomKey()
+	assert.True(t, ok)
+	_, ok = d.get(key)
+	assert.True(t, ok)
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrGeoMemberMissing is returned when a search is centered on a member
+// that is not in the index.
+var ErrGeoMemberMissing = errors.New("could not decode requested zset member")
+
+// GeoSort is the order of search results.
+type GeoSort int
+
+const (
+	GeoSortNone GeoSort = iota
+	GeoSortAsc
+	GeoSortDesc
+)
+
+// GeoQuery describes a GEOSEARCH. The center is Member when FromMember is
+// set and Lon, Lat otherwise. ByBox selects a Width x Height box over a Radius
+// circle. Sizes are in units of Unit meters. Count 0 means no limit; with
+// Any the search stops as soon as Count matches are found.
+type GeoQuery struct {
+	FromMember bool
+	Member     string
+	Lon, Lat   float64
+
+	ByBox         bool
+	Radius        float64
+	Width, Height float64
+	Unit          float64
+
+	Sort  GeoSort
+	Count int
+	Any   bool
+}
+
+// GeoMatch is a member found by GeoSearch. Dist is in the query's unit.
+type GeoMatch struct {
+	Member   string
+	Dist     float64
+	Score    float64
+	Lon, Lat float64
+}
+
+// geoShape is the area of a GeoQuery around a resolved center. Sizes are
+// in units of unit meters and converted where Redis converts them, so
+// that rounding matches.
+type geoShape struct {
+	lon, lat      float64
+	byBox         bool
+	radius        float64
+	width, height float64
+	unit          float64
+}
+
+// bounds returns the bounding box of s as min lon, min lat, max lon, max lat.
+func (s geoShape) bounds() (float64, float64, float64, float64) {
+	height, width := s.unit*s.radius, s.unit*s.radius
+	if s.byBox {
+		height, width = s.unit*(s.height/2), s.unit*(s.width/2)
+	}
+	latDelta := height / earthRadiusMeters / degToRad
+	lonDeltaTop := width / earthRadiusMeters / math.Cos((s.lat+latDelta)*degToRad) / degToRad
+	lonDeltaBottom := width / earthRadiusMeters / math.Cos((s.lat-latDelta)*degToRad) / degToRad
+	// The widest edge is the one nearest the equator.
+	lonDelta := lonDeltaTop
+	if s.lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	return s.lon - lonDelta, s.lat - latDelta, s.lon + lonDelta, s.lat + latDelta
+}
+
+// contains reports whether the point is inside s and its distance from the center.
+func (s geoShape) contains(lon float64, lat float64) (float64, bool) {
+	if !s.byBox {
+		dist := GeoDistance(s.lon, s.lat, lon, lat)
+		return dist, dist <= s.radius*s.unit
+	}
+	// The latitude distance is cheaper, so it is checked first.
+	if geoLatDistance(lat, s.lat) > s.height*s.unit/2 {
+		return 0, false
+	}
+	if GeoDistance(lon, lat, s.lon, lat) > s.width*s.unit/2 {
+		return 0, false
+	}
+	return GeoDistance(s.lon, s.lat, lon, lat), true
+}
+
+// cells returns the geohash cells to scan for s: the cell holding the
+// center followed by its neighbors, with those that cannot intersect s
+// zeroed out.
+func (s geoShape) cells() [9]geoHashBits {
+	minLon, minLat, maxLon, maxLat := s.bounds()
+	radius := s.radius
+	if s.byBox {
+		radius = math.Sqrt(float64(s.width/2*(s.width/2)) + float64(s.height/2*(s.height/2)))
+	}
+	steps := geohashStepsForRadius(radius*s.unit, s.lat)
+
+	hash, _ := geohashEncode(geoLongRange, geoLatRange, s.lon, s.lat, steps)
+	neighbors := geohashNeighbors(hash)
+	area := geohashDecode(geoLongRange, geoLatRange, hash)
+
+	// Near the edge of the center cell the estimated step can leave part
+	// of the shape outside the neighbors, in which case a coarser step is
+	// used.
+	north := geohashDecode(geoLongRange, geoLatRange, neighbors.north)
+	south := geohashDecode(geoLongRange, geoLatRange, neighbors.south)
+	east := geohashDecode(geoLongRange, geoLatRange, neighbors.east)
+	west := geohashDecode(geoLongRange, geoLatRange, neighbors.west)
+	if steps > 1 && (north.lat.max < maxLat || south.lat.min > minLat || east.lon.max < maxLon || west.lon.min > minLon) {
+		steps--
+		hash, _ = geohashEncode(geoLongRange, geoLatRange, s.lon, s.lat, steps)
+		neighbors = geohashNeighbors(hash)
+		area = geohashDecode(geoLongRange, geoLatRange, hash)
+	}
+
+	if steps >= 2 {
+		if area.lat.min < minLat {
+			neighbors.south, neighbors.southWest, neighbors.southEast = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.lat.max > maxLat {
+			neighbors.north, neighbors.northEast, neighbors.northWest = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.lon.min < minLon {
+			neighbors.west, neighbors.southWest, neighbors.northWest = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.lon.max > maxLon {
+			neighbors.east, neighbors.southEast, neighbors.northEast = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+	}
+
+	return [9]geoHashBits{
+		hash,
+		neighbors.north, neighbors.south, neighbors.east, neighbors.west,
+		neighbors.northEast, neighbors.northWest, neighbors.southEast, neighbors.southWest,
+	}
+}
+
+// geoSearch returns the members of z inside s, visiting cells in order and
+// stopping once limit matches are found when limit is positive.
+func (z *ZSet) geoSearch(s geoShape, limit int) []GeoMatch {
+	matches := []GeoMatch{}
+	cells := s.cells()
+	last := 0
+	for i, cell := range cells {
+		if cell.isZero() {
+			continue
+		}
+		// With huge radii adjacent neighbors can be the same cell. Like
+		// Redis, the center cell is never compared against.
+		if last != 0 && cell == cells[last] {
+			continue
+		}
+		if limit > 0 && len(matches) >= limit {
+			break
+		}
+
+		next := cell
+		next.bits++
+		r := ScoreRange{Min: float64(cell.align52()), Max: float64(next.align52()), MaxExclusive: true}
+		for x := z.zsl.firstInScoreRange(r); x != nil && r.Contains(x.score); x = x.level[0].forward {
+			lon, lat := GeoDecode(x.score)
+			if dist, ok := s.contains(lon, lat); ok {
+				matches = append(matches, GeoMatch{Member: x.member, Dist: dist, Score: x.score, Lon: lon, Lat: lat})
+				if limit > 0 && len(matches) >= limit {
+					break
+				}
+			}
+		}
+		last = i
+	}
+	return matches
+}
+
+// geoSearch runs q against the index at key, or returns nil when key is
+// missing. The caller must hold s.mu.
+func (s *Store) geoSearch(key string, q GeoQuery) ([]GeoMatch, error) {
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return nil, err
+	}
+
+	shape := geoShape{
+		lon: q.Lon, lat: q.Lat, byBox: q.ByBox,
+		radius: q.Radius, width: q.Width, height: q.Height, unit: q.Unit,
+	}
+	if q.FromMember {
+		score, ok := zset.Score(q.Member)
+		if !ok {
+			return nil, ErrGeoMemberMissing
+		}
+		shape.lon, shape.lat = GeoDecode(score)
+	}
+
+	limit := 0
+	if q.Any {
+		limit = q.Count
+	}
+	matches := zset.geoSearch(shape, limit)
+
+	order := q.Sort
+	if q.Count > 0 && order == GeoSortNone && !q.Any {
+		order = GeoSortAsc
+	}
+	switch order {
+	case GeoSortAsc:
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Dist < matches[j].Dist })
+	case GeoSortDesc:
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Dist > matches[j].Dist })
+	}
+	if q.Count > 0 && len(matches) > q.Count {
+		matches = matches[:q.Count]
+	}
+	for i := range matches {
+		matches[i].Dist /= q.Unit
+	}
+	return matches, nil
+}
+
+// GeoSearch returns the members of the geo index at key matching q.
+func (s *Store) GeoSearch(key string, q GeoQuery) ([]GeoMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches, err := s.geoSearch(key, q)
+	if matches == nil {
+		matches = []GeoMatch{}
+	}
+	return matches, err
+}
+
+// GeoSearchStore stores the members of the geo index at source matching q
+// as a sorted set at destination and returns how many there are. The
+// scores are the geohashes, or the distances when storeDist is set.
+func (s *Store) GeoSearchStore(destination string, source string, q GeoQuery, storeDist bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches, err := s.geoSearch(source, q)
+	if err != nil {
+		return 0, err
+	}
+	result := newZSet()
+	for _, m := range matches {
+		score := m.Score
+		if storeDist {
+			score = m.Dist
+		}
+		result.add(score, m.Member, ZAddFlags{})
+	}
+	s.storeZSet(destination, result)
+	return result.Len(), nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sicily is the index from the Redis geo documentation.
+func sicily(t *testing.T) *Store {
+	t.Helper()
+	s := NewStore()
+	_, err := s.ZAdd("Sicily", ZAddFlags{}, false, []ScoredMember{
+		{Member: "Palermo", Score: GeoScore(13.361389, 38.115556)},
+		{Member: "Catania", Score: GeoScore(15.087269, 37.502669)},
+		{Member: "edge1", Score: GeoScore(12.758489, 38.788135)},
+		{Member: "edge2", Score: GeoScore(17.241510, 38.788135)},
+	})
+	assert.NoError(t, err)
+	return s
+}
+
+func TestGeoScoreAndDecode(t *testing.T) {
+	t.Parallel()
+	score := GeoScore(13.361389, 38.115556)
+	assert.Equal(t, float64(3479099956230698), score)
+
+	lon, lat := GeoDecode(score)
+	assert.Equal(t, 13.361389338970184, lon)
+	assert.Equal(t, 38.1155563954963, lat)
+	assert.Equal(t, "sqc8b49rny0", GeoHashString(score))
+
+	lon2, lat2 := GeoDecode(GeoScore(15.087269, 37.502669))
+	assert.InDelta(t, 166274.1516, GeoDistance(lon, lat, lon2, lat2), 0.0001)
+}
+
+func TestGeoValid(t *testing.T) {
+	t.Parallel()
+	assert.True(t, GeoValid(-180, GeoLatMax))
+	assert.False(t, GeoValid(180.1, 0))
+	assert.False(t, GeoValid(0, 86))
+}
+
+func TestStoreGeoSearch(t *testing.T) {
+	t.Parallel()
+	s := sicily(t)
+	cases := []struct {
+		name string
+		q    GeoQuery
+		want []string
+	}{
+		{
+			name: "radius",
+			q:    GeoQuery{Lon: 15, Lat: 37, Radius: 200, Unit: 1000, Sort: GeoSortAsc},
+			want: []string{"Catania", "Palermo"},
+		},
+		{
+			name: "box",
+			q:    GeoQuery{Lon: 15, Lat: 37, ByBox: true, Width: 400, Height: 400, Unit: 1000, Sort: GeoSortAsc},
+			want: []string{"Catania", "Palermo", "edge2", "edge1"},
+		},
+		{
+			name: "from member desc",
+			q:    GeoQuery{FromMember: true, Member: "Palermo", Radius: 200, Unit: 1000, Sort: GeoSortDesc},
+			want: []string{"Catania", "edge1", "Palermo"},
+		},
+		{
+			name: "count sorts ascending",
+			q:    GeoQuery{Lon: 15, Lat: 37, ByBox: true, Width: 400, Height: 400, Unit: 1000, Count: 2},
+			want: []string{"Catania", "Palermo"},
+		},
+		{
+			name: "count any",
+			q:    GeoQuery{Lon: 15, Lat: 37, Radius: 200, Unit: 1000, Count: 1, Any: true},
+			want: []string{"Palermo"},
+		},
+		{
+			name: "nothing in range",
+			q:    GeoQuery{Lon: 0, Lat: 0, Radius: 10, Unit: 1},
+			want: []string{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			matches, err := s.GeoSearch("Sicily", tc.q)
+			assert.NoError(t, err)
+			got := make([]string, len(matches))
+			for i, m := range matches {
+				got[i] = m.Member
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	matches, _ := s.GeoSearch("Sicily", GeoQuery{Lon: 15, Lat: 37, Radius: 100, Unit: 1000})
+	assert.InDelta(t, 56.4413, matches[0].Dist, 0.0001)
+
+	_, err := s.GeoSearch("Sicily", GeoQuery{FromMember: true, Member: "Rome", Radius: 1, Unit: 1})
+	assert.ErrorIs(t, err, ErrGeoMemberMissing)
+	matches, err = s.GeoSearch("missing", GeoQuery{FromMember: true, Member: "Rome", Radius: 1, Unit: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestStoreGeoSearchStore(t *testing.T) {
+	t.Parallel()
+	s := sicily(t)
+	q := GeoQuery{Lon: 15, Lat: 37, ByBox: true, Width: 400, Height: 400, Unit: 1000, Sort: GeoSortAsc, Count: 3}
+
+	n, err := s.GeoSearchStore("dst", "Sicily", q, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	score, _, _ := s.ZScore("dst", "Catania")
+	assert.InDelta(t, 56.4412578701582, score, 1e-9)
+
+	n, err = s.GeoSearchStore("dst", "missing", q, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	_, ok := s.Get("dst")
+	assert.False(t, ok)
+}
@@ -0,0 +1,267 @@
+package store
+
+import "math"
+
+// Geo indexes are sorted sets whose scores are 52-bit geohashes: longitude
+// and latitude are each quantised to GeoStepMax bits and interleaved, with
+// latitude in the even bits. The code follows Redis so that scores, search
+// areas and the order COUNT ANY visits members in are the same.
+const (
+	GeoStepMax = 26
+
+	GeoLongMin = -180.0
+	GeoLongMax = 180.0
+	GeoLatMin  = -85.05112878
+	GeoLatMax  = 85.05112878
+
+	earthRadiusMeters = 6372797.560856
+	mercatorMax       = 20037726.37
+	degToRad          = math.Pi / 180.0
+)
+
+// GeoValid reports whether lon and lat can be indexed.
+func GeoValid(lon float64, lat float64) bool {
+	return lon >= GeoLongMin && lon <= GeoLongMax && lat >= GeoLatMin && lat <= GeoLatMax
+}
+
+type geoRange struct {
+	min, max float64
+}
+
+var (
+	geoLongRange = geoRange{GeoLongMin, GeoLongMax}
+	geoLatRange  = geoRange{GeoLatMin, GeoLatMax}
+)
+
+// geoHashBits is a geohash of step bits per coordinate.
+type geoHashBits struct {
+	bits uint64
+	step uint
+}
+
+func (h geoHashBits) isZero() bool {
+	return h.bits == 0 && h.step == 0
+}
+
+// align52 scales h to the 52-bit score space.
+func (h geoHashBits) align52() uint64 {
+	return h.bits << (52 - h.step*2)
+}
+
+type geoArea struct {
+	lon, lat geoRange
+}
+
+// interleave64 spreads the bits of x over the even bits and those of y
+// over the odd bits of the result.
+func interleave64(x uint32, y uint32) uint64 {
+	spread := func(v uint64) uint64 {
+		v = (v | v<<16) & 0x0000ffff0000ffff
+		v = (v | v<<8) & 0x00ff00ff00ff00ff
+		v = (v | v<<4) & 0x0f0f0f0f0f0f0f0f
+		v = (v | v<<2) & 0x3333333333333333
+		v = (v | v<<1) & 0x5555555555555555
+		return v
+	}
+	return spread(uint64(x)) | spread(uint64(y))<<1
+}
+
+// deinterleave64 reverses interleave64, returning the even bits in the low
+// half and the odd bits in the high half.
+func deinterleave64(v uint64) uint64 {
+	squash := func(v uint64) uint64 {
+		v &= 0x5555555555555555
+		v = (v | v>>1) & 0x3333333333333333
+		v = (v | v>>2) & 0x0f0f0f0f0f0f0f0f
+		v = (v | v>>4) & 0x00ff00ff00ff00ff
+		v = (v | v>>8) & 0x0000ffff0000ffff
+		v = (v | v>>16) & 0x00000000ffffffff
+		return v
+	}
+	return squash(v) | squash(v>>1)<<32
+}
+
+// geohashEncode returns the geohash of lon and lat within the given ranges.
+// ok is false when the point is outside them or outside what can be indexed.
+func geohashEncode(lonRange geoRange, latRange geoRange, lon float64, lat float64, step uint) (geoHashBits, bool) {
+	if !GeoValid(lon, lat) {
+		return geoHashBits{}, false
+	}
+	if lat < latRange.min || lat > latRange.max || lon < lonRange.min || lon > lonRange.max {
+		return geoHashBits{}, false
+	}
+
+	latOffset := (lat - latRange.min) / (latRange.max - latRange.min)
+	lonOffset := (lon - lonRange.min) / (lonRange.max - lonRange.min)
+	latOffset *= float64(uint64(1) << step)
+	lonOffset *= float64(uint64(1) << step)
+	return geoHashBits{bits: interleave64(uint32(latOffset), uint32(lonOffset)), step: step}, true
+}
+
+// geohashDecode returns the area covered by h.
+func geohashDecode(lonRange geoRange, latRange geoRange, h geoHashBits) geoArea {
+	separated := deinterleave64(h.bits)
+	latScale := latRange.max - latRange.min
+	lonScale := lonRange.max - lonRange.min
+	ilat := float64(uint32(separated))
+	ilon := float64(uint32(separated >> 32))
+	cells := float64(uint64(1) << h.step)
+	return geoArea{
+		lat: geoRange{
+			min: latRange.min + (ilat/cells)*latScale,
+			max: latRange.min + ((ilat+1)/cells)*latScale,
+		},
+		lon: geoRange{
+			min: lonRange.min + (ilon/cells)*lonScale,
+			max: lonRange.min + ((ilon+1)/cells)*lonScale,
+		},
+	}
+}
+
+// center returns the middle of the area, clamped to the indexable range.
+func (a geoArea) center() (float64, float64) {
+	lon := min(max((a.lon.min+a.lon.max)/2, GeoLongMin), GeoLongMax)
+	lat := min(max((a.lat.min+a.lat.max)/2, GeoLatMin), GeoLatMax)
+	return lon, lat
+}
+
+// GeoScore returns the sorted set score indexing lon and lat, which must
+// satisfy GeoValid.
+func GeoScore(lon float64, lat float64) float64 {
+	h, ok := geohashEncode(geoLongRange, geoLatRange, lon, lat, GeoStepMax)
+	if !ok {
+		panic("coordinates out of range")
+	}
+	return float64(h.align52())
+}
+
+// GeoDecode returns the longitude and latitude at the center of the cell
+// indexed by score.
+func GeoDecode(score float64) (float64, float64) {
+	h := geoHashBits{bits: uint64(score), step: GeoStepMax}
+	return geohashDecode(geoLongRange, geoLatRange, h).center()
+}
+
+const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeoHashString returns the standard 11 character geohash of the point
+// indexed by score. Scores use a latitude range of ±85.05 degrees rather
+// than the standard ±90, so the point is re-encoded first.
+func GeoHashString(score float64) string {
+	lon, lat := GeoDecode(score)
+	h, _ := geohashEncode(geoRange{-180, 180}, geoRange{-90, 90}, lon, lat, GeoStepMax)
+
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		// Only 52 bits are available for 55 output bits; the last
+		// character is assumed to be zero.
+		if i < 10 {
+			idx = int(h.bits >> (52 - (i+1)*5) & 0x1f)
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+	return string(buf)
+}
+
+func geoLatDistance(lat1 float64, lat2 float64) float64 {
+	return earthRadiusMeters * math.Abs(lat2*degToRad-lat1*degToRad)
+}
+
+// GeoDistance returns the haversine distance in meters between two points.
+func GeoDistance(lon1 float64, lat1 float64, lon2 float64, lat2 float64) float64 {
+	lon1r, lon2r := lon1*degToRad, lon2*degToRad
+	v := math.Sin((lon2r - lon1r) / 2)
+	// Points on the same meridian only differ in latitude.
+	if v == 0 {
+		return geoLatDistance(lat1, lat2)
+	}
+	lat1r, lat2r := lat1*degToRad, lat2*degToRad
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := float64(u*u) + float64(math.Cos(lat1r)*math.Cos(lat2r)*v*v)
+	return 2.0 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// geohashMoveX moves h d cells east (d > 0) or west (d < 0), wrapping around.
+func geohashMoveX(h *geoHashBits, d int) {
+	if d == 0 {
+		return
+	}
+	x := h.bits & 0xaaaaaaaaaaaaaaaa
+	y := h.bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - h.step*2)
+	if d > 0 {
+		x += zz + 1
+	} else {
+		x |= zz
+		x -= zz + 1
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - h.step*2)
+	h.bits = x | y
+}
+
+// geohashMoveY moves h d cells north (d > 0) or south (d < 0), wrapping around.
+func geohashMoveY(h *geoHashBits, d int) {
+	if d == 0 {
+		return
+	}
+	x := h.bits & 0xaaaaaaaaaaaaaaaa
+	y := h.bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - h.step*2)
+	if d > 0 {
+		y += zz + 1
+	} else {
+		y |= zz
+		y -= zz + 1
+	}
+	y &= 0x5555555555555555 >> (64 - h.step*2)
+	h.bits = x | y
+}
+
+// geoNeighbors are the eight cells around a geohash, in the order Redis
+// searches them after the center cell.
+type geoNeighbors struct {
+	north, south, east, west                   geoHashBits
+	northEast, northWest, southEast, southWest geoHashBits
+}
+
+func geohashNeighbors(h geoHashBits) geoNeighbors {
+	move := func(dx int, dy int) geoHashBits {
+		n := h
+		geohashMoveX(&n, dx)
+		geohashMoveY(&n, dy)
+		return n
+	}
+	return geoNeighbors{
+		north:     move(0, 1),
+		south:     move(0, -1),
+		east:      move(1, 0),
+		west:      move(-1, 0),
+		northEast: move(1, 1),
+		northWest: move(-1, 1),
+		southEast: move(1, -1),
+		southWest: move(-1, -1),
+	}
+}
+
+// geohashStepsForRadius picks the precision whose cells, together with
+// their neighbors, cover a search of radius meters around lat.
+func geohashStepsForRadius(radius float64, lat float64) uint {
+	if radius == 0 {
+		return GeoStepMax
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	// Cells narrow towards the poles.
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return uint(min(max(step, 1), GeoStepMax))
+}
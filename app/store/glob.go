@@ -0,0 +1,112 @@
+package store
+
+// MatchGlob reports whether s matches the glob-style pattern the way KEYS
+// and SCAN MATCH do.
+func MatchGlob(pattern string, s string) bool {
+	return matchGlob(pattern, s)
+}
+
+// matchGlob reports whether s matches the glob-style pattern, with the
+// syntax of Redis's stringmatchlen: "*" and "?" wildcards, "[...]" classes
+// with "^" negation and "a-z" ranges, and "\" escapes.
+func matchGlob(pattern string, s string) bool {
+	skipLonger := false
+	return matchGlobAt(pattern, s, &skipLonger, 0)
+}
+
+// matchGlobAt is the recursive step of matchGlob. skipLonger is set once the
+// rest of a pattern after a "*" failed to match at any position, which means
+// no earlier "*" can match either.
+func matchGlobAt(pattern string, s string, skipLonger *bool, nesting int) bool {
+	// Protection against abusive patterns.
+	if nesting > 1000 {
+		return false
+	}
+	at := func(i int) byte {
+		if i < len(pattern) {
+			return pattern[i]
+		}
+		return 0
+	}
+
+	p := 0
+	for p < len(pattern) && len(s) > 0 {
+		switch pattern[p] {
+		case '*':
+			for p < len(pattern) && at(p+1) == '*' {
+				p++
+			}
+			if p == len(pattern)-1 {
+				return true
+			}
+			for len(s) > 0 {
+				if matchGlobAt(pattern[p+1:], s, skipLonger, nesting+1) {
+					return true
+				}
+				if *skipLonger {
+					return false
+				}
+				s = s[1:]
+			}
+			*skipLonger = true
+			return false
+		case '?':
+			s = s[1:]
+		case '[':
+			p++
+			negate := at(p) == '^'
+			if negate {
+				p++
+			}
+			match := false
+			for {
+				if at(p) == '\\' && len(pattern)-p >= 2 {
+					p++
+					if pattern[p] == s[0] {
+						match = true
+					}
+				} else if at(p) == ']' {
+					break
+				} else if p >= len(pattern) {
+					p--
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end := pattern[p], pattern[p+2]
+					if start > end {
+						start, end = end, start
+					}
+					p += 2
+					if s[0] >= start && s[0] <= end {
+						match = true
+					}
+				} else if pattern[p] == s[0] {
+					match = true
+				}
+				p++
+			}
+			if negate {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s = s[1:]
+		default:
+			if pattern[p] == '\\' && len(pattern)-p >= 2 {
+				p++
+			}
+			if pattern[p] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		p++
+		if len(s) == 0 {
+			for at(p) == '*' {
+				p++
+			}
+			break
+		}
+	}
+	return p >= len(pattern) && len(s) == 0
+}
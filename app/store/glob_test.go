@@ -0,0 +1,43 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"a*", "a", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXbY", false},
+		{"abc*", "abc", true},
+		{"h[ab", "ha", true},
+		{"", "", true},
+		{"", "a", false},
+		{"user:*:name", "user:1000:name", true},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, matchGlob(tc.pattern, tc.s), "%q ~ %q", tc.pattern, tc.s)
+	}
+}
@@ -0,0 +1,592 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var (
+	ErrNotHLL     = errors.New("Key is not a valid HyperLogLog string value.")
+	ErrCorruptHLL = errors.New("Corrupted HLL object detected")
+)
+
+// The HyperLogLog layout below is byte-for-byte the one of Redis so values
+// can be exchanged with it: a 16 byte header ("HYLL", the encoding, three
+// unused bytes and the cached cardinality as a little-endian uint64 whose
+// top bit flags it stale) followed by the registers, either densely packed
+// at 6 bits each or run-length encoded with the sparse opcodes.
+const (
+	hllP              = 14
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllPMask          = hllRegisters - 1
+	hllBits           = 6
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHeaderSize     = 16
+	hllDenseSize      = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllEncodingDense  = 0
+	hllEncodingSparse = 1
+	hllMagic          = "HYLL"
+
+	// hllSparseMaxBytes mirrors the hll-sparse-max-bytes default: past this
+	// size a sparse HyperLogLog is converted to the dense encoding.
+	hllSparseMaxBytes = 3000
+
+	hllSparseXZeroBit     = 0x40
+	hllSparseValBit       = 0x80
+	hllSparseValMaxValue  = 32
+	hllSparseValMaxLen    = 4
+	hllSparseZeroMaxLen   = 64
+	hllSparseXZeroMaxLen  = 16384
+	hllAlphaInf           = 0.721347520444481703680
+	hllMurmurSeed         = 0xadc83b19
+	hllCardinalityInvalid = 1 << 7
+)
+
+func hllSparseIsZero(op byte) bool  { return op&0xc0 == 0 }
+func hllSparseIsXZero(op byte) bool { return op&0xc0 == hllSparseXZeroBit }
+func hllSparseIsVal(op byte) bool   { return op&hllSparseValBit != 0 }
+func hllSparseZeroLen(op byte) int  { return int(op&0x3f) + 1 }
+func hllSparseXZeroLen(op0, op1 byte) int {
+	return (int(op0&0x3f)<<8 | int(op1)) + 1
+}
+func hllSparseValValue(op byte) int { return int(op>>2&0x1f) + 1 }
+func hllSparseValLen(op byte) int   { return int(op&0x3) + 1 }
+
+func hllSparseVal(value int, length int) byte {
+	return byte((value-1)<<2|(length-1)) | hllSparseValBit
+}
+
+// hllSparseZeros appends the shortest opcode for a run of length zero registers.
+func hllSparseZeros(seq []byte, length int) []byte {
+	if length > hllSparseZeroMaxLen {
+		l := length - 1
+		return append(seq, byte(l>>8)|hllSparseXZeroBit, byte(l&0xff))
+	}
+	return append(seq, byte(length-1))
+}
+
+// newHLL returns an empty sparse HyperLogLog: every register is covered by
+// a single XZERO opcode.
+func newHLL() []byte {
+	hll := make([]byte, hllHeaderSize, hllHeaderSize+2)
+	copy(hll, hllMagic)
+	hll[4] = hllEncodingSparse
+	return hllSparseZeros(hll, hllRegisters)
+}
+
+func isHLL(value []byte) bool {
+	if len(value) < hllHeaderSize || string(value[:4]) != hllMagic {
+		return false
+	}
+	switch value[4] {
+	case hllEncodingDense:
+		return len(value) == hllDenseSize
+	case hllEncodingSparse:
+		return true
+	default:
+		return false
+	}
+}
+
+func hllInvalidateCache(hll []byte) {
+	hll[15] |= hllCardinalityInvalid
+}
+
+// murmurHash64A is the 64-bit MurmurHash2 variant Redis hashes elements with.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPatLen returns the register element hashes to and the length of the
+// "000..1" pattern that follows, which is the value to store in it.
+func hllPatLen(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, hllMurmurSeed)
+	index := int(hash & hllPMask)
+	hash >>= hllP
+	hash |= 1 << hllQ
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+func hllDenseGet(registers []byte, index int) uint8 {
+	byteIndex := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	b0 := uint(registers[byteIndex])
+	var b1 uint
+	if byteIndex+1 < len(registers) {
+		b1 = uint(registers[byteIndex+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & hllRegisterMax)
+}
+
+func hllDenseSetRegister(registers []byte, index int, value uint8) {
+	byteIndex := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	v := uint(value)
+	registers[byteIndex] &^= byte(hllRegisterMax << fb)
+	registers[byteIndex] |= byte(v << fb)
+	if byteIndex+1 < len(registers) {
+		registers[byteIndex+1] &^= byte(hllRegisterMax >> (8 - fb))
+		registers[byteIndex+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// hllDenseSet raises the register at index to count and reports whether it changed.
+func hllDenseSet(registers []byte, index int, count uint8) bool {
+	if count <= hllDenseGet(registers, index) {
+		return false
+	}
+	hllDenseSetRegister(registers, index, count)
+	return true
+}
+
+// hllSparseToDense converts hll to the dense encoding, keeping its header.
+func hllSparseToDense(hll []byte) ([]byte, error) {
+	if hll[4] == hllEncodingDense {
+		return hll, nil
+	}
+
+	dense := make([]byte, hllDenseSize)
+	copy(dense, hll[:hllHeaderSize])
+	dense[4] = hllEncodingDense
+	registers := dense[hllHeaderSize:]
+
+	index := 0
+	sparse := hll[hllHeaderSize:]
+	for p := 0; p < len(sparse); {
+		op := sparse[p]
+		switch {
+		case hllSparseIsZero(op):
+			index += hllSparseZeroLen(op)
+			p++
+		case hllSparseIsXZero(op):
+			if p+1 >= len(sparse) {
+				return nil, ErrCorruptHLL
+			}
+			index += hllSparseXZeroLen(op, sparse[p+1])
+			p += 2
+		default:
+			runLen, value := hllSparseValLen(op), hllSparseValValue(op)
+			if index+runLen > hllRegisters {
+				return nil, ErrCorruptHLL
+			}
+			for range runLen {
+				hllDenseSetRegister(registers, index, uint8(value))
+				index++
+			}
+			p++
+		}
+	}
+	if index != hllRegisters {
+		return nil, ErrCorruptHLL
+	}
+	return dense, nil
+}
+
+// hllSparseSet raises the register at index to count, splitting the opcode
+// that covers it, and reports whether it changed. When the result no longer
+// fits the sparse encoding hll is converted to dense, so the possibly
+// reallocated value is returned.
+func hllSparseSet(hll []byte, index int, count uint8) ([]byte, bool, error) {
+	if count > hllSparseValMaxValue {
+		return hllPromote(hll, index, count)
+	}
+
+	// Step 1: locate the opcode covering index.
+	sparse := hll[hllHeaderSize:]
+	p, prev, first, span := 0, -1, 0, 0
+	for p < len(sparse) {
+		op := sparse[p]
+		opLen := 1
+		switch {
+		case hllSparseIsZero(op):
+			span = hllSparseZeroLen(op)
+		case hllSparseIsVal(op):
+			span = hllSparseValLen(op)
+		default:
+			if p+1 >= len(sparse) {
+				return nil, false, ErrCorruptHLL
+			}
+			span = hllSparseXZeroLen(op, sparse[p+1])
+			opLen = 2
+		}
+		if index <= first+span-1 {
+			break
+		}
+		prev = p
+		p += opLen
+		first += span
+	}
+	if span == 0 || p >= len(sparse) {
+		return nil, false, ErrCorruptHLL
+	}
+
+	op := sparse[p]
+	isZero, isXZero, isVal := hllSparseIsZero(op), hllSparseIsXZero(op), hllSparseIsVal(op)
+
+	// Step 2: the trivial in-place updates.
+	updated := false
+	if isVal {
+		if hllSparseValValue(op) >= int(count) {
+			return hll, false, nil
+		}
+		if hllSparseValLen(op) == 1 {
+			sparse[p] = hllSparseVal(int(count), 1)
+			updated = true
+		}
+	}
+	if isZero && hllSparseZeroLen(op) == 1 {
+		sparse[p] = hllSparseVal(int(count), 1)
+		updated = true
+	}
+
+	if !updated {
+		// Step 3: split the opcode into up to three, the worst case being
+		// XZERO-VAL-XZERO, and splice them in place of the old one.
+		last := first + span - 1
+		seq := make([]byte, 0, 5)
+		if isZero || isXZero {
+			if index != first {
+				seq = hllSparseZeros(seq, index-first)
+			}
+			seq = append(seq, hllSparseVal(int(count), 1))
+			if index != last {
+				seq = hllSparseZeros(seq, last-index)
+			}
+		} else {
+			value := hllSparseValValue(op)
+			if index != first {
+				seq = append(seq, hllSparseVal(value, index-first))
+			}
+			seq = append(seq, hllSparseVal(int(count), 1))
+			if index != last {
+				seq = append(seq, hllSparseVal(value, last-index))
+			}
+		}
+
+		oldLen := 1
+		if isXZero {
+			oldLen = 2
+		}
+		if delta := len(seq) - oldLen; delta > 0 && len(hll)+delta > hllSparseMaxBytes {
+			return hllPromote(hll, index, count)
+		}
+		spliced := make([]byte, 0, len(hll)+len(seq)-oldLen)
+		spliced = append(spliced, hll[:hllHeaderSize+p]...)
+		spliced = append(spliced, seq...)
+		spliced = append(spliced, hll[hllHeaderSize+p+oldLen:]...)
+		hll = spliced
+	}
+
+	// Step 4: merge adjacent VAL opcodes with the same value, scanning up
+	// to five opcodes from the one before the update.
+	sparse = hll[hllHeaderSize:]
+	p = max(prev, 0)
+	for scan := 5; p < len(sparse) && scan > 0; scan-- {
+		op := sparse[p]
+		if hllSparseIsXZero(op) {
+			p += 2
+			continue
+		}
+		if hllSparseIsZero(op) {
+			p++
+			continue
+		}
+		if p+1 < len(sparse) && hllSparseIsVal(sparse[p+1]) {
+			v1, v2 := hllSparseValValue(op), hllSparseValValue(sparse[p+1])
+			if v1 == v2 {
+				if length := hllSparseValLen(op) + hllSparseValLen(sparse[p+1]); length <= hllSparseValMaxLen {
+					sparse[p+1] = hllSparseVal(v1, length)
+					hll = append(hll[:hllHeaderSize+p], hll[hllHeaderSize+p+1:]...)
+					sparse = hll[hllHeaderSize:]
+					continue
+				}
+			}
+		}
+		p++
+	}
+	hllInvalidateCache(hll)
+	return hll, true, nil
+}
+
+// hllPromote converts hll to dense and sets the register there.
+func hllPromote(hll []byte, index int, count uint8) ([]byte, bool, error) {
+	dense, err := hllSparseToDense(hll)
+	if err != nil {
+		return nil, false, err
+	}
+	hllDenseSet(dense[hllHeaderSize:], index, count)
+	return dense, true, nil
+}
+
+// hllSet raises the register at index to count in either encoding.
+func hllSet(hll []byte, index int, count uint8) ([]byte, bool, error) {
+	if hll[4] == hllEncodingDense {
+		return hll, hllDenseSet(hll[hllHeaderSize:], index, count), nil
+	}
+	return hllSparseSet(hll, index, count)
+}
+
+// hllMerge raises each of registers to the matching register of hll.
+func hllMerge(registers []uint8, hll []byte) error {
+	if hll[4] == hllEncodingDense {
+		for i := range hllRegisters {
+			registers[i] = max(registers[i], hllDenseGet(hll[hllHeaderSize:], i))
+		}
+		return nil
+	}
+
+	index := 0
+	sparse := hll[hllHeaderSize:]
+	for p := 0; p < len(sparse); {
+		op := sparse[p]
+		switch {
+		case hllSparseIsZero(op):
+			index += hllSparseZeroLen(op)
+			p++
+		case hllSparseIsXZero(op):
+			if p+1 >= len(sparse) {
+				return ErrCorruptHLL
+			}
+			index += hllSparseXZeroLen(op, sparse[p+1])
+			p += 2
+		default:
+			runLen, value := hllSparseValLen(op), uint8(hllSparseValValue(op))
+			if index+runLen > hllRegisters {
+				return ErrCorruptHLL
+			}
+			for range runLen {
+				registers[index] = max(registers[index], value)
+				index++
+			}
+			p++
+		}
+	}
+	if index != hllRegisters {
+		return ErrCorruptHLL
+	}
+	return nil
+}
+
+// hllCount estimates the cardinality of registers with the estimator of
+// Otmar Ertl, "New cardinality estimation algorithms for HyperLogLog
+// sketches", as Redis does. Products are rounded explicitly so that the
+// compiler does not fuse them and results match Redis exactly.
+func hllCount(registers []uint8) uint64 {
+	var histogram [64]int
+	for _, register := range registers {
+		histogram[register]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += float64(m * hllSigma(float64(histogram[0])/m))
+	return uint64(math.Round(float64(hllAlphaInf*m*m) / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += float64(x * y)
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= float64(float64((1-x)*(1-x)) * y)
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// hllRegistersOf decodes every register of hll.
+func hllRegistersOf(hll []byte) ([]uint8, error) {
+	registers := make([]uint8, hllRegisters)
+	if err := hllMerge(registers, hll); err != nil {
+		return nil, err
+	}
+	return registers, nil
+}
+
+// hllAt returns a copy of the HyperLogLog at key, or nil when key is
+// missing, along with the entry holding it. The caller must hold s.mu.
+func (s *Store) hllAt(key string) ([]byte, Entry, error) {
+	entry, ok, err := s.stringAt(key)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	if !ok {
+		return nil, Entry{}, nil
+	}
+	hll := []byte(entry.Value)
+	if !isHLL(hll) {
+		return nil, Entry{}, ErrNotHLL
+	}
+	return hll, entry, nil
+}
+
+// PFAdd adds elements to the HyperLogLog at key, creating it when missing,
+// and reports whether any register changed or the key was created.
+func (s *Store) PFAdd(key string, elements []string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hll, entry, err := s.hllAt(key)
+	if err != nil {
+		return false, err
+	}
+	updated := false
+	if hll == nil {
+		hll, updated = newHLL(), true
+	}
+
+	for _, element := range elements {
+		index, count := hllPatLen([]byte(element))
+		var changed bool
+		hll, changed, err = hllSet(hll, index, count)
+		if err != nil {
+			return false, err
+		}
+		updated = updated || changed
+	}
+
+	if updated {
+		hllInvalidateCache(hll)
+		s.setKey(key, Entry{Value: string(hll), TTL: entry.TTL})
+	}
+	return updated, nil
+}
+
+// PFCount estimates the cardinality of the union of the HyperLogLogs at
+// keys. With a single key a valid estimate cached in its header is used,
+// but none is written there: PFCOUNT is not sent to replicas, so the value
+// would differ from theirs.
+func (s *Store) PFCount(keys []string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(keys) == 1 {
+		hll, _, err := s.hllAt(keys[0])
+		if err != nil || hll == nil {
+			return 0, err
+		}
+		if hll[15]&hllCardinalityInvalid == 0 {
+			return binary.LittleEndian.Uint64(hll[8:16]), nil
+		}
+		registers, err := hllRegistersOf(hll)
+		if err != nil {
+			return 0, err
+		}
+		return hllCount(registers), nil
+	}
+
+	registers := make([]uint8, hllRegisters)
+	for _, key := range keys {
+		hll, _, err := s.hllAt(key)
+		if err != nil {
+			return 0, err
+		}
+		if hll == nil {
+			continue
+		}
+		if err := hllMerge(registers, hll); err != nil {
+			return 0, err
+		}
+	}
+	return hllCount(registers), nil
+}
+
+// PFMerge stores at destination the union of the HyperLogLogs at
+// destination and keys. The result is dense if any input was.
+func (s *Store) PFMerge(destination string, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	registers := make([]uint8, hllRegisters)
+	dense := false
+	for _, key := range append([]string{destination}, keys...) {
+		hll, _, err := s.hllAt(key)
+		if err != nil {
+			return err
+		}
+		if hll == nil {
+			continue
+		}
+		dense = dense || hll[4] == hllEncodingDense
+		if err := hllMerge(registers, hll); err != nil {
+			return err
+		}
+	}
+
+	hll, entry, _ := s.hllAt(destination)
+	if hll == nil {
+		hll = newHLL()
+	}
+	var err error
+	if dense {
+		if hll, err = hllSparseToDense(hll); err != nil {
+			return err
+		}
+	}
+	for i, register := range registers {
+		if register == 0 {
+			continue
+		}
+		if hll, _, err = hllSet(hll, i, register); err != nil {
+			return err
+		}
+	}
+	hllInvalidateCache(hll)
+	s.setKey(destination, Entry{Value: string(hll), TTL: entry.TTL})
+	return nil
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorePFAddCount(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+
+	count, err := s.PFCount([]string{"missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+
+	updated, err := s.PFAdd("hll", nil)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	updated, _ = s.PFAdd("hll", nil)
+	assert.False(t, updated)
+
+	updated, _ = s.PFAdd("hll", []string{"a", "b", "c", "d", "e", "f", "g"})
+	assert.True(t, updated)
+	updated, _ = s.PFAdd("hll", []string{"a", "g"})
+	assert.False(t, updated)
+
+	count, err = s.PFCount([]string{"hll"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), count)
+	value, _ := s.Get("hll")
+	assert.Equal(t, byte(hllEncodingSparse), value[4])
+	assert.NotZero(t, value[15]&hllCardinalityInvalid, "PFCOUNT does not cache the estimate")
+}
+
+func TestStorePFAddKeepsTTL(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.PFAdd("hll", nil)
+	entry, _ := s.store.get("hll")
+	entry.TTL = time.Now().Add(time.Hour)
+	s.store.set("hll", entry)
+
+	s.PFAdd("hll", []string{"a"})
+	s.PFCount([]string{"hll"})
+	entry, _ = s.store.get("hll")
+	assert.False(t, entry.TTL.IsZero())
+}
+
+func TestStorePFAddPromotesToDense(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	dense := make([]byte, hllDenseSize)
+	copy(dense, hllMagic)
+
+	for i := range 100000 {
+		element := strconv.Itoa(i)
+		s.PFAdd("hll", []string{element})
+		index, count := hllPatLen([]byte(element))
+		hllDenseSet(dense[hllHeaderSize:], index, count)
+
+		// The sparse encoding must decode to the same registers as the
+		// dense one up to the point where it is promoted.
+		if i == 1000 {
+			value, _ := s.Get("hll")
+			assert.Equal(t, byte(hllEncodingSparse), value[4])
+			registers, err := hllRegistersOf([]byte(value))
+			assert.NoError(t, err)
+			want, _ := hllRegistersOf(dense)
+			assert.Equal(t, want, registers)
+		}
+	}
+
+	value, _ := s.Get("hll")
+	assert.Equal(t, byte(hllEncodingDense), value[4])
+	assert.Equal(t, dense[hllHeaderSize:], []byte(value[hllHeaderSize:]))
+
+	count, err := s.PFCount([]string{"hll"})
+	assert.NoError(t, err)
+	assert.InDelta(t, 100000, float64(count), 100000*0.02)
+}
+
+func TestStorePFMerge(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.PFAdd("a", []string{"foo", "bar", "zap", "a"})
+	s.PFAdd("b", []string{"a", "b", "c", "foo"})
+
+	count, err := s.PFCount([]string{"a", "b", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), count)
+
+	assert.NoError(t, s.PFMerge("dst", []string{"a", "b"}))
+	count, _ = s.PFCount([]string{"dst"})
+	assert.Equal(t, uint64(6), count)
+	value, _ := s.Get("dst")
+	assert.Equal(t, byte(hllEncodingSparse), value[4])
+
+	// The destination takes part in the union.
+	s.PFAdd("c", []string{"x"})
+	assert.NoError(t, s.PFMerge("dst", []string{"c"}))
+	count, _ = s.PFCount([]string{"dst"})
+	assert.Equal(t, uint64(7), count)
+
+	elements := make([]string, 5000)
+	for i := range elements {
+		elements[i] = strconv.Itoa(i)
+	}
+	s.PFAdd("big", elements)
+	assert.NoError(t, s.PFMerge("dst", []string{"big"}))
+	value, _ = s.Get("dst")
+	assert.Equal(t, byte(hllEncodingDense), value[4])
+}
+
+func TestStorePFErrors(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("str", "not an hll", nil)
+	s.SAdd("set", []string{"a"})
+	s.Set("corrupt", hllMagic+"\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f", nil)
+
+	_, err := s.PFAdd("str", []string{"a"})
+	assert.ErrorIs(t, err, ErrNotHLL)
+	_, err = s.PFCount([]string{"set"})
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.PFCount([]string{"corrupt"})
+	assert.ErrorIs(t, err, ErrCorruptHLL)
+	assert.ErrorIs(t, s.PFMerge("dst", []string{"corrupt"}), ErrCorruptHLL)
+}
+
+// The vectors below come from Redis: the bytes of an empty HyperLogLog and
+// its cached cardinality as its test suite checks them, the replies of the
+// PFADD, PFCOUNT and PFMERGE examples of its documentation, and values
+// built by hand from the dense and sparse layouts hyperloglog.c documents.
+func TestStorePFRedisVectors(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+
+	s.PFAdd("empty", nil)
+	value, _ := s.Get("empty")
+	assert.Equal(t, "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff", value)
+
+	s.PFAdd("cache", []string{"a", "b", "c"})
+	value, _ = s.Get("cache")
+	s.store.set("cache", Entry{Value: value[:8] + "\x03\x00\x00\x00\x00\x00\x00\x00" + value[16:]})
+	count, _ := s.PFCount([]string{"cache"})
+	assert.Equal(t, uint64(3), count)
+	value, _ = s.Get("cache")
+	assert.Equal(t, "\x03\x00\x00\x00\x00\x00\x00\x00", value[8:16])
+	s.PFAdd("cache", []string{"a", "b", "c"})
+	value, _ = s.Get("cache")
+	assert.Equal(t, byte(0x00), value[15])
+	s.PFAdd("cache", []string{"1", "2", "3"})
+	value, _ = s.Get("cache")
+	assert.Equal(t, byte(0x80), value[15])
+
+	s.PFAdd("hll", []string{"foo", "bar", "zap"})
+	s.PFAdd("hll", []string{"zap", "zap", "zap"})
+	s.PFAdd("hll", []string{"foo", "bar"})
+	count, _ = s.PFCount([]string{"hll"})
+	assert.Equal(t, uint64(3), count)
+	s.PFAdd("some-other-hll", []string{"1", "2", "3"})
+	count, _ = s.PFCount([]string{"hll", "some-other-hll"})
+	assert.Equal(t, uint64(6), count)
+
+	s.PFAdd("hll1", []string{"foo", "bar", "zap", "a"})
+	s.PFAdd("hll2", []string{"a", "b", "c", "foo"})
+	assert.NoError(t, s.PFMerge("hll3", []string{"hll1", "hll2"}))
+	count, _ = s.PFCount([]string{"hll3"})
+	assert.Equal(t, uint64(6), count)
+
+	// Register 0 set to 3 and register 1 to 5. Dense registers are packed
+	// 6 bits at a time from the least significant bit of each byte; sparse
+	// ones are VAL:3,1 (1 00010 00), VAL:5,1 (1 00100 00) and XZERO:16382.
+	dense := make([]byte, hllDenseSize)
+	copy(dense, "HYLL\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80")
+	dense[hllHeaderSize], dense[hllHeaderSize+1] = 0x43, 0x01
+	sparse := "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x88\x90\x7f\xfd"
+	s.Set("dense", string(dense), nil)
+	s.Set("sparse", sparse, nil)
+	denseCount, err := s.PFCount([]string{"dense"})
+	assert.NoError(t, err)
+	sparseCount, err := s.PFCount([]string{"sparse"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), denseCount)
+	assert.Equal(t, denseCount, sparseCount)
+
+	assert.NoError(t, s.PFMerge("merged", []string{"sparse", "dense"}))
+	value, _ = s.Get("merged")
+	registers, err := hllRegistersOf([]byte(value))
+	assert.NoError(t, err)
+	want := make([]uint8, hllRegisters)
+	want[0], want[1] = 3, 5
+	assert.Equal(t, want, registers)
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// lazyFreeThreshold is Redis's LAZYFREE_THRESHOLD: values whose freeing
+// takes more steps than this are handed to the reclaiming goroutine when
+// lazy freeing applies.
+const lazyFreeThreshold = 64
+
+// LazyFreeOptions are the lazyfree-lazy-* settings, which make evictions,
+// expirations, overwrites and DEL free large values in the background the
+// way UNLINK always does. They are shared by the databases of a server.
+type LazyFreeOptions struct {
+	Eviction  atomic.Bool
+	Expire    atomic.Bool
+	ServerDel atomic.Bool
+	UserDel   atomic.Bool
+}
+
+type lazyFreeJob struct {
+	objects int
+	free    func()
+}
+
+// lazyFreer is the queue of the reclaiming goroutine. Like Redis's lazyfree
+// thread there is one per process, started on first use. The queue is
+// unbounded so that handing a value off never blocks under a Store's lock.
+type lazyFreer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []lazyFreeJob
+	started bool
+	pending atomic.Int64
+	freed   atomic.Int64
+}
+
+var freer lazyFreer
+
+// enqueue queues free, which releases objects values.
+func (f *lazyFreer) enqueue(objects int, free func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.started {
+		f.started = true
+		f.cond = sync.NewCond(&f.mu)
+		go f.run()
+	}
+	f.pending.Add(int64(objects))
+	f.jobs = append(f.jobs, lazyFreeJob{objects: objects, free: free})
+	f.cond.Signal()
+}
+
+func (f *lazyFreer) run() {
+	f.mu.Lock()
+	for {
+		for len(f.jobs) == 0 {
+			f.cond.Wait()
+		}
+		job := f.jobs[0]
+		f.jobs[0] = lazyFreeJob{}
+		f.jobs = f.jobs[1:]
+		f.mu.Unlock()
+
+		job.free()
+		f.pending.Add(-int64(job.objects))
+		f.freed.Add(int64(job.objects))
+		f.mu.Lock()
+	}
+}
+
+// LazyFreePendingObjects returns the number of values waiting to be freed
+// in the background.
+func LazyFreePendingObjects() int64 {
+	return freer.pending.Load()
+}
+
+// LazyFreedObjects returns the number of values freed in the background
+// since startup.
+func LazyFreedObjects() int64 {
+	return freer.freed.Load()
+}
+
+// freeEffort is Redis's lazyfreeGetFreeEffort: about how many steps
+// releasing e takes.
+func (e Entry) freeEffort() int {
+	switch e.Kind {
+	case KindSet:
+		if e.Set.IsIntset() {
+			return 1
+		}
+		return e.Set.Len()
+	case KindZSet:
+		return e.ZSet.Len()
+	case KindStream:
+		effort := e.Stream.index.size
+		for _, g := range e.Stream.groups {
+			effort += g.pel.size + len(g.consumers)
+		}
+		return effort
+	default:
+		return 1
+	}
+}
+
+// free releases e, which was dropped from the keyspace, in the background
+// when lazy is set and it is large enough for that to pay off.
+func free(e Entry, lazy bool) {
+	if lazy && e.freeEffort() > lazyFreeThreshold {
+		freer.enqueue(1, e.release)
+		return
+	}
+	e.release()
+}
+
+// release clears the value of e. The garbage collector reclaims its memory
+// either way, but clearing it is what takes time proportional to its size,
+// the part Redis moves off the main thread, and ensures that nothing still
+// pointing at the value keeps its elements alive.
+func (e Entry) release() {
+	switch e.Kind {
+	case KindSet:
+		e.Set.release()
+	case KindZSet:
+		e.ZSet.release()
+	case KindStream:
+		e.Stream.release()
+	}
+}
+
+func (st *Set) release() {
+	if st.members != nil {
+		st.members.clear()
+	}
+	st.members, st.intset = nil, nil
+	st.bytes = 0
+}
+
+func (z *ZSet) release() {
+	z.scores.clear()
+	for x := z.zsl.header.level[0].forward; x != nil; {
+		next := x.level[0].forward
+		x.level, x.backward = nil, nil
+		x = next
+	}
+	z.zsl = newSkiplist()
+	z.bytes = 0
+}
+
+func (st *Stream) release() {
+	for key, block, ok := st.index.ceil(radixKey{}); ok; key, block, ok = st.nextBlock(key) {
+		block.entries = nil
+	}
+	st.index = radixTree[*streamBlock]{}
+	st.groups = nil
+	st.length = 0
+	st.bytes = 0
+}
+
+// releaseAll releases every value of d, a keyspace dropped by a flush.
+func releaseAll(d *dict[Entry]) {
+	for _, e := range d.all() {
+		e.release()
+	}
+	d.clear()
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addLargeSet stores a hashtable set of n members at key and returns it.
+func addLargeSet(t *testing.T, s *Store, key string, n int) *Set {
+	t.Helper()
+	members := make([]string, n)
+	for i := range members {
+		members[i] = fmt.Sprintf("m%d", i)
+	}
+	_, err := s.SAdd(key, members)
+	require.NoError(t, err)
+	entry, _ := s.store.get(key)
+	return entry.Set
+}
+
+// drainLazyFree waits until every value queued so far has been freed.
+func drainLazyFree() {
+	done := make(chan struct{})
+	freer.enqueue(0, func() { close(done) })
+	<-done
+}
+
+func TestFreeEffort(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("str", "v", nil)
+	_, err := s.SAdd("intset", []string{"1", "2", "3"})
+	require.NoError(t, err)
+	addLargeSet(t, s, "set", 100)
+
+	for key, want := range map[string]int{"str": 1, "intset": 1, "set": 100} {
+		entry, _ := s.store.get(key)
+		assert.Equal(t, want, entry.freeEffort(), key)
+	}
+}
+
+func TestDelFreesValues(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	large := addLargeSet(t, s, "large", lazyFreeThreshold+1)
+	small := addLargeSet(t, s, "small", 2)
+
+	assert.Equal(t, 2, s.Del([]string{"large", "small", "missing"}))
+	assert.Zero(t, large.Len(), "freed right away without lazyfree-lazy-user-del")
+	assert.Zero(t, small.Len())
+	assert.Zero(t, s.DBSize())
+	assert.Zero(t, s.UsedMemory())
+
+	s.lazyFree.UserDel.Store(true)
+	large = addLargeSet(t, s, "large", lazyFreeThreshold+1)
+	small = addLargeSet(t, s, "small", 2)
+	assert.Equal(t, 2, s.Del([]string{"large", "small"}))
+	assert.Zero(t, small.Len(), "small values are not worth handing off")
+	drainLazyFree()
+	assert.Zero(t, large.Len())
+}
+
+func TestUnlinkFreesInBackground(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	large := addLargeSet(t, s, "large", 1000)
+	freed := LazyFreedObjects()
+
+	assert.Equal(t, 1, s.Unlink([]string{"large"}))
+	_, ok := s.store.get("large")
+	assert.False(t, ok)
+	drainLazyFree()
+	assert.Zero(t, large.Len())
+	assert.Greater(t, LazyFreedObjects(), freed)
+}
+
+func TestOverwriteAndExpiryFreeValues(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	old := addLargeSet(t, s, "k", lazyFreeThreshold+1)
+	s.Set("k", "v", nil)
+	assert.Zero(t, old.Len())
+
+	set := addLargeSet(t, s, "kept", 3)
+	s.setKey("kept", Entry{Kind: KindSet, Set: set})
+	assert.Equal(t, 3, set.Len(), "storing the same value again keeps it")
+
+	s.lazyFree.Expire.Store(true)
+	expiring := addLargeSet(t, s, "expiring", lazyFreeThreshold+1)
+	entry, _ := s.store.get("expiring")
+	entry.TTL = time.Now().Add(-time.Second)
+	s.store.set("expiring", entry)
+	_, ok := s.Type("expiring")
+	assert.False(t, ok)
+	drainLazyFree()
+	assert.Zero(t, expiring.Len())
+}
+
+func TestFlushAsync(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	set := addLargeSet(t, s, "set", 10)
+	s.Flush(true)
+	assert.Zero(t, s.DBSize())
+	drainLazyFree()
+	assert.Zero(t, set.Len())
+
+	set = addLargeSet(t, s, "set", 10)
+	s.Flush(false)
+	assert.Zero(t, set.Len())
+}
+
+func TestMoveKeepsValue(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(2)
+	set := addLargeSet(t, dbs.DB(0), "set", lazyFreeThreshold+1)
+	moved, err := dbs.Move(0, 1, "set")
+	require.NoError(t, err)
+	require.True(t, moved)
+	assert.Equal(t, lazyFreeThreshold+1, set.Len())
+	assert.Same(t, dbs.LazyFree(), dbs.DB(1).lazyFree)
+}
@@ -0,0 +1,254 @@
+package store
+
+// Approximate sizes in bytes of the structures behind a key, used to
+// account the memory of each keyspace against maxmemory. They follow the
+// layout of the Go types rather than Redis's allocations, and only need to
+// grow and shrink with the data.
+const (
+	// keyOverhead covers the dict entry, the key's string header and the Entry.
+	keyOverhead = 112
+	// expireOverhead is the entry in the dict of keys with a TTL.
+	expireOverhead = 40
+	// intsetMemberSize is one element of an intset.
+	intsetMemberSize = 8
+	// setMemberOverhead is the dict entry and string header of a hashtable member.
+	setMemberOverhead = 48
+	// zsetMemberOverhead is the dict entry of a member and its skiplist
+	// node, with the average of 4/3 levels.
+	zsetMemberOverhead = 128
+	// streamEntryOverhead is an entry's ID and fields slice header, and
+	// streamFieldOverhead the header of each field and value.
+	streamEntryOverhead = 40
+	streamFieldOverhead = 16
+	// streamGroupOverhead and streamConsumerOverhead are a consumer group
+	// and a consumer with their map entries, and streamNACKSize a pending
+	// entry with its leaf in the group's and the consumer's PEL.
+	streamGroupOverhead    = 128
+	streamConsumerOverhead = 112
+	streamNACKSize         = 96
+	// bucketSize is a slot of a dict's table.
+	bucketSize = 8
+)
+
+// DefaultMemorySamples is the default SAMPLES of MEMORY USAGE.
+const DefaultMemorySamples = 5
+
+// memAccount is the approximate memory held by a keyspace. It is guarded by
+// the mutex of the Store it belongs to.
+type memAccount struct {
+	used int64
+}
+
+// memCounter tracks the size of a collection. While the collection is
+// stored at a key, acct is the account of its keyspace and every change is
+// reported there as well.
+type memCounter struct {
+	bytes int
+	acct  *memAccount
+}
+
+func (m *memCounter) grow(n int) {
+	m.bytes += n
+	if m.acct != nil {
+		m.acct.used += int64(n)
+	}
+}
+
+// counter returns the size counter of a collection entry, or nil for strings.
+func (e Entry) counter() *memCounter {
+	switch e.Kind {
+	case KindSet:
+		return &e.Set.memCounter
+	case KindZSet:
+		return &e.ZSet.memCounter
+	case KindStream:
+		return &e.Stream.memCounter
+	default:
+		return nil
+	}
+}
+
+// memory returns the approximate size of key holding e.
+func (e Entry) memory(key string) int {
+	n := keyOverhead + len(key)
+	if !e.TTL.IsZero() {
+		n += expireOverhead
+	}
+	if c := e.counter(); c != nil {
+		return n + c.bytes
+	}
+	return n + len(e.Value)
+}
+
+// streamEntrySize returns the approximate size of a stream entry.
+func streamEntrySize(entry StreamEntry) int {
+	n := streamEntryOverhead
+	for _, field := range entry.Fields {
+		n += streamFieldOverhead + len(field)
+	}
+	return n
+}
+
+// attach adds key holding e to the account, which the collection then
+// reports its changes to. The caller must hold s.mu.
+func (s *Store) attach(key string, e Entry) {
+	if c := e.counter(); c != nil {
+		c.acct = s.mem
+	}
+	s.mem.used += int64(e.memory(key))
+}
+
+// detach is the reverse of attach. The caller must hold s.mu.
+func (s *Store) detach(key string, e Entry) {
+	if c := e.counter(); c != nil {
+		c.acct = nil
+	}
+	s.mem.used -= int64(e.memory(key))
+}
+
+// sizeSampler averages the size of up to limit elements of a collection,
+// or of every element when limit is 0.
+type sizeSampler struct {
+	limit, seen, total int
+}
+
+// add records an element of n bytes and reports whether to sample more.
+func (s *sizeSampler) add(n int) bool {
+	s.seen++
+	s.total += n
+	return s.limit == 0 || s.seen < s.limit
+}
+
+// extrapolate returns the size of count elements of the average size.
+func (s *sizeSampler) extrapolate(count int) int {
+	if s.seen == 0 {
+		return 0
+	}
+	return int(float64(s.total) / float64(s.seen) * float64(count))
+}
+
+// estimate walks the value of e, sampling collections like Redis's
+// objectComputeSize, and returns its approximate size.
+func (e Entry) estimate(samples int) int {
+	switch e.Kind {
+	case KindSet:
+		return e.Set.estimate(samples)
+	case KindZSet:
+		return e.ZSet.estimate(samples)
+	case KindStream:
+		return e.Stream.estimate(samples)
+	default:
+		return len(e.Value)
+	}
+}
+
+func (st *Set) estimate(samples int) int {
+	if st.IsIntset() {
+		return len(st.intset) * intsetMemberSize
+	}
+	sampler := sizeSampler{limit: samples}
+	for member := range st.members.all() {
+		if !sampler.add(setMemberOverhead + len(member)) {
+			break
+		}
+	}
+	return sampler.extrapolate(st.Len())
+}
+
+func (z *ZSet) estimate(samples int) int {
+	sampler := sizeSampler{limit: samples}
+	for x := z.zsl.first(); x != nil; x = x.level[0].forward {
+		if !sampler.add(zsetMemberOverhead + len(x.member)) {
+			break
+		}
+	}
+	return sampler.extrapolate(z.Len())
+}
+
+// estimate samples the blocks of entries, then each consumer group with
+// its pending entries and a sample of its consumers.
+func (st *Stream) estimate(samples int) int {
+	blocks := sizeSampler{limit: samples}
+	for key, block, ok := st.index.ceil(radixKey{}); ok; key, block, ok = st.nextBlock(key) {
+		size := 0
+		for _, entry := range block.entries {
+			size += streamEntrySize(entry)
+		}
+		if !blocks.add(size) {
+			break
+		}
+	}
+	n := blocks.extrapolate(st.index.size)
+
+	for name, g := range st.groups {
+		n += streamGroupOverhead + len(name) + g.pel.size*streamNACKSize
+		consumers := sizeSampler{limit: samples}
+		for _, c := range g.consumers {
+			if !consumers.add(streamConsumerOverhead + len(c.name)) {
+				break
+			}
+		}
+		n += consumers.extrapolate(len(g.consumers))
+	}
+	return n
+}
+
+// MemoryUsage estimates the memory used by key and its value from up to
+// samples elements of collections, or all of them when samples is 0.
+// Unlike the running account it includes the consumer groups of streams.
+func (s *Store) MemoryUsage(key string, samples int) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.peek(key)
+	if !ok {
+		return 0, false
+	}
+	n := keyOverhead + len(key) + e.estimate(samples)
+	if !e.TTL.IsZero() {
+		n += expireOverhead
+	}
+	return n, true
+}
+
+// KeyspaceMemory is the memory of a database as MEMORY STATS reports it:
+// the overhead of its dicts of keys and of keys with a TTL, and the dataset,
+// the key names and values they hold.
+type KeyspaceMemory struct {
+	DB      int
+	Keys    int
+	Main    int64
+	Expires int64
+	Dataset int64
+}
+
+func (s *Store) keyspaceMemory(db int) KeyspaceMemory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := len(s.store.tables[0]) + len(s.store.tables[1])
+	expireBuckets := len(s.expires.tables[0]) + len(s.expires.tables[1])
+	return KeyspaceMemory{
+		DB:      db,
+		Keys:    s.store.len(),
+		Main:    int64(buckets*bucketSize + s.store.len()*keyOverhead),
+		Expires: int64(expireBuckets*bucketSize + s.expires.len()*expireOverhead),
+		Dataset: s.mem.used - int64(s.store.len()*keyOverhead+s.expires.len()*expireOverhead),
+	}
+}
+
+// KeyspaceMemory returns the memory of the databases holding keys.
+func (d *Databases) KeyspaceMemory() []KeyspaceMemory {
+	var stats []KeyspaceMemory
+	for i, db := range d.dbs {
+		if m := db.keyspaceMemory(i); m.Keys > 0 {
+			stats = append(stats, m)
+		}
+	}
+	return stats
+}
+
+// UsedMemory returns the approximate memory held by the keys of s.
+func (s *Store) UsedMemory() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.used
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recount sums the memory of every key of s from scratch.
+func (s *Store) recount() int64 {
+	var used int64
+	for key, e := range s.store.all() {
+		used += int64(e.memory(key))
+	}
+	return used
+}
+
+func TestMemoryAccountingFollowsWrites(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	assert.Zero(t, s.UsedMemory())
+
+	s.Set("k", "value", nil)
+	assert.Equal(t, int64(keyOverhead+len("k")+len("value")), s.UsedMemory())
+	ttl := time.Hour
+	s.Set("k", "v", &ttl)
+	assert.Equal(t, int64(keyOverhead+len("k")+len("v")+expireOverhead), s.UsedMemory())
+
+	_, err := s.SAdd("set", []string{"1", "2", "3"})
+	require.NoError(t, err)
+	assert.Equal(t, s.recount(), s.UsedMemory())
+	_, err = s.SAdd("set", []string{"member"})
+	require.NoError(t, err)
+	assert.Equal(t, s.recount(), s.UsedMemory(), "after converting to a hashtable")
+
+	_, err = s.ZAdd("zset", ZAddFlags{}, false, []ScoredMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}})
+	require.NoError(t, err)
+	_, _, err = s.ZAddIncr("zset", ZAddFlags{}, "a", 5)
+	require.NoError(t, err)
+	assert.Equal(t, s.recount(), s.UsedMemory())
+
+	for range 3 {
+		_, _, err := s.XAdd("stream", StreamIDSpec{AutoMs: true, AutoSeq: true}, []string{"field", "value"}, false, nil)
+		require.NoError(t, err)
+	}
+	_, err = s.XTrim("stream", StreamTrim{Strategy: StreamTrimMaxLen, MaxLen: 1})
+	require.NoError(t, err)
+	assert.Equal(t, s.recount(), s.UsedMemory())
+
+	_, err = s.SRem("set", []string{"1", "2", "3", "member"})
+	require.NoError(t, err)
+	_, err = s.ZRem("zset", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, s.recount(), s.UsedMemory(), "emptied collections are deleted")
+
+	s.Flush(false)
+	assert.Zero(t, s.UsedMemory())
+}
+
+func TestMemoryAccountingExpiryAndMove(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(2)
+	ttl := time.Millisecond
+	dbs.DB(0).Set("gone", "v", &ttl)
+	_, err := dbs.DB(0).SAdd("set", []string{"a", "b"})
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := dbs.DB(0).Get("gone")
+	assert.False(t, ok)
+	assert.Equal(t, dbs.DB(0).recount(), dbs.DB(0).UsedMemory())
+
+	moved, err := dbs.Move(0, 1, "set")
+	require.NoError(t, err)
+	require.True(t, moved)
+	assert.Zero(t, dbs.DB(0).UsedMemory())
+	_, err = dbs.DB(1).SAdd("set", []string{"c"})
+	require.NoError(t, err)
+	assert.Equal(t, dbs.DB(1).recount(), dbs.DB(1).UsedMemory(), "the set reports to its new database")
+
+	dbs.Swap(0, 1)
+	assert.Zero(t, dbs.DB(1).UsedMemory())
+	_, err = dbs.DB(0).SAdd("set", []string{"d"})
+	require.NoError(t, err)
+	assert.Equal(t, dbs.DB(0).recount(), dbs.DB(0).UsedMemory())
+	assert.Equal(t, dbs.DB(0).UsedMemory(), dbs.UsedMemory())
+}
+
+func TestMemoryUsage(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	_, ok := s.MemoryUsage("missing", 0)
+	assert.False(t, ok)
+
+	ttl := time.Hour
+	s.Set("str", "value", &ttl)
+	n, ok := s.MemoryUsage("str", DefaultMemorySamples)
+	assert.True(t, ok)
+	assert.Equal(t, keyOverhead+len("str")+len("value")+expireOverhead, n)
+
+	members := make([]string, 100)
+	scored := make([]ScoredMember, 100)
+	for i := range members {
+		members[i] = fmt.Sprintf("member-%03d", i)
+		scored[i] = ScoredMember{Member: members[i], Score: float64(i)}
+	}
+	_, err := s.SAdd("set", members)
+	require.NoError(t, err)
+	_, err = s.ZAdd("zset", ZAddFlags{}, false, scored)
+	require.NoError(t, err)
+	for _, key := range []string{"set", "zset"} {
+		all, _ := s.MemoryUsage(key, 0)
+		entry, _ := s.store.get(key)
+		assert.Equal(t, entry.memory(key), all, "sampling everything matches the account")
+		sampled, _ := s.MemoryUsage(key, 1)
+		assert.Equal(t, all, sampled, "members of equal length extrapolate exactly")
+	}
+}
+
+func TestMemoryUsageCountsStreamGroups(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	for range 5 {
+		_, _, err := s.XAdd("stream", StreamIDSpec{AutoMs: true, AutoSeq: true}, []string{"f", "v"}, false, nil)
+		require.NoError(t, err)
+	}
+	before, _ := s.MemoryUsage("stream", 0)
+	entry, _ := s.store.get("stream")
+	assert.Equal(t, entry.memory("stream"), before)
+
+	require.NoError(t, s.XGroupCreate("stream", "group", StreamID{}, false, false, 0))
+	after, _ := s.MemoryUsage("stream", 0)
+	assert.Equal(t, before+streamGroupOverhead+len("group"), after)
+}
+
+func TestKeyspaceMemory(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(3)
+	ttl := time.Hour
+	dbs.DB(1).Set("a", "value", nil)
+	dbs.DB(1).Set("b", "value", &ttl)
+
+	stats := dbs.KeyspaceMemory()
+	require.Len(t, stats, 1)
+	assert.Equal(t, 1, stats[0].DB)
+	assert.Equal(t, 2, stats[0].Keys)
+	assert.Equal(t, int64(dictInitialSize*bucketSize+2*keyOverhead), stats[0].Main)
+	assert.Equal(t, int64(dictInitialSize*bucketSize+expireOverhead), stats[0].Expires)
+	assert.Equal(t, int64(len("a")+len("b")+2*len("value")), stats[0].Dataset)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// Access counter parameters, Redis's LFU_INIT_VAL and the defaults of
+// lfu-log-factor and lfu-decay-time.
+const (
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+)
+
+// sharedIntegers mirrors Redis's OBJ_SHARED_INTEGERS: small integer strings
+// are served from shared objects, whose refcount reads as sharedRefCount.
+const (
+	sharedIntegers = 10000
+	sharedRefCount = math.MaxInt32
+)
+
+// embstrMaxLen is the longest string Redis allocates together with its
+// object header.
+const embstrMaxLen = 44
+
+// touch records an access at now. The counter first decays by one for each
+// lfuDecayTime since the previous access, then grows with a probability
+// that falls as it gets larger, so that 255 takes about a million accesses.
+func (e *Entry) touch(now time.Time) {
+	e.Freq = lfuLogIncr(e.decayedFreq(now))
+	e.Access = now
+}
+
+// decayedFreq returns the access counter as of now.
+func (e Entry) decayedFreq(now time.Time) uint8 {
+	periods := now.Sub(e.Access) / lfuDecayTime
+	if periods >= time.Duration(e.Freq) {
+		return 0
+	}
+	return e.Freq - uint8(periods)
+}
+
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == math.MaxUint8 {
+		return counter
+	}
+	base := max(float64(counter)-lfuInitVal, 0)
+	if rand.Float64() < 1/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// sharedInteger reports whether value is an integer Redis would serve from
+// its shared objects.
+func sharedInteger(value string) bool {
+	n, ok := canonicalInt(value)
+	return ok && n >= 0 && n < sharedIntegers
+}
+
+// canonicalInt parses value when it is the canonical representation of a
+// 64-bit integer, the strings Redis stores as integers.
+func canonicalInt(value string) (int64, bool) {
+	if len(value) == 0 || len(value) > 20 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != value {
+		return 0, false
+	}
+	return n, true
+}
+
+// encoding returns the name Redis gives the representation of the value.
+// Go strings are all alike, so strings are named after what Redis would
+// choose for them.
+func (e Entry) encoding() string {
+	switch e.Kind {
+	case KindString:
+		if _, ok := canonicalInt(e.Value); ok {
+			return "int"
+		}
+		if len(e.Value) <= embstrMaxLen {
+			return "embstr"
+		}
+		return "raw"
+	case KindSet:
+		if e.Set.IsIntset() {
+			return "intset"
+		}
+		return "hashtable"
+	case KindZSet:
+		return "skiplist"
+	case KindStream:
+		return "stream"
+	default:
+		panic("unknown kind")
+	}
+}
+
+// ObjectInfo is what OBJECT reports about a key.
+type ObjectInfo struct {
+	Encoding string
+	RefCount int
+	Idle     time.Duration
+	Freq     uint8
+}
+
+// Object describes the value at key without counting as an access.
+func (s *Store) Object(key string) (ObjectInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.peek(key)
+	if !ok {
+		return ObjectInfo{}, false
+	}
+	now := time.Now()
+	info := ObjectInfo{
+		Encoding: entry.encoding(),
+		RefCount: 1,
+		Idle:     now.Sub(entry.Access),
+		Freq:     entry.decayedFreq(now),
+	}
+	if entry.Kind == KindString && sharedInteger(entry.Value) {
+		info.RefCount = sharedRefCount
+	}
+	return info, true
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreObjectEncoding(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("int", "-42", nil)
+	s.Set("padded", "042", nil)
+	s.Set("short", strings.Repeat("a", 44), nil)
+	s.Set("long", strings.Repeat("a", 45), nil)
+	s.SAdd("intset", []string{"1", "2"})
+	s.SAdd("hashtable", []string{"1", "a"})
+	s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{Member: "a", Score: 1}})
+	_, _, err := s.XAdd("x", StreamIDSpec{AutoMs: true}, []string{"f", "v"}, false, nil)
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"int": "int", "padded": "embstr", "short": "embstr", "long": "raw",
+		"intset": "intset", "hashtable": "hashtable", "z": "skiplist", "x": "stream",
+	}
+	for key, want := range cases {
+		info, ok := s.Object(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, info.Encoding, key)
+	}
+	_, ok := s.Object("missing")
+	assert.False(t, ok)
+}
+
+func TestStoreObjectRefCount(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("shared", "9999", nil)
+	s.Set("big", "10000", nil)
+	info, _ := s.Object("shared")
+	assert.Equal(t, sharedRefCount, info.RefCount)
+	info, _ = s.Object("big")
+	assert.Equal(t, 1, info.RefCount)
+}
+
+func TestStoreAccessTracking(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("k", "v", nil)
+	entry, _ := s.store.get("k")
+	assert.Equal(t, uint8(lfuInitVal), entry.Freq)
+
+	// Pretend the key was last accessed a while ago.
+	entry.Access = time.Now().Add(-3 * time.Minute)
+	entry.Freq = 10
+	s.store.set("k", entry)
+
+	info, _ := s.Object("k")
+	assert.GreaterOrEqual(t, info.Idle, 3*time.Minute)
+	assert.Equal(t, uint8(7), info.Freq, "three decay periods have passed")
+	s.Type("k")
+	info, _ = s.Object("k")
+	assert.GreaterOrEqual(t, info.Idle, 3*time.Minute, "TYPE does not count as an access")
+
+	s.Get("k")
+	info, _ = s.Object("k")
+	assert.Less(t, info.Idle, time.Second)
+	assert.Contains(t, []uint8{7, 8}, info.Freq)
+
+	// Overwriting keeps the counter.
+	entry, _ = s.store.get("k")
+	entry.Freq = 100
+	s.store.set("k", entry)
+	s.Set("k", "new", nil)
+	info, _ = s.Object("k")
+	assert.Equal(t, uint8(100), info.Freq)
+}
+
+func TestLFULogIncr(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, uint8(255), lfuLogIncr(255))
+	assert.Equal(t, uint8(1), lfuLogIncr(0), "counters below the initial value always grow")
+
+	counter := uint8(lfuInitVal)
+	for range 1000 {
+		counter = lfuLogIncr(counter)
+	}
+	// With a log factor of 10, a thousand hits reach about 19.
+	assert.GreaterOrEqual(t, counter, uint8(10))
+	assert.LessOrEqual(t, counter, uint8(30))
+}
+
+func TestStoreRestoreAccessMetadata(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	idle := time.Hour
+	freq := uint8(200)
+	require.NoError(t, s.Restore("idle", redisDocsPayload, RestoreOptions{Idle: &idle}))
+	require.NoError(t, s.Restore("freq", redisDocsPayload, RestoreOptions{Freq: &freq}))
+
+	info, _ := s.Object("idle")
+	assert.GreaterOrEqual(t, info.Idle, time.Hour)
+	info, _ = s.Object("freq")
+	assert.Equal(t, uint8(200), info.Freq)
+}
+
+func TestLoadRDBAccessMetadata(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	enc := rdb.NewEncoder(&buf)
+	enc.WriteHeader()
+	enc.WriteType(rdb.OpIdle)
+	enc.WriteLength(7200)
+	enc.WriteType(rdb.TypeString)
+	enc.WriteString("idle")
+	enc.WriteString("v")
+	enc.WriteType(rdb.OpFreq)
+	enc.WriteRaw([]byte{42})
+	enc.WriteType(rdb.TypeString)
+	enc.WriteString("freq")
+	enc.WriteString("v")
+	enc.WriteType(rdb.TypeString)
+	enc.WriteString("plain")
+	enc.WriteString("v")
+	enc.WriteEOF()
+
+	dbs := NewDatabases(1)
+	require.NoError(t, dbs.LoadRDB(&buf))
+	info, _ := dbs.DB(0).Object("idle")
+	assert.GreaterOrEqual(t, info.Idle, 2*time.Hour)
+	info, _ = dbs.DB(0).Object("freq")
+	assert.Equal(t, uint8(42), info.Freq)
+	info, _ = dbs.DB(0).Object("plain")
+	assert.Less(t, info.Idle, time.Minute)
+	assert.Equal(t, uint8(lfuInitVal), info.Freq)
+}
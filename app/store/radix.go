@@ -0,0 +1,182 @@
+package store
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// radixKeyLen is the length of a stream ID encoded as two big-endian uint64s.
+const radixKeyLen = 16
+
+type radixKey [radixKeyLen]byte
+
+func radixKeyOf(id StreamID) radixKey {
+	var key radixKey
+	binary.BigEndian.PutUint64(key[:8], id.Ms)
+	binary.BigEndian.PutUint64(key[8:], id.Seq)
+	return key
+}
+
+func (k radixKey) id() StreamID {
+	return StreamID{Ms: binary.BigEndian.Uint64(k[:8]), Seq: binary.BigEndian.Uint64(k[8:])}
+}
+
+// radixNode is one byte level of the tree. Children are kept sorted by their
+// edge byte so that ordered seeks are a binary search per level.
+type radixNode[V any] struct {
+	edges    []byte
+	children []*radixNode[V]
+	value    V
+	leaf     bool
+}
+
+// radixTree maps fixed-length stream IDs to values, mirroring how Redis uses
+// a rax both to index stream listpacks by their master ID and to hold the
+// pending entries lists of consumer groups. Because the key is the big-endian
+// ID, byte order equals ID order and range scans become ordered seeks.
+type radixTree[V any] struct {
+	root radixNode[V]
+	size int
+}
+
+func (t *radixTree[V]) insert(key radixKey, value V) {
+	node := &t.root
+	for depth := range radixKeyLen {
+		b := key[depth]
+		i := sort.Search(len(node.edges), func(i int) bool { return node.edges[i] >= b })
+		if i == len(node.edges) || node.edges[i] != b {
+			node.edges = append(node.edges, 0)
+			copy(node.edges[i+1:], node.edges[i:])
+			node.edges[i] = b
+			node.children = append(node.children, nil)
+			copy(node.children[i+1:], node.children[i:])
+			node.children[i] = &radixNode[V]{}
+		}
+		node = node.children[i]
+	}
+	if !node.leaf {
+		t.size++
+	}
+	node.value, node.leaf = value, true
+}
+
+// remove deletes key, pruning nodes left without children.
+func (t *radixTree[V]) remove(key radixKey) bool {
+	var path [radixKeyLen]*radixNode[V]
+	var indexes [radixKeyLen]int
+
+	node := &t.root
+	for depth := range radixKeyLen {
+		b := key[depth]
+		i := sort.Search(len(node.edges), func(i int) bool { return node.edges[i] >= b })
+		if i == len(node.edges) || node.edges[i] != b {
+			return false
+		}
+		path[depth], indexes[depth] = node, i
+		node = node.children[i]
+	}
+	if !node.leaf {
+		return false
+	}
+	var zero V
+	node.value, node.leaf = zero, false
+	t.size--
+
+	for depth := radixKeyLen - 1; depth >= 0; depth-- {
+		parent, i := path[depth], indexes[depth]
+		if len(parent.children[i].children) > 0 {
+			break
+		}
+		parent.edges = append(parent.edges[:i], parent.edges[i+1:]...)
+		parent.children = append(parent.children[:i], parent.children[i+1:]...)
+	}
+	return true
+}
+
+// get returns the value stored at key.
+func (t *radixTree[V]) get(key radixKey) (V, bool) {
+	node := &t.root
+	for depth := range radixKeyLen {
+		b := key[depth]
+		i := sort.Search(len(node.edges), func(i int) bool { return node.edges[i] >= b })
+		if i == len(node.edges) || node.edges[i] != b {
+			var zero V
+			return zero, false
+		}
+		node = node.children[i]
+	}
+	return node.value, node.leaf
+}
+
+// ceil returns the smallest key greater than or equal to key.
+func (t *radixTree[V]) ceil(key radixKey) (radixKey, V, bool) {
+	var out radixKey
+	value, ok := radixCeil(&t.root, key, 0, true, &out)
+	return out, value, ok
+}
+
+// floor returns the greatest key less than or equal to key.
+func (t *radixTree[V]) floor(key radixKey) (radixKey, V, bool) {
+	var out radixKey
+	value, ok := radixFloor(&t.root, key, 0, true, &out)
+	return out, value, ok
+}
+
+// nodes counts the nodes of the tree, including the root.
+func (t *radixTree[V]) nodes() int {
+	var count func(node *radixNode[V]) int
+	count = func(node *radixNode[V]) int {
+		n := 1
+		for _, child := range node.children {
+			n += count(child)
+		}
+		return n
+	}
+	return count(&t.root)
+}
+
+// radixCeil descends at most radixKeyLen levels. While bounded is true the
+// path so far equals the prefix of key; once it is false any leaf qualifies
+// and the leftmost one is taken.
+func radixCeil[V any](node *radixNode[V], key radixKey, depth int, bounded bool, out *radixKey) (V, bool) {
+	if depth == radixKeyLen {
+		return node.value, node.leaf
+	}
+
+	start := 0
+	if bounded {
+		b := key[depth]
+		start = sort.Search(len(node.edges), func(i int) bool { return node.edges[i] >= b })
+	}
+	for i := start; i < len(node.edges); i++ {
+		out[depth] = node.edges[i]
+		stillBounded := bounded && node.edges[i] == key[depth]
+		if value, ok := radixCeil(node.children[i], key, depth+1, stillBounded, out); ok {
+			return value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// radixFloor mirrors radixCeil, taking the rightmost leaf once unbounded.
+func radixFloor[V any](node *radixNode[V], key radixKey, depth int, bounded bool, out *radixKey) (V, bool) {
+	if depth == radixKeyLen {
+		return node.value, node.leaf
+	}
+
+	start := len(node.edges) - 1
+	if bounded {
+		b := key[depth]
+		start = sort.Search(len(node.edges), func(i int) bool { return node.edges[i] > b }) - 1
+	}
+	for i := start; i >= 0; i-- {
+		out[depth] = node.edges[i]
+		stillBounded := bounded && node.edges[i] == key[depth]
+		if value, ok := radixFloor(node.children[i], key, depth+1, stillBounded, out); ok {
+			return value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
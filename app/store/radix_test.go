@@ -0,0 +1,58 @@
+package store
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRadixTreeMatchesSortedModel(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewPCG(3, 4))
+	var tree radixTree[*streamBlock]
+	model := map[StreamID]*streamBlock{}
+	for range 3000 {
+		id := StreamID{Ms: uint64(rng.IntN(1000)), Seq: uint64(rng.IntN(3))}
+		if rng.IntN(4) == 0 {
+			_, exists := model[id]
+			assert.Equal(t, exists, tree.remove(radixKeyOf(id)))
+			delete(model, id)
+			continue
+		}
+		block := &streamBlock{}
+		tree.insert(radixKeyOf(id), block)
+		model[id] = block
+	}
+	assert.Equal(t, len(model), tree.size)
+
+	ids := make([]StreamID, 0, len(model))
+	for id := range model {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, StreamID.Compare)
+
+	for range 500 {
+		probe := StreamID{Ms: uint64(rng.IntN(1100)), Seq: uint64(rng.IntN(3))}
+		i, _ := slices.BinarySearchFunc(ids, probe, StreamID.Compare)
+
+		key, block, ok := tree.ceil(radixKeyOf(probe))
+		assert.Equal(t, i < len(ids), ok)
+		if ok {
+			assert.Equal(t, ids[i], key.id())
+			assert.Same(t, model[ids[i]], block)
+		}
+
+		j := i
+		if j == len(ids) || ids[j] != probe {
+			j--
+		}
+		key, block, ok = tree.floor(radixKeyOf(probe))
+		assert.Equal(t, j >= 0, ok)
+		if ok {
+			assert.Equal(t, ids[j], key.id())
+			assert.Same(t, model[ids[j]], block)
+		}
+	}
+}
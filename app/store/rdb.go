@@ -0,0 +1,752 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+// ErrCorruptRDB is returned when an RDB file or payload is malformed.
+var ErrCorruptRDB = errors.New("corrupt RDB data")
+
+// Flags of the entries of a stream listpack node.
+const (
+	streamItemDeleted    = 1
+	streamItemSameFields = 2
+)
+
+// SaveRDB writes every database as an RDB file. Each database is locked
+// while it is written, so the snapshot is consistent per database only.
+func (d *Databases) SaveRDB(w io.Writer) error {
+	enc := rdb.NewEncoder(w)
+	enc.WriteHeader()
+	enc.WriteAux("redis-ver", "7.2.0")
+	enc.WriteAux("redis-bits", strconv.Itoa(strconv.IntSize))
+	enc.WriteAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	enc.WriteAux("aof-base", "0")
+	policy := d.EvictionPolicy()
+	for i, db := range d.dbs {
+		db.writeRDB(enc, i, policy)
+	}
+	enc.WriteEOF()
+	return enc.Err()
+}
+
+// writeRDB writes the live keys of s as database index db, or nothing when
+// it is empty. Like Redis, the idle time or access counter of each key is
+// saved when the eviction policy uses it.
+func (s *Store) writeRDB(enc *rdb.Encoder, db int, policy EvictionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store.len() == 0 {
+		return
+	}
+
+	enc.WriteSelectDB(db)
+	enc.WriteResizeDB(s.store.len(), s.expires.len())
+
+	now := time.Now()
+	for key, entry := range s.store.all() {
+		if entry.expired(now) {
+			continue
+		}
+		if !entry.TTL.IsZero() {
+			enc.WriteExpireTime(entry.TTL)
+		}
+		switch policy {
+		case AllKeysLRU, VolatileLRU:
+			enc.WriteType(rdb.OpIdle)
+			enc.WriteLength(uint64(max(now.Sub(entry.Access), 0) / time.Second))
+		case AllKeysLFU, VolatileLFU:
+			enc.WriteType(rdb.OpFreq)
+			enc.WriteRaw([]byte{entry.decayedFreq(now)})
+		}
+		enc.WriteType(valueType(entry))
+		enc.WriteString(key)
+		writeObject(enc, entry)
+	}
+}
+
+// valueType returns the RDB type entry is written as.
+func valueType(entry Entry) byte {
+	switch entry.Kind {
+	case KindString:
+		return rdb.TypeString
+	case KindSet:
+		return rdb.TypeSet
+	case KindZSet:
+		return rdb.TypeZSet2
+	case KindStream:
+		return rdb.TypeStreamListpacks3
+	default:
+		panic("unknown kind")
+	}
+}
+
+// writeObject writes the value of entry in the encoding of its valueType.
+func writeObject(enc *rdb.Encoder, entry Entry) {
+	switch entry.Kind {
+	case KindString:
+		enc.WriteString(entry.Value)
+	case KindSet:
+		members := entry.Set.Members()
+		enc.WriteLength(uint64(len(members)))
+		for _, member := range members {
+			enc.WriteString(member)
+		}
+	case KindZSet:
+		enc.WriteLength(uint64(entry.ZSet.Len()))
+		// Highest scores first, so that loading inserts at the head.
+		for x := entry.ZSet.zsl.tail; x != nil; x = x.backward {
+			enc.WriteString(x.member)
+			enc.WriteBinaryDouble(x.score)
+		}
+	case KindStream:
+		entry.Stream.writeRDB(enc)
+	default:
+		panic("unknown kind")
+	}
+}
+
+// writeRDB writes the stream as Redis does: each block as a listpack keyed
+// by its master ID, the stream metadata, then the consumer groups with
+// their pending entries and consumers.
+func (st *Stream) writeRDB(enc *rdb.Encoder) {
+	type node struct {
+		key   radixKey
+		block *streamBlock
+	}
+	var nodes []node
+	for key, block, ok := st.index.ceil(radixKey{}); ok; key, block, ok = st.nextBlock(key) {
+		nodes = append(nodes, node{key, block})
+	}
+	enc.WriteLength(uint64(len(nodes)))
+	for _, n := range nodes {
+		enc.WriteString(string(n.key[:]))
+		enc.WriteString(string(streamListpack(n.key.id(), n.block.entries).Bytes()))
+	}
+
+	first := StreamID{}
+	if entry, ok := st.first(); ok {
+		first = entry.ID
+	}
+	enc.WriteLength(uint64(st.length))
+	writeStreamID(enc, st.lastID)
+	writeStreamID(enc, first)
+	writeStreamID(enc, st.maxDeletedID)
+	enc.WriteLength(st.entriesAdded)
+
+	names := make([]string, 0, len(st.groups))
+	for name := range st.groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	enc.WriteLength(uint64(len(names)))
+	for _, name := range names {
+		g := st.groups[name]
+		enc.WriteString(name)
+		writeStreamID(enc, g.lastID)
+		enc.WriteLength(uint64(g.entriesRead))
+
+		enc.WriteLength(uint64(g.pel.size))
+		pelRange(&g.pel, StreamID{}, MaxStreamID, func(id StreamID, nack *streamNACK) bool {
+			key := radixKeyOf(id)
+			enc.WriteRaw(key[:])
+			enc.WriteMillis(nack.deliveryTime.UnixMilli())
+			enc.WriteLength(uint64(nack.deliveryCount))
+			return true
+		})
+
+		consumers := sortedConsumerNames(g)
+		enc.WriteLength(uint64(len(consumers)))
+		for _, name := range consumers {
+			c := g.consumers[name]
+			enc.WriteString(name)
+			enc.WriteMillis(c.seenTime.UnixMilli())
+			activeTime := int64(-1)
+			if !c.activeTime.IsZero() {
+				activeTime = c.activeTime.UnixMilli()
+			}
+			enc.WriteMillis(activeTime)
+			enc.WriteLength(uint64(c.pel.size))
+			pelRange(&c.pel, StreamID{}, MaxStreamID, func(id StreamID, _ *streamNACK) bool {
+				key := radixKeyOf(id)
+				enc.WriteRaw(key[:])
+				return true
+			})
+		}
+	}
+}
+
+func writeStreamID(enc *rdb.Encoder, id StreamID) {
+	enc.WriteLength(id.Ms)
+	enc.WriteLength(id.Seq)
+}
+
+// streamListpack encodes entries as a stream node whose master entry holds
+// the fields of the first one. Entries with the same fields only store
+// their values. IDs are stored as deltas from master.
+func streamListpack(master StreamID, entries []StreamEntry) *rdb.Listpack {
+	lp := &rdb.Listpack{}
+	masterFields := make([]string, 0, len(entries[0].Fields)/2)
+	for i := 0; i < len(entries[0].Fields); i += 2 {
+		masterFields = append(masterFields, entries[0].Fields[i])
+	}
+
+	lp.AppendInt(int64(len(entries)))
+	lp.AppendInt(0)
+	lp.AppendInt(int64(len(masterFields)))
+	for _, field := range masterFields {
+		lp.AppendString(field)
+	}
+	lp.AppendInt(0)
+
+	for _, entry := range entries {
+		n := len(entry.Fields) / 2
+		same := n == len(masterFields)
+		for i := 0; same && i < n; i++ {
+			same = entry.Fields[2*i] == masterFields[i]
+		}
+
+		flags := int64(0)
+		if same {
+			flags = streamItemSameFields
+		}
+		lp.AppendInt(flags)
+		lp.AppendInt(int64(entry.ID.Ms - master.Ms))
+		lp.AppendInt(int64(entry.ID.Seq - master.Seq))
+		if same {
+			for i := 1; i < len(entry.Fields); i += 2 {
+				lp.AppendString(entry.Fields[i])
+			}
+			lp.AppendInt(int64(n + 3))
+		} else {
+			lp.AppendInt(int64(n))
+			for _, s := range entry.Fields {
+				lp.AppendString(s)
+			}
+			lp.AppendInt(int64(2*n + 4))
+		}
+	}
+	return lp
+}
+
+// LoadRDB adds the keys of an RDB file to the databases, replacing existing
+// keys with the same name. Keys that have already expired are skipped.
+func (d *Databases) LoadRDB(r io.Reader) error {
+	dec := rdb.NewDecoder(r)
+	version, err := dec.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	db := d.dbs[0]
+	// The expiry and access metadata opcodes apply to the key after them.
+	var ttl time.Time
+	var idle *time.Duration
+	var freq *uint8
+	for {
+		typ, err := dec.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case rdb.OpEOF:
+			if version < 5 {
+				return nil
+			}
+			return dec.ReadChecksum()
+		case rdb.OpSelectDB:
+			n, err := dec.ReadLength()
+			if err != nil {
+				return err
+			}
+			if n >= uint64(len(d.dbs)) {
+				return fmt.Errorf("data file was created with a server configured to handle more than %d databases", len(d.dbs))
+			}
+			db = d.dbs[n]
+		case rdb.OpResizeDB:
+			if _, err := dec.ReadLength(); err != nil {
+				return err
+			}
+			if _, err := dec.ReadLength(); err != nil {
+				return err
+			}
+		case rdb.OpAux:
+			if _, err := dec.ReadString(); err != nil {
+				return err
+			}
+			if _, err := dec.ReadString(); err != nil {
+				return err
+			}
+		case rdb.OpFunction2:
+			// Functions are not supported; their code is skipped.
+			if _, err := dec.ReadString(); err != nil {
+				return err
+			}
+		case rdb.OpExpireTimeMs:
+			ms, err := dec.ReadMillis()
+			if err != nil {
+				return err
+			}
+			ttl = time.UnixMilli(ms)
+		case rdb.OpExpireTime:
+			if ttl, err = dec.ReadSeconds(); err != nil {
+				return err
+			}
+		case rdb.OpFreq:
+			b, err := dec.ReadByte()
+			if err != nil {
+				return err
+			}
+			freq = &b
+		case rdb.OpIdle:
+			secs, err := dec.ReadLength()
+			if err != nil {
+				return err
+			}
+			d := time.Duration(secs) * time.Second
+			idle = &d
+		default:
+			key, err := dec.ReadString()
+			if err != nil {
+				return err
+			}
+			entry, ok, err := readValue(dec, typ)
+			if err != nil {
+				return fmt.Errorf("loading key %q: %w", key, err)
+			}
+			now := time.Now()
+			entry.TTL, entry.Access, entry.Freq = ttl, now, lfuInitVal
+			if idle != nil {
+				entry.Access = now.Add(-*idle)
+			}
+			if freq != nil {
+				entry.Freq = *freq
+			}
+			ttl, idle, freq = time.Time{}, nil, nil
+			if !ok || entry.expired(now) {
+				continue
+			}
+			db.mu.Lock()
+			db.setKey(key, entry)
+			db.mu.Unlock()
+		}
+	}
+}
+
+// readValue reads a value of type typ. It reports false for the empty
+// collections older servers could write, which are not loaded.
+func readValue(dec *rdb.Decoder, typ byte) (Entry, bool, error) {
+	switch typ {
+	case rdb.TypeString:
+		value, err := dec.ReadString()
+		return Entry{Kind: KindString, Value: value}, true, err
+	case rdb.TypeSet, rdb.TypeSetIntset, rdb.TypeSetListpack:
+		set, err := readSet(dec, typ)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		return Entry{Kind: KindSet, Set: set}, set.Len() > 0, nil
+	case rdb.TypeZSet, rdb.TypeZSet2, rdb.TypeZSetListpack:
+		zset, err := readZSet(dec, typ)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		return Entry{Kind: KindZSet, ZSet: zset}, zset.Len() > 0, nil
+	case rdb.TypeStreamListpacks, rdb.TypeStreamListpacks2, rdb.TypeStreamListpacks3:
+		stream, err := readStream(dec, typ)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		return Entry{Kind: KindStream, Stream: stream}, true, nil
+	default:
+		return Entry{}, false, fmt.Errorf("unsupported RDB value type %d", typ)
+	}
+}
+
+func readSet(dec *rdb.Decoder, typ byte) (*Set, error) {
+	set := newSet()
+	switch typ {
+	case rdb.TypeSet:
+		n, err := dec.ReadLength()
+		if err != nil {
+			return nil, err
+		}
+		for range n {
+			member, err := dec.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			set.Add(member)
+		}
+	case rdb.TypeSetIntset:
+		blob, err := dec.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		members, err := rdb.ParseIntset([]byte(blob))
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			set.Add(strconv.FormatInt(member, 10))
+		}
+	default:
+		members, err := readListpack(dec)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			set.Add(member)
+		}
+	}
+	return set, nil
+}
+
+func readZSet(dec *rdb.Decoder, typ byte) (*ZSet, error) {
+	zset := newZSet()
+	if typ == rdb.TypeZSetListpack {
+		elems, err := readListpack(dec)
+		if err != nil {
+			return nil, err
+		}
+		if len(elems)%2 != 0 {
+			return nil, ErrCorruptRDB
+		}
+		for i := 0; i < len(elems); i += 2 {
+			score, err := strconv.ParseFloat(elems[i+1], 64)
+			if err != nil || math.IsNaN(score) {
+				return nil, ErrCorruptRDB
+			}
+			zset.add(score, elems[i], ZAddFlags{})
+		}
+		return zset, nil
+	}
+
+	n, err := dec.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	for range n {
+		member, err := dec.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if typ == rdb.TypeZSet2 {
+			score, err = dec.ReadBinaryDouble()
+		} else {
+			score, err = dec.ReadDouble()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(score) {
+			return nil, ErrCorruptRDB
+		}
+		zset.add(score, member, ZAddFlags{})
+	}
+	return zset, nil
+}
+
+func readListpack(dec *rdb.Decoder) ([]string, error) {
+	blob, err := dec.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	return rdb.ParseListpack([]byte(blob))
+}
+
+func readStreamID(dec *rdb.Decoder) (StreamID, error) {
+	ms, err := dec.ReadLength()
+	if err != nil {
+		return StreamID{}, err
+	}
+	seq, err := dec.ReadLength()
+	return StreamID{Ms: ms, Seq: seq}, err
+}
+
+// readRadixKey reads a stream ID stored as its raw 16-byte radix key.
+func readRadixKey(dec *rdb.Decoder) (radixKey, error) {
+	var key radixKey
+	buf, err := dec.ReadRaw(radixKeyLen)
+	copy(key[:], buf)
+	return key, err
+}
+
+// readStream reads any version of the stream encoding. Version 1 lacks the
+// deletion metadata and the groups' entries read, and versions before 3
+// lack the consumers' active time.
+func readStream(dec *rdb.Decoder, typ byte) (*Stream, error) {
+	st := newStream()
+	nodes, err := dec.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	for range nodes {
+		key, err := dec.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != radixKeyLen {
+			return nil, ErrCorruptRDB
+		}
+		elems, err := readListpack(dec)
+		if err != nil {
+			return nil, err
+		}
+		master := radixKey([]byte(key))
+		entries, err := parseStreamListpack(master.id(), elems)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			st.index.insert(master, &streamBlock{entries: entries})
+		}
+		for _, entry := range entries {
+			st.grow(streamEntrySize(entry))
+		}
+	}
+
+	length, err := dec.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	st.length = int(length)
+	if st.lastID, err = readStreamID(dec); err != nil {
+		return nil, err
+	}
+	if typ == rdb.TypeStreamListpacks {
+		st.entriesAdded = length
+	} else {
+		if _, err := readStreamID(dec); err != nil {
+			return nil, err
+		}
+		if st.maxDeletedID, err = readStreamID(dec); err != nil {
+			return nil, err
+		}
+		if st.entriesAdded, err = dec.ReadLength(); err != nil {
+			return nil, err
+		}
+	}
+
+	groups, err := dec.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	for range groups {
+		name, err := dec.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		lastID, err := readStreamID(dec)
+		if err != nil {
+			return nil, err
+		}
+		entriesRead := st.estimateEntriesRead(lastID)
+		if typ != rdb.TypeStreamListpacks {
+			n, err := dec.ReadLength()
+			if err != nil {
+				return nil, err
+			}
+			entriesRead = int64(n)
+		}
+		g := newStreamGroup(lastID, entriesRead)
+		if st.groups == nil {
+			st.groups = make(map[string]*streamGroup)
+		}
+		st.groups[name] = g
+		if err := readStreamGroup(dec, typ, g); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// readStreamGroup reads the pending entries and consumers of g.
+func readStreamGroup(dec *rdb.Decoder, typ byte, g *streamGroup) error {
+	pending, err := dec.ReadLength()
+	if err != nil {
+		return err
+	}
+	for range pending {
+		key, err := readRadixKey(dec)
+		if err != nil {
+			return err
+		}
+		deliveryTime, err := dec.ReadMillis()
+		if err != nil {
+			return err
+		}
+		deliveryCount, err := dec.ReadLength()
+		if err != nil {
+			return err
+		}
+		g.pel.insert(key, &streamNACK{deliveryTime: time.UnixMilli(deliveryTime), deliveryCount: int(deliveryCount)})
+	}
+
+	consumers, err := dec.ReadLength()
+	if err != nil {
+		return err
+	}
+	for range consumers {
+		name, err := dec.ReadString()
+		if err != nil {
+			return err
+		}
+		seenTime, err := dec.ReadMillis()
+		if err != nil {
+			return err
+		}
+		c := &streamConsumer{name: name, seenTime: time.UnixMilli(seenTime), activeTime: time.UnixMilli(seenTime)}
+		if typ == rdb.TypeStreamListpacks3 {
+			activeTime, err := dec.ReadMillis()
+			if err != nil {
+				return err
+			}
+			c.activeTime = time.Time{}
+			if activeTime != -1 {
+				c.activeTime = time.UnixMilli(activeTime)
+			}
+		}
+		g.consumers[name] = c
+
+		owned, err := dec.ReadLength()
+		if err != nil {
+			return err
+		}
+		for range owned {
+			key, err := readRadixKey(dec)
+			if err != nil {
+				return err
+			}
+			nack, ok := g.pel.get(key)
+			if !ok || nack.consumer != nil {
+				return ErrCorruptRDB
+			}
+			nack.consumer = c
+			c.pel.insert(key, nack)
+		}
+	}
+
+	// Every pending entry must belong to a consumer.
+	orphan := false
+	pelRange(&g.pel, StreamID{}, MaxStreamID, func(_ StreamID, nack *streamNACK) bool {
+		orphan = nack.consumer == nil
+		return !orphan
+	})
+	if orphan {
+		return ErrCorruptRDB
+	}
+	return nil
+}
+
+// parseStreamListpack decodes the live entries of a stream node.
+func parseStreamListpack(master StreamID, elems []string) ([]StreamEntry, error) {
+	pos := 0
+	next := func() (string, error) {
+		if pos >= len(elems) {
+			return "", ErrCorruptRDB
+		}
+		pos++
+		return elems[pos-1], nil
+	}
+	nextInt := func() (int64, error) {
+		s, err := next()
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, ErrCorruptRDB
+		}
+		return v, nil
+	}
+
+	count, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	numFields, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 || deleted < 0 || numFields < 0 || count > int64(len(elems)) || int(numFields) > len(elems) {
+		return nil, ErrCorruptRDB
+	}
+	masterFields := make([]string, numFields)
+	for i := range masterFields {
+		if masterFields[i], err = next(); err != nil {
+			return nil, err
+		}
+	}
+	if terminator, err := nextInt(); err != nil || terminator != 0 {
+		return nil, ErrCorruptRDB
+	}
+
+	entries := make([]StreamEntry, 0, count)
+	for range count + deleted {
+		flags, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		msDiff, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		seqDiff, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+
+		var fields []string
+		if flags&streamItemSameFields != 0 {
+			fields = make([]string, 0, 2*len(masterFields))
+			for _, field := range masterFields {
+				value, err := next()
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, field, value)
+			}
+		} else {
+			n, err := nextInt()
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || int(2*n) > len(elems)-pos {
+				return nil, ErrCorruptRDB
+			}
+			fields = make([]string, 2*n)
+			for i := range fields {
+				if fields[i], err = next(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, err := nextInt(); err != nil {
+			return nil, err
+		}
+
+		if flags&streamItemDeleted == 0 {
+			id := StreamID{Ms: master.Ms + uint64(msDiff), Seq: master.Seq + uint64(seqDiff)}
+			entries = append(entries, StreamEntry{ID: id, Fields: fields})
+		}
+	}
+	if pos != len(elems) || len(entries) != int(count) {
+		return nil, ErrCorruptRDB
+	}
+	return entries, nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reload(t *testing.T, dbs *Databases) *Databases {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, dbs.SaveRDB(&buf))
+	loaded := NewDatabases(dbs.Len())
+	require.NoError(t, loaded.LoadRDB(&buf))
+	return loaded
+}
+
+func TestRDBRoundTrip(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(16)
+	db := dbs.DB(0)
+	ttl := time.Hour
+	db.Set("str", "hello", nil)
+	db.Set("num", "12345", &ttl)
+	db.SAdd("ints", []string{"3", "1", "-2"})
+	db.SAdd("words", []string{"a", "b", "c"})
+	db.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{Member: "a", Score: 1.5}, {Member: "b", Score: -2}, {Member: "c", Score: 1.5}})
+	dbs.DB(5).Set("other", "db", nil)
+
+	loaded := reload(t, dbs)
+	ldb := loaded.DB(0)
+	got, _ := ldb.Get("str")
+	assert.Equal(t, "hello", got)
+	entry, ok := ldb.store.get("num")
+	require.True(t, ok)
+	assert.Equal(t, "12345", entry.Value)
+	assert.Equal(t, db.lookupTTL(t, "num").UnixMilli(), entry.TTL.UnixMilli())
+
+	members, _ := ldb.SMembers("ints")
+	assert.ElementsMatch(t, []string{"-2", "1", "3"}, members)
+	set, _ := ldb.setAt("ints", false)
+	assert.True(t, set.IsIntset())
+	members, _ = ldb.SMembers("words")
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, members)
+
+	zrange, _ := ldb.ZRange("z", ZRangeSpec{By: ZRangeByRank, Start: 0, Stop: -1})
+	assert.Equal(t, []ScoredMember{{Member: "b", Score: -2}, {Member: "a", Score: 1.5}, {Member: "c", Score: 1.5}}, zrange)
+
+	got, _ = loaded.DB(5).Get("other")
+	assert.Equal(t, "db", got)
+	assert.Equal(t, 0, loaded.DB(1).DBSize())
+}
+
+// lookupTTL returns the expiry of key.
+func (s *Store) lookupTTL(t *testing.T, key string) time.Time {
+	t.Helper()
+	entry, ok := s.store.get(key)
+	require.True(t, ok)
+	return entry.TTL
+}
+
+func TestRDBSkipsExpiredKeys(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(1)
+	dbs.DB(0).store.set("old", Entry{Value: "v", TTL: time.Now().Add(-time.Second)})
+	dbs.DB(0).Set("new", "v", nil)
+
+	loaded := reload(t, dbs)
+	assert.Equal(t, 1, loaded.DB(0).DBSize())
+}
+
+// truncateStreamInfo rounds the times of info to the milliseconds RDB keeps.
+func truncateStreamInfo(info *StreamInfo) {
+	round := func(t *time.Time) {
+		if !t.IsZero() {
+			*t = time.UnixMilli(t.UnixMilli())
+		}
+	}
+	for i := range info.Groups {
+		g := &info.Groups[i]
+		for j := range g.PEL {
+			round(&g.PEL[j].DeliveryTime)
+		}
+		for j := range g.ConsumerDetails {
+			c := &g.ConsumerDetails[j]
+			round(&c.SeenTime)
+			round(&c.ActiveTime)
+			for k := range c.PEL {
+				round(&c.PEL[k].DeliveryTime)
+			}
+		}
+	}
+}
+
+func TestRDBStreamRoundTrip(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(1)
+	s := dbs.DB(0)
+	for i := 1; i <= 250; i++ {
+		fields := []string{"n", strconv.Itoa(i), "v", "x"}
+		if i%7 == 0 {
+			fields = []string{"other", "field"}
+		}
+		_, _, err := s.XAdd("s", StreamIDSpec{ID: StreamID{Ms: uint64(i), Seq: uint64(i % 3)}}, fields, false, nil)
+		require.NoError(t, err)
+	}
+	s.XDel("s", []StreamID{{1, 1}, {150, 0}})
+	require.NoError(t, s.XGroupCreate("s", "g1", StreamID{}, false, false, InvalidEntriesRead))
+	require.NoError(t, s.XGroupCreate("s", "g2", StreamID{Ms: 200}, false, false, 5))
+	_, err := s.XReadGroup([]string{"s"}, []XReadStart{{From: XReadNew}}, "g1", "alice", 3, false)
+	require.NoError(t, err)
+	_, err = s.XReadGroup([]string{"s"}, []XReadStart{{From: XReadNew}}, "g1", "bob", 2, false)
+	require.NoError(t, err)
+	_, err = s.XGroupCreateConsumer("s", "g2", "idle")
+	require.NoError(t, err)
+	_, _, err = s.XAdd("empty", StreamIDSpec{ID: StreamID{Ms: 1}}, []string{"a", "b"}, false, nil)
+	require.NoError(t, err)
+	s.XDel("empty", []StreamID{{1, 0}})
+
+	loaded := reload(t, dbs)
+	for _, key := range []string{"s", "empty"} {
+		want, err := s.XInfoStream(key, true, 0)
+		require.NoError(t, err)
+		got, err := loaded.DB(0).XInfoStream(key, true, 0)
+		require.NoError(t, err)
+		truncateStreamInfo(&want)
+		truncateStreamInfo(&got)
+		assert.Equal(t, want, got, key)
+	}
+
+	// The loaded stream keeps accepting entries after the last ID.
+	_, _, err = loaded.DB(0).XAdd("s", StreamIDSpec{ID: StreamID{Ms: 250}}, []string{"a", "b"}, false, nil)
+	assert.ErrorIs(t, err, ErrStreamIDTooSmall)
+	id, _, err := loaded.DB(0).XAdd("s", StreamIDSpec{AutoMs: true}, []string{"a", "b"}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id.Compare(StreamID{Ms: 250}))
+}
+
+func TestLoadRDBErrors(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	dbs := NewDatabases(4)
+	dbs.DB(3).Set("k", "v", nil)
+	require.NoError(t, dbs.SaveRDB(&buf))
+	data := buf.Bytes()
+
+	assert.Error(t, NewDatabases(2).LoadRDB(bytes.NewReader(data)), "more databases than configured")
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-10] ^= 0xff
+	assert.Error(t, NewDatabases(4).LoadRDB(bytes.NewReader(corrupt)))
+
+	assert.Error(t, NewDatabases(4).LoadRDB(bytes.NewReader(data[:len(data)-3])))
+}
+
+func TestLoadRDBRedisEncodings(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	enc := rdb.NewEncoder(&buf)
+	enc.WriteHeader()
+	enc.WriteAux("redis-ver", "7.2.4")
+	enc.WriteSelectDB(0)
+	enc.WriteResizeDB(3, 1)
+	enc.WriteType(rdb.OpExpireTime)
+	enc.WriteRaw([]byte{0xff, 0xff, 0xff, 0x7f})
+	enc.WriteType(rdb.TypeSetIntset)
+	enc.WriteString("ints")
+	enc.WriteString(string([]byte{2, 0, 0, 0, 2, 0, 0, 0, 1, 0, 2, 0}))
+
+	lp := &rdb.Listpack{}
+	lp.AppendString("a")
+	lp.AppendString("x")
+	enc.WriteType(rdb.TypeSetListpack)
+	enc.WriteString("small")
+	enc.WriteString(string(lp.Bytes()))
+
+	lp = &rdb.Listpack{}
+	lp.AppendString("m")
+	lp.AppendString("2.5")
+	lp.AppendString("n")
+	lp.AppendString("3")
+	enc.WriteType(rdb.TypeZSetListpack)
+	enc.WriteString("z")
+	enc.WriteString(string(lp.Bytes()))
+	enc.WriteEOF()
+	require.NoError(t, enc.Err())
+
+	dbs := NewDatabases(1)
+	require.NoError(t, dbs.LoadRDB(&buf))
+	db := dbs.DB(0)
+	members, _ := db.SMembers("ints")
+	assert.ElementsMatch(t, []string{"1", "2"}, members)
+	assert.Equal(t, int64(0x7fffffff), db.lookupTTL(t, "ints").Unix())
+	members, _ = db.SMembers("small")
+	assert.ElementsMatch(t, []string{"a", "x"}, members)
+	zrange, _ := db.ZRange("z", ZRangeSpec{By: ZRangeByRank, Start: 0, Stop: -1})
+	assert.Equal(t, []ScoredMember{{Member: "m", Score: 2.5}, {Member: "n", Score: 3}}, zrange)
+}
+
+func TestRDBSavesAccessForEvictionPolicy(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(1)
+	dbs.DB(0).Set("k", "v", nil)
+	e := dbs.DB(0).store.find("k")
+	e.value.Access = time.Now().Add(-time.Hour)
+	e.value.Freq = 42
+
+	loaded := reload(t, dbs)
+	got, _ := loaded.DB(0).store.get("k")
+	assert.WithinDuration(t, time.Now(), got.Access, time.Minute, "noeviction saves neither")
+
+	dbs.SetEvictionPolicy(AllKeysLRU)
+	got, _ = reload(t, dbs).DB(0).store.get("k")
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), got.Access, time.Minute)
+
+	dbs.SetEvictionPolicy(VolatileLFU)
+	got, _ = reload(t, dbs).DB(0).store.get("k")
+	assert.Zero(t, got.Freq, "an hour of decay")
+	e.value.Access = time.Now()
+	got, _ = reload(t, dbs).DB(0).store.get("k")
+	assert.Equal(t, uint8(42), got.Freq)
+}
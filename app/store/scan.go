@@ -0,0 +1,122 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// ScanOptions filters a SCAN family iteration. An empty Match matches
+// everything and an empty Type every kind. Count is the amount of work per
+// call, not a limit on the reply.
+type ScanOptions struct {
+	Match string
+	Count int
+	Type  string
+}
+
+// scanDict runs one SCAN step over d: buckets are visited from cursor until
+// about Count entries were collected or ten times as many buckets were
+// visited, so that sparse tables still make progress.
+func scanDict[V any](d *dict[V], cursor uint64, count int, fn func(key string, value V)) uint64 {
+	found := 0
+	for iterations := count * 10; ; iterations-- {
+		cursor = d.scan(cursor, func(key string, value V) {
+			fn(key, value)
+			found++
+		})
+		if cursor == 0 || iterations == 0 || found >= count {
+			return cursor
+		}
+	}
+}
+
+// Keys returns every live key matching pattern.
+func (s *Store) Keys(pattern string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []string{}
+	now := time.Now()
+	for key, entry := range s.store.all() {
+		if !entry.expired(now) && (pattern == "*" || matchGlob(pattern, key)) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Scan returns the next cursor and a batch of keys starting at cursor. A
+// full iteration from cursor 0 back to 0 returns every key that existed for
+// its whole duration at least once, regardless of resizes in between.
+func (s *Store) Scan(cursor uint64, opts ScanOptions) (uint64, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var candidates []string
+	cursor = scanDict(s.store, cursor, opts.Count, func(key string, _ Entry) {
+		candidates = append(candidates, key)
+	})
+
+	// Expired keys are deleted here rather than while scanning, since the
+	// table must not change in the middle of a step.
+	keys := []string{}
+	for _, key := range candidates {
+		entry, ok := s.peek(key)
+		if !ok || (opts.Match != "" && !matchGlob(opts.Match, key)) {
+			continue
+		}
+		if opts.Type != "" && !strings.EqualFold(opts.Type, entry.Kind.String()) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return cursor, keys
+}
+
+// SScan is Scan over the members of the set at key. Intset encoded sets are
+// returned whole with a 0 cursor.
+func (s *Store) SScan(key string, cursor uint64, opts ScanOptions) (uint64, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return 0, []string{}, err
+	}
+
+	var candidates []string
+	if set.IsIntset() {
+		candidates, cursor = set.Members(), 0
+	} else {
+		cursor = scanDict(set.members, cursor, opts.Count, func(member string, _ struct{}) {
+			candidates = append(candidates, member)
+		})
+	}
+
+	members := []string{}
+	for _, member := range candidates {
+		if opts.Match == "" || matchGlob(opts.Match, member) {
+			members = append(members, member)
+		}
+	}
+	return cursor, members, nil
+}
+
+// ZScan is Scan over the members of the sorted set at key.
+func (s *Store) ZScan(key string, cursor uint64, opts ScanOptions) (uint64, []ScoredMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, []ScoredMember{}, err
+	}
+
+	members := []ScoredMember{}
+	cursor = scanDict(zset.scores, cursor, opts.Count, func(member string, score float64) {
+		if opts.Match == "" || matchGlob(opts.Match, member) {
+			members = append(members, ScoredMember{Member: member, Score: score})
+		}
+	})
+	return cursor, members, nil
+}
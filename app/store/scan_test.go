@@ -0,0 +1,104 @@
+package store
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scanAll runs a full SCAN iteration and returns the distinct keys it saw.
+func scanAll(s *Store, opts ScanOptions) []string {
+	seen := map[string]bool{}
+	cursor := uint64(0)
+	for {
+		var keys []string
+		cursor, keys = s.Scan(cursor, opts)
+		for _, key := range keys {
+			seen[key] = true
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestStoreKeys(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("user:1", "a", nil)
+	s.Set("user:2", "b", nil)
+	s.Set("order:1", "c", nil)
+	expired := time.Nanosecond
+	s.Set("user:3", "gone", &expired)
+	time.Sleep(time.Millisecond)
+
+	keys := s.Keys("user:*")
+	slices.Sort(keys)
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+	assert.Len(t, s.Keys("*"), 3)
+	assert.Empty(t, s.Keys("nope*"))
+}
+
+func TestStoreScan(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	for i := range 100 {
+		s.Set("k"+strconv.Itoa(i), "v", nil)
+	}
+	s.SAdd("set", []string{"a"})
+
+	assert.Len(t, scanAll(s, ScanOptions{Count: 10}), 101)
+	assert.Equal(t, []string{"k1", "k10", "k11", "k12", "k13", "k14", "k15", "k16", "k17", "k18", "k19"},
+		scanAll(s, ScanOptions{Count: 7, Match: "k1*"}))
+	assert.Equal(t, []string{"set"}, scanAll(s, ScanOptions{Count: 10, Type: "SET"}))
+	assert.Empty(t, scanAll(s, ScanOptions{Count: 10, Type: "hash"}))
+}
+
+func TestStoreSScanAndZScan(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.SAdd("ints", []string{"3", "1", "2"})
+	cursor, members, err := s.SScan("ints", 0, ScanOptions{Count: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), cursor)
+	assert.Equal(t, []string{"1", "2", "3"}, members)
+
+	names := []string{}
+	for i := range 300 {
+		names = append(names, "m"+strconv.Itoa(i))
+	}
+	s.SAdd("names", names)
+	seen := map[string]bool{}
+	for cursor = 0; ; {
+		cursor, members, _ = s.SScan("names", cursor, ScanOptions{Count: 20, Match: "m2*"})
+		for _, member := range members {
+			seen[member] = true
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Len(t, seen, 111)
+
+	s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}})
+	cursor, scored, err := s.ZScan("z", 0, ScanOptions{Count: 10, Match: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), cursor)
+	assert.Equal(t, []ScoredMember{{Member: "b", Score: 2}}, scored)
+
+	_, _, err = s.ZScan("ints", 0, ScanOptions{Count: 10})
+	assert.ErrorIs(t, err, ErrWrongType)
+	cursor, members, err = s.SScan("missing", 0, ScanOptions{Count: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), cursor)
+	assert.Empty(t, members)
+}
@@ -0,0 +1,504 @@
+package store
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+)
+
+// maxIntsetEntries mirrors Redis's set-max-intset-entries default.
+const maxIntsetEntries = 512
+
+// Set is an unordered collection of unique strings. While every member is an
+// integer the set is kept as a sorted int64 slice (Redis's intset encoding);
+// it converts to a hashtable once a non-integer member is added or the set
+// grows beyond maxIntsetEntries.
+type Set struct {
+	intset  []int64
+	members *dict[struct{}]
+	memCounter
+}
+
+func newSet() *Set {
+	return &Set{intset: []int64{}}
+}
+
+// IsIntset reports whether the set is using the compact integer encoding.
+func (st *Set) IsIntset() bool {
+	return st.members == nil
+}
+
+func (st *Set) Len() int {
+	if st.IsIntset() {
+		return len(st.intset)
+	}
+	return st.members.len()
+}
+
+func (st *Set) Contains(member string) bool {
+	if !st.IsIntset() {
+		_, ok := st.members.get(member)
+		return ok
+	}
+
+	value, ok := parseSetInt(member)
+	if !ok {
+		return false
+	}
+	_, found := slices.BinarySearch(st.intset, value)
+	return found
+}
+
+// Add inserts member and reports whether it was not already present.
+func (st *Set) Add(member string) bool {
+	if st.IsIntset() {
+		value, ok := parseSetInt(member)
+		if ok {
+			i, found := slices.BinarySearch(st.intset, value)
+			if found {
+				return false
+			}
+			if len(st.intset) < maxIntsetEntries {
+				st.intset = slices.Insert(st.intset, i, value)
+				st.grow(intsetMemberSize)
+				return true
+			}
+		}
+		st.convertToHashtable()
+	}
+
+	if !st.members.set(member, struct{}{}) {
+		return false
+	}
+	st.grow(setMemberOverhead + len(member))
+	return true
+}
+
+// Remove deletes member and reports whether it was present.
+func (st *Set) Remove(member string) bool {
+	if !st.IsIntset() {
+		if !st.members.delete(member) {
+			return false
+		}
+		st.grow(-setMemberOverhead - len(member))
+		return true
+	}
+
+	value, ok := parseSetInt(member)
+	if !ok {
+		return false
+	}
+	i, found := slices.BinarySearch(st.intset, value)
+	if !found {
+		return false
+	}
+	st.intset = slices.Delete(st.intset, i, i+1)
+	st.grow(-intsetMemberSize)
+	return true
+}
+
+// Members returns every member. Intset encoded sets are returned in ascending order.
+func (st *Set) Members() []string {
+	out := make([]string, 0, st.Len())
+	if st.IsIntset() {
+		for _, value := range st.intset {
+			out = append(out, strconv.FormatInt(value, 10))
+		}
+		return out
+	}
+
+	for member := range st.members.all() {
+		out = append(out, member)
+	}
+	return out
+}
+
+func (st *Set) convertToHashtable() {
+	if !st.IsIntset() {
+		panic("set is already a hashtable")
+	}
+	members := newDict[struct{}]()
+	size := 0
+	for _, value := range st.intset {
+		member := strconv.FormatInt(value, 10)
+		members.set(member, struct{}{})
+		size += setMemberOverhead + len(member)
+	}
+	st.grow(size - len(st.intset)*intsetMemberSize)
+	st.members = members
+	st.intset = nil
+}
+
+// parseSetInt accepts only the canonical decimal form of an int64, so that
+// converting back with FormatInt yields the original member.
+func parseSetInt(member string) (int64, bool) {
+	value, err := strconv.ParseInt(member, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if strconv.FormatInt(value, 10) != member {
+		return 0, false
+	}
+	return value, true
+}
+
+// setAt returns the set stored at key. When create is true a missing key is
+// initialised with an empty set. The caller must hold s.mu.
+func (s *Store) setAt(key string, create bool) (*Set, error) {
+	entry, ok := s.lookup(key)
+	if !ok {
+		if !create {
+			return nil, nil
+		}
+		set := newSet()
+		s.setKey(key, Entry{Kind: KindSet, Set: set})
+		return set, nil
+	}
+
+	if entry.Kind != KindSet {
+		return nil, ErrWrongType
+	}
+	return entry.Set, nil
+}
+
+// deleteIfEmptySet removes key when it holds a set without members. The caller must hold s.mu.
+func (s *Store) deleteIfEmptySet(key string, set *Set) {
+	if set.Len() == 0 {
+		s.deleteKey(key)
+	}
+}
+
+func (s *Store) SAdd(key string, members []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, true)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, member := range members {
+		if set.Add(member) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+func (s *Store) SRem(key string, members []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	s.deleteIfEmptySet(key, set)
+	return removed, nil
+}
+
+func (s *Store) SMembers(key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+	return set.Members(), nil
+}
+
+func (s *Store) SIsMember(key string, member string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return false, err
+	}
+	return set.Contains(member), nil
+}
+
+func (s *Store) SMIsMember(key string, members []string) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]bool, len(members))
+	if set == nil {
+		return out, nil
+	}
+	for i, member := range members {
+		out[i] = set.Contains(member)
+	}
+	return out, nil
+}
+
+func (s *Store) SCard(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SPop removes and returns up to count random members.
+func (s *Store) SPop(key string, count int) ([]string, error) {
+	if count < 0 {
+		panic("count must be non-negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+
+	members := set.Members()
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if count < len(members) {
+		members = members[:count]
+	}
+	for _, member := range members {
+		set.Remove(member)
+	}
+	s.deleteIfEmptySet(key, set)
+	return members, nil
+}
+
+// srandMemberPrealloc bounds the reply SRandMember allocates ahead for a
+// negative count.
+const srandMemberPrealloc = 1024
+
+// SRandMember returns random members without removing them. A positive count
+// returns up to count distinct members; a negative count returns exactly
+// -count members which may repeat.
+func (s *Store) SRandMember(key string, count int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.setAt(key, false)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+
+	members := set.Members()
+	if count < 0 {
+		// The reply grows with the members picked rather than being
+		// allocated up front for a count that may not fit in memory.
+		out := make([]string, 0, min(-count, srandMemberPrealloc))
+		for range -count {
+			out = append(out, members[rand.IntN(len(members))])
+		}
+		return out, nil
+	}
+
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if count < len(members) {
+		members = members[:count]
+	}
+	return members, nil
+}
+
+// SMove moves member from source to destination and reports whether it was moved.
+func (s *Store) SMove(source string, destination string, member string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.setAt(source, false)
+	if err != nil {
+		return false, err
+	}
+	dst, err := s.setAt(destination, false)
+	if err != nil {
+		return false, err
+	}
+
+	if src == nil || !src.Contains(member) {
+		return false, nil
+	}
+	if source == destination {
+		return true, nil
+	}
+
+	src.Remove(member)
+	s.deleteIfEmptySet(source, src)
+	if dst == nil {
+		dst, _ = s.setAt(destination, true)
+	}
+	dst.Add(member)
+	return true, nil
+}
+
+// SetOp selects the algebra applied by SInter, SUnion and SDiff and their STORE variants.
+type SetOp int
+
+const (
+	SetOpInter SetOp = iota
+	SetOpUnion
+	SetOpDiff
+)
+
+func (s *Store) SInter(keys []string) ([]string, error) {
+	return s.setAlgebra(SetOpInter, keys)
+}
+
+func (s *Store) SUnion(keys []string) ([]string, error) {
+	return s.setAlgebra(SetOpUnion, keys)
+}
+
+func (s *Store) SDiff(keys []string) ([]string, error) {
+	return s.setAlgebra(SetOpDiff, keys)
+}
+
+func (s *Store) setAlgebra(op SetOp, keys []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return result.Members(), nil
+}
+
+// SetOpStore stores the result of op over keys at destination and returns its cardinality.
+// An empty result deletes destination.
+func (s *Store) SetOpStore(op SetOp, destination string, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	if result.Len() == 0 {
+		s.deleteKey(destination)
+		return 0, nil
+	}
+	s.setKey(destination, Entry{Kind: KindSet, Set: result})
+	return result.Len(), nil
+}
+
+// SInterCard returns the cardinality of the intersection of keys. A positive
+// limit stops counting once it is reached.
+func (s *Store) SInterCard(keys []string, limit int) (int, error) {
+	if limit < 0 {
+		panic("limit must be non-negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sets, err := s.setsAt(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(intersect(sets, limit)), nil
+}
+
+// setsAt resolves every key to a set, using nil for missing keys. The caller must hold s.mu.
+func (s *Store) setsAt(keys []string) ([]*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := s.setAt(key, false)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// combineSets computes op over keys into a new set. The caller must hold s.mu.
+func (s *Store) combineSets(op SetOp, keys []string) (*Set, error) {
+	if len(keys) == 0 {
+		panic("set algebra requires at least one key")
+	}
+
+	sets, err := s.setsAt(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newSet()
+	switch op {
+	case SetOpInter:
+		for _, member := range intersect(sets, 0) {
+			result.Add(member)
+		}
+	case SetOpUnion:
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			for _, member := range set.Members() {
+				result.Add(member)
+			}
+		}
+	case SetOpDiff:
+		if sets[0] == nil {
+			return result, nil
+		}
+	members:
+		for _, member := range sets[0].Members() {
+			for _, other := range sets[1:] {
+				if other != nil && other.Contains(member) {
+					continue members
+				}
+			}
+			result.Add(member)
+		}
+	default:
+		panic("unknown set operation")
+	}
+	return result, nil
+}
+
+// intersect returns the members common to every set, iterating the smallest
+// one. A positive limit stops once that many members have been found.
+func intersect(sets []*Set, limit int) []string {
+	smallest := -1
+	for i, set := range sets {
+		if set == nil {
+			return nil
+		}
+		if smallest == -1 || set.Len() < sets[smallest].Len() {
+			smallest = i
+		}
+	}
+
+	var out []string
+members:
+	for _, member := range sets[smallest].Members() {
+		for i, other := range sets {
+			if i != smallest && !other.Contains(member) {
+				continue members
+			}
+		}
+		out = append(out, member)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
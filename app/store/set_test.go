@@ -0,0 +1,167 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEncoding(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name       string
+		members    []string
+		wantIntset bool
+	}{
+		{"integers", []string{"3", "1", "2"}, true},
+		{"non canonical integer", []string{"1", "01"}, false},
+		{"string member", []string{"1", "a"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			set := newSet()
+			for _, member := range tc.members {
+				set.Add(member)
+			}
+			assert.Equal(t, tc.wantIntset, set.IsIntset())
+			assert.Equal(t, len(tc.members), set.Len())
+			for _, member := range tc.members {
+				assert.True(t, set.Contains(member))
+			}
+		})
+	}
+}
+
+func TestSetEncodingGrowsIntoHashtable(t *testing.T) {
+	t.Parallel()
+	set := newSet()
+	for i := range maxIntsetEntries {
+		set.Add(fmt.Sprint(i))
+	}
+	assert.True(t, set.IsIntset())
+	set.Add(fmt.Sprint(maxIntsetEntries))
+	assert.False(t, set.IsIntset())
+	assert.Equal(t, maxIntsetEntries+1, set.Len())
+}
+
+func TestStoreSAddSRem(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	added, err := s.SAdd("tags", []string{"a", "b", "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	removed, err := s.SRem("tags", []string{"a", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	removed, err = s.SRem("tags", []string{"b"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, ok := s.Type("tags")
+	assert.False(t, ok, "empty sets are deleted")
+}
+
+func TestStoreSetWrongType(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.Set("str", "v", nil)
+	_, err := s.SAdd("str", []string{"a"})
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = s.SInter([]string{"str"})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	_, err = s.SAdd("set", []string{"a"})
+	assert.NoError(t, err)
+	_, _, err = s.GetString("set")
+	assert.ErrorIs(t, err, ErrWrongType)
+}
+
+func TestStoreSetAlgebra(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		op   SetOp
+		keys []string
+		want []string
+	}{
+		{"inter", SetOpInter, []string{"a", "b"}, []string{"2", "3"}},
+		{"inter missing key", SetOpInter, []string{"a", "missing"}, []string{}},
+		{"union", SetOpUnion, []string{"a", "b", "missing"}, []string{"1", "2", "3", "4"}},
+		{"diff", SetOpDiff, []string{"a", "b"}, []string{"1"}},
+		{"diff missing first", SetOpDiff, []string{"missing", "a"}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			s.SAdd("a", []string{"1", "2", "3"})
+			s.SAdd("b", []string{"2", "3", "4"})
+
+			count, err := s.SetOpStore(tc.op, "dst", tc.keys)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.want), count)
+
+			got, err := s.SMembers("dst")
+			assert.NoError(t, err)
+			slices.Sort(got)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStoreSInterCard(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.SAdd("a", []string{"1", "2", "3"})
+	s.SAdd("b", []string{"1", "2", "3", "4"})
+
+	count, err := s.SInterCard([]string{"a", "b"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	count, err = s.SInterCard([]string{"a", "b"}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestStoreSPopSRandMember(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.SAdd("s", []string{"a", "b", "c"})
+
+	members, err := s.SRandMember("s", -5)
+	assert.NoError(t, err)
+	assert.Len(t, members, 5)
+
+	members, err = s.SRandMember("s", 5)
+	assert.NoError(t, err)
+	assert.Len(t, members, 3)
+
+	popped, err := s.SPop("s", 2)
+	assert.NoError(t, err)
+	assert.Len(t, popped, 2)
+	card, _ := s.SCard("s")
+	assert.Equal(t, 1, card)
+}
+
+func TestStoreSMove(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.SAdd("src", []string{"a"})
+
+	moved, err := s.SMove("src", "dst", "a")
+	assert.NoError(t, err)
+	assert.True(t, moved)
+	ok, _ := s.SIsMember("dst", "a")
+	assert.True(t, ok)
+	_, exists := s.Type("src")
+	assert.False(t, exists)
+
+	moved, err = s.SMove("src", "dst", "a")
+	assert.NoError(t, err)
+	assert.False(t, moved)
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	skiplistMaxLevel    = 32
+	skiplistProbability = 0.25
+)
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	// span is the number of nodes skipped by forward, used to compute ranks.
+	span int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+// skiplist orders members by score and then lexicographically by member, as
+// Redis's zskiplist does. Ranks are 1-based.
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	header := &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)}
+	return &skiplist{header: header, level: 1}
+}
+
+func randomSkiplistLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistProbability {
+		level++
+	}
+	return level
+}
+
+// before reports whether node sorts strictly before (score, member).
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// insert adds a node; the caller guarantees member is not already present.
+func (zsl *skiplist) insert(score float64, member string) *skiplistNode {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i != zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomSkiplistLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := range level {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+// delete removes the node matching score and member and reports whether it existed.
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	zsl.deleteNode(x, update[:zsl.level])
+	return true
+}
+
+func (zsl *skiplist) deleteNode(x *skiplistNode, update []*skiplistNode) {
+	for i := range zsl.level {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+// rank returns the 1-based rank of the node, or 0 when it is not present.
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && (x.level[i].forward.before(score, member) || (x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the 1-based rank, or nil when out of range.
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	if rank < 1 || rank > zsl.length {
+		return nil
+	}
+
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+func (zsl *skiplist) first() *skiplistNode {
+	return zsl.header.level[0].forward
+}
+
+// firstWhere returns the first node for which below is false. below must be
+// monotonic: true for a prefix of the list and false afterwards.
+func (zsl *skiplist) firstWhere(below func(n *skiplistNode) bool) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && below(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	return x.level[0].forward
+}
+
+// lastWhere returns the last node for which within is true. within must be
+// monotonic: true for a prefix of the list and false afterwards.
+func (zsl *skiplist) lastWhere(within func(n *skiplistNode) bool) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && within(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header {
+		return nil
+	}
+	return x
+}
+
+// ScoreRange is an interval of scores whose ends may be exclusive.
+type ScoreRange struct {
+	Min          float64
+	Max          float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	if r.MinExclusive {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	if r.MaxExclusive {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+func (r ScoreRange) Contains(score float64) bool {
+	return r.aboveMin(score) && r.belowMax(score)
+}
+
+func (zsl *skiplist) firstInScoreRange(r ScoreRange) *skiplistNode {
+	x := zsl.firstWhere(func(n *skiplistNode) bool { return !r.aboveMin(n.score) })
+	if x == nil || !r.belowMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInScoreRange(r ScoreRange) *skiplistNode {
+	x := zsl.lastWhere(func(n *skiplistNode) bool { return r.belowMax(n.score) })
+	if x == nil || !r.aboveMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+// LexBound is one end of a lexicographic range: "-" and "+" are the
+// infinities, otherwise the value is inclusive ("[") or exclusive ("(").
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	// Infinity is -1 for "-", 1 for "+" and 0 for a value bound.
+	Infinity int
+}
+
+// compare orders member against the bound like strings.Compare.
+func (b LexBound) compare(member string) int {
+	switch {
+	case b.Infinity < 0:
+		return 1
+	case b.Infinity > 0:
+		return -1
+	default:
+		return strings.Compare(member, b.Value)
+	}
+}
+
+// LexRange is an interval of members, only meaningful when all scores are equal.
+type LexRange struct {
+	Min LexBound
+	Max LexBound
+}
+
+func (r LexRange) aboveMin(member string) bool {
+	if r.Min.Exclusive {
+		return r.Min.compare(member) > 0
+	}
+	return r.Min.compare(member) >= 0
+}
+
+func (r LexRange) belowMax(member string) bool {
+	if r.Max.Exclusive {
+		return r.Max.compare(member) < 0
+	}
+	return r.Max.compare(member) <= 0
+}
+
+func (r LexRange) Contains(member string) bool {
+	return r.aboveMin(member) && r.belowMax(member)
+}
+
+func (zsl *skiplist) firstInLexRange(r LexRange) *skiplistNode {
+	x := zsl.firstWhere(func(n *skiplistNode) bool { return !r.aboveMin(n.member) })
+	if x == nil || !r.belowMax(x.member) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInLexRange(r LexRange) *skiplistNode {
+	x := zsl.lastWhere(func(n *skiplistNode) bool { return r.belowMax(n.member) })
+	if x == nil || !r.aboveMin(x.member) {
+		return nil
+	}
+	return x
+}
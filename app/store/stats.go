@@ -0,0 +1,60 @@
+package store
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// avgTTLSamples is how many keys with a TTL AvgTTL samples.
+const avgTTLSamples = 20
+
+// keyspaceStats counts what happens to the keys of the databases, which
+// share them.
+type keyspaceStats struct {
+	hits    atomic.Int64
+	misses  atomic.Int64
+	expired atomic.Int64
+}
+
+// KeyspaceHits returns the number of lookups of a key that found it.
+func (d *Databases) KeyspaceHits() int64 {
+	return d.dbs[0].stats.hits.Load()
+}
+
+// KeyspaceMisses returns the number of lookups of a key that did not.
+func (d *Databases) KeyspaceMisses() int64 {
+	return d.dbs[0].stats.misses.Load()
+}
+
+// ExpiredKeys returns the number of keys deleted because their TTL passed.
+func (d *Databases) ExpiredKeys() int64 {
+	return d.dbs[0].stats.expired.Load()
+}
+
+// Expires returns the number of keys with a TTL, counting those that have
+// expired but were not reclaimed yet.
+func (s *Store) Expires() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expires.len()
+}
+
+// AvgTTL estimates the average remaining TTL of the keys that have one
+// from a sample of them, or returns 0 if there are none.
+func (s *Store) AvgTTL() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var total time.Duration
+	n := 0
+	s.expires.sample(avgTTLSamples, func(key string, _ struct{}) {
+		if e, ok := s.store.get(key); ok && !e.expired(now) {
+			total += e.TTL.Sub(now)
+			n++
+		}
+	})
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
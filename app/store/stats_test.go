@@ -0,0 +1,37 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyspaceStats(t *testing.T) {
+	t.Parallel()
+	dbs := NewDatabases(2)
+	ttl := time.Millisecond
+	dbs.DB(0).Set("a", "1", nil)
+	dbs.DB(1).Set("gone", "1", &ttl)
+	dbs.DB(0).Get("a")
+	dbs.DB(1).Get("b")
+	time.Sleep(2 * time.Millisecond)
+	dbs.DB(1).Get("gone")
+
+	assert.Equal(t, int64(1), dbs.KeyspaceHits())
+	assert.Equal(t, int64(2), dbs.KeyspaceMisses())
+	assert.Equal(t, int64(1), dbs.ExpiredKeys())
+}
+
+func TestAvgTTL(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	assert.Zero(t, s.AvgTTL())
+	hour, twoHours := time.Hour, 2*time.Hour
+	s.Set("a", "1", &hour)
+	s.Set("b", "1", &twoHours)
+	s.Set("c", "1", nil)
+	assert.Equal(t, 2, s.Expires())
+	avg := s.AvgTTL()
+	assert.InDelta(t, float64(90*time.Minute), float64(avg), float64(time.Second))
+}
@@ -7,61 +7,275 @@ import (
 	"time"
 )
 
+// ErrWrongType is returned when a command targets a key holding another kind of value.
+var ErrWrongType = errors.New("Operation against a key holding the wrong kind of value")
+
+// Kind identifies the data type held by an Entry.
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindSet
+	KindZSet
+	KindStream
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindSet:
+		return "set"
+	case KindZSet:
+		return "zset"
+	case KindStream:
+		return "stream"
+	default:
+		panic("unknown kind")
+	}
+}
+
 type Entry struct {
-	Value string
-	TTL   time.Time
+	Kind   Kind
+	Value  string
+	Set    *Set
+	ZSet   *ZSet
+	Stream *Stream
+	TTL    time.Time
+	// Access is when the key was last read or written and Freq its
+	// logarithmic access counter, what Redis keeps in each object's lru
+	// field for LRU and LFU eviction. A zero Access marks an entry that was
+	// never stored.
+	Access time.Time
+	Freq   uint8
+}
+
+func (e Entry) expired(now time.Time) bool {
+	return !e.TTL.IsZero() && e.TTL.Before(now)
 }
 
 type Store struct {
 	mu    sync.Mutex
-	store map[string]Entry
+	store *dict[Entry]
+	// expires holds the keys of store that have a TTL, which volatile
+	// eviction policies sample from.
+	expires  *dict[struct{}]
+	mem      *memAccount
+	lazyFree *LazyFreeOptions
+	stats    *keyspaceStats
+	waiters  map[string][]chan struct{}
 }
 
 func NewStore() *Store {
-	return &Store{store: make(map[string]Entry)}
+	return &Store{
+		store:    newDict[Entry](),
+		expires:  newDict[struct{}](),
+		mem:      &memAccount{},
+		lazyFree: &LazyFreeOptions{},
+		stats:    &keyspaceStats{},
+		waiters:  make(map[string][]chan struct{}),
+	}
+}
+
+// lookup returns the live entry for key, deleting it first if it has expired,
+// and records the access. Every lookup by a command, including those of
+// writes, counts as a keyspace hit or miss. The caller must hold s.mu.
+func (s *Store) lookup(key string) (Entry, bool) {
+	e := s.find(key)
+	if e == nil {
+		s.stats.misses.Add(1)
+		return Entry{}, false
+	}
+	s.stats.hits.Add(1)
+	e.value.touch(time.Now())
+	return e.value, true
+}
+
+// peek is like lookup but leaves the access time and counter alone, for
+// commands that only inspect keys. The caller must hold s.mu.
+func (s *Store) peek(key string) (Entry, bool) {
+	e := s.find(key)
+	if e == nil {
+		return Entry{}, false
+	}
+	return e.value, true
+}
+
+func (s *Store) find(key string) *dictEntry[Entry] {
+	e := s.store.find(key)
+	if e == nil {
+		return nil
+	}
+	if e.value.expired(time.Now()) {
+		old, _ := s.unlinkKey(key)
+		free(old, s.lazyFree.Expire.Load())
+		s.stats.expired.Add(1)
+		return nil
+	}
+	return e
+}
+
+// setKey stores entry at key. An entry that was never stored is accessed
+// now and keeps the access counter of the value it replaces, as Redis does.
+// The replaced value is freed unless entry holds it again. The caller must
+// hold s.mu.
+func (s *Store) setKey(key string, entry Entry) {
+	old, exists := s.store.get(key)
+	if entry.Access.IsZero() {
+		entry.Access = time.Now()
+		entry.Freq = lfuInitVal
+		if exists {
+			entry.Freq = old.Freq
+		}
+	}
+	if exists {
+		s.detach(key, old)
+		if old.Set != entry.Set || old.ZSet != entry.ZSet || old.Stream != entry.Stream {
+			free(old, s.lazyFree.ServerDel.Load())
+		}
+	}
+	s.attach(key, entry)
+	s.store.set(key, entry)
+	if entry.TTL.IsZero() {
+		s.expires.delete(key)
+	} else {
+		s.expires.set(key, struct{}{})
+	}
+}
+
+// unlinkKey removes key, even if expired, and returns its entry without
+// freeing it. The caller must hold s.mu.
+func (s *Store) unlinkKey(key string) (Entry, bool) {
+	old, ok := s.store.get(key)
+	if !ok {
+		return Entry{}, false
+	}
+	s.detach(key, old)
+	s.store.delete(key)
+	s.expires.delete(key)
+	return old, true
+}
+
+// deleteKey removes key, even if expired, frees its value and reports
+// whether it was present. The caller must hold s.mu.
+func (s *Store) deleteKey(key string) bool {
+	old, ok := s.unlinkKey(key)
+	if ok {
+		free(old, s.lazyFree.ServerDel.Load())
+	}
+	return ok
+}
+
+// Del deletes keys and returns how many existed. Large values are freed in
+// the background when lazyfree-lazy-user-del is set.
+func (s *Store) Del(keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.del(keys, s.lazyFree.UserDel.Load())
+}
+
+// Unlink is like Del but always frees large values in the background.
+func (s *Store) Unlink(keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.del(keys, true)
+}
+
+func (s *Store) del(keys []string, lazy bool) int {
+	deleted := 0
+	for _, key := range keys {
+		if s.find(key) == nil {
+			continue
+		}
+		old, _ := s.unlinkKey(key)
+		free(old, lazy)
+		deleted++
+	}
+	return deleted
 }
 
 func (s *Store) Set(key string, value string, ttl *time.Duration) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if ttl != nil {
-		s.store[key] = Entry{Value: value, TTL: time.Now().Add(*ttl)}
+		s.setKey(key, Entry{Value: value, TTL: time.Now().Add(*ttl)})
 	} else {
-		s.store[key] = Entry{Value: value, TTL: time.Time{}}
+		s.setKey(key, Entry{Value: value, TTL: time.Time{}})
 	}
 }
 
+// SetAt stores value at key to expire at at.
+func (s *Store) SetAt(key string, value string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setKey(key, Entry{Value: value, TTL: at})
+}
+
+// ExpireTime returns when key expires, the zero time if it does not, and
+// whether it exists.
+func (s *Store) ExpireTime(key string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.peek(key)
+	return entry.TTL, ok
+}
+
 func (s *Store) Get(key string) (string, bool) {
+	value, ok, err := s.GetString(key)
+	if err != nil {
+		return "", false
+	}
+
+	return value, ok
+}
+
+// GetString is like Get but reports ErrWrongType when key holds a non-string value.
+func (s *Store) GetString(key string) (string, bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	value, ok := s.store[key]
+	entry, ok := s.lookup(key)
 	if !ok {
-		return "", false
+		return "", false, nil
 	}
 
-	if !value.TTL.IsZero() && value.TTL.Before(time.Now()) {
-		delete(s.store, key)
-		return "", false
+	if entry.Kind != KindString {
+		return "", false, ErrWrongType
 	}
 
-	return value.Value, true
+	return entry.Value, true, nil
+}
+
+// Type returns the kind of value stored at key.
+func (s *Store) Type(key string) (Kind, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.peek(key)
+	if !ok {
+		return 0, false
+	}
+
+	return entry.Kind, true
 }
 
 func (s *Store) Incr(key string) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	value, ok := s.store[key]
+	value, ok := s.lookup(key)
 	if !ok {
-		s.store[key] = Entry{Value: "1"}
+		s.setKey(key, Entry{Value: "1"})
 		return 1, nil
 	}
 
+	if value.Kind != KindString {
+		return 0, ErrWrongType
+	}
+
 	intValue, err := strconv.Atoi(value.Value)
 	if err != nil {
 		return 0, errors.New("value is not an integer or out of range")
 	}
 
-	s.store[key] = Entry{Value: strconv.Itoa(intValue + 1)}
+	s.setKey(key, Entry{Value: strconv.Itoa(intValue + 1)})
 	return intValue + 1, nil
 }
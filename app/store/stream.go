@@ -0,0 +1,580 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrStreamIDTooSmall = errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamIDZero     = errors.New("The ID specified in XADD must be greater than 0-0")
+	ErrStreamExhausted  = errors.New("The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// streamNodeMaxEntries mirrors Redis's stream-node-max-entries default: the
+// number of entries packed in one block of the radix tree.
+const streamNodeMaxEntries = 100
+
+// StreamID is a stream entry ID, <milliseconds>-<sequence>.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MaxStreamID is the greatest possible ID, used for the "+" range bound.
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Next returns the ID immediately after id, or false when id is MaxStreamID.
+func (id StreamID) Next() (StreamID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return StreamID{Ms: id.Ms + 1}, true
+	}
+	return StreamID{}, false
+}
+
+// Prev returns the ID immediately before id, or false when id is 0-0.
+func (id StreamID) Prev() (StreamID, bool) {
+	if id.Seq > 0 {
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return StreamID{}, false
+}
+
+// StreamEntry is a stream item: its ID and a flat list of field/value pairs.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// streamBlock holds consecutive entries, indexed in the radix tree by the ID
+// of the first entry ever appended to it.
+type streamBlock struct {
+	entries []StreamEntry
+}
+
+// Stream is an append-only log of entries with strictly increasing IDs.
+type Stream struct {
+	index        radixTree[*streamBlock]
+	length       int
+	lastID       StreamID
+	maxDeletedID StreamID
+	entriesAdded uint64
+	groups       map[string]*streamGroup
+	// memCounter counts the entries only; consumer groups are small next
+	// to them and left out.
+	memCounter
+}
+
+func newStream() *Stream {
+	return &Stream{}
+}
+
+func (st *Stream) Len() int {
+	return st.length
+}
+
+// LastID returns the ID of the last entry ever added, even if it was deleted since.
+func (st *Stream) LastID() StreamID {
+	return st.lastID
+}
+
+func (st *Stream) append(id StreamID, fields []string) {
+	if id.Compare(st.lastID) <= 0 && st.entriesAdded > 0 {
+		panic("stream IDs must increase")
+	}
+
+	entry := StreamEntry{ID: id, Fields: fields}
+	_, last, ok := st.index.floor(radixKeyOf(MaxStreamID))
+	if ok && len(last.entries) < streamNodeMaxEntries {
+		last.entries = append(last.entries, entry)
+	} else {
+		block := &streamBlock{entries: make([]StreamEntry, 0, streamNodeMaxEntries)}
+		block.entries = append(block.entries, entry)
+		st.index.insert(radixKeyOf(id), block)
+	}
+	st.length++
+	st.lastID = id
+	st.entriesAdded++
+	st.grow(streamEntrySize(entry))
+}
+
+// nextBlock returns the block indexed right after key.
+func (st *Stream) nextBlock(key radixKey) (radixKey, *streamBlock, bool) {
+	next, ok := key.id().Next()
+	if !ok {
+		return radixKey{}, nil, false
+	}
+	return st.index.ceil(radixKeyOf(next))
+}
+
+// prevBlock returns the block indexed right before key.
+func (st *Stream) prevBlock(key radixKey) (radixKey, *streamBlock, bool) {
+	prev, ok := key.id().Prev()
+	if !ok {
+		return radixKey{}, nil, false
+	}
+	return st.index.floor(radixKeyOf(prev))
+}
+
+// rangeOf returns up to count entries with IDs between start and end
+// inclusive, newest first when rev is set. A negative count means no limit.
+func (st *Stream) rangeOf(start StreamID, end StreamID, count int, rev bool) []StreamEntry {
+	out := []StreamEntry{}
+	if count == 0 || start.Compare(end) > 0 {
+		return out
+	}
+
+	if rev {
+		key, block, ok := st.index.floor(radixKeyOf(end))
+		for ok {
+			for i := len(block.entries) - 1; i >= 0; i-- {
+				id := block.entries[i].ID
+				if id.Compare(end) > 0 {
+					continue
+				}
+				if id.Compare(start) < 0 {
+					return out
+				}
+				out = append(out, block.entries[i])
+				if len(out) == count {
+					return out
+				}
+			}
+			key, block, ok = st.prevBlock(key)
+		}
+		return out
+	}
+
+	key, block, ok := st.index.floor(radixKeyOf(start))
+	if !ok {
+		key, block, ok = st.index.ceil(radixKeyOf(start))
+	}
+	for ok {
+		for _, entry := range block.entries {
+			if entry.ID.Compare(start) < 0 {
+				continue
+			}
+			if entry.ID.Compare(end) > 0 {
+				return out
+			}
+			out = append(out, entry)
+			if len(out) == count {
+				return out
+			}
+		}
+		key, block, ok = st.nextBlock(key)
+	}
+	return out
+}
+
+// first returns the oldest entry.
+func (st *Stream) first() (StreamEntry, bool) {
+	entries := st.rangeOf(StreamID{}, MaxStreamID, 1, false)
+	if len(entries) == 0 {
+		return StreamEntry{}, false
+	}
+	return entries[0], true
+}
+
+// last returns the newest entry.
+func (st *Stream) last() (StreamEntry, bool) {
+	entries := st.rangeOf(StreamID{}, MaxStreamID, 1, true)
+	if len(entries) == 0 {
+		return StreamEntry{}, false
+	}
+	return entries[0], true
+}
+
+// locate returns the block holding id and the entry's index within it.
+func (st *Stream) locate(id StreamID) (radixKey, *streamBlock, int, bool) {
+	key, block, ok := st.index.floor(radixKeyOf(id))
+	if !ok {
+		return radixKey{}, nil, 0, false
+	}
+	i := sort.Search(len(block.entries), func(i int) bool { return block.entries[i].ID.Compare(id) >= 0 })
+	if i == len(block.entries) || block.entries[i].ID != id {
+		return radixKey{}, nil, 0, false
+	}
+	return key, block, i, true
+}
+
+// get returns the entry with id.
+func (st *Stream) get(id StreamID) (StreamEntry, bool) {
+	_, block, i, ok := st.locate(id)
+	if !ok {
+		return StreamEntry{}, false
+	}
+	return block.entries[i], true
+}
+
+// delete removes the entry with id and reports whether it existed.
+func (st *Stream) delete(id StreamID) bool {
+	key, block, i, ok := st.locate(id)
+	if !ok {
+		return false
+	}
+
+	st.grow(-streamEntrySize(block.entries[i]))
+	block.entries = append(block.entries[:i], block.entries[i+1:]...)
+	if len(block.entries) == 0 {
+		st.index.remove(key)
+	}
+	st.length--
+	if id.Compare(st.maxDeletedID) > 0 {
+		st.maxDeletedID = id
+	}
+	return true
+}
+
+// StreamTrimStrategy selects what XADD and XTRIM compare the threshold against.
+type StreamTrimStrategy int
+
+const (
+	StreamTrimMaxLen StreamTrimStrategy = iota
+	StreamTrimMinID
+)
+
+// StreamTrim describes a MAXLEN or MINID trim. With Approx only whole blocks
+// are evicted, and at most Limit entries are evicted (0 means no limit).
+type StreamTrim struct {
+	Strategy StreamTrimStrategy
+	MaxLen   int
+	MinID    StreamID
+	Approx   bool
+	Limit    int
+}
+
+// trim evicts the oldest entries as described by spec and returns how many were removed.
+func (st *Stream) trim(spec StreamTrim) int {
+	if spec.Limit != 0 && !spec.Approx {
+		panic("LIMIT requires approximate trimming")
+	}
+
+	removed := 0
+	for st.length > 0 {
+		key, block, ok := st.index.ceil(radixKey{})
+		if !ok {
+			panic("stream length out of sync with index")
+		}
+
+		if spec.Approx {
+			if spec.Limit > 0 && removed+len(block.entries) > spec.Limit {
+				return removed
+			}
+			if !st.canEvictBlock(block, spec) {
+				return removed
+			}
+			st.index.remove(key)
+			st.length -= len(block.entries)
+			removed += len(block.entries)
+			for _, entry := range block.entries {
+				st.grow(-streamEntrySize(entry))
+			}
+			continue
+		}
+
+		evict := 0
+		for evict < len(block.entries) && st.shouldEvict(block.entries[evict].ID, st.length-evict, spec) {
+			evict++
+		}
+		for _, entry := range block.entries[:evict] {
+			st.grow(-streamEntrySize(entry))
+		}
+		block.entries = block.entries[evict:]
+		st.length -= evict
+		removed += evict
+		if len(block.entries) > 0 {
+			return removed
+		}
+		st.index.remove(key)
+	}
+	return removed
+}
+
+// shouldEvict reports whether the oldest entry, with the stream at length, is past the threshold.
+func (st *Stream) shouldEvict(id StreamID, length int, spec StreamTrim) bool {
+	switch spec.Strategy {
+	case StreamTrimMaxLen:
+		return length > spec.MaxLen
+	case StreamTrimMinID:
+		return id.Compare(spec.MinID) < 0
+	default:
+		panic("unknown trim strategy")
+	}
+}
+
+// canEvictBlock reports whether removing the whole block keeps the stream within the threshold.
+func (st *Stream) canEvictBlock(block *streamBlock, spec StreamTrim) bool {
+	switch spec.Strategy {
+	case StreamTrimMaxLen:
+		return st.length-len(block.entries) >= spec.MaxLen
+	case StreamTrimMinID:
+		return block.entries[len(block.entries)-1].ID.Compare(spec.MinID) < 0
+	default:
+		panic("unknown trim strategy")
+	}
+}
+
+// StreamIDSpec is the ID argument of XADD: "*" sets AutoMs, "<ms>-*" sets
+// AutoSeq, otherwise ID is used as given.
+type StreamIDSpec struct {
+	ID      StreamID
+	AutoMs  bool
+	AutoSeq bool
+}
+
+// resolve picks the concrete ID for spec given the stream's last ID.
+func (spec StreamIDSpec) resolve(st *Stream, now time.Time) (StreamID, error) {
+	last := st.lastID
+	switch {
+	case spec.AutoMs:
+		ms := uint64(now.UnixMilli())
+		if ms > last.Ms {
+			return StreamID{Ms: ms}, nil
+		}
+		next, ok := last.Next()
+		if !ok {
+			return StreamID{}, ErrStreamExhausted
+		}
+		return next, nil
+	case spec.AutoSeq:
+		if spec.ID.Ms > last.Ms {
+			return StreamID{Ms: spec.ID.Ms}, nil
+		}
+		if spec.ID.Ms < last.Ms || last.Seq == math.MaxUint64 {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return StreamID{Ms: last.Ms, Seq: last.Seq + 1}, nil
+	default:
+		if spec.ID == (StreamID{}) {
+			return StreamID{}, ErrStreamIDZero
+		}
+		if spec.ID.Compare(last) <= 0 {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return spec.ID, nil
+	}
+}
+
+// streamAt returns the stream stored at key, or nil when key is missing. The caller must hold s.mu.
+func (s *Store) streamAt(key string) (*Stream, error) {
+	entry, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if entry.Kind != KindStream {
+		return nil, ErrWrongType
+	}
+	return entry.Stream, nil
+}
+
+// XAdd appends an entry and returns its ID. With noMkStream a missing key is
+// left alone and ok is false. trim, when not nil, is applied after the append.
+func (s *Store) XAdd(key string, idSpec StreamIDSpec, fields []string, noMkStream bool, trim *StreamTrim) (StreamID, bool, error) {
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		panic("fields must be non-empty field/value pairs")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	created := stream == nil
+	if created {
+		if noMkStream {
+			return StreamID{}, false, nil
+		}
+		stream = newStream()
+	}
+
+	id, err := idSpec.resolve(stream, time.Now())
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if created {
+		s.setKey(key, Entry{Kind: KindStream, Stream: stream})
+	}
+
+	stream.append(id, fields)
+	if trim != nil {
+		stream.trim(*trim)
+	}
+	s.signal(key)
+	return id, true, nil
+}
+
+// XRange returns up to count entries between start and end inclusive, newest
+// first when rev is set. A negative count means no limit.
+func (s *Store) XRange(key string, start StreamID, end StreamID, count int, rev bool) ([]StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil || stream == nil {
+		return []StreamEntry{}, err
+	}
+	return stream.rangeOf(start, end, count, rev), nil
+}
+
+func (s *Store) XLen(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+	return stream.Len(), nil
+}
+
+// XDel deletes entries by ID and returns how many existed. Streams are kept
+// even when they become empty, as in Redis.
+func (s *Store) XDel(key string, ids []StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if stream.delete(id) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) XTrim(key string, trim StreamTrim) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+	return stream.trim(trim), nil
+}
+
+// XReadFrom selects where XREAD starts reading a stream.
+type XReadFrom int
+
+const (
+	// XReadAfterID reads entries with IDs greater than an explicit ID.
+	XReadAfterID XReadFrom = iota
+	// XReadNew reads only entries added after the call, the "$" ID.
+	XReadNew
+	// XReadLast reads the current last entry, the "+" ID. An empty stream
+	// behaves like XReadNew.
+	XReadLast
+)
+
+// XReadStart is the position XREAD reads one stream from.
+type XReadStart struct {
+	From XReadFrom
+	ID   StreamID
+}
+
+// StreamRead holds the entries XREAD returned for one key.
+type StreamRead struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// XReadResolve turns each start into the exclusive ID entries must follow,
+// so that a blocked XREAD keeps reading from where "$" and "+" pointed when
+// it was issued.
+func (s *Store) XReadResolve(keys []string, starts []XReadStart) ([]StreamID, error) {
+	if len(keys) != len(starts) {
+		panic("starts must match keys")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	after := make([]StreamID, len(keys))
+	for i, key := range keys {
+		if starts[i].From == XReadAfterID {
+			after[i] = starts[i].ID
+			continue
+		}
+		stream, err := s.streamAt(key)
+		if err != nil {
+			return nil, err
+		}
+		if stream == nil {
+			continue
+		}
+		after[i] = stream.LastID()
+		if starts[i].From == XReadLast {
+			if last, ok := stream.last(); ok {
+				after[i], _ = last.ID.Prev()
+			}
+		}
+	}
+	return after, nil
+}
+
+// XRead returns up to count entries newer than after[i] from each stream in
+// keys, skipping streams with nothing new. A negative count means no limit.
+func (s *Store) XRead(keys []string, after []StreamID, count int) ([]StreamRead, error) {
+	if len(keys) != len(after) {
+		panic("IDs must match keys")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reads []StreamRead
+	for i, key := range keys {
+		stream, err := s.streamAt(key)
+		if err != nil {
+			return nil, err
+		}
+		if stream == nil {
+			continue
+		}
+		start, ok := after[i].Next()
+		if !ok {
+			continue
+		}
+		entries := stream.rangeOf(start, MaxStreamID, count, false)
+		if len(entries) > 0 {
+			reads = append(reads, StreamRead{Key: key, Entries: entries})
+		}
+	}
+	return reads, nil
+}
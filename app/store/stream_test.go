@@ -0,0 +1,120 @@
+package store
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(entries []StreamEntry) []StreamID {
+	out := make([]StreamID, len(entries))
+	for i, entry := range entries {
+		out[i] = entry.ID
+	}
+	return out
+}
+
+func TestStoreXAddIDs(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		last    *StreamID
+		spec    StreamIDSpec
+		want    StreamID
+		wantErr error
+	}{
+		{name: "explicit", spec: StreamIDSpec{ID: StreamID{1, 1}}, want: StreamID{1, 1}},
+		{name: "zero", spec: StreamIDSpec{ID: StreamID{}}, wantErr: ErrStreamIDZero},
+		{name: "auto seq on empty zero ms", spec: StreamIDSpec{AutoSeq: true}, want: StreamID{0, 1}},
+		{name: "auto seq same ms", last: &StreamID{5, 3}, spec: StreamIDSpec{ID: StreamID{Ms: 5}, AutoSeq: true}, want: StreamID{5, 4}},
+		{name: "auto seq newer ms", last: &StreamID{5, 3}, spec: StreamIDSpec{ID: StreamID{Ms: 6}, AutoSeq: true}, want: StreamID{6, 0}},
+		{name: "auto seq older ms", last: &StreamID{5, 3}, spec: StreamIDSpec{ID: StreamID{Ms: 4}, AutoSeq: true}, wantErr: ErrStreamIDTooSmall},
+		{name: "equal to top", last: &StreamID{5, 3}, spec: StreamIDSpec{ID: StreamID{5, 3}}, wantErr: ErrStreamIDTooSmall},
+		{name: "auto ms behind clock", last: &StreamID{math.MaxUint64 - 1, 7}, spec: StreamIDSpec{AutoMs: true}, want: StreamID{math.MaxUint64 - 1, 8}},
+		{name: "exhausted", last: &MaxStreamID, spec: StreamIDSpec{AutoMs: true}, wantErr: ErrStreamExhausted},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			if tc.last != nil {
+				_, _, err := s.XAdd("s", StreamIDSpec{ID: *tc.last}, []string{"f", "v"}, false, nil)
+				assert.NoError(t, err)
+			}
+			got, ok, err := s.XAdd("s", tc.spec, []string{"f", "v"}, false, nil)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStoreXAddNoMkStream(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	_, ok, err := s.XAdd("s", StreamIDSpec{AutoMs: true}, []string{"f", "v"}, true, nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, exists := s.Type("s")
+	assert.False(t, exists)
+}
+
+func TestStoreXRangeAcrossBlocks(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	total := streamNodeMaxEntries*3 + 7
+	for i := 1; i <= total; i++ {
+		s.XAdd("s", StreamIDSpec{ID: StreamID{Ms: uint64(i)}}, []string{"n", "v"}, false, nil)
+	}
+
+	all, err := s.XRange("s", StreamID{}, MaxStreamID, -1, false)
+	assert.NoError(t, err)
+	assert.Len(t, all, total)
+
+	got, _ := s.XRange("s", StreamID{Ms: 99}, StreamID{Ms: 102}, -1, false)
+	assert.Equal(t, []StreamID{{99, 0}, {100, 0}, {101, 0}, {102, 0}}, ids(got))
+
+	got, _ = s.XRange("s", StreamID{Ms: 99}, StreamID{Ms: 250}, 2, true)
+	assert.Equal(t, []StreamID{{250, 0}, {249, 0}}, ids(got))
+
+	deleted, err := s.XDel("s", []StreamID{{100, 0}, {101, 0}, {100, 0}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	got, _ = s.XRange("s", StreamID{Ms: 99}, StreamID{Ms: 102}, -1, false)
+	assert.Equal(t, []StreamID{{99, 0}, {102, 0}}, ids(got))
+}
+
+func TestStoreXTrim(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		trim        StreamTrim
+		wantRemoved int
+	}{
+		{"maxlen exact", StreamTrim{Strategy: StreamTrimMaxLen, MaxLen: 150}, 100},
+		{"maxlen approx keeps partial block", StreamTrim{Strategy: StreamTrimMaxLen, MaxLen: 150, Approx: true}, 100},
+		{"maxlen approx below block", StreamTrim{Strategy: StreamTrimMaxLen, MaxLen: 180, Approx: true}, 0},
+		{"maxlen approx limit", StreamTrim{Strategy: StreamTrimMaxLen, MaxLen: 0, Approx: true, Limit: 150}, 100},
+		{"minid exact", StreamTrim{Strategy: StreamTrimMinID, MinID: StreamID{Ms: 121}}, 120},
+		{"minid approx", StreamTrim{Strategy: StreamTrimMinID, MinID: StreamID{Ms: 121}, Approx: true}, 100},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			for i := 1; i <= 250; i++ {
+				s.XAdd("s", StreamIDSpec{ID: StreamID{Ms: uint64(i)}}, []string{"n", "v"}, false, nil)
+			}
+			removed, err := s.XTrim("s", tc.trim)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantRemoved, removed)
+			length, _ := s.XLen("s")
+			assert.Equal(t, 250-tc.wantRemoved, length)
+		})
+	}
+}
@@ -0,0 +1,861 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBusyGroup        = errors.New("Consumer Group name already exists")
+	ErrXGroupKeyMissing = errors.New("The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	ErrNoSuchKey        = errors.New("no such key")
+)
+
+// NoGroupError reports that key holds no consumer group named Group.
+type NoGroupError struct {
+	Key   string
+	Group string
+}
+
+func (e NoGroupError) Error() string {
+	return fmt.Sprintf("No such consumer group '%s' for key name '%s'", e.Group, e.Key)
+}
+
+// InvalidEntriesRead marks a group whose count of entries read is unknown,
+// for example after XGROUP SETID to an arbitrary ID.
+const InvalidEntriesRead = -1
+
+// streamNACK is a pending entry: delivered to consumer but not acknowledged.
+type streamNACK struct {
+	deliveryTime  time.Time
+	deliveryCount int
+	consumer      *streamConsumer
+}
+
+// streamConsumer is a member of a group. Its PEL shares the NACKs of the
+// group PEL for the entries delivered to it. A zero activeTime means the
+// consumer never read or claimed an entry.
+type streamConsumer struct {
+	name       string
+	seenTime   time.Time
+	activeTime time.Time
+	pel        radixTree[*streamNACK]
+}
+
+// streamGroup is a consumer group: the last ID delivered with ">", the
+// pending entries of every consumer and the consumers themselves.
+type streamGroup struct {
+	lastID      StreamID
+	entriesRead int64
+	pel         radixTree[*streamNACK]
+	consumers   map[string]*streamConsumer
+}
+
+func newStreamGroup(lastID StreamID, entriesRead int64) *streamGroup {
+	return &streamGroup{lastID: lastID, entriesRead: entriesRead, consumers: make(map[string]*streamConsumer)}
+}
+
+// consumer returns the consumer called name, creating it when missing, and
+// marks it as seen.
+func (g *streamGroup) consumer(name string, now time.Time) *streamConsumer {
+	c, ok := g.consumers[name]
+	if !ok {
+		c = &streamConsumer{name: name}
+		g.consumers[name] = c
+	}
+	c.seenTime = now
+	return c
+}
+
+// assign makes id pending for consumer, moving it from its previous owner.
+func (g *streamGroup) assign(id StreamID, nack *streamNACK, consumer *streamConsumer) {
+	key := radixKeyOf(id)
+	if nack.consumer == consumer {
+		return
+	}
+	if nack.consumer != nil {
+		nack.consumer.pel.remove(key)
+	}
+	nack.consumer = consumer
+	consumer.pel.insert(key, nack)
+}
+
+// ack removes id from the PELs and reports whether it was pending.
+func (g *streamGroup) ack(id StreamID) bool {
+	key := radixKeyOf(id)
+	nack, ok := g.pel.get(key)
+	if !ok {
+		return false
+	}
+	g.pel.remove(key)
+	nack.consumer.pel.remove(key)
+	return true
+}
+
+// pelRange calls fn for each pending ID of pel from start to end inclusive,
+// in order, until fn returns false.
+func pelRange(pel *radixTree[*streamNACK], start StreamID, end StreamID, fn func(StreamID, *streamNACK) bool) {
+	key, nack, ok := pel.ceil(radixKeyOf(start))
+	for ok {
+		id := key.id()
+		if id.Compare(end) > 0 || !fn(id, nack) {
+			return
+		}
+		next, more := id.Next()
+		if !more {
+			return
+		}
+		key, nack, ok = pel.ceil(radixKeyOf(next))
+	}
+}
+
+// hasTombstones reports whether an entry at or after start was deleted.
+func (st *Stream) hasTombstones(start StreamID) bool {
+	if st.length == 0 || st.maxDeletedID == (StreamID{}) {
+		return false
+	}
+	return start.Compare(st.maxDeletedID) <= 0
+}
+
+// estimateEntriesRead returns the number of entries added up to and
+// including id, or InvalidEntriesRead when deletions make it unknowable.
+func (st *Stream) estimateEntriesRead(id StreamID) int64 {
+	added := int64(st.entriesAdded)
+	if added == 0 {
+		return 0
+	}
+	cmpLast := id.Compare(st.lastID)
+	if st.length == 0 && cmpLast <= 0 {
+		return added
+	}
+	if cmpLast == 0 {
+		return added
+	}
+	if cmpLast > 0 {
+		return InvalidEntriesRead
+	}
+
+	first, _ := st.first()
+	if st.maxDeletedID == (StreamID{}) || st.maxDeletedID.Compare(first.ID) < 0 {
+		switch id.Compare(first.ID) {
+		case -1:
+			return added - int64(st.length)
+		case 0:
+			return added - int64(st.length) + 1
+		}
+	}
+	return InvalidEntriesRead
+}
+
+// lag returns how many entries the group has yet to read, or false when it
+// cannot be known.
+func (st *Stream) lag(g *streamGroup) (int64, bool) {
+	added := int64(st.entriesAdded)
+	if added == 0 {
+		return 0, true
+	}
+	if g.entriesRead != InvalidEntriesRead && !st.hasTombstones(g.lastID) {
+		return added - g.entriesRead, true
+	}
+	read := st.estimateEntriesRead(g.lastID)
+	if read == InvalidEntriesRead {
+		return 0, false
+	}
+	return added - read, true
+}
+
+// advanceGroup records that the group was delivered id with ">".
+func (st *Stream) advanceGroup(g *streamGroup, id StreamID) {
+	if id.Compare(g.lastID) <= 0 {
+		return
+	}
+	if g.entriesRead != InvalidEntriesRead && !st.hasTombstones(id) {
+		g.entriesRead++
+	} else if st.entriesAdded > 0 {
+		g.entriesRead = st.estimateEntriesRead(id)
+	}
+	g.lastID = id
+}
+
+// xgroupStreamAt returns the stream at key for an XGROUP subcommand, which
+// requires the key to exist. The caller must hold s.mu.
+func (s *Store) xgroupStreamAt(key string) (*Stream, error) {
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrXGroupKeyMissing
+	}
+	return stream, nil
+}
+
+// groupAt returns the stream at key and its group. The caller must hold s.mu.
+func (s *Store) groupAt(key string, group string) (*Stream, *streamGroup, error) {
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stream == nil {
+		return nil, nil, NoGroupError{Key: key, Group: group}
+	}
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, nil, NoGroupError{Key: key, Group: group}
+	}
+	return stream, g, nil
+}
+
+// XGroupCreate creates group on the stream at key, delivering entries after
+// id, or after the last ID when useLastID is set. With mkStream a missing key
+// becomes an empty stream.
+func (s *Store) XGroupCreate(key string, group string, id StreamID, useLastID bool, mkStream bool, entriesRead int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		if !mkStream {
+			return ErrXGroupKeyMissing
+		}
+		stream = newStream()
+		s.setKey(key, Entry{Kind: KindStream, Stream: stream})
+	}
+	if _, ok := stream.groups[group]; ok {
+		return ErrBusyGroup
+	}
+
+	if useLastID {
+		id = stream.lastID
+	}
+	if stream.groups == nil {
+		stream.groups = make(map[string]*streamGroup)
+	}
+	stream.groups[group] = newStreamGroup(id, entriesRead)
+	return nil
+}
+
+// XGroupSetID moves the last delivered ID of group.
+func (s *Store) XGroupSetID(key string, group string, id StreamID, useLastID bool, entriesRead int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.xgroupStreamAt(key)
+	if err != nil {
+		return err
+	}
+	g, ok := stream.groups[group]
+	if !ok {
+		return NoGroupError{Key: key, Group: group}
+	}
+	if useLastID {
+		id = stream.lastID
+	}
+	g.lastID, g.entriesRead = id, entriesRead
+	return nil
+}
+
+// XGroupDestroy deletes group and reports whether it existed. Clients
+// blocked reading the group are woken so they can report it is gone.
+func (s *Store) XGroupDestroy(key string, group string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.xgroupStreamAt(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := stream.groups[group]; !ok {
+		return false, nil
+	}
+	delete(stream.groups, group)
+	s.signal(key)
+	return true, nil
+}
+
+// XGroupCreateConsumer adds consumer to group and reports whether it was created.
+func (s *Store) XGroupCreateConsumer(key string, group string, consumer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.xgroupStreamAt(key)
+	if err != nil {
+		return false, err
+	}
+	g, ok := stream.groups[group]
+	if !ok {
+		return false, NoGroupError{Key: key, Group: group}
+	}
+	if _, ok := g.consumers[consumer]; ok {
+		return false, nil
+	}
+	g.consumer(consumer, time.Now())
+	return true, nil
+}
+
+// XGroupDelConsumer removes consumer from group, dropping its pending entries,
+// and returns how many were pending.
+func (s *Store) XGroupDelConsumer(key string, group string, consumer string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.xgroupStreamAt(key)
+	if err != nil {
+		return 0, err
+	}
+	g, ok := stream.groups[group]
+	if !ok {
+		return 0, NoGroupError{Key: key, Group: group}
+	}
+	c, ok := g.consumers[consumer]
+	if !ok {
+		return 0, nil
+	}
+
+	pending := c.pel.size
+	pelRange(&c.pel, StreamID{}, MaxStreamID, func(id StreamID, _ *streamNACK) bool {
+		g.pel.remove(radixKeyOf(id))
+		return true
+	})
+	delete(g.consumers, consumer)
+	return pending, nil
+}
+
+// XReadGroup reads the streams at keys on behalf of consumer in group. A
+// start of XReadNew is the ">" ID: entries never delivered to the group,
+// which become pending for consumer unless noAck is set. Any other start
+// reads the consumer's own pending entries after its ID; those are always
+// returned, even when empty, and an entry deleted since delivery has nil
+// Fields. A negative count means no limit.
+func (s *Store) XReadGroup(keys []string, starts []XReadStart, group string, consumer string, count int, noAck bool) ([]StreamRead, error) {
+	if len(keys) != len(starts) {
+		panic("starts must match keys")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	streams := make([]*Stream, len(keys))
+	groups := make([]*streamGroup, len(keys))
+	for i, key := range keys {
+		stream, g, err := s.groupAt(key, group)
+		if err != nil {
+			return nil, err
+		}
+		streams[i], groups[i] = stream, g
+	}
+
+	now := time.Now()
+	var reads []StreamRead
+	for i, key := range keys {
+		stream, g := streams[i], groups[i]
+		c := g.consumer(consumer, now)
+
+		switch starts[i].From {
+		case XReadNew:
+			start, ok := g.lastID.Next()
+			if !ok {
+				continue
+			}
+			entries := stream.rangeOf(start, MaxStreamID, count, false)
+			if len(entries) == 0 {
+				continue
+			}
+			for _, entry := range entries {
+				stream.advanceGroup(g, entry.ID)
+				if noAck {
+					continue
+				}
+				nack, ok := g.pel.get(radixKeyOf(entry.ID))
+				if !ok {
+					nack = &streamNACK{}
+					g.pel.insert(radixKeyOf(entry.ID), nack)
+				}
+				nack.deliveryTime, nack.deliveryCount = now, 1
+				g.assign(entry.ID, nack, c)
+			}
+			c.activeTime = now
+			reads = append(reads, StreamRead{Key: key, Entries: entries})
+		case XReadAfterID:
+			entries := []StreamEntry{}
+			if start, ok := starts[i].ID.Next(); ok {
+				pelRange(&c.pel, start, MaxStreamID, func(id StreamID, _ *streamNACK) bool {
+					entry, ok := stream.get(id)
+					if !ok {
+						entry = StreamEntry{ID: id}
+					}
+					entries = append(entries, entry)
+					return count < 0 || len(entries) < count
+				})
+			}
+			reads = append(reads, StreamRead{Key: key, Entries: entries})
+		default:
+			panic("unsupported XREADGROUP start")
+		}
+	}
+	return reads, nil
+}
+
+// XAck acknowledges ids in group and returns how many were pending. A
+// missing key or group acknowledges nothing.
+func (s *Store) XAck(key string, group string, ids []StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.groupAt(key, group)
+	if errors.As(err, &NoGroupError{}) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	acked := 0
+	for _, id := range ids {
+		if g.ack(id) {
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// PendingEntry describes one entry of a pending entries list.
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  time.Time
+	DeliveryCount int
+}
+
+// ConsumerPending is the number of entries pending for one consumer.
+type ConsumerPending struct {
+	Consumer string
+	Count    int
+}
+
+// PendingSummary is the reply of the short form of XPENDING.
+type PendingSummary struct {
+	Count     int
+	Min       StreamID
+	Max       StreamID
+	Consumers []ConsumerPending
+}
+
+// XPendingSummary returns the pending count of group, its lowest and highest
+// pending IDs and the count of every consumer with pending entries, ordered
+// by consumer name.
+func (s *Store) XPendingSummary(key string, group string) (PendingSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.groupAt(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+
+	summary := PendingSummary{Count: g.pel.size}
+	if g.pel.size == 0 {
+		return summary, nil
+	}
+	lowest, _, _ := g.pel.ceil(radixKey{})
+	highest, _, _ := g.pel.floor(radixKeyOf(MaxStreamID))
+	summary.Min, summary.Max = lowest.id(), highest.id()
+	for _, name := range sortedConsumerNames(g) {
+		if c := g.consumers[name]; c.pel.size > 0 {
+			summary.Consumers = append(summary.Consumers, ConsumerPending{Consumer: name, Count: c.pel.size})
+		}
+	}
+	return summary, nil
+}
+
+func sortedConsumerNames(g *streamGroup) []string {
+	names := make([]string, 0, len(g.consumers))
+	for name := range g.consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// XPendingSpec selects entries for the extended form of XPENDING. An empty
+// Consumer selects every consumer.
+type XPendingSpec struct {
+	Start    StreamID
+	End      StreamID
+	Count    int
+	Consumer string
+	MinIdle  time.Duration
+}
+
+// XPendingRange returns up to spec.Count pending entries of group between
+// spec.Start and spec.End that have been idle for at least spec.MinIdle.
+func (s *Store) XPendingRange(key string, group string, spec XPendingSpec) ([]PendingEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.groupAt(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []PendingEntry{}
+	pel := &g.pel
+	if spec.Consumer != "" {
+		c, ok := g.consumers[spec.Consumer]
+		if !ok {
+			return entries, nil
+		}
+		pel = &c.pel
+	}
+	if spec.Count <= 0 {
+		return entries, nil
+	}
+
+	now := time.Now()
+	pelRange(pel, spec.Start, spec.End, func(id StreamID, nack *streamNACK) bool {
+		if now.Sub(nack.deliveryTime) < spec.MinIdle {
+			return true
+		}
+		entries = append(entries, PendingEntry{ID: id, Consumer: nack.consumer.name, DeliveryTime: nack.deliveryTime, DeliveryCount: nack.deliveryCount})
+		return len(entries) < spec.Count
+	})
+	return entries, nil
+}
+
+// XGroupState returns the last delivered ID and entries read of group and
+// those of ids that are pending, in the order of ids, so that what a read or
+// claim did can be sent to replicas as its outcome.
+func (s *Store) XGroupState(key string, group string, ids []StreamID) (StreamID, int64, []PendingEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.groupAt(key, group)
+	if err != nil {
+		return StreamID{}, 0, nil, err
+	}
+
+	var pending []PendingEntry
+	for _, id := range ids {
+		if nack, ok := g.pel.get(radixKeyOf(id)); ok {
+			pending = append(pending, PendingEntry{ID: id, Consumer: nack.consumer.name, DeliveryTime: nack.deliveryTime, DeliveryCount: nack.deliveryCount})
+		}
+	}
+	return g.lastID, g.entriesRead, pending, nil
+}
+
+// XClaimOptions are the options of XCLAIM. A zero DeliveryTime means now and
+// a negative RetryCount leaves delivery counts to be incremented, which
+// JustID suppresses. LastID, when not nil, advances the group's last ID.
+type XClaimOptions struct {
+	DeliveryTime time.Time
+	RetryCount   int
+	Force        bool
+	JustID       bool
+	LastID       *StreamID
+}
+
+// XClaim transfers the pending entries ids that have been idle for at least
+// minIdle to consumer and returns them; with JustID only IDs are set. Pending
+// entries deleted from the stream are dropped from the PEL instead. Force
+// creates pending entries for ids that exist in the stream but are not
+// pending.
+func (s *Store) XClaim(key string, group string, consumer string, minIdle time.Duration, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, g, err := s.groupAt(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deliveryTime := opts.DeliveryTime
+	if deliveryTime.IsZero() || deliveryTime.After(now) {
+		deliveryTime = now
+	}
+	if opts.LastID != nil && opts.LastID.Compare(g.lastID) > 0 {
+		g.lastID = *opts.LastID
+	}
+
+	var c *streamConsumer
+	claimed := []StreamEntry{}
+	for _, id := range ids {
+		key := radixKeyOf(id)
+		nack, pending := g.pel.get(key)
+		entry, exists := stream.get(id)
+		if !exists {
+			if pending {
+				g.ack(id)
+			}
+			continue
+		}
+		if !pending {
+			if !opts.Force {
+				continue
+			}
+			nack = &streamNACK{deliveryTime: now, deliveryCount: 1}
+			g.pel.insert(key, nack)
+		}
+		if nack.consumer != nil && minIdle > 0 && now.Sub(nack.deliveryTime) < minIdle {
+			continue
+		}
+
+		if c == nil {
+			c = g.consumer(consumer, now)
+		}
+		nack.deliveryTime = deliveryTime
+		if opts.RetryCount >= 0 {
+			nack.deliveryCount = opts.RetryCount
+		} else if !opts.JustID {
+			nack.deliveryCount++
+		}
+		g.assign(id, nack, c)
+		c.activeTime = now
+
+		if opts.JustID {
+			entry = StreamEntry{ID: id}
+		}
+		claimed = append(claimed, entry)
+	}
+	return claimed, nil
+}
+
+// xautoclaimAttemptsFactor bounds the PEL entries XAUTOCLAIM examines to this
+// many times its count, as Redis does.
+const xautoclaimAttemptsFactor = 10
+
+// XAutoClaim claims up to count pending entries of group, starting at start,
+// that have been idle for at least minIdle. It returns the ID to resume the
+// scan from (0-0 once the PEL is exhausted), the claimed entries (only IDs
+// with justID) and the IDs of pending entries found deleted from the stream,
+// which are dropped from the PEL.
+func (s *Store) XAutoClaim(key string, group string, consumer string, minIdle time.Duration, start StreamID, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	if count <= 0 {
+		panic("count must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, g, err := s.groupAt(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+
+	now := time.Now()
+	c := g.consumer(consumer, now)
+	claimed := []StreamEntry{}
+	deleted := []StreamID{}
+	attempts := count * xautoclaimAttemptsFactor
+	next := StreamID{}
+	exhausted := true
+
+	pelRange(&g.pel, start, MaxStreamID, func(id StreamID, nack *streamNACK) bool {
+		if attempts == 0 || len(claimed) == count {
+			next, exhausted = id, false
+			return false
+		}
+		attempts--
+
+		entry, exists := stream.get(id)
+		if !exists {
+			g.ack(id)
+			deleted = append(deleted, id)
+			return true
+		}
+		if minIdle > 0 && now.Sub(nack.deliveryTime) < minIdle {
+			return true
+		}
+
+		nack.deliveryTime = now
+		if !justID {
+			nack.deliveryCount++
+		}
+		g.assign(id, nack, c)
+		c.activeTime = now
+		if justID {
+			entry = StreamEntry{ID: id}
+		}
+		claimed = append(claimed, entry)
+		return true
+	})
+	if exhausted {
+		next = StreamID{}
+	}
+	return next, claimed, deleted, nil
+}
+
+// ConsumerInfo describes a consumer for XINFO. PEL is only filled for XINFO
+// STREAM FULL.
+type ConsumerInfo struct {
+	Name       string
+	Pending    int
+	SeenTime   time.Time
+	ActiveTime time.Time
+	PEL        []PendingEntry
+}
+
+// GroupInfo describes a consumer group for XINFO. EntriesRead is
+// InvalidEntriesRead and LagValid false when they cannot be known. PEL and
+// ConsumerDetails are only filled for XINFO STREAM FULL.
+type GroupInfo struct {
+	Name            string
+	Consumers       int
+	Pending         int
+	LastDeliveredID StreamID
+	EntriesRead     int64
+	Lag             int64
+	LagValid        bool
+	PEL             []PendingEntry
+	ConsumerDetails []ConsumerInfo
+}
+
+// StreamInfo describes a stream for XINFO STREAM. Without FULL, FirstEntry
+// and LastEntry are set when the stream is not empty; with it, Entries and
+// the details of Groups are.
+type StreamInfo struct {
+	Length               int
+	RadixTreeKeys        int
+	RadixTreeNodes       int
+	LastGeneratedID      StreamID
+	MaxDeletedEntryID    StreamID
+	EntriesAdded         uint64
+	RecordedFirstEntryID StreamID
+	Groups               []GroupInfo
+	FirstEntry           *StreamEntry
+	LastEntry            *StreamEntry
+	Entries              []StreamEntry
+}
+
+// XInfoStream describes the stream at key. With full, up to count entries
+// and pending entries per list are included; a count of 0 means no limit.
+func (s *Store) XInfoStream(key string, full bool, count int) (StreamInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	if stream == nil {
+		return StreamInfo{}, ErrNoSuchKey
+	}
+
+	info := StreamInfo{
+		Length:            stream.length,
+		RadixTreeKeys:     stream.index.size,
+		RadixTreeNodes:    stream.index.nodes(),
+		LastGeneratedID:   stream.lastID,
+		MaxDeletedEntryID: stream.maxDeletedID,
+		EntriesAdded:      stream.entriesAdded,
+		Groups:            stream.groupInfos(full, count),
+	}
+	first, hasFirst := stream.first()
+	if hasFirst {
+		info.RecordedFirstEntryID = first.ID
+	}
+
+	if full {
+		limit := count
+		if limit == 0 {
+			limit = -1
+		}
+		info.Entries = stream.rangeOf(StreamID{}, MaxStreamID, limit, false)
+		return info, nil
+	}
+	if hasFirst {
+		last, _ := stream.last()
+		info.FirstEntry, info.LastEntry = &first, &last
+	}
+	return info, nil
+}
+
+// XInfoGroups describes the consumer groups of the stream at key, ordered by name.
+func (s *Store) XInfoGroups(key string) ([]GroupInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrNoSuchKey
+	}
+	return stream.groupInfos(false, 0), nil
+}
+
+// XInfoConsumers describes the consumers of group, ordered by name.
+func (s *Store) XInfoConsumers(key string, group string) ([]ConsumerInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.streamAt(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrNoSuchKey
+	}
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, NoGroupError{Key: key, Group: group}
+	}
+	return g.consumerInfos(false, 0), nil
+}
+
+func (st *Stream) groupInfos(full bool, count int) []GroupInfo {
+	names := make([]string, 0, len(st.groups))
+	for name := range st.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]GroupInfo, len(names))
+	for i, name := range names {
+		g := st.groups[name]
+		lag, lagValid := st.lag(g)
+		infos[i] = GroupInfo{
+			Name:            name,
+			Consumers:       len(g.consumers),
+			Pending:         g.pel.size,
+			LastDeliveredID: g.lastID,
+			EntriesRead:     g.entriesRead,
+			Lag:             lag,
+			LagValid:        lagValid,
+		}
+		if full {
+			infos[i].PEL = pendingEntries(&g.pel, count)
+			infos[i].ConsumerDetails = g.consumerInfos(true, count)
+		}
+	}
+	return infos
+}
+
+func (g *streamGroup) consumerInfos(full bool, count int) []ConsumerInfo {
+	names := sortedConsumerNames(g)
+	infos := make([]ConsumerInfo, len(names))
+	for i, name := range names {
+		c := g.consumers[name]
+		infos[i] = ConsumerInfo{Name: name, Pending: c.pel.size, SeenTime: c.seenTime, ActiveTime: c.activeTime}
+		if full {
+			infos[i].PEL = pendingEntries(&c.pel, count)
+		}
+	}
+	return infos
+}
+
+// pendingEntries lists up to count entries of pel; 0 means no limit.
+func pendingEntries(pel *radixTree[*streamNACK], count int) []PendingEntry {
+	entries := []PendingEntry{}
+	pelRange(pel, StreamID{}, MaxStreamID, func(id StreamID, nack *streamNACK) bool {
+		entries = append(entries, PendingEntry{ID: id, Consumer: nack.consumer.name, DeliveryTime: nack.deliveryTime, DeliveryCount: nack.deliveryCount})
+		return count == 0 || len(entries) < count
+	})
+	return entries
+}
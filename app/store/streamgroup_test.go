@@ -0,0 +1,115 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newGroupStream(t *testing.T, n int) *Store {
+	t.Helper()
+	s := NewStore()
+	assert.NoError(t, s.XGroupCreate("s", "g", StreamID{}, false, true, InvalidEntriesRead))
+	for i := 1; i <= n; i++ {
+		_, _, err := s.XAdd("s", StreamIDSpec{ID: StreamID{Ms: uint64(i)}}, []string{"n", "v"}, false, nil)
+		assert.NoError(t, err)
+	}
+	return s
+}
+
+func TestStoreXReadGroupDeliversAndTracksPending(t *testing.T) {
+	t.Parallel()
+	s := newGroupStream(t, 3)
+	newOnly := []XReadStart{{From: XReadNew}}
+
+	reads, err := s.XReadGroup([]string{"s"}, newOnly, "g", "alice", 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamID{{1, 0}, {2, 0}}, ids(reads[0].Entries))
+
+	reads, err = s.XReadGroup([]string{"s"}, newOnly, "g", "bob", -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamID{{3, 0}}, ids(reads[0].Entries))
+
+	reads, err = s.XReadGroup([]string{"s"}, newOnly, "g", "bob", -1, false)
+	assert.NoError(t, err)
+	assert.Empty(t, reads)
+
+	summary, err := s.XPendingSummary("s", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, PendingSummary{Count: 2, Min: StreamID{1, 0}, Max: StreamID{2, 0}, Consumers: []ConsumerPending{{"alice", 2}}}, summary)
+
+	s.XDel("s", []StreamID{{1, 0}})
+	reads, err = s.XReadGroup([]string{"s"}, []XReadStart{{ID: StreamID{}}}, "g", "alice", -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamEntry{{ID: StreamID{1, 0}}, {ID: StreamID{2, 0}, Fields: []string{"n", "v"}}}, reads[0].Entries)
+
+	acked, err := s.XAck("s", "g", []StreamID{{1, 0}, {3, 0}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, acked)
+
+	_, err = s.XReadGroup([]string{"s"}, newOnly, "missing", "alice", -1, false)
+	assert.ErrorIs(t, err, NoGroupError{Key: "s", Group: "missing"})
+}
+
+func TestStoreXClaim(t *testing.T) {
+	t.Parallel()
+	s := newGroupStream(t, 3)
+	s.XReadGroup([]string{"s"}, []XReadStart{{From: XReadNew}}, "g", "alice", -1, false)
+	s.XDel("s", []StreamID{{2, 0}})
+
+	claimed, err := s.XClaim("s", "g", "bob", time.Hour, []StreamID{{1, 0}}, XClaimOptions{RetryCount: -1})
+	assert.NoError(t, err)
+	assert.Empty(t, claimed, "entries idle for less than min-idle-time stay put")
+
+	claimed, err = s.XClaim("s", "g", "bob", 0, []StreamID{{1, 0}, {2, 0}, {3, 0}}, XClaimOptions{RetryCount: -1, JustID: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamEntry{{ID: StreamID{1, 0}}, {ID: StreamID{3, 0}}}, claimed)
+
+	pending, err := s.XPendingRange("s", "g", XPendingSpec{Start: StreamID{}, End: MaxStreamID, Count: 10})
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2, "the deleted entry is dropped from the PEL")
+	for _, entry := range pending {
+		assert.Equal(t, "bob", entry.Consumer)
+		assert.Equal(t, 1, entry.DeliveryCount, "JUSTID does not count as a delivery")
+	}
+}
+
+func TestStoreXAutoClaim(t *testing.T) {
+	t.Parallel()
+	s := newGroupStream(t, 5)
+	s.XReadGroup([]string{"s"}, []XReadStart{{From: XReadNew}}, "g", "alice", -1, false)
+	s.XDel("s", []StreamID{{2, 0}})
+
+	next, claimed, deleted, err := s.XAutoClaim("s", "g", "bob", 0, StreamID{}, 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{4, 0}, next)
+	assert.Equal(t, []StreamID{{1, 0}, {3, 0}}, ids(claimed))
+	assert.Equal(t, []StreamID{{2, 0}}, deleted)
+
+	next, claimed, _, err = s.XAutoClaim("s", "g", "bob", 0, next, 10, true)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamID{}, next)
+	assert.Equal(t, []StreamID{{4, 0}, {5, 0}}, ids(claimed))
+}
+
+func TestStoreXInfoGroupsLag(t *testing.T) {
+	t.Parallel()
+	s := newGroupStream(t, 4)
+	s.XReadGroup([]string{"s"}, []XReadStart{{From: XReadNew}}, "g", "alice", 1, false)
+
+	groups, err := s.XInfoGroups("s")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), groups[0].EntriesRead)
+	assert.True(t, groups[0].LagValid)
+	assert.Equal(t, int64(3), groups[0].Lag)
+
+	s.XDel("s", []StreamID{{3, 0}})
+	groups, _ = s.XInfoGroups("s")
+	assert.False(t, groups[0].LagValid, "a deletion ahead of the group makes the lag unknown")
+
+	assert.NoError(t, s.XGroupSetID("s", "g", StreamID{}, true, InvalidEntriesRead))
+	groups, _ = s.XInfoGroups("s")
+	assert.True(t, groups[0].LagValid)
+	assert.Equal(t, int64(0), groups[0].Lag)
+}
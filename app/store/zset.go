@@ -0,0 +1,424 @@
+package store
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrNotANumber is returned when an increment would produce a NaN score.
+var ErrNotANumber = errors.New("resulting score is not a number (NaN)")
+
+// ZSet is a sorted set: a member to score map for O(1) lookups plus a
+// skiplist ordered by (score, member) for ranks and range scans.
+type ZSet struct {
+	scores *dict[float64]
+	zsl    *skiplist
+	memCounter
+}
+
+func newZSet() *ZSet {
+	return &ZSet{scores: newDict[float64](), zsl: newSkiplist()}
+}
+
+func (z *ZSet) Len() int {
+	return z.scores.len()
+}
+
+func (z *ZSet) Score(member string) (float64, bool) {
+	return z.scores.get(member)
+}
+
+// ScoredMember is a sorted set member together with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAddFlags are the ZADD conditions. At most one of NX and XX may be set,
+// and NX excludes GT and LT.
+type ZAddFlags struct {
+	NX   bool
+	XX   bool
+	GT   bool
+	LT   bool
+	Incr bool
+}
+
+// zaddOutcome reports what add did to the set.
+type zaddOutcome int
+
+const (
+	// zaddNop means the flags prevented the operation.
+	zaddNop zaddOutcome = iota
+	zaddAdded
+	zaddUpdated
+	zaddUnchanged
+)
+
+// add inserts or updates member following flags, returning the outcome and
+// the member's resulting score. With flags.Incr the score is an increment.
+func (z *ZSet) add(score float64, member string, flags ZAddFlags) (zaddOutcome, float64, error) {
+	if flags.NX && flags.XX {
+		panic("NX and XX are exclusive")
+	}
+
+	current, exists := z.scores.get(member)
+	if exists {
+		if flags.NX {
+			return zaddNop, current, nil
+		}
+		if flags.Incr {
+			score += current
+			if math.IsNaN(score) {
+				return zaddNop, 0, ErrNotANumber
+			}
+		}
+		if (flags.LT && score >= current) || (flags.GT && score <= current) {
+			return zaddNop, current, nil
+		}
+		if score == current {
+			return zaddUnchanged, current, nil
+		}
+		z.zsl.delete(current, member)
+		z.zsl.insert(score, member)
+		z.scores.set(member, score)
+		return zaddUpdated, score, nil
+	}
+
+	if flags.XX {
+		return zaddNop, 0, nil
+	}
+	z.zsl.insert(score, member)
+	z.scores.set(member, score)
+	z.grow(zsetMemberOverhead + len(member))
+	return zaddAdded, score, nil
+}
+
+// remove deletes member and reports whether it was present.
+func (z *ZSet) remove(member string) bool {
+	score, ok := z.scores.get(member)
+	if !ok {
+		return false
+	}
+	z.scores.delete(member)
+	if !z.zsl.delete(score, member) {
+		panic("skiplist out of sync with score map")
+	}
+	z.grow(-zsetMemberOverhead - len(member))
+	return true
+}
+
+// ZRangeBy selects how ZRange interprets its bounds.
+type ZRangeBy int
+
+const (
+	ZRangeByRank ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ZRangeSpec describes a ZRANGE query. Start and Stop are used with
+// ZRangeByRank and may be negative to count from the end. Offset and Count
+// implement LIMIT for score and lex ranges; a negative Count means no limit.
+type ZRangeSpec struct {
+	By     ZRangeBy
+	Rev    bool
+	Start  int
+	Stop   int
+	Score  ScoreRange
+	Lex    LexRange
+	Offset int
+	Count  int
+}
+
+// rangeOf returns the members selected by spec in reply order.
+func (z *ZSet) rangeOf(spec ZRangeSpec) []ScoredMember {
+	switch spec.By {
+	case ZRangeByRank:
+		return z.rangeByRank(spec.Start, spec.Stop, spec.Rev)
+	case ZRangeByScore:
+		var x *skiplistNode
+		if spec.Rev {
+			x = z.zsl.lastInScoreRange(spec.Score)
+		} else {
+			x = z.zsl.firstInScoreRange(spec.Score)
+		}
+		return collectRange(x, spec, func(n *skiplistNode) bool { return spec.Score.Contains(n.score) })
+	case ZRangeByLex:
+		var x *skiplistNode
+		if spec.Rev {
+			x = z.zsl.lastInLexRange(spec.Lex)
+		} else {
+			x = z.zsl.firstInLexRange(spec.Lex)
+		}
+		return collectRange(x, spec, func(n *skiplistNode) bool { return spec.Lex.Contains(n.member) })
+	default:
+		panic("unknown zrange type")
+	}
+}
+
+// rangeByRank returns the members between the 0-based start and stop
+// indexes, inclusive. Negative indexes count from the end.
+func (z *ZSet) rangeByRank(start int, stop int, rev bool) []ScoredMember {
+	length := z.zsl.length
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= length {
+		return []ScoredMember{}
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+
+	out := make([]ScoredMember, 0, stop-start+1)
+	var x *skiplistNode
+	if rev {
+		x = z.zsl.byRank(length - start)
+	} else {
+		x = z.zsl.byRank(start + 1)
+	}
+	for range stop - start + 1 {
+		out = append(out, ScoredMember{Member: x.member, Score: x.score})
+		if rev {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return out
+}
+
+// collectRange walks from x in the direction given by spec while within holds,
+// applying the LIMIT offset and count.
+func collectRange(x *skiplistNode, spec ZRangeSpec, within func(n *skiplistNode) bool) []ScoredMember {
+	out := []ScoredMember{}
+	if spec.Offset < 0 {
+		return out
+	}
+
+	next := func(n *skiplistNode) *skiplistNode {
+		if spec.Rev {
+			return n.backward
+		}
+		return n.level[0].forward
+	}
+	for offset := spec.Offset; x != nil && offset > 0; offset-- {
+		x = next(x)
+	}
+	for x != nil && spec.Count != 0 && within(x) {
+		out = append(out, ScoredMember{Member: x.member, Score: x.score})
+		if spec.Count > 0 && len(out) == spec.Count {
+			break
+		}
+		x = next(x)
+	}
+	return out
+}
+
+// count returns the number of members with a score inside r.
+func (z *ZSet) count(r ScoreRange) int {
+	first := z.zsl.firstInScoreRange(r)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInScoreRange(r)
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+// zsetAt returns the sorted set stored at key. When create is true a missing
+// key is initialised with an empty sorted set. The caller must hold s.mu.
+func (s *Store) zsetAt(key string, create bool) (*ZSet, error) {
+	entry, ok := s.lookup(key)
+	if !ok {
+		if !create {
+			return nil, nil
+		}
+		zset := newZSet()
+		s.setKey(key, Entry{Kind: KindZSet, ZSet: zset})
+		return zset, nil
+	}
+
+	if entry.Kind != KindZSet {
+		return nil, ErrWrongType
+	}
+	return entry.ZSet, nil
+}
+
+// deleteIfEmptyZSet removes key when it holds a sorted set without members. The caller must hold s.mu.
+func (s *Store) deleteIfEmptyZSet(key string, zset *ZSet) {
+	if zset.Len() == 0 {
+		s.deleteKey(key)
+	}
+}
+
+// ZAdd adds or updates members and returns how many were added, plus how many
+// were updated when countChanged is set (ZADD CH).
+func (s *Store) ZAdd(key string, flags ZAddFlags, countChanged bool, members []ScoredMember) (int, error) {
+	if flags.Incr {
+		panic("use ZAddIncr for INCR")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, !flags.XX)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, m := range members {
+		outcome, _, err := zset.add(m.Score, m.Member, flags)
+		if err != nil {
+			return 0, err
+		}
+		if outcome == zaddAdded || (countChanged && outcome == zaddUpdated) {
+			changed++
+		}
+	}
+	s.deleteIfEmptyZSet(key, zset)
+	if zset.Len() > 0 {
+		s.signal(key)
+	}
+	return changed, nil
+}
+
+// ZAddIncr increments member's score by increment following flags and returns
+// the new score. ok is false when the flags prevented the update.
+func (s *Store) ZAddIncr(key string, flags ZAddFlags, member string, increment float64) (float64, bool, error) {
+	flags.Incr = true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, !flags.XX)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+
+	outcome, score, err := zset.add(increment, member, flags)
+	s.deleteIfEmptyZSet(key, zset)
+	if err != nil {
+		return 0, false, err
+	}
+	if zset.Len() > 0 {
+		s.signal(key)
+	}
+	return score, outcome != zaddNop, nil
+}
+
+func (s *Store) ZRem(key string, members []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if zset.remove(member) {
+			removed++
+		}
+	}
+	s.deleteIfEmptyZSet(key, zset)
+	return removed, nil
+}
+
+func (s *Store) ZScore(key string, member string) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	score, ok := zset.Score(member)
+	return score, ok, nil
+}
+
+// ZMScore returns the score of each member and whether it exists.
+func (s *Store) ZMScore(key string, members []string) ([]float64, []bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scores := make([]float64, len(members))
+	found := make([]bool, len(members))
+	if zset == nil {
+		return scores, found, nil
+	}
+	for i, member := range members {
+		scores[i], found[i] = zset.Score(member)
+	}
+	return scores, found, nil
+}
+
+func (s *Store) ZCard(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+func (s *Store) ZCount(key string, r ScoreRange) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.count(r), nil
+}
+
+// ZRank returns the 0-based rank of member, counted from the highest score when rev is set.
+func (s *Store) ZRank(key string, member string, rev bool) (int, float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, 0, false, err
+	}
+
+	score, ok := zset.Score(member)
+	if !ok {
+		return 0, 0, false, nil
+	}
+	rank := zset.zsl.rank(score, member)
+	if rank == 0 {
+		panic("skiplist out of sync with score map")
+	}
+	if rev {
+		return zset.Len() - rank, score, true, nil
+	}
+	return rank - 1, score, true, nil
+}
+
+func (s *Store) ZRange(key string, spec ZRangeSpec) ([]ScoredMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return []ScoredMember{}, err
+	}
+	return zset.rangeOf(spec), nil
+}
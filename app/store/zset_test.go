@@ -0,0 +1,182 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func members(scored []ScoredMember) []string {
+	out := make([]string, len(scored))
+	for i, m := range scored {
+		out[i] = m.Member
+	}
+	return out
+}
+
+func TestSkiplistMatchesSortedModel(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewPCG(1, 2))
+	zset := newZSet()
+	model := map[string]float64{}
+	for range 2000 {
+		member := fmt.Sprint(rng.IntN(200))
+		if rng.IntN(3) == 0 {
+			zset.remove(member)
+			delete(model, member)
+			continue
+		}
+		score := float64(rng.IntN(50))
+		zset.add(score, member, ZAddFlags{})
+		model[member] = score
+	}
+
+	want := make([]ScoredMember, 0, len(model))
+	for member, score := range model {
+		want = append(want, ScoredMember{Member: member, Score: score})
+	}
+	slices.SortFunc(want, func(a, b ScoredMember) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.Member, b.Member))
+	})
+
+	assert.Equal(t, want, zset.rangeByRank(0, -1, false))
+	for i, m := range want {
+		assert.Equal(t, i+1, zset.zsl.rank(m.Score, m.Member))
+		assert.Equal(t, m.Member, zset.zsl.byRank(i+1).member)
+	}
+}
+
+func TestStoreZAdd(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name         string
+		flags        ZAddFlags
+		countChanged bool
+		wantChanged  int
+		wantScore    float64
+	}{
+		{"plain updates", ZAddFlags{}, false, 1, 5},
+		{"ch counts updates", ZAddFlags{}, true, 2, 5},
+		{"nx keeps existing", ZAddFlags{NX: true}, true, 1, 2},
+		{"xx skips new", ZAddFlags{XX: true}, true, 1, 5},
+		{"gt rejects lower", ZAddFlags{GT: true}, false, 1, 5},
+		{"lt rejects higher", ZAddFlags{LT: true}, true, 1, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{Member: "a", Score: 2}})
+			changed, err := s.ZAdd("z", tc.flags, tc.countChanged, []ScoredMember{{Member: "a", Score: 5}, {Member: "b", Score: 1}})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantChanged, changed)
+			score, ok, err := s.ZScore("z", "a")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, tc.wantScore, score)
+		})
+	}
+}
+
+func TestStoreZAddIncr(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	score, ok, err := s.ZAddIncr("z", ZAddFlags{}, "a", 1.5)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, score)
+
+	_, ok, err = s.ZAddIncr("z", ZAddFlags{NX: true}, "a", 1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{Member: "inf", Score: math.Inf(1)}})
+	_, _, err = s.ZAddIncr("z", ZAddFlags{}, "inf", math.Inf(-1))
+	assert.ErrorIs(t, err, ErrNotANumber)
+}
+
+func TestStoreZRange(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		spec ZRangeSpec
+		want []string
+	}{
+		{"rank all", ZRangeSpec{Start: 0, Stop: -1, Count: -1}, []string{"a", "b", "c", "d"}},
+		{"rank rev", ZRangeSpec{Start: 0, Stop: 1, Rev: true, Count: -1}, []string{"d", "c"}},
+		{"rank out of range", ZRangeSpec{Start: 5, Stop: 10, Count: -1}, []string{}},
+		{"score inclusive", ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 2, Max: 3}, Count: -1}, []string{"b", "c"}},
+		{"score exclusive", ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 1, Max: 3, MinExclusive: true, MaxExclusive: true}, Count: -1}, []string{"b"}},
+		{"score infinite rev", ZRangeSpec{By: ZRangeByScore, Rev: true, Score: ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, Count: -1}, []string{"d", "c", "b", "a"}},
+		{"score limit", ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, Offset: 1, Count: 2}, []string{"b", "c"}},
+		{"score empty", ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 3, Max: 2}, Count: -1}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}})
+			got, err := s.ZRange("z", tc.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, members(got))
+		})
+	}
+}
+
+func TestStoreZRangeByLex(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		r    LexRange
+		rev  bool
+		want []string
+	}{
+		{"all", LexRange{Min: LexBound{Infinity: -1}, Max: LexBound{Infinity: 1}}, false, []string{"a", "b", "c"}},
+		{"inclusive", LexRange{Min: LexBound{Value: "b"}, Max: LexBound{Infinity: 1}}, false, []string{"b", "c"}},
+		{"exclusive rev", LexRange{Min: LexBound{Infinity: -1}, Max: LexBound{Value: "c", Exclusive: true}}, true, []string{"b", "a"}},
+		{"plus as min", LexRange{Min: LexBound{Infinity: 1}, Max: LexBound{Infinity: 1}}, false, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{"a", 0}, {"b", 0}, {"c", 0}})
+			got, err := s.ZRange("z", ZRangeSpec{By: ZRangeByLex, Lex: tc.r, Rev: tc.rev, Count: -1})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, members(got))
+		})
+	}
+}
+
+func TestStoreZRankAndCount(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{"a", 1}, {"b", 2}, {"c", 3}})
+
+	rank, score, ok, err := s.ZRank("z", "b", false)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, rank)
+	assert.Equal(t, 2.0, score)
+
+	rank, _, _, _ = s.ZRank("z", "a", true)
+	assert.Equal(t, 2, rank)
+
+	_, _, ok, _ = s.ZRank("z", "missing", false)
+	assert.False(t, ok)
+
+	count, err := s.ZCount("z", ScoreRange{Min: 1, Max: 3, MinExclusive: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	removed, err := s.ZRem("z", []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	_, exists := s.Type("z")
+	assert.False(t, exists)
+}
@@ -0,0 +1,267 @@
+package store
+
+import "math"
+
+// ZAggregate selects how ZUNION and ZINTER combine the scores of a member
+// present in several inputs.
+type ZAggregate int
+
+const (
+	ZAggregateSum ZAggregate = iota
+	ZAggregateMin
+	ZAggregateMax
+)
+
+// ZCombineSpec describes a ZUNION, ZINTER or ZDIFF over Keys. Weights, when
+// not nil, holds one multiplier per key; ZDIFF ignores Weights and Aggregate.
+// Plain sets are accepted as inputs with every member scored 1.
+type ZCombineSpec struct {
+	Op        SetOp
+	Keys      []string
+	Weights   []float64
+	Aggregate ZAggregate
+}
+
+// ZCombine returns the result of spec ordered by score.
+func (s *Store) ZCombine(spec ZCombineSpec) ([]ScoredMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.combineZSets(spec)
+	if err != nil {
+		return nil, err
+	}
+	return result.rangeByRank(0, -1, false), nil
+}
+
+// ZCombineStore stores the result of spec at destination and returns its
+// cardinality. An empty result deletes destination.
+func (s *Store) ZCombineStore(destination string, spec ZCombineSpec) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.combineZSets(spec)
+	if err != nil {
+		return 0, err
+	}
+	s.storeZSet(destination, result)
+	return result.Len(), nil
+}
+
+// storeZSet replaces destination with zset, deleting it when zset is empty. The caller must hold s.mu.
+func (s *Store) storeZSet(destination string, zset *ZSet) {
+	if zset.Len() == 0 {
+		s.deleteKey(destination)
+		return
+	}
+	s.setKey(destination, Entry{Kind: KindZSet, ZSet: zset})
+	s.signal(destination)
+}
+
+// scoresAt returns the member to score map of the set or sorted set at key,
+// or nil when key is missing. The caller must hold s.mu.
+func (s *Store) scoresAt(key string) (map[string]float64, error) {
+	entry, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+
+	switch entry.Kind {
+	case KindZSet:
+		scores := make(map[string]float64, entry.ZSet.Len())
+		for member, score := range entry.ZSet.scores.all() {
+			scores[member] = score
+		}
+		return scores, nil
+	case KindSet:
+		scores := make(map[string]float64, entry.Set.Len())
+		for _, member := range entry.Set.Members() {
+			scores[member] = 1
+		}
+		return scores, nil
+	default:
+		return nil, ErrWrongType
+	}
+}
+
+// combineZSets computes spec into a new sorted set. The caller must hold s.mu.
+func (s *Store) combineZSets(spec ZCombineSpec) (*ZSet, error) {
+	if len(spec.Keys) == 0 {
+		panic("sorted set algebra requires at least one key")
+	}
+	if spec.Weights != nil && len(spec.Weights) != len(spec.Keys) {
+		panic("weights must match keys")
+	}
+
+	inputs := make([]map[string]float64, len(spec.Keys))
+	for i, key := range spec.Keys {
+		scores, err := s.scoresAt(key)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = scores
+	}
+
+	weight := func(i int) float64 {
+		if spec.Weights == nil {
+			return 1
+		}
+		return spec.Weights[i]
+	}
+
+	result := newZSet()
+	switch spec.Op {
+	case SetOpUnion:
+		combined := make(map[string]float64)
+		for i, input := range inputs {
+			for member, score := range input {
+				score = weightedScore(score, weight(i))
+				if current, ok := combined[member]; ok {
+					score = aggregateScores(current, score, spec.Aggregate)
+				}
+				combined[member] = score
+			}
+		}
+		for member, score := range combined {
+			result.add(score, member, ZAddFlags{})
+		}
+	case SetOpInter:
+		smallest := 0
+		for i, input := range inputs {
+			if len(input) < len(inputs[smallest]) {
+				smallest = i
+			}
+		}
+	members:
+		for member := range inputs[smallest] {
+			var score float64
+			for i, input := range inputs {
+				other, ok := input[member]
+				if !ok {
+					continue members
+				}
+				other = weightedScore(other, weight(i))
+				if i == 0 {
+					score = other
+				} else {
+					score = aggregateScores(score, other, spec.Aggregate)
+				}
+			}
+			result.add(score, member, ZAddFlags{})
+		}
+	case SetOpDiff:
+	diff:
+		for member, score := range inputs[0] {
+			for _, other := range inputs[1:] {
+				if _, ok := other[member]; ok {
+					continue diff
+				}
+			}
+			result.add(score, member, ZAddFlags{})
+		}
+	default:
+		panic("unknown set operation")
+	}
+	return result, nil
+}
+
+// weightedScore multiplies score by weight, mapping the NaN of inf*0 to 0 as Redis does.
+func weightedScore(score float64, weight float64) float64 {
+	weighted := score * weight
+	if math.IsNaN(weighted) {
+		return 0
+	}
+	return weighted
+}
+
+func aggregateScores(a float64, b float64, aggregate ZAggregate) float64 {
+	switch aggregate {
+	case ZAggregateSum:
+		sum := a + b
+		if math.IsNaN(sum) {
+			return 0
+		}
+		return sum
+	case ZAggregateMin:
+		return math.Min(a, b)
+	case ZAggregateMax:
+		return math.Max(a, b)
+	default:
+		panic("unknown aggregate")
+	}
+}
+
+// ZPop removes up to count members with the lowest scores, or the highest
+// when highest is set, from the first non-empty sorted set among keys and
+// returns the key they were popped from. An empty key means every sorted set
+// was empty.
+func (s *Store) ZPop(keys []string, highest bool, count int) (string, []ScoredMember, error) {
+	if count < 0 {
+		panic("count must be non-negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		zset, err := s.zsetAt(key, false)
+		if err != nil {
+			return "", nil, err
+		}
+		if zset == nil {
+			continue
+		}
+
+		popped := make([]ScoredMember, 0, min(count, zset.Len()))
+		for len(popped) < count && zset.Len() > 0 {
+			x := zset.zsl.first()
+			if highest {
+				x = zset.zsl.tail
+			}
+			popped = append(popped, ScoredMember{Member: x.member, Score: x.score})
+			zset.remove(x.member)
+		}
+		s.deleteIfEmptyZSet(key, zset)
+		return key, popped, nil
+	}
+	return "", nil, nil
+}
+
+// ZRangeStore stores the members of source selected by spec at destination
+// and returns how many were stored. An empty result deletes destination.
+func (s *Store) ZRangeStore(destination string, source string, spec ZRangeSpec) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(source, false)
+	if err != nil {
+		return 0, err
+	}
+
+	result := newZSet()
+	if zset != nil {
+		for _, m := range zset.rangeOf(spec) {
+			result.add(m.Score, m.Member, ZAddFlags{})
+		}
+	}
+	s.storeZSet(destination, result)
+	return result.Len(), nil
+}
+
+// ZRemRange removes the members selected by spec and returns how many were removed.
+func (s *Store) ZRemRange(key string, spec ZRangeSpec) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.zsetAt(key, false)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	removed := zset.rangeOf(spec)
+	for _, m := range removed {
+		zset.remove(m.Member)
+	}
+	s.deleteIfEmptyZSet(key, zset)
+	return len(removed), nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreZCombine(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		spec ZCombineSpec
+		want []ScoredMember
+	}{
+		{
+			name: "union sum",
+			spec: ZCombineSpec{Op: SetOpUnion, Keys: []string{"a", "b"}},
+			want: []ScoredMember{{"x", 1}, {"z", 3}, {"y", 6}},
+		},
+		{
+			name: "union weighted max",
+			spec: ZCombineSpec{Op: SetOpUnion, Keys: []string{"a", "b"}, Weights: []float64{10, 1}, Aggregate: ZAggregateMax},
+			want: []ScoredMember{{"z", 3}, {"x", 10}, {"y", 20}},
+		},
+		{
+			name: "inter min with plain set",
+			spec: ZCombineSpec{Op: SetOpInter, Keys: []string{"a", "b", "tags"}, Aggregate: ZAggregateMin},
+			want: []ScoredMember{{"y", 1}},
+		},
+		{
+			name: "inter missing key",
+			spec: ZCombineSpec{Op: SetOpInter, Keys: []string{"a", "missing"}},
+			want: []ScoredMember{},
+		},
+		{
+			name: "diff",
+			spec: ZCombineSpec{Op: SetOpDiff, Keys: []string{"a", "b"}},
+			want: []ScoredMember{{"x", 1}},
+		},
+		{
+			name: "inf times zero weight is zero",
+			spec: ZCombineSpec{Op: SetOpUnion, Keys: []string{"inf"}, Weights: []float64{0}},
+			want: []ScoredMember{{"i", 0}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewStore()
+			s.ZAdd("a", ZAddFlags{}, false, []ScoredMember{{"x", 1}, {"y", 2}})
+			s.ZAdd("b", ZAddFlags{}, false, []ScoredMember{{"y", 4}, {"z", 3}})
+			s.ZAdd("inf", ZAddFlags{}, false, []ScoredMember{{"i", math.Inf(1)}})
+			s.SAdd("tags", []string{"y"})
+
+			got, err := s.ZCombine(tc.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			count, err := s.ZCombineStore("dst", tc.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.want), count)
+		})
+	}
+}
+
+func TestStoreZPop(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.ZAdd("b", ZAddFlags{}, false, []ScoredMember{{"x", 1}, {"y", 2}, {"z", 3}})
+
+	key, popped, err := s.ZPop([]string{"missing", "b"}, true, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", key)
+	assert.Equal(t, []ScoredMember{{"z", 3}, {"y", 2}}, popped)
+
+	key, popped, err = s.ZPop([]string{"b"}, false, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", key)
+	assert.Equal(t, []ScoredMember{{"x", 1}}, popped)
+
+	key, _, err = s.ZPop([]string{"b"}, false, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "", key)
+}
+
+func TestStoreZRangeStoreAndRemRange(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	s.ZAdd("src", ZAddFlags{}, false, []ScoredMember{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}})
+
+	count, err := s.ZRangeStore("dst", "src", ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 2, Max: math.Inf(1)}, Count: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	got, _ := s.ZRange("dst", ZRangeSpec{Start: 0, Stop: -1})
+	assert.Equal(t, []string{"b", "c"}, members(got))
+
+	removed, err := s.ZRemRange("src", ZRangeSpec{Start: 0, Stop: 1, Count: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	removed, err = s.ZRemRange("src", ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 3, Max: 3, MinExclusive: true}, Count: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	card, _ := s.ZCard("src")
+	assert.Equal(t, 2, card)
+}
+
+func TestStoreWatchWakesOnZAdd(t *testing.T) {
+	t.Parallel()
+	s := NewStore()
+	wake, cancel := s.Watch([]string{"z"})
+	defer cancel()
+
+	s.ZAdd("z", ZAddFlags{}, false, []ScoredMember{{"a", 1}})
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("watcher was not woken")
+	}
+}